@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
 	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -14,14 +17,204 @@ func CreateAbsence(db *pgxpool.Pool) http.Handler {
 		ctx, span := tracer.Start(reqCtx, "create absence")
 		defer span.End()
 
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
 		absence := reqCtx.Value("absence").(models.Absence)
 
-		err := utils.HandleTx(ctx, db, absence.SaveToDB)
+		err := utils.HandleTx(ctx, db, absence.SaveToDBWithSchoolId(claims.SchoolId))
 		if err != nil {
-			utils.UnexpectedError(w, err, ctx)
+			handleCreateError(w, err, ctx)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		writeCreated(w, fmt.Sprintf("/absence/%d", absence.Id()), absence, ctx)
 	})
 }
+
+func ListAbsences(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list absences")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("absence filter").(models.AbsenceFilter)
+
+			absences, err := models.ListAbsences(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, absences, ctx)
+		},
+	)
+}
+
+func GetAbsenceTotals(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get absence totals")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("absence totals query").(models.AbsenceTotalsQuery)
+
+			totals, err := query.Totals(ctx, db, claims.SchoolId)
+			if err != nil {
+				handleReadError(w, err, "Term not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, totals, ctx)
+		},
+	)
+}
+
+func GetAbsence(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get absence")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence id").HandleError(w, ctx)
+				return
+			}
+
+			absence, err := models.GetAbsence(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Absence not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, absence, ctx)
+		},
+	)
+}
+
+func UpdateAbsence(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update absence")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("absence update").(models.AbsenceUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Absence not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteAbsence(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete absence")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteAbsence(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Absence not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func GetSubstitutionSuggestions(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get substitution suggestions")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence id").HandleError(w, ctx)
+				return
+			}
+
+			suggestions, err := models.SuggestSubstitutions(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Absence not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, struct {
+				Suggestions []models.SubstitutionSuggestion `json:"suggestions"`
+			}{
+				Suggestions: suggestions,
+			}, ctx)
+		},
+	)
+}
+
+func AcceptSubstitutions(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "accept substitutions")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			acceptance := reqCtx.Value("substitution acceptance").(models.SubstitutionAcceptance)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence id").HandleError(w, ctx)
+				return
+			}
+
+			created := []models.SubstituteTimetable{}
+			if err := utils.HandleTx(ctx, db, acceptance.Accept(claims.SchoolId, id, &created)); err != nil {
+				switch {
+				case errors.Is(err, pgx.ErrNoRows):
+					utils.HandleError(w, err, http.StatusNotFound, "Absence not found", ctx)
+				case errors.Is(err, models.ErrLessonNotAffected), errors.Is(err, models.ErrForeignReference):
+					utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+				default:
+					handleCreateError(w, err, ctx)
+				}
+				return
+			}
+
+			from, to := created[0].Date(), created[0].Date()
+			for _, t := range created {
+				from, to = min(from, t.Date()), max(to, t.Date())
+			}
+			location := fmt.Sprintf("/substitute_timetable?from=%s&to=%s", from, to)
+
+			writeCreated(w, location, struct {
+				Substitutions []models.SubstituteTimetable `json:"substitutions"`
+			}{
+				Substitutions: created,
+			}, ctx)
+		},
+	)
+}
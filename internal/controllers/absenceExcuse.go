@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxExcuseSize limits the whole excuse form together with its attachment
+const MaxExcuseSize = 5 << 20
+
+// SubmitAbsenceExcuse takes the excuse as a form, multipart forms can attach a file (attachment)
+func SubmitAbsenceExcuse(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "submit absence excuse")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			excuse := reqCtx.Value("absence excuse").(models.AbsenceExcuse)
+			absenceId, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence id").HandleError(w, ctx)
+				return
+			}
+
+			if r.MultipartForm != nil {
+				file, header, err := r.FormFile("attachment")
+				if err != nil && !errors.Is(err, http.ErrMissingFile) {
+					utils.HandleError(w, err, http.StatusBadRequest, "Invalid attachment", ctx)
+					return
+				} else if err == nil {
+					defer file.Close()
+					data, err := io.ReadAll(file)
+					if err != nil {
+						utils.UnexpectedError(w, err, ctx)
+						return
+					}
+					contentType := header.Header.Get("Content-Type")
+					if contentType == "" {
+						contentType = http.DetectContentType(data)
+					}
+					excuse.Attach(header.Filename, contentType, data)
+				}
+			}
+
+			err = utils.HandleTx(ctx, db, excuse.SubmitForChild(claims.Id, absenceId))
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				utils.HandleError(w, err, http.StatusNotFound, "Absence not found", ctx)
+				return
+			case errors.Is(err, models.ErrAbsenceAlreadyExcused):
+				utils.HandleError(w, err, http.StatusConflict, "", ctx)
+				return
+			case err != nil:
+				handleCreateError(w, err, ctx)
+				return
+			}
+
+			writeCreated(w, fmt.Sprintf("/absence_excuse/%d", excuse.Id()), excuse, ctx)
+		},
+	)
+}
+
+func ListAbsenceExcuses(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list absence excuses")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("absence excuse filter").(models.AbsenceExcuseFilter)
+
+			excuses, err := models.ListAbsenceExcuses(ctx, db, claims.SchoolId, claims.Id, claims.Role, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, excuses, ctx)
+		},
+	)
+}
+
+func GetAbsenceExcuse(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get absence excuse")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence excuse id").HandleError(w, ctx)
+				return
+			}
+
+			excuse, err := models.GetAbsenceExcuse(ctx, db, claims.SchoolId, claims.Id, claims.Role, id)
+			if err != nil {
+				handleReadError(w, err, "Absence excuse not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, excuse, ctx)
+		},
+	)
+}
+
+func GetAbsenceExcuseAttachment(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get absence excuse attachment")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence excuse id").HandleError(w, ctx)
+				return
+			}
+
+			excuse, err := models.GetAbsenceExcuseAttachment(ctx, db, claims.SchoolId, claims.Id, claims.Role, id)
+			if err != nil {
+				handleReadError(w, err, "Attachment not found", ctx)
+				return
+			}
+
+			name, contentType, data := excuse.Attachment()
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+			w.Write(data)
+		},
+	)
+}
+
+func ReviewAbsenceExcuse(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "review absence excuse")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			review := reqCtx.Value("excuse review").(models.ExcuseReview)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid absence excuse id").HandleError(w, ctx)
+				return
+			}
+
+			err = utils.HandleTx(ctx, db, review.ReviewInDB(claims.SchoolId, claims.Id, claims.Role, id))
+			switch {
+			case errors.Is(err, models.ErrNotClassTeacher):
+				utils.HandleError(w, err, http.StatusForbidden, "", ctx)
+			case errors.Is(err, models.ErrExcuseAlreadyReviewed):
+				utils.HandleError(w, err, http.StatusConflict, "", ctx)
+			case err != nil:
+				handleUpdateError(w, err, "Absence excuse not found", ctx)
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		},
+	)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func CreateAcademicYear(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "academic year creation")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			year := reqCtx.Value("academic year").(models.AcademicYear)
+
+			if err := utils.HandleTx(ctx, db, year.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
+				return
+			}
+
+			writeCreated(w, fmt.Sprintf("/academic_year/%d", year.Id()), year, ctx)
+		},
+	)
+}
+
+func ListAcademicYears(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list academic years")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+
+			years, err := models.ListAcademicYears(ctx, db, claims.SchoolId, query)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, years, ctx)
+		},
+	)
+}
+
+func GetAcademicYear(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get academic year")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid academic year id").HandleError(w, ctx)
+				return
+			}
+
+			year, err := models.GetAcademicYear(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Academic year not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, year, ctx)
+		},
+	)
+}
+
+func UpdateAcademicYear(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update academic year")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("academic year update").(models.AcademicYearUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid academic year id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Academic year not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteAcademicYear(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete academic year")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid academic year id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteAcademicYear(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Academic year not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func CreateCalendarToken(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "create calendar token")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			var token string
+			if err := utils.HandleTx(ctx, db, models.ResetCalendarToken(claims.Id, &token)); err != nil {
+				handleUpdateError(w, err, "User not found", ctx)
+				return
+			}
+
+			location := "/calendar/" + token + ".ics"
+			writeCreated(w, location, struct {
+				Url string `json:"url"`
+			}{
+				Url: location,
+			}, ctx)
+		},
+	)
+}
+
+// GetCalendar serves the feed under /calendar/{token}.ics, calendar apps can't send
+// cookies, so the token in the url is what authorizes the request
+func GetCalendar(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get calendar")
+			defer span.End()
+
+			token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+			if !ok || token == "" {
+				http.NotFound(w, r)
+				return
+			}
+
+			calendar, err := models.GetCalendar(ctx, db, token, time.Now())
+			if err != nil {
+				handleReadError(w, err, "Calendar not found", ctx)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+			if err := utils.WriteICalendar(w, calendar.Name, time.Now(), calendar.Events); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+			}
+		},
+	)
+}
@@ -0,0 +1,360 @@
+package controllers
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// linkedChild resolves the child in the path, the response is written when it isn't a child of the user
+func linkedChild(w http.ResponseWriter, r *http.Request, db *pgxpool.Pool, ctx context.Context) (models.Child, bool) {
+	span := trace.SpanFromContext(ctx)
+	claims := r.Context().Value("claims").(*utils.UserClaims)
+	childId, err := utils.ParseUuid(span, "id", r.PathValue("id"))
+	if err != nil {
+		utils.NewParserError(err, "Invalid child id").HandleError(w, ctx)
+		return models.Child{}, false
+	}
+
+	child, err := models.LinkedChild(ctx, db, claims.SchoolId, claims.Id, childId)
+	if err != nil {
+		handleReadError(w, err, "Child not found", ctx)
+		return models.Child{}, false
+	}
+	return child, true
+}
+
+func ListChildren(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list children")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			children, err := models.LinkedChildren(ctx, db, claims.SchoolId, claims.Id)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+			if children == nil {
+				children = []models.Child{}
+			}
+
+			utils.WriteJSON(w, http.StatusOK, map[string]any{"children": children}, ctx)
+		},
+	)
+}
+
+func GetChildTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get child timetable")
+			defer span.End()
+
+			day, err := weekOf(r)
+			if err != nil {
+				utils.NewParserError(err, "Invalid week (expected date in format YYYY-MM-DD)").HandleError(w, ctx)
+				return
+			}
+			child, ok := linkedChild(w, r, db, ctx)
+			if !ok {
+				return
+			}
+
+			timetable, err := child.WeekTimetable(ctx, db, day)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, timetable, ctx)
+		},
+	)
+}
+
+func GetChildGradebook(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get child gradebook")
+			defer span.End()
+
+			query := reqCtx.Value("child query").(models.ChildQuery)
+			child, ok := linkedChild(w, r, db, ctx)
+			if !ok {
+				return
+			}
+
+			gradebook, err := child.Gradebook(ctx, db, query)
+			if err != nil {
+				handleReadError(w, err, "Term not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, gradebook, ctx)
+		},
+	)
+}
+
+func ListChildNotes(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list child notes")
+			defer span.End()
+
+			query := reqCtx.Value("child query").(models.ChildQuery)
+			child, ok := linkedChild(w, r, db, ctx)
+			if !ok {
+				return
+			}
+
+			notes, err := child.Notes(ctx, db, query)
+			if err != nil {
+				handleReadError(w, err, "Term not found", ctx)
+				return
+			}
+			if notes == nil {
+				notes = []models.ChildNote{}
+			}
+
+			utils.WriteJSON(w, http.StatusOK, map[string]any{"notes": notes}, ctx)
+		},
+	)
+}
+
+func ListChildAbsences(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list child absences")
+			defer span.End()
+
+			query := reqCtx.Value("child query").(models.ChildQuery)
+			child, ok := linkedChild(w, r, db, ctx)
+			if !ok {
+				return
+			}
+
+			absences, err := child.Absences(ctx, db, query)
+			if err != nil {
+				handleReadError(w, err, "Term not found", ctx)
+				return
+			}
+			if absences == nil {
+				absences = []models.Absence{}
+			}
+
+			utils.WriteJSON(w, http.StatusOK, map[string]any{"absences": absences}, ctx)
+		},
+	)
+}
+
+func ListChildReports(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list child reports")
+			defer span.End()
+
+			query := reqCtx.Value("child query").(models.ChildQuery)
+			child, ok := linkedChild(w, r, db, ctx)
+			if !ok {
+				return
+			}
+
+			reports, err := child.Reports(ctx, db, query)
+			if err != nil {
+				handleReadError(w, err, "Term not found", ctx)
+				return
+			}
+			if reports == nil {
+				reports = []models.ChildReport{}
+			}
+
+			utils.WriteJSON(w, http.StatusOK, map[string]any{"reports": reports}, ctx)
+		},
+	)
+}
+
+var (
+	noteTypeNames = map[string]string{"homework": "domácí úkol", "test": "test"}
+
+	attendanceNames = map[string]string{
+		models.AttendancePresent: "přítomen(a)",
+		models.AttendanceAbsent:  "nepřítomen(a)",
+		models.AttendanceLate:    "pozdní příchod",
+		models.AttendanceExcused: "omluven(a)",
+	}
+
+	absenceStatusNames = map[string]string{
+		models.AbsenceUnexcused: "neomluvená",
+		models.AbsencePending:   "čeká na schválení",
+		models.AbsenceExcused:   "omluvená",
+	}
+)
+
+type parentPortalChild struct {
+	Id       string
+	Name     string
+	Selected bool
+}
+
+type parentPortalNote struct {
+	Date    string
+	Subject string
+	Type    string
+	Content string
+}
+
+type parentPortalAbsence struct {
+	Start  string
+	End    string
+	Status string
+}
+
+type parentPortalReport struct {
+	Date       string
+	Subject    string
+	Topic      string
+	Attendance string
+}
+
+// GetParentPortal renders the week of one of the parent's children (the one in the child
+// query parameter or the first one): the timetable with homework, tests and reports of the week
+// together with grades and absences of the current term
+func GetParentPortal(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCtx := r.Context()
+		ctx, span := tracer.Start(reqCtx, "get parent portal")
+		defer span.End()
+
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
+		day, err := weekOf(r)
+		if err != nil {
+			utils.NewParserError(err, "Invalid week (expected date in format YYYY-MM-DD)").HandleError(w, ctx)
+			return
+		}
+
+		children, err := models.LinkedChildren(ctx, db, claims.SchoolId, claims.Id)
+		if err != nil {
+			utils.UnexpectedError(w, err, ctx)
+			return
+		}
+		page := struct {
+			Name         string
+			Children     []parentPortalChild
+			ChildId      string
+			Periods      []models.WeekPeriod
+			Days         []homepageDay
+			Monday       string
+			PreviousWeek string
+			NextWeek     string
+			Notes        []parentPortalNote
+			Reports      []parentPortalReport
+			Subjects     []models.SubjectGradebook
+			Absences     []parentPortalAbsence
+		}{
+			Name: claims.Name + " " + claims.Surname,
+		}
+
+		var child *models.Child
+		for i, c := range children {
+			selected := c.Id().String() == r.URL.Query().Get("child")
+			if selected {
+				child = &children[i]
+			}
+			page.Children = append(page.Children, parentPortalChild{Id: c.Id().String(), Name: c.Name(), Selected: selected})
+		}
+		if child == nil && len(children) > 0 {
+			child = &children[0]
+			page.Children[0].Selected = true
+		}
+
+		tmpl := template.Must(template.ParseFiles(utils.WebPath("parent.html")))
+		if child == nil {
+			tmpl.Execute(w, page)
+			return
+		}
+		page.ChildId = child.Id().String()
+
+		timetable, err := child.WeekTimetable(ctx, db, day)
+		if err != nil {
+			utils.UnexpectedError(w, err, ctx)
+			return
+		}
+		monday, _ := time.Parse(time.DateOnly, timetable.Monday)
+		friday := monday.AddDate(0, 0, len(models.Weekdays)-1)
+		page.Periods = timetable.Periods
+		page.Days = homepageDays(timetable)
+		page.Monday = monday.Format("2. 1. 2006")
+		page.PreviousWeek = monday.AddDate(0, 0, -7).Format(time.DateOnly)
+		page.NextWeek = monday.AddDate(0, 0, 7).Format(time.DateOnly)
+
+		week := models.NewChildQuery(monday, friday)
+		notes, err := child.Notes(ctx, db, week)
+		if err != nil {
+			utils.UnexpectedError(w, err, ctx)
+			return
+		}
+		for _, n := range notes {
+			date, _ := time.Parse(time.DateOnly, n.Date)
+			page.Notes = append(page.Notes, parentPortalNote{
+				Date:    date.Format("2. 1."),
+				Subject: n.Subject,
+				Type:    noteTypeNames[n.Type],
+				Content: n.Content,
+			})
+		}
+		reports, err := child.Reports(ctx, db, week)
+		if err != nil {
+			utils.UnexpectedError(w, err, ctx)
+			return
+		}
+		for _, rep := range reports {
+			reportedAt, _ := time.Parse(time.RFC3339, rep.ReportedAt)
+			attendance := ""
+			if rep.Attendance != nil {
+				attendance = attendanceNames[*rep.Attendance]
+			}
+			page.Reports = append(page.Reports, parentPortalReport{
+				Date:       reportedAt.Format("2. 1."),
+				Subject:    rep.Subject,
+				Topic:      rep.TopicCovered,
+				Attendance: attendance,
+			})
+		}
+
+		//grades and absences are of the term containing today
+		gradebook, err := child.Gradebook(ctx, db, models.ChildQuery{})
+		if err != nil {
+			utils.UnexpectedError(w, err, ctx)
+			return
+		}
+		page.Subjects = gradebook.Subjects
+		absences, err := child.Absences(ctx, db, models.ChildQuery{})
+		if err != nil {
+			utils.UnexpectedError(w, err, ctx)
+			return
+		}
+		for _, a := range absences {
+			start, end := a.Span()
+			page.Absences = append(page.Absences, parentPortalAbsence{
+				Start:  start.Format("2. 1. 2006 15:04"),
+				End:    end.Format("2. 1. 2006 15:04"),
+				Status: absenceStatusNames[a.Status()],
+			})
+		}
+
+		tmpl.Execute(w, page)
+	})
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -14,13 +15,110 @@ func CreateClass(db *pgxpool.Pool) http.Handler {
 		ctx, span := tracer.Start(reqCtx, "create class")
 		defer span.End()
 
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
 		class := reqCtx.Value("class").(models.Class)
 
-		if err := utils.HandleTx(ctx, db, class.SaveToDb); err != nil {
-			utils.UnexpectedError(w, err, ctx)
+		if err := utils.HandleTx(ctx, db, class.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+			handleCreateError(w, err, ctx)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		writeCreated(w, fmt.Sprintf("/class/%d", class.Id()), class, ctx)
 	})
 }
+
+func ListClasses(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list classes")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("class filter").(models.ClassFilter)
+
+			classes, err := models.ListClasses(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, classes, ctx)
+		},
+	)
+}
+
+func GetClass(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get class")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid class id").HandleError(w, ctx)
+				return
+			}
+
+			class, err := models.GetClass(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Class not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, class, ctx)
+		},
+	)
+}
+
+func UpdateClass(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update class")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("class update").(models.ClassUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid class id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Class not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteClass(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete class")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid class id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteClass(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Class not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -1,6 +1,10 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -15,15 +19,161 @@ func CreateEventTimetable(db *pgxpool.Pool) http.Handler {
 			ctx, span := tracer.Start(reqCtx, "create eventt timetable")
 			defer span.End()
 
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
 			timetable := reqCtx.Value("event timetable").(models.EventTimetable)
 
-			if err := utils.HandleTx(ctx, db, timetable.SaveToDB); err != nil {
+			if err := utils.HandleTx(ctx, db, timetable.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
+				return
+			}
+
+			writeCreated(w, fmt.Sprintf("/event_timetable/%d", timetable.Id()), timetable, ctx)
+		},
+	)
+}
+
+const maxCalendarSize = 5 << 20
+
+// ImportEventTimetables takes the calendar either as the file field of a multipart form
+// or as the whole request body
+func ImportEventTimetables(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "import event timetables")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxCalendarSize)
+			var file io.Reader = r.Body
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+				f, _, err := r.FormFile("file")
+				if err != nil {
+					utils.HandleError(w, err, http.StatusBadRequest, "Calendar file not provided", ctx)
+					return
+				}
+				defer f.Close()
+				file = f
+			}
+
+			events, problems, err := utils.ParseICalendar(file)
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				utils.HandleError(w, err, http.StatusRequestEntityTooLarge, "Calendar file is too large", ctx)
+				return
+			case errors.Is(err, utils.ErrInvalidICalendar):
+				utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+				return
+			case err != nil:
+				utils.HandleError(w, err, http.StatusBadRequest, "Error reading calendar file", ctx)
+				return
+			}
+
+			var result models.EventImport
+			if err := utils.HandleTx(ctx, db, models.ImportEvents(claims.SchoolId, events, problems, &result)); err != nil {
 				utils.UnexpectedError(w, err, ctx)
 				return
 			}
 
-			w.WriteHeader(http.StatusCreated)
+			utils.WriteJSON(w, http.StatusOK, result, ctx)
+		},
+	)
+}
+
+func ListEventTimetables(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list event timetables")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("event timetable filter").(models.EventTimetableFilter)
+
+			eventTimetables, err := models.ListEventTimetables(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, eventTimetables, ctx)
+		},
+	)
+}
+
+func GetEventTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get event timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid event timetable id").HandleError(w, ctx)
+				return
+			}
+
+			eventTimetable, err := models.GetEventTimetable(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Event timetable not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, eventTimetable, ctx)
+		},
+	)
+}
+
+func UpdateEventTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update event timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("event timetable update").(models.EventTimetableUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid event timetable id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Event timetable not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteEventTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete event timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid event timetable id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteEventTimetable(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Event timetable not found", ctx)
+				return
+			}
 
+			w.WriteHeader(http.StatusNoContent)
 		},
 	)
 }
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func CreateFinalGrade(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "create final grade")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			grade := reqCtx.Value("final grade").(models.FinalGrade)
+
+			if err := utils.HandleTx(ctx, db, grade.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
+				return
+			}
+
+			writeCreated(w, fmt.Sprintf("/final_grade/%d", grade.Id()), grade, ctx)
+		},
+	)
+}
+
+func ListFinalGrades(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list final grades")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("final grade filter").(models.FinalGradeFilter)
+
+			grades, err := models.ListFinalGrades(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, grades, ctx)
+		},
+	)
+}
+
+func GetFinalGrade(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get final grade")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid final grade id").HandleError(w, ctx)
+				return
+			}
+
+			grade, err := models.GetFinalGrade(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Final grade not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, grade, ctx)
+		},
+	)
+}
+
+func UpdateFinalGrade(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update final grade")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("final grade update").(models.FinalGradeUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid final grade id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Final grade not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteFinalGrade(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete final grade")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid final grade id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteFinalGrade(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Final grade not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
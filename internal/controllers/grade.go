@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -15,14 +16,111 @@ func CreateGrade(db *pgxpool.Pool) http.Handler {
 			ctx, span := tracer.Start(reqCtx, "create grade")
 			defer span.End()
 
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
 			grade := reqCtx.Value("grade").(models.Grade)
 
-			if err := utils.HandleTx(ctx, db, grade.SaveToDB); err != nil {
-				utils.UnexpectedError(w, err, ctx)
+			if err := utils.HandleTx(ctx, db, grade.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
 				return
 			}
 
-			w.WriteHeader(http.StatusCreated)
+			writeCreated(w, fmt.Sprintf("/grade/%d", grade.Id()), grade, ctx)
+		},
+	)
+}
+
+func ListGrades(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list grades")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("grade filter").(models.GradeFilter)
+
+			grades, err := models.ListGrades(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, grades, ctx)
+		},
+	)
+}
+
+func GetGrade(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get grade")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid grade id").HandleError(w, ctx)
+				return
+			}
+
+			grade, err := models.GetGrade(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Grade not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, grade, ctx)
+		},
+	)
+}
+
+func UpdateGrade(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update grade")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("grade update").(models.GradeUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid grade id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Grade not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteGrade(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete grade")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid grade id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteGrade(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Grade not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
 		},
 	)
 }
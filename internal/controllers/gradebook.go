@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func GetStudentGradebook(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get student gradebook")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("gradebook query").(models.GradebookQuery)
+			studentId, err := utils.ParseUuid(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid student id").HandleError(w, ctx)
+				return
+			}
+
+			visible, err := models.StudentVisibleTo(ctx, db, claims.SchoolId, claims.Id, claims.Role, studentId)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			} else if !visible {
+				utils.HandleError(w, models.ErrStudentNotVisible, http.StatusNotFound, "Student not found", ctx)
+				return
+			}
+
+			gradebook, err := query.StudentGradebook(ctx, db, claims.SchoolId, studentId)
+			if err != nil {
+				handleReadError(w, err, "Term not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, gradebook, ctx)
+		},
+	)
+}
+
+func GetClassGradebook(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get class gradebook")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("gradebook query").(models.GradebookQuery)
+			classId, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid class id").HandleError(w, ctx)
+				return
+			}
+
+			gradebook, err := query.ClassGradebook(ctx, db, claims.SchoolId, classId)
+			if err != nil {
+				handleReadError(w, err, "Class or term not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, gradebook, ctx)
+		},
+	)
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -14,14 +15,111 @@ func CreateGroup(db *pgxpool.Pool) http.Handler {
 		ctx, span := tracer.Start(reqCtx, "create group")
 		defer span.End()
 
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
 		group := reqCtx.Value("group").(models.Group)
 
-		err := utils.HandleTx(ctx, db, group.SaveToDB)
+		err := utils.HandleTx(ctx, db, group.SaveToDBWithSchoolId(claims.SchoolId))
 		if err != nil {
-			utils.UnexpectedError(w, err, ctx)
+			handleCreateError(w, err, ctx)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		writeCreated(w, fmt.Sprintf("/group/%d", group.Id()), group, ctx)
 	})
 }
+
+func ListGroups(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list groups")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("group filter").(models.GroupFilter)
+
+			groups, err := models.ListGroups(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, groups, ctx)
+		},
+	)
+}
+
+func GetGroup(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get group")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid group id").HandleError(w, ctx)
+				return
+			}
+
+			group, err := models.GetGroup(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Group not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, group, ctx)
+		},
+	)
+}
+
+func UpdateGroup(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update group")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("group update").(models.GroupUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid group id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Group not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteGroup(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete group")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid group id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteGroup(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Group not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -3,57 +3,90 @@ package controllers
 import (
 	"html/template"
 	"net/http"
+	"time"
 
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+type homepageDay struct {
+	Name   string
+	Date   string
+	Slots  [][]models.WeekLesson
+	Events []models.WeekEvent
+}
+
+// weekOf is the day of the week query parameter, today when it isn't set
+func weekOf(r *http.Request) (time.Time, error) {
+	week := r.URL.Query().Get("week")
+	if week == "" {
+		return time.Now(), nil
+	}
+	return time.Parse(time.DateOnly, week)
+}
+
+func homepageDays(timetable models.WeekTimetable) []homepageDay {
+	days := make([]homepageDay, len(timetable.Days))
+	for i, d := range timetable.Days {
+		date, _ := time.Parse(time.DateOnly, d.Date)
+		days[i] = homepageDay{
+			Name:   models.Weekdays[d.Weekday-1],
+			Date:   date.Format("2. 1."),
+			Slots:  d.Slots,
+			Events: d.Events,
+		}
+	}
+	return days
+}
+
 func GetHomepage(db *pgxpool.Pool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqCtx := r.Context()
-		_, span := tracer.Start(reqCtx, "get homepage")
+		ctx, span := tracer.Start(reqCtx, "get homepage")
 		defer span.End()
 
-		/*claims := reqCtx.Value("claims").(utils.UserClaims)
-		peridRows, err := db.Query(ctx, "SELECT start, end FROM period WHERE school_id=$1", claims.SchoolId)
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
+		if claims.Role == utils.RoleParent {
+			//parents see the timetable of one child at a time in the parent portal
+			http.Redirect(w, r, "/parent?"+r.URL.RawQuery, http.StatusSeeOther)
+			return
+		}
+
+		day, err := weekOf(r)
+		if err != nil {
+			utils.NewParserError(err, "Invalid week (expected date in format YYYY-MM-DD)").HandleError(w, ctx)
+			return
+		}
+
+		filter, err := models.PersonalWeekTimetableFilter(ctx, db, claims.Id, claims.Role)
 		if err != nil {
 			utils.UnexpectedError(w, err, ctx)
 			return
 		}
-		defer peridRows.Close()
-
-		for peridRows.Next() {
-			var start, end string
-			if err := peridRows.Scan(&start, &end); err != nil {
-				utils.UnexpectedError(w, err, ctx)
-				return
-			}
-			fmt.Println("Start: ", start, "End: ", end)
-		}*/
-
-		randomData := []string{"12:30 - 13:45", "12:30 - 13:45"}
-		timetable := struct {
-			Periods   []string
-			Monday    []string
-			Tuesday   []string
-			Wednesday []string
-			Thursday  []string
-			Friday    []string
-		}{
-			Periods:   randomData,
-			Monday:    randomData,
-			Tuesday:   randomData,
-			Wednesday: randomData,
-			Thursday:  randomData,
-			Friday:    randomData,
+		timetable, err := models.ResolveWeekTimetable(ctx, db, claims.SchoolId, filter, day)
+		if err != nil {
+			utils.UnexpectedError(w, err, ctx)
+			return
 		}
-		// timetable["periods"] = []string{"12:30 - 13:45", "12:30 - 13:45"}
-		// timetable["monday"] = []string{"12:30 - 13:45", "12:30 - 13:45"}
-		// timetable["tuesday"] = []string{"12:30 - 13:45", "12:30 - 13:45"}
-		// timetable["wednesday"] = []string{"12:30 - 13:45", "12:30 - 13:45"}
-		// timetable["thursday"] = []string{"12:30 - 13:45", "12:30 - 13:45"}
-		// timetable["friday"] = []string{"12:30 - 13:45", "12:30 - 13:45"}
-
-		tmpl := template.Must(template.ParseFiles("./web/homepage.html"))
-		tmpl.Execute(w, timetable)
+
+		monday, _ := time.Parse(time.DateOnly, timetable.Monday)
+
+		tmpl := template.Must(template.ParseFiles(utils.WebPath("homepage.html")))
+		tmpl.Execute(w, struct {
+			Name         string
+			Periods      []models.WeekPeriod
+			Days         []homepageDay
+			Monday       string
+			PreviousWeek string
+			NextWeek     string
+		}{
+			Name:         claims.Name + " " + claims.Surname,
+			Periods:      timetable.Periods,
+			Days:         homepageDays(timetable),
+			Monday:       monday.Format("2. 1. 2006"),
+			PreviousWeek: monday.AddDate(0, 0, -7).Format(time.DateOnly),
+			NextWeek:     monday.AddDate(0, 0, 7).Format(time.DateOnly),
+		})
 	})
 }
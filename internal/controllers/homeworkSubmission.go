@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxSubmissionSize limits the whole submission form together with its files
+const MaxSubmissionSize = 20 << 20
+
+// SubmitHomework takes the submission as a form, multipart forms can upload any number of files (file)
+func SubmitHomework(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "submit homework")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			submission := reqCtx.Value("homework submission").(models.HomeworkSubmission)
+			noteId, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid note id").HandleError(w, ctx)
+				return
+			}
+
+			if r.MultipartForm != nil {
+				for _, header := range r.MultipartForm.File["file"] {
+					file, err := header.Open()
+					if err != nil {
+						utils.HandleError(w, err, http.StatusBadRequest, "Invalid file", ctx)
+						return
+					}
+					data, err := io.ReadAll(file)
+					file.Close()
+					if err != nil {
+						utils.UnexpectedError(w, err, ctx)
+						return
+					}
+					contentType := header.Header.Get("Content-Type")
+					if contentType == "" {
+						contentType = http.DetectContentType(data)
+					}
+					submission.Attach(header.Filename, contentType, data)
+				}
+			}
+
+			err = utils.HandleTx(ctx, db, submission.SubmitForNote(claims.Id, noteId))
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				utils.HandleError(w, err, http.StatusNotFound, "Note not found", ctx)
+				return
+			case errors.Is(err, models.ErrSubmissionAlreadyReviewed):
+				utils.HandleError(w, err, http.StatusConflict, "", ctx)
+				return
+			case err != nil:
+				handleCreateError(w, err, ctx)
+				return
+			}
+
+			writeCreated(w, fmt.Sprintf("/homework_submission/%d", submission.Id()), submission, ctx)
+		},
+	)
+}
+
+// GetSubmissionMatrix lists every student the homework was given to with their submission
+func GetSubmissionMatrix(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get submission matrix")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			noteId, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid note id").HandleError(w, ctx)
+				return
+			}
+
+			matrix, err := models.GetSubmissionMatrix(ctx, db, claims.SchoolId, noteId)
+			if errors.Is(err, models.ErrNotHomework) {
+				utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+				return
+			} else if err != nil {
+				handleReadError(w, err, "Note not found", ctx)
+				return
+			}
+			if matrix == nil {
+				matrix = []models.SubmissionMatrixRow{}
+			}
+
+			utils.WriteJSON(w, http.StatusOK, map[string]any{"submissions": matrix}, ctx)
+		},
+	)
+}
+
+func MarkHomeworkSubmission(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "mark homework submission")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			mark := reqCtx.Value("submission mark").(models.SubmissionMark)
+			noteId, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid note id").HandleError(w, ctx)
+				return
+			}
+			studentId, err := utils.ParseUuid(span, "student_id", r.PathValue("student_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid student id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, mark.MarkInDB(claims.SchoolId, noteId, studentId)); err != nil {
+				handleUpdateError(w, err, "Note or submission not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func ReviewHomeworkSubmission(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "review homework submission")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			review := reqCtx.Value("submission review").(models.SubmissionReview)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid homework submission id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, review.ReviewInDB(claims.SchoolId, claims.Id, id)); err != nil {
+				handleUpdateError(w, err, "Homework submission not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func ListHomeworkSubmissions(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list homework submissions")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("homework submission filter").(models.HomeworkSubmissionFilter)
+
+			submissions, err := models.ListHomeworkSubmissions(ctx, db, claims.SchoolId, claims.Id, claims.Role, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, submissions, ctx)
+		},
+	)
+}
+
+func GetHomeworkSubmission(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get homework submission")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid homework submission id").HandleError(w, ctx)
+				return
+			}
+
+			submission, err := models.GetHomeworkSubmission(ctx, db, claims.SchoolId, claims.Id, claims.Role, id)
+			if err != nil {
+				handleReadError(w, err, "Homework submission not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, submission, ctx)
+		},
+	)
+}
+
+func GetSubmissionFile(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get submission file")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid homework submission id").HandleError(w, ctx)
+				return
+			}
+			fileId, err := utils.ParseInt(span, "file_id", r.PathValue("file_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid file id").HandleError(w, ctx)
+				return
+			}
+
+			file, err := models.GetSubmissionFile(ctx, db, claims.SchoolId, claims.Id, claims.Role, id, fileId)
+			if err != nil {
+				handleReadError(w, err, "File not found", ctx)
+				return
+			}
+
+			w.Header().Set("Content-Type", file.ContentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+			w.Write(file.Data)
+		},
+	)
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -14,14 +16,142 @@ func CreateNote(db *pgxpool.Pool) http.Handler {
 		ctx, span := tracer.Start(reqCtx, "create note")
 		defer span.End()
 
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
 		note := reqCtx.Value("note").(models.Note)
 
-		err := utils.HandleTx(ctx, db, note.SaveToDB)
-		if err != nil {
-			utils.UnexpectedError(w, err, ctx)
+		err := utils.HandleTx(ctx, db, note.SaveWithinLimits(claims.SchoolId))
+		var limitErr *models.NoteLimitError
+		if errors.As(err, &limitErr) {
+			writeNoteClashes(w, limitErr, ctx)
+			return
+		} else if err != nil {
+			handleCreateError(w, err, ctx)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		writeCreated(w, fmt.Sprintf("/note/%d", note.Id()), note, ctx)
 	})
 }
+
+func ListNotes(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list notes")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("note filter").(models.NoteFilter)
+
+			notes, err := models.ListNotes(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, notes, ctx)
+		},
+	)
+}
+
+func GetNote(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get note")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid note id").HandleError(w, ctx)
+				return
+			}
+
+			note, err := models.GetNote(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Note not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, note, ctx)
+		},
+	)
+}
+
+func UpdateNote(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update note")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("note update").(models.NoteUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid note id").HandleError(w, ctx)
+				return
+			}
+
+			err = utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id))
+			var limitErr *models.NoteLimitError
+			if errors.As(err, &limitErr) {
+				writeNoteClashes(w, limitErr, ctx)
+				return
+			} else if err != nil {
+				handleUpdateError(w, err, "Note not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteNote(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete note")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid note id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteNote(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Note not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+// GetNoteCalendar shows the load of dated tests and homework day by day
+func GetNoteCalendar(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get note calendar")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("note calendar query").(models.NoteCalendarQuery)
+
+			calendar, err := models.GetNoteCalendar(ctx, db, claims.SchoolId, query)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, calendar, ctx)
+		},
+	)
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func ListNoteLimits(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list note limits")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			limits, err := models.ListNoteLimits(ctx, db, claims.SchoolId)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+			if limits == nil {
+				limits = []models.NoteLimit{}
+			}
+
+			utils.WriteJSON(w, http.StatusOK, map[string]any{"limits": limits}, ctx)
+		},
+	)
+}
+
+// SetNoteLimit sets the limit of the note type in the path
+func SetNoteLimit(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "set note limit")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			limit := reqCtx.Value("note limit").(models.NoteLimit)
+
+			err := utils.HandleTx(ctx, db, limit.SaveForType(claims.SchoolId, r.PathValue("type")))
+			if errors.Is(err, models.ErrInvalidNoteType) {
+				utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+				return
+			} else if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, limit, ctx)
+		},
+	)
+}
+
+func DeleteNoteLimit(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete note limit")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			err := utils.HandleTx(ctx, db, models.DeleteNoteLimit(claims.SchoolId, r.PathValue("type")))
+			if errors.Is(err, models.ErrInvalidNoteType) {
+				utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+				return
+			} else if err != nil {
+				handleDeleteError(w, err, "Note limit not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func ListNotifications(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list notifications")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("notification filter").(models.NotificationFilter)
+
+			notifications, err := models.ListNotifications(ctx, db, claims.Id, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, notifications, ctx)
+		},
+	)
+}
+
+func GetUnreadNotificationCount(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get unread notification count")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			count, err := models.CountUnreadNotifications(ctx, db, claims.Id)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, struct {
+				Unread int `json:"unread"`
+			}{
+				Unread: count,
+			}, ctx)
+		},
+	)
+}
+
+// MarkNotification marks the notification of the user read, or unread again
+func MarkNotification(db *pgxpool.Pool, read bool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "mark notification")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid notification id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.MarkNotification(claims.Id, id, read)); err != nil {
+				handleUpdateError(w, err, "Notification not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func MarkAllNotificationsRead(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "mark all notifications read")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			if err := utils.HandleTx(ctx, db, models.MarkAllNotificationsRead(claims.Id)); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func ListNotificationPreferences(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list notification preferences")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			preferences, err := models.ListNotificationPreferences(ctx, db, claims.Id)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, struct {
+				Preferences []models.NotificationPreference `json:"preferences"`
+			}{
+				Preferences: preferences,
+			}, ctx)
+		},
+	)
+}
+
+func SetNotificationPreference(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "set notification preference")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			preference := reqCtx.Value("notification preference").(models.NotificationPreference)
+
+			if err := utils.HandleTx(ctx, db, preference.SaveForUser(claims.Id)); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, preference, ctx)
+		},
+	)
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -15,14 +16,66 @@ func CreateParentChild(db *pgxpool.Pool) http.Handler {
 			ctx, span := tracer.Start(reqCtx, "create parent child")
 			defer span.End()
 
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
 			parentChild := reqCtx.Value("parent child").(models.ParentChild)
 
-			if err := utils.HandleTx(ctx, db, parentChild.SaveToDB); err != nil {
-				utils.UnexpectedError(w, err, ctx)
+			if err := utils.HandleTx(ctx, db, parentChild.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
 				return
 			}
 
-			w.WriteHeader(http.StatusCreated)
+			writeCreated(w, fmt.Sprintf("/parent_child?parent_id=%s&child_id=%s", parentChild.ParentId(), parentChild.ChildId()), parentChild, ctx)
+		},
+	)
+}
+
+func ListParentChildren(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list parent children")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("parent child filter").(models.ParentChildFilter)
+
+			parentChildren, err := models.ListParentChildren(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, parentChildren, ctx)
+		},
+	)
+}
+
+func DeleteParentChild(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete parent child")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			parentId, err := utils.ParseUuid(span, "parent_id", r.PathValue("parent_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid parent id").HandleError(w, ctx)
+				return
+			}
+			childId, err := utils.ParseUuid(span, "child_id", r.PathValue("child_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid child id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteParentChild(claims.SchoolId, parentId, childId)); err != nil {
+				handleDeleteError(w, err, "Parent child link not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
 		},
 	)
 }
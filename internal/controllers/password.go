@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ForgotPassword emails a reset link if the email is registered, the response is the same either way
+func ForgotPassword(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "forgot password")
+			defer span.End()
+
+			request := reqCtx.Value("password reset request").(models.PasswordResetRequest)
+
+			if err := utils.HandleTx(ctx, db, request.RequestInDB()); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, "If the email is registered, a link to reset the password was sent to it")
+		},
+	)
+}
+
+// ResetPassword sets the password with the token of a reset link and logs the user out everywhere
+func ResetPassword(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "reset password")
+			defer span.End()
+
+			reset := reqCtx.Value("password reset").(models.PasswordReset)
+
+			var userId string
+			err := utils.HandleTx(ctx, db, reset.ResetInDB(&userId))
+			if errors.Is(err, models.ErrInvalidResetToken) {
+				utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+				return
+			} else if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			span.AddEvent("Revoke sessions of the user")
+			if err := utils.RevokeUserSessions(ctx, userId, ""); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			w.Header().Set("HX-Redirect", "/login")
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+// ChangePassword sets the password of the user, other sessions of the user are revoked
+func ChangePassword(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "change password")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			change := reqCtx.Value("password change").(models.PasswordChange)
+
+			err := utils.HandleTx(ctx, db, change.ChangeInDB(claims.Id))
+			if errors.Is(err, models.ErrWrongPassword) {
+				utils.HandleError(w, err, http.StatusForbidden, "", ctx)
+				return
+			} else if err != nil {
+				handleUpdateError(w, err, "User not found", ctx)
+				return
+			}
+
+			span.AddEvent("Revoke other sessions of the user")
+			if err := utils.RevokeUserSessions(ctx, claims.Id, claims.ID); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func GetForgotPassword() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tmpl := template.Must(template.ParseFiles(utils.WebPath("forgotPassword.html")))
+		tmpl.Execute(w, nil)
+	})
+}
+
+// GetResetPassword is the page of reset links, the token is in the query
+func GetResetPassword() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tmpl := template.Must(template.ParseFiles(utils.WebPath("resetPassword.html")))
+		tmpl.Execute(w, struct {
+			Token string
+		}{
+			Token: r.URL.Query().Get("token"),
+		})
+	})
+}
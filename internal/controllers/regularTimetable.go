@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -15,15 +16,111 @@ func CreateRegularTimetable(db *pgxpool.Pool) http.Handler {
 			ctx, span := tracer.Start(reqCtx, "create regulart timetable")
 			defer span.End()
 
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
 			timetable := reqCtx.Value("regular timetable").(models.RegularTimetable)
 
-			if err := utils.HandleTx(ctx, db, timetable.SaveToDB); err != nil {
-				utils.UnexpectedError(w, err, ctx)
+			if err := utils.HandleTx(ctx, db, timetable.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
 				return
 			}
 
-			w.WriteHeader(http.StatusCreated)
+			writeCreated(w, fmt.Sprintf("/regular_timetable/%d", timetable.Id()), timetable, ctx)
+		},
+	)
+}
+
+func ListRegularTimetables(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list regular timetables")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("regular timetable filter").(models.RegularTimetableFilter)
+
+			regularTimetables, err := models.ListRegularTimetables(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, regularTimetables, ctx)
+		},
+	)
+}
+
+func GetRegularTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get regular timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid regular timetable id").HandleError(w, ctx)
+				return
+			}
+
+			regularTimetable, err := models.GetRegularTimetable(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Regular timetable not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, regularTimetable, ctx)
+		},
+	)
+}
+
+func UpdateRegularTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update regular timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("regular timetable update").(models.RegularTimetableUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid regular timetable id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Regular timetable not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteRegularTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete regular timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid regular timetable id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteRegularTimetable(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Regular timetable not found", ctx)
+				return
+			}
 
+			w.WriteHeader(http.StatusNoContent)
 		},
 	)
 }
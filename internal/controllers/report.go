@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
 	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -14,13 +17,168 @@ func CreateReport(db *pgxpool.Pool) http.Handler {
 		ctx, span := tracer.Start(reqCtx, "create report")
 		defer span.End()
 
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
 		Report := ctx.Value(" report").(models.Report)
+		attendance := ctx.Value("attendance").(models.Attendance)
 
-		if err := utils.HandleTx(ctx, db, Report.SaveToDB); err != nil {
-			utils.UnexpectedError(w, err, ctx)
+		err := utils.HandleTx(ctx, db, Report.SaveToDBWithSchoolId(claims.SchoolId), func(tx pgx.Tx) error {
+			err := attendance.SaveForReport(claims.SchoolId, Report.Id())(tx)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.ErrForeignReference
+			}
+			return err
+		})
+		if err != nil {
+			handleCreateError(w, err, ctx)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		writeCreated(w, fmt.Sprintf("/report/%d", Report.Id()), Report, ctx)
 	})
 }
+
+func ListReports(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list reports")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("report filter").(models.ReportFilter)
+
+			reports, err := models.ListReports(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, reports, ctx)
+		},
+	)
+}
+
+func GetReport(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get report")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid report id").HandleError(w, ctx)
+				return
+			}
+
+			report, err := models.GetReport(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Report not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, report, ctx)
+		},
+	)
+}
+
+func UpdateReport(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update report")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("report update").(models.ReportUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid report id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Report not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func GetReportAttendance(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get report attendance")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid report id").HandleError(w, ctx)
+				return
+			}
+
+			attendance, err := models.GetReportAttendance(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Report not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, map[string]any{"attendance": attendance}, ctx)
+		},
+	)
+}
+
+func UpdateReportAttendance(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update report attendance")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			attendance := reqCtx.Value("attendance").(models.Attendance)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid report id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, attendance.SaveForReport(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Report not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteReport(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete report")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid report id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteReport(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Report not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// reportCardView formats values of the report card the way they are printed
+type reportCardView struct {
+	models.ReportCard
+	Average  string
+	IssuedOn string
+}
+
+func newReportCardView(c models.ReportCard) reportCardView {
+	view := reportCardView{ReportCard: c, IssuedOn: c.IssuedOn}
+	if c.Average != nil {
+		view.Average = fmt.Sprintf("%.2f", *c.Average)
+	}
+	if issuedOn, err := time.Parse(time.DateOnly, c.IssuedOn); err == nil {
+		view.IssuedOn = issuedOn.Format("2. 1. 2006")
+	}
+	return view
+}
+
+// GetClassReportCards renders report cards of the whole class into a single document,
+// one page per student, unless student_id selects only one of them
+func GetClassReportCards(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get class report cards")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("report card query").(models.ReportCardQuery)
+			classId, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid class id").HandleError(w, ctx)
+				return
+			}
+
+			cards, err := query.ClassReportCards(ctx, db, claims.SchoolId, classId)
+			if errors.Is(err, models.ErrStudentNotVisible) {
+				utils.HandleError(w, err, http.StatusNotFound, "Student not found in the class", ctx)
+				return
+			} else if err != nil {
+				handleReadError(w, err, "Class or term not found", ctx)
+				return
+			}
+
+			views := make([]reportCardView, len(cards))
+			for i, c := range cards {
+				views[i] = newReportCardView(c)
+			}
+			filename := fmt.Sprintf("report-cards-%d", classId)
+			if len(cards) == 1 {
+				filename = "report-card-" + cards[0].StudentId
+			}
+
+			format := query.Format()
+			if format == "" && utils.GetResponseFormat(ctx) == utils.FormatJSON {
+				format = "json"
+			}
+			switch format {
+			case "json":
+				utils.WriteJSON(w, http.StatusOK, map[string]any{"reportCards": cards}, ctx)
+			case "pdf":
+				w.Header().Set("Content-Type", "application/pdf")
+				w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, filename))
+				if _, err := reportCardsPDF(views).WriteTo(w); err != nil {
+					utils.UnexpectedError(w, err, ctx)
+				}
+			default:
+				tmpl := template.Must(template.ParseFiles(utils.WebPath("reportCards.html")))
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.html"`, filename))
+				tmpl.Execute(w, struct {
+					Title string
+					Cards []reportCardView
+				}{
+					Title: filename,
+					Cards: views,
+				})
+			}
+		},
+	)
+}
+
+// reportCardsPDF lays out the same document as web/reportCards.html
+func reportCardsPDF(cards []reportCardView) *utils.PDF {
+	const (
+		left       = 60.0
+		gradeLeft  = 330.0
+		right      = utils.PDFPageWidth - 60
+		lineHeight = 20.0
+		bottom     = utils.PDFPageHeight - 140
+	)
+
+	pdf := &utils.PDF{}
+	for _, c := range cards {
+		page := pdf.AddPage()
+		page.Text(left, 70, 12, true, c.School.Name)
+		page.Text(left, 86, 10, false, c.School.Address)
+		page.Text(utils.PDFPageWidth/2-70, 140, 22, true, "VYSVĚDČENÍ")
+
+		page.Text(left, 190, 11, false, "Jméno a příjmení:")
+		page.Text(gradeLeft-170, 190, 11, true, c.Student)
+		page.Text(left, 208, 11, false, fmt.Sprintf("Třída: %s, školní rok %s, %s", c.Class, c.AcademicYear, c.Term))
+		page.Text(left, 226, 11, false, "Třídní učitel: "+c.ClassTeacher)
+
+		y := 270.0
+		page.Text(left, y, 11, true, "Předmět")
+		page.Text(gradeLeft, y, 11, true, "Hodnocení")
+		page.Line(left, y+6, right, y+6)
+		for _, s := range c.Subjects {
+			y += lineHeight
+			if y > bottom {
+				page = pdf.AddPage()
+				y = 70
+			}
+			grade := "nehodnocen(a)"
+			if s.Grade != nil {
+				grade = s.GradeName
+			}
+			page.Text(left, y, 11, false, s.Subject)
+			page.Text(gradeLeft, y, 11, false, grade)
+			page.Line(left, y+6, right, y+6)
+		}
+
+		y += 2 * lineHeight
+		result := "Celkové hodnocení: " + c.ResultName
+		if c.Average != "" {
+			result += fmt.Sprintf(" (průměr %s)", c.Average)
+		}
+		page.Text(left, y, 11, true, result)
+		page.Text(left, y+lineHeight, 11, false, fmt.Sprintf(
+			"Zameškané hodiny: %d omluvených, %d neomluvených", c.ExcusedLessons, c.UnexcusedLessons,
+		))
+		page.Text(left, y+2*lineHeight, 11, false, "Datum: "+c.IssuedOn)
+
+		page.Line(left, utils.PDFPageHeight-90, left+170, utils.PDFPageHeight-90)
+		page.Text(left+35, utils.PDFPageHeight-76, 9, false, "ředitel(ka) školy")
+		page.Line(right-170, utils.PDFPageHeight-90, right, utils.PDFPageHeight-90)
+		page.Text(right-130, utils.PDFPageHeight-76, 9, false, "třídní učitel(ka)")
+	}
+	if len(cards) == 0 {
+		pdf.AddPage()
+	}
+
+	return pdf
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -15,14 +16,111 @@ func CreateRoom(db *pgxpool.Pool) http.Handler {
 			ctx, span := tracer.Start(reqCtx, "room creation")
 			defer span.End()
 
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
 			room := reqCtx.Value("room").(models.Room)
 
-			if err := utils.HandleTx(ctx, db, room.SaveToDB); err != nil {
-				utils.UnexpectedError(w, err, ctx)
-				//TODO: add handling for invalid foreign keys later
+			if err := utils.HandleTx(ctx, db, room.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
+				return
 			}
 
-			w.WriteHeader(http.StatusCreated)
+			writeCreated(w, fmt.Sprintf("/room/%d", room.Id()), room, ctx)
+		},
+	)
+}
+
+func ListRooms(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list rooms")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("room filter").(models.RoomFilter)
+
+			rooms, err := models.ListRooms(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, rooms, ctx)
+		},
+	)
+}
+
+func GetRoom(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get room")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid room id").HandleError(w, ctx)
+				return
+			}
+
+			room, err := models.GetRoom(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Room not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, room, ctx)
+		},
+	)
+}
+
+func UpdateRoom(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update room")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("room update").(models.RoomUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid room id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Room not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteRoom(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete room")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid room id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteRoom(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Room not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
 		},
 	)
 }
@@ -4,14 +4,13 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
-	"time"
 
 	"github.com/dr0th3r/learnscape/internal/models"
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func RegisterSchool(db *pgxpool.Pool, jwtSecret string) http.Handler {
+func RegisterSchool(db *pgxpool.Pool) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			reqCtx := r.Context()
@@ -20,6 +19,7 @@ func RegisterSchool(db *pgxpool.Pool, jwtSecret string) http.Handler {
 
 			school := reqCtx.Value("school").(models.School)
 			admin := reqCtx.Value("user").(models.User)
+			admin.SetRole(utils.RoleAdmin)
 
 			var newSchoolId int
 			if err := utils.HandleTx(
@@ -34,12 +34,10 @@ func RegisterSchool(db *pgxpool.Pool, jwtSecret string) http.Handler {
 			}
 
 			span.AddEvent("Starting to set jwt token for admin")
-			tokenCookie, err := admin.CreateTokenCookie([]byte(jwtSecret), time.Now().Add(jwtCookieLifetime))
-			if err != nil {
+			if err := startSession(w, r, admin); err != nil {
 				utils.UnexpectedError(w, err, ctx)
 				return
 			}
-			http.SetCookie(w, tokenCookie)
 
 			w.WriteHeader(http.StatusCreated)
 		},
@@ -58,3 +56,74 @@ func GetRegister() http.Handler {
 		},
 	)
 }
+
+func ListSchools(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list schools")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+
+			schools, err := models.ListSchools(ctx, db, claims.SchoolId, query)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, schools, ctx)
+		},
+	)
+}
+
+func GetSchool(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get school")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid school id").HandleError(w, ctx)
+				return
+			}
+
+			school, err := models.GetSchool(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "School not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, school, ctx)
+		},
+	)
+}
+
+func UpdateSchool(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update school")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("school update").(models.SchoolUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid school id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "School not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// startSession starts a session of the user and sets the cookies with its access and refresh token
+func startSession(w http.ResponseWriter, r *http.Request, user models.User) error {
+	session, refreshToken, err := utils.CreateSession(r.Context(), user.Claims(), r.UserAgent())
+	if err != nil {
+		return err
+	}
+	return utils.SetTokenCookies(w, session, refreshToken)
+}
+
+// Logout revokes the session of the token with its refresh token and clears their cookies
+func Logout() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "logout")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			if err := utils.RevokeSession(ctx, claims.Id, claims.ID); err != nil && !errors.Is(err, utils.ErrSessionNotFound) {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+			utils.ClearTokenCookies(w)
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+// ListSessions lists active sessions of the user, current is the session of the request
+func ListSessions() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list sessions")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			sessions, err := utils.ListSessions(ctx, claims.Id)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			//hashes of refresh tokens stay on the server
+			type session struct {
+				Id        string    `json:"id"`
+				UserAgent string    `json:"userAgent"`
+				CreatedAt time.Time `json:"createdAt"`
+				ExpiresAt time.Time `json:"expiresAt"`
+				Current   bool      `json:"current"`
+			}
+			list := make([]session, len(sessions))
+			for i, s := range sessions {
+				list[i] = session{
+					Id:        s.Id,
+					UserAgent: s.UserAgent,
+					CreatedAt: s.CreatedAt,
+					ExpiresAt: s.ExpiresAt,
+					Current:   s.Id == claims.ID,
+				}
+			}
+			utils.WriteJSON(w, http.StatusOK, struct {
+				Sessions []session `json:"sessions"`
+			}{
+				Sessions: list,
+			}, ctx)
+		},
+	)
+}
+
+func RevokeSession() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "revoke session")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			err := utils.RevokeSession(ctx, claims.Id, r.PathValue("id"))
+			if errors.Is(err, utils.ErrSessionNotFound) {
+				utils.HandleError(w, err, http.StatusNotFound, "Session not found", ctx)
+				return
+			} else if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+// RevokeOtherSessions revokes every session of the user except the one of the request
+func RevokeOtherSessions() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "revoke other sessions")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			if err := utils.RevokeUserSessions(ctx, claims.Id, claims.ID); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+// RevokeUserSessions lets an admin log a user of the school out everywhere
+func RevokeUserSessions(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "revoke user sessions")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseUuid(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid user id").HandleError(w, ctx)
+				return
+			}
+
+			if _, err := models.GetUser(ctx, db, claims.SchoolId, id); err != nil {
+				handleReadError(w, err, "User not found", ctx)
+				return
+			}
+			if err := utils.RevokeUserSessions(ctx, id.String(), ""); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -15,14 +16,111 @@ func CreateSubject(db *pgxpool.Pool) http.Handler {
 			ctx, span := tracer.Start(reqCtx, "subject creation")
 			defer span.End()
 
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
 			subject := reqCtx.Value("subject").(models.Subject)
 
-			if err := utils.HandleTx(ctx, db, subject.SaveToDB); err != nil {
-				utils.UnexpectedError(w, err, ctx)
+			if err := utils.HandleTx(ctx, db, subject.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
 				return
 			}
 
-			w.WriteHeader(http.StatusCreated)
+			writeCreated(w, fmt.Sprintf("/subject/%d", subject.Id()), subject, ctx)
+		},
+	)
+}
+
+func ListSubjects(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list subjects")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("subject filter").(models.SubjectFilter)
+
+			subjects, err := models.ListSubjects(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, subjects, ctx)
+		},
+	)
+}
+
+func GetSubject(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get subject")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid subject id").HandleError(w, ctx)
+				return
+			}
+
+			subject, err := models.GetSubject(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Subject not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, subject, ctx)
+		},
+	)
+}
+
+func UpdateSubject(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update subject")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("subject update").(models.SubjectUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid subject id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Subject not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteSubject(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete subject")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid subject id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteSubject(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Subject not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
 		},
 	)
 }
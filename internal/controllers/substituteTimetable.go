@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -15,15 +16,111 @@ func CreateSubstituteTimetable(db *pgxpool.Pool) http.Handler {
 			ctx, span := tracer.Start(reqCtx, "create substitutet timetable")
 			defer span.End()
 
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
 			timetable := reqCtx.Value("substitute timetable").(models.SubstituteTimetable)
 
-			if err := utils.HandleTx(ctx, db, timetable.SaveToDB); err != nil {
-				utils.UnexpectedError(w, err, ctx)
+			if err := utils.HandleTx(ctx, db, timetable.SaveToDBWithSchoolId(claims.SchoolId)); err != nil {
+				handleCreateError(w, err, ctx)
 				return
 			}
 
-			w.WriteHeader(http.StatusCreated)
+			writeCreated(w, fmt.Sprintf("/substitute_timetable/%d", timetable.Id()), timetable, ctx)
+		},
+	)
+}
+
+func ListSubstituteTimetables(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list substitute timetables")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("substitute timetable filter").(models.SubstituteTimetableFilter)
+
+			substituteTimetables, err := models.ListSubstituteTimetables(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, substituteTimetables, ctx)
+		},
+	)
+}
+
+func GetSubstituteTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get substitute timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid substitute timetable id").HandleError(w, ctx)
+				return
+			}
+
+			substituteTimetable, err := models.GetSubstituteTimetable(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Substitute timetable not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, substituteTimetable, ctx)
+		},
+	)
+}
+
+func UpdateSubstituteTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update substitute timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("substitute timetable update").(models.SubstituteTimetableUpdate)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid substitute timetable id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Substitute timetable not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteSubstituteTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete substitute timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid substitute timetable id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteSubstituteTimetable(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Substitute timetable not found", ctx)
+				return
+			}
 
+			w.WriteHeader(http.StatusNoContent)
 		},
 	)
 }
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func GetWeekTimetable(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get week timetable")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("week timetable query").(models.WeekTimetableQuery)
+
+			week, err := query.Resolve(ctx, db, claims.SchoolId)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, week, ctx)
+		},
+	)
+}
+
+func GetTimetableConflicts(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get timetable conflicts")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			conflicts, err := models.FindTimetableConflicts(ctx, db, claims.SchoolId)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, struct {
+				Conflicts []models.TimetableConflict `json:"conflicts"`
+			}{
+				Conflicts: conflicts,
+			}, ctx)
+		},
+	)
+}
+
+func CreateTimetableDraft(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "create timetable draft")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			requirements := reqCtx.Value("timetable requirements").(models.TimetableRequirements)
+			createdBy, err := utils.ParseUuid(span, "created_by", claims.Id)
+			if err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			var draft models.TimetableDraft
+			if err := utils.HandleTx(ctx, db, requirements.GenerateTimetableDraft(claims.SchoolId, createdBy, &draft)); err != nil {
+				if errors.Is(err, models.ErrForeignReference) {
+					utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+				} else {
+					handleCreateError(w, err, ctx)
+				}
+				return
+			}
+
+			writeCreated(w, fmt.Sprintf("/timetable/draft/%d", draft.Id()), draft, ctx)
+		},
+	)
+}
+
+func GetTimetableDraft(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get timetable draft")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid timetable draft id").HandleError(w, ctx)
+				return
+			}
+
+			draft, err := models.GetTimetableDraft(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "Timetable draft not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, draft, ctx)
+		},
+	)
+}
+
+func CommitTimetableDraft(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "commit timetable draft")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid timetable draft id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.CommitTimetableDraft(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "Timetable draft not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteTimetableDraft(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete timetable draft")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseInt(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid timetable draft id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteTimetableDraft(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "Timetable draft not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -14,14 +15,66 @@ func CreateTimetableGroup(db *pgxpool.Pool) http.Handler {
 		ctx, span := tracer.Start(reqCtx, "create timetable group")
 		defer span.End()
 
-		usersGroup := reqCtx.Value("timetable_group").(models.TimetableGroup)
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
+		timetableGroup := reqCtx.Value("timetable_group").(models.TimetableGroup)
 
-		err := utils.HandleTx(ctx, db, usersGroup.SaveToDB)
+		err := utils.HandleTx(ctx, db, timetableGroup.SaveToDBWithSchoolId(claims.SchoolId))
 		if err != nil {
-			utils.UnexpectedError(w, err, ctx)
+			handleCreateError(w, err, ctx)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		writeCreated(w, fmt.Sprintf("/timetable_group?timetable_id=%d&group_id=%d", timetableGroup.TimetableId(), timetableGroup.GroupId()), timetableGroup, ctx)
 	})
 }
+
+func ListTimetableGroups(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list timetable groups")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("timetable_group filter").(models.TimetableGroupFilter)
+
+			timetableGroups, err := models.ListTimetableGroups(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, timetableGroups, ctx)
+		},
+	)
+}
+
+func DeleteTimetableGroup(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete timetable group")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			timetableId, err := utils.ParseInt(span, "timetable_id", r.PathValue("timetable_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid timetable id").HandleError(w, ctx)
+				return
+			}
+			groupId, err := utils.ParseInt(span, "group_id", r.PathValue("group_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid group id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteTimetableGroup(claims.SchoolId, timetableId, groupId)); err != nil {
+				handleDeleteError(w, err, "Group is not in the timetable", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
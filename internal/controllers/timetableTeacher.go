@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -14,14 +15,66 @@ func CreateTimetableTeacher(db *pgxpool.Pool) http.Handler {
 		ctx, span := tracer.Start(reqCtx, "create regular timetable teacher")
 		defer span.End()
 
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
 		timetableTeacher := reqCtx.Value("timetable_teacher").(models.TimetableTeacher)
 
-		err := utils.HandleTx(ctx, db, timetableTeacher.SaveToDB)
+		err := utils.HandleTx(ctx, db, timetableTeacher.SaveToDBWithSchoolId(claims.SchoolId))
 		if err != nil {
-			utils.UnexpectedError(w, err, ctx)
+			handleCreateError(w, err, ctx)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		writeCreated(w, fmt.Sprintf("/timetable_teacher?timetable_id=%d&teacher_id=%s", timetableTeacher.TimetableId(), timetableTeacher.TeacherId()), timetableTeacher, ctx)
 	})
 }
+
+func ListTimetableTeachers(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list timetable teachers")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("timetable_teacher filter").(models.TimetableTeacherFilter)
+
+			timetableTeachers, err := models.ListTimetableTeachers(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, timetableTeachers, ctx)
+		},
+	)
+}
+
+func DeleteTimetableTeacher(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete timetable teacher")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			timetableId, err := utils.ParseInt(span, "timetable_id", r.PathValue("timetable_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid timetable id").HandleError(w, ctx)
+				return
+			}
+			teacherId, err := utils.ParseUuid(span, "teacher_id", r.PathValue("teacher_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid teacher id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteTimetableTeacher(claims.SchoolId, timetableId, teacherId)); err != nil {
+				handleDeleteError(w, err, "Teacher is not in the timetable", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
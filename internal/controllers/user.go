@@ -4,7 +4,6 @@ import (
 	"errors"
 	"html/template"
 	"net/http"
-	"time"
 
 	"github.com/dr0th3r/learnscape/internal/models"
 	"github.com/dr0th3r/learnscape/internal/utils"
@@ -12,9 +11,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-const jwtCookieLifetime = time.Hour * 72
-
-func RegisterUser(db *pgxpool.Pool, jwtSecret string) http.Handler {
+func RegisterUser(db *pgxpool.Pool) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			reqCtx := r.Context()
@@ -26,6 +23,10 @@ func RegisterUser(db *pgxpool.Pool, jwtSecret string) http.Handler {
 				utils.NewParserError(nil, "User doesn't have school id").HandleError(w, ctx)
 				return
 			}
+			if role := user.Role(); role == utils.RoleAdmin || role == utils.RoleTeacher {
+				utils.HandleError(w, nil, http.StatusForbidden, "Only school admin can register teachers and admins", ctx)
+				return
+			}
 
 			if err := utils.HandleTx(ctx, db, user.SaveToDB); err != nil {
 				var pgErr *pgconn.PgError
@@ -38,19 +39,42 @@ func RegisterUser(db *pgxpool.Pool, jwtSecret string) http.Handler {
 			}
 
 			span.AddEvent("Set user jwt token")
-			tokenCookie, err := user.CreateTokenCookie([]byte(jwtSecret), time.Now().Add(jwtCookieLifetime))
-			if err != nil {
+			if err := startSession(w, r, user); err != nil {
 				utils.UnexpectedError(w, err, ctx)
 				return
 			}
-			http.SetCookie(w, tokenCookie)
 
 			w.WriteHeader(http.StatusCreated)
 		},
 	)
 }
 
-func Login(db *pgxpool.Pool, jwtSecret string) http.Handler {
+func CreateUser(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "user creation by admin")
+			defer span.End()
+
+			user := reqCtx.Value("user").(models.User)
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+
+			if err := utils.HandleTx(ctx, db, user.SaveToDBWithSchoolId(&claims.SchoolId)); err != nil {
+				var pgErr *pgconn.PgError
+				if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+					utils.HandleError(w, err, http.StatusConflict, "Email already registered", ctx)
+				} else {
+					utils.UnexpectedError(w, err, ctx)
+				}
+				return
+			}
+
+			writeCreated(w, "/user/"+user.Id(), user, ctx)
+		},
+	)
+}
+
+func Login(db *pgxpool.Pool) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			reqCtx := r.Context()
@@ -66,12 +90,10 @@ func Login(db *pgxpool.Pool, jwtSecret string) http.Handler {
 			}
 
 			span.AddEvent("Set user jwt")
-			tokenCookie, err := user.CreateTokenCookie([]byte(jwtSecret), time.Now().Add(jwtCookieLifetime))
-			if err != nil {
+			if err := startSession(w, r, user); err != nil {
 				utils.UnexpectedError(w, err, ctx)
 				return
 			}
-			http.SetCookie(w, tokenCookie)
 
 		},
 	)
@@ -83,3 +105,103 @@ func GetLogin() http.Handler {
 		tmpl.Execute(w, nil)
 	})
 }
+
+func ListUsers(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list users")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("user filter").(models.UserFilter)
+
+			users, err := models.ListUsers(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, users, ctx)
+		},
+	)
+}
+
+func GetUser(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "get user")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseUuid(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid user id").HandleError(w, ctx)
+				return
+			}
+
+			user, err := models.GetUser(ctx, db, claims.SchoolId, id)
+			if err != nil {
+				handleReadError(w, err, "User not found", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, user, ctx)
+		},
+	)
+}
+
+func UpdateUser(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "update user")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			update := reqCtx.Value("user update").(models.UserUpdate)
+			id, err := utils.ParseUuid(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid user id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, update.UpdateInDB(claims.SchoolId, id)); err != nil {
+				handleUpdateError(w, err, "User not found", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
+
+func DeleteUser(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete user")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			id, err := utils.ParseUuid(span, "id", r.PathValue("id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid user id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteUser(claims.SchoolId, id)); err != nil {
+				handleDeleteError(w, err, "User not found", ctx)
+				return
+			}
+			if err := utils.RevokeUserSessions(ctx, id.String(), ""); err != nil {
+				utils.UnexpectedError(w, err, ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dr0th3r/learnscape/internal/models"
@@ -14,14 +15,66 @@ func CreateUsersGroup(db *pgxpool.Pool) http.Handler {
 		ctx, span := tracer.Start(reqCtx, "create users group")
 		defer span.End()
 
+		claims := reqCtx.Value("claims").(*utils.UserClaims)
 		usersGroup := reqCtx.Value("users_group").(models.UsersGroup)
 
-		err := utils.HandleTx(ctx, db, usersGroup.SaveToDB)
+		err := utils.HandleTx(ctx, db, usersGroup.SaveToDBWithSchoolId(claims.SchoolId))
 		if err != nil {
-			utils.UnexpectedError(w, err, ctx)
+			handleCreateError(w, err, ctx)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
+		writeCreated(w, fmt.Sprintf("/users_group?user_id=%s&group_id=%d", usersGroup.UserId(), usersGroup.GroupId()), usersGroup, ctx)
 	})
 }
+
+func ListUsersGroups(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "list users groups")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			query := reqCtx.Value("list query").(models.ListQuery)
+			filter := reqCtx.Value("users_group filter").(models.UsersGroupFilter)
+
+			usersGroups, err := models.ListUsersGroups(ctx, db, claims.SchoolId, query, filter)
+			if err != nil {
+				handleReadError(w, err, "", ctx)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, usersGroups, ctx)
+		},
+	)
+}
+
+func DeleteUsersGroup(db *pgxpool.Pool) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			reqCtx := r.Context()
+			ctx, span := tracer.Start(reqCtx, "delete users group")
+			defer span.End()
+
+			claims := reqCtx.Value("claims").(*utils.UserClaims)
+			userId, err := utils.ParseUuid(span, "user_id", r.PathValue("user_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid user id").HandleError(w, ctx)
+				return
+			}
+			groupId, err := utils.ParseInt(span, "group_id", r.PathValue("group_id"))
+			if err != nil {
+				utils.NewParserError(err, "Invalid group id").HandleError(w, ctx)
+				return
+			}
+
+			if err := utils.HandleTx(ctx, db, models.DeleteUsersGroup(claims.SchoolId, userId, groupId)); err != nil {
+				handleDeleteError(w, err, "User is not in the group", ctx)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+}
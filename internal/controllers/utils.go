@@ -1,7 +1,186 @@
 package controllers
 
-import "go.opentelemetry.io/otel"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
 
 var (
 	tracer = otel.Tracer("controllers")
 )
+
+func handleReadError(w http.ResponseWriter, err error, notFoundMsg string, ctx context.Context) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		utils.HandleError(w, err, http.StatusNotFound, notFoundMsg, ctx)
+	case errors.Is(err, models.ErrInvalidCursor):
+		utils.HandleError(w, err, http.StatusBadRequest, "Invalid cursor", ctx)
+	case errors.Is(err, models.ErrInvalidSort):
+		utils.HandleError(w, err, http.StatusBadRequest, "Invalid sort (not supported by the list)", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "22P02": //cursor values which aren't valid for the key type
+		utils.HandleError(w, err, http.StatusBadRequest, "Invalid cursor", ctx)
+	default:
+		utils.UnexpectedError(w, err, ctx)
+	}
+}
+
+// handleCreateError handles errors of creating records which reference other ones
+func handleCreateError(w http.ResponseWriter, err error, ctx context.Context) {
+	var pgErr *pgconn.PgError
+	var conflictErr *models.ConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		writeConflicts(w, conflictErr, ctx)
+	case errors.Is(err, models.ErrAcademicYearNotFound), errors.Is(err, models.ErrForeignReference),
+		errors.Is(err, models.ErrStudentNotInLesson), errors.Is(err, models.ErrNotHomework),
+		errors.Is(err, models.ErrEmptySubmission):
+		utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		utils.HandleError(w, err, http.StatusBadRequest, "Referenced record doesn't exist", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		utils.HandleError(w, err, http.StatusConflict, "Record with the same values already exists", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23P01":
+		utils.HandleError(w, err, http.StatusConflict, "Overlaps with an existing record", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23514":
+		utils.HandleError(w, err, http.StatusBadRequest, pgErr.Message, ctx)
+	default:
+		utils.UnexpectedError(w, err, ctx)
+	}
+}
+
+func handleUpdateError(w http.ResponseWriter, err error, notFoundMsg string, ctx context.Context) {
+	var pgErr *pgconn.PgError
+	var conflictErr *models.ConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		writeConflicts(w, conflictErr, ctx)
+	case errors.Is(err, pgx.ErrNoRows):
+		utils.HandleError(w, err, http.StatusNotFound, notFoundMsg, ctx)
+	case errors.Is(err, models.ErrNoteWithoutDate), errors.Is(err, models.ErrAcademicYearNotFound),
+		errors.Is(err, models.ErrStudentNotInLesson), errors.Is(err, models.ErrNotHomework),
+		errors.Is(err, models.ErrForeignReference), errors.Is(err, models.ErrNoReportForGrade):
+		utils.HandleError(w, err, http.StatusBadRequest, "", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		utils.HandleError(w, err, http.StatusBadRequest, "Referenced record doesn't exist", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		utils.HandleError(w, err, http.StatusConflict, "Record with the same values already exists", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23P01":
+		utils.HandleError(w, err, http.StatusConflict, "Overlaps with an existing record", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23514":
+		utils.HandleError(w, err, http.StatusBadRequest, pgErr.Message, ctx)
+	default:
+		utils.UnexpectedError(w, err, ctx)
+	}
+}
+
+func handleDeleteError(w http.ResponseWriter, err error, notFoundMsg string, ctx context.Context) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		utils.HandleError(w, err, http.StatusNotFound, notFoundMsg, ctx)
+	case errors.Is(err, models.ErrTimetableHasNotes):
+		utils.HandleError(w, err, http.StatusConflict, "", ctx)
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		utils.HandleError(w, err, http.StatusConflict, fmt.Sprintf("Can't delete, it is still referenced from %s", pgErr.TableName), ctx)
+	default:
+		utils.UnexpectedError(w, err, ctx)
+	}
+}
+
+// writeCreated responds with the newly created resource, htmx requests and
+// clients asking for html get a fragment they can swap in, everybody else
+// gets json
+func writeCreated(w http.ResponseWriter, location string, v any, ctx context.Context) {
+	w.Header().Set("Location", location)
+
+	if utils.GetResponseFormat(ctx) != utils.FormatHTML {
+		utils.WriteJSON(w, http.StatusCreated, v, ctx)
+		return
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		utils.UnexpectedError(w, err, ctx)
+		return
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		utils.UnexpectedError(w, err, ctx)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(utils.WebPath("fragments/created.html")))
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusCreated)
+	tmpl.Execute(w, struct {
+		Location string
+		Fields   map[string]any
+	}{
+		Location: location,
+		Fields:   fields,
+	})
+}
+
+// writeConflicts responds with 409 listing every lesson the timetable clashes with
+func writeConflicts(w http.ResponseWriter, err *models.ConflictError, ctx context.Context) {
+	const msg = "Timetable conflicts with other lessons"
+
+	if utils.GetResponseFormat(ctx) != utils.FormatJSON {
+		conflicts := make([]string, len(err.Conflicts))
+		for i, c := range err.Conflicts {
+			conflicts[i] = c.String()
+		}
+		utils.HandleError(w, err, http.StatusConflict, fmt.Sprintf("%s: %s", msg, strings.Join(conflicts, ", ")), ctx)
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetStatus(codes.Error, msg)
+	span.RecordError(err)
+	utils.WriteJSON(w, http.StatusConflict, struct {
+		Error     string                     `json:"error"`
+		Conflicts []models.TimetableConflict `json:"conflicts"`
+	}{
+		Error:     msg,
+		Conflicts: err.Conflicts,
+	}, ctx)
+}
+
+// writeNoteClashes responds with 409 listing the notes which already reached the limit
+func writeNoteClashes(w http.ResponseWriter, err *models.NoteLimitError, ctx context.Context) {
+	msg := fmt.Sprintf("Limit of %d notes of type %s per day was reached on %s", err.MaxPerDay, err.Type, err.Date)
+
+	if utils.GetResponseFormat(ctx) != utils.FormatJSON {
+		clashes := make([]string, len(err.Clashes))
+		for i, c := range err.Clashes {
+			clashes[i] = c.String()
+		}
+		utils.HandleError(w, err, http.StatusConflict, fmt.Sprintf("%s: %s", msg, strings.Join(clashes, ", ")), ctx)
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetStatus(codes.Error, msg)
+	span.RecordError(err)
+	utils.WriteJSON(w, http.StatusConflict, struct {
+		Error   string             `json:"error"`
+		Clashes []models.NoteClash `json:"clashes"`
+	}{
+		Error:   msg,
+		Clashes: err.Clashes,
+	}, ctx)
+}
@@ -0,0 +1,4 @@
+ALTER TABLE users
+DROP COLUMN role;
+
+DROP TYPE user_role;
@@ -0,0 +1,4 @@
+CREATE TYPE user_role AS ENUM ('admin', 'teacher', 'student', 'parent');
+
+ALTER TABLE users
+ADD COLUMN role USER_ROLE NOT NULL DEFAULT 'student';
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS timetable_draft_unplaced;
+
+DROP TABLE IF EXISTS timetable_draft_lesson;
+
+DROP TABLE IF EXISTS timetable_draft;
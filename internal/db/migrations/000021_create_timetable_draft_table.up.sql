@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS timetable_draft (
+	id SERIAL PRIMARY KEY,
+	school_id INT REFERENCES school(id) NOT NULL,
+	created_by UUID REFERENCES users(id) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS timetable_draft_lesson (
+	draft_id INT REFERENCES timetable_draft(id) ON DELETE CASCADE NOT NULL,
+	group_id INT REFERENCES "group"(id) NOT NULL,
+	subject_id INT REFERENCES subject(id) NOT NULL,
+	teacher_id UUID REFERENCES users(id) NOT NULL,
+	room_id INT REFERENCES room(id) NOT NULL,
+	period_id INT REFERENCES period(id) NOT NULL,
+	weekday WEEKDAY NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS timetable_draft_unplaced (
+	draft_id INT REFERENCES timetable_draft(id) ON DELETE CASCADE NOT NULL,
+	group_id INT REFERENCES "group"(id) NOT NULL,
+	subject_id INT REFERENCES subject(id) NOT NULL,
+	teacher_id UUID REFERENCES users(id) NOT NULL,
+	hours INT NOT NULL
+);
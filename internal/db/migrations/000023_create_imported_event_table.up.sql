@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS imported_event (
+	school_id INT REFERENCES school(id) NOT NULL,
+	uid VARCHAR(255) NOT NULL,
+	event_id INT REFERENCES event_timetable(id) ON DELETE CASCADE NOT NULL,
+	PRIMARY KEY (school_id, uid)
+);
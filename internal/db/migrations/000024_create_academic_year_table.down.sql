@@ -0,0 +1,7 @@
+ALTER TABLE regular_timetable
+DROP COLUMN academic_year_id;
+
+DROP TABLE IF EXISTS term;
+DROP FUNCTION IF EXISTS validate_term_within_academic_year;
+DROP TABLE IF EXISTS academic_year;
+DROP FUNCTION IF EXISTS validate_academic_year_contains_terms;
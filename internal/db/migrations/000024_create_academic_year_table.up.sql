@@ -0,0 +1,61 @@
+CREATE TABLE IF NOT EXISTS academic_year (
+	id SERIAL PRIMARY KEY,
+	school_id INT REFERENCES school(id) NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	span DATERANGE NOT NULL,
+	EXCLUDE USING gist (school_id WITH =, span WITH &&)
+);
+
+CREATE TABLE IF NOT EXISTS term (
+	id SERIAL PRIMARY KEY,
+	academic_year_id INT REFERENCES academic_year(id) NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	span DATERANGE NOT NULL,
+	EXCLUDE USING gist (academic_year_id WITH =, span WITH &&)
+);
+
+CREATE OR REPLACE FUNCTION validate_term_within_academic_year()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM academic_year
+        WHERE id = NEW.academic_year_id
+            AND span @> NEW.span
+    ) THEN
+        RAISE EXCEPTION 'Term has to be within its academic year' USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER term_within_academic_year
+        BEFORE INSERT OR UPDATE
+        ON term
+        FOR EACH ROW
+        EXECUTE FUNCTION validate_term_within_academic_year();
+
+CREATE OR REPLACE FUNCTION validate_academic_year_contains_terms()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1
+        FROM term
+        WHERE academic_year_id = NEW.id
+            AND NOT NEW.span @> span
+    ) THEN
+        RAISE EXCEPTION 'Academic year has to contain all of its terms' USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER academic_year_contains_terms
+        BEFORE UPDATE
+        ON academic_year
+        FOR EACH ROW
+        EXECUTE FUNCTION validate_academic_year_contains_terms();
+
+-- regular timetable without academic year is valid forever
+ALTER TABLE regular_timetable
+ADD COLUMN academic_year_id INT REFERENCES academic_year(id);
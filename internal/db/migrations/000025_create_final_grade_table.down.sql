@@ -0,0 +1 @@
+DROP TABLE IF EXISTS final_grade;
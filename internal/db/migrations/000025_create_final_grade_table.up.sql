@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS final_grade (
+	id SERIAL PRIMARY KEY,
+	student_id UUID REFERENCES users(id) NOT NULL,
+	term_id INT REFERENCES term(id) NOT NULL,
+	subject_id INT REFERENCES subject(id) NOT NULL,
+	value SMALLINT NOT NULL CHECK (value >= 1 AND value <= 5),
+	UNIQUE (student_id, term_id, subject_id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS attendance;
+DROP TYPE IF EXISTS attendance_status;
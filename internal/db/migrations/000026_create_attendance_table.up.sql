@@ -0,0 +1,8 @@
+CREATE TYPE attendance_status AS ENUM ('present', 'absent', 'late', 'excused');
+
+CREATE TABLE IF NOT EXISTS attendance (
+	report_id INT REFERENCES report(id) ON DELETE CASCADE NOT NULL,
+	student_id UUID REFERENCES users(id) NOT NULL,
+	status ATTENDANCE_STATUS NOT NULL,
+	PRIMARY KEY (report_id, student_id)
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS absence_excuse;
+DROP TYPE IF EXISTS excuse_status;
+
+ALTER TABLE absence
+DROP COLUMN status;
+
+DROP TYPE IF EXISTS absence_status;
@@ -0,0 +1,24 @@
+CREATE TYPE absence_status AS ENUM ('unexcused', 'pending', 'excused');
+
+ALTER TABLE absence
+ADD COLUMN status ABSENCE_STATUS NOT NULL DEFAULT 'unexcused';
+
+CREATE TYPE excuse_status AS ENUM ('pending', 'approved', 'rejected');
+
+CREATE TABLE IF NOT EXISTS absence_excuse (
+	id SERIAL PRIMARY KEY,
+	absence_id INT REFERENCES absence(id) ON DELETE CASCADE NOT NULL,
+	submitted_by UUID REFERENCES users(id) NOT NULL,
+	submitted_at TIMESTAMP NOT NULL DEFAULT now(),
+	reason TEXT NOT NULL,
+	attachment_name VARCHAR(255),
+	attachment_type VARCHAR(255),
+	attachment BYTEA,
+	status EXCUSE_STATUS NOT NULL DEFAULT 'pending',
+	reviewed_by UUID REFERENCES users(id),
+	reviewed_at TIMESTAMP,
+	review_note TEXT
+);
+
+-- an absence waits for at most one decision at a time
+CREATE UNIQUE INDEX absence_excuse_pending ON absence_excuse (absence_id) WHERE status = 'pending';
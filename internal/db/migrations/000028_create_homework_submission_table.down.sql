@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS homework_submission_file;
+DROP TABLE IF EXISTS homework_submission;
+DROP TYPE IF EXISTS submission_status;
@@ -0,0 +1,26 @@
+CREATE TYPE submission_status AS ENUM ('submitted', 'missing', 'reviewed');
+
+-- note_id can't reference note, foreign keys don't see rows of note_with_date (inheritance),
+-- so submissions are deleted together with their note by the application
+CREATE TABLE IF NOT EXISTS homework_submission (
+	id SERIAL PRIMARY KEY,
+	note_id INT NOT NULL,
+	student_id UUID REFERENCES users(id) NOT NULL,
+	content TEXT,
+	submitted_at TIMESTAMP,
+	status SUBMISSION_STATUS NOT NULL DEFAULT 'submitted',
+	late BOOLEAN NOT NULL DEFAULT false,
+	reviewed_by UUID REFERENCES users(id),
+	reviewed_at TIMESTAMP,
+	review_note TEXT,
+	grade_id INT REFERENCES grade(id) ON DELETE SET NULL,
+	UNIQUE (note_id, student_id)
+);
+
+CREATE TABLE IF NOT EXISTS homework_submission_file (
+	id SERIAL PRIMARY KEY,
+	submission_id INT REFERENCES homework_submission(id) ON DELETE CASCADE NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	content_type VARCHAR(255) NOT NULL,
+	data BYTEA NOT NULL
+);
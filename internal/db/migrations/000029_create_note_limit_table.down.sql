@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS note_limit;
+DROP TYPE IF EXISTS limit_mode;
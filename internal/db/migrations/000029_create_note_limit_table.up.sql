@@ -0,0 +1,10 @@
+CREATE TYPE limit_mode AS ENUM ('warn', 'reject');
+
+-- at most max_per_day dated notes of the type on one day per class (or per group for groups without a class)
+CREATE TABLE IF NOT EXISTS note_limit (
+	school_id INT REFERENCES school(id) ON DELETE CASCADE NOT NULL,
+	type NOTE_TYPE NOT NULL,
+	max_per_day SMALLINT NOT NULL CHECK (max_per_day >= 1),
+	mode LIMIT_MODE NOT NULL DEFAULT 'reject',
+	PRIMARY KEY (school_id, type)
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notification_preference;
+DROP TABLE IF EXISTS notification;
+DROP TYPE IF EXISTS notification_channel;
+DROP TYPE IF EXISTS notification_kind;
@@ -0,0 +1,25 @@
+CREATE TYPE notification_kind AS ENUM ('grade', 'substitution', 'test', 'excuse');
+CREATE TYPE notification_channel AS ENUM ('in_app', 'email');
+
+-- the in-app inbox
+CREATE TABLE IF NOT EXISTS notification (
+	id SERIAL PRIMARY KEY,
+	user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+	kind NOTIFICATION_KIND NOT NULL,
+	title VARCHAR(255) NOT NULL,
+	body TEXT NOT NULL,
+	link VARCHAR(255),
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	read_at TIMESTAMP
+);
+
+CREATE INDEX notification_unread ON notification (user_id) WHERE read_at IS NULL;
+
+-- every channel is enabled for every kind unless the user turned it off
+CREATE TABLE IF NOT EXISTS notification_preference (
+	user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+	kind NOTIFICATION_KIND NOT NULL,
+	channel NOTIFICATION_CHANNEL NOT NULL,
+	enabled BOOLEAN NOT NULL,
+	PRIMARY KEY (user_id, kind, channel)
+);
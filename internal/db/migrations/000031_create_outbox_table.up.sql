@@ -0,0 +1,17 @@
+CREATE TYPE outbox_status AS ENUM ('pending', 'delivered', 'dead');
+
+-- events added in the transaction of the change they are about, the worker delivers them
+-- after the commit. available_at is when the worker may take a pending event (again)
+CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	topic VARCHAR(255) NOT NULL,
+	payload JSONB NOT NULL,
+	status OUTBOX_STATUS NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	available_at TIMESTAMP NOT NULL DEFAULT now(),
+	last_error TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	delivered_at TIMESTAMP
+);
+
+CREATE INDEX outbox_pending ON outbox (available_at) WHERE status = 'pending';
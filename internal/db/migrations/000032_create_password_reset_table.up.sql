@@ -0,0 +1,10 @@
+-- only hashes of reset tokens are stored, a token can be used once before it expires
+CREATE TABLE IF NOT EXISTS password_reset (
+	token_hash CHAR(64) PRIMARY KEY,
+	user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX password_reset_user ON password_reset (user_id);
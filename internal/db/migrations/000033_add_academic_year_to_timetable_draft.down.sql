@@ -0,0 +1,2 @@
+ALTER TABLE timetable_draft
+DROP COLUMN academic_year_id;
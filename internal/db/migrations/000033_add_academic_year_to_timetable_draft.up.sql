@@ -0,0 +1,3 @@
+-- lessons of the draft are committed into the academic year, a draft without one is valid forever
+ALTER TABLE timetable_draft
+ADD COLUMN academic_year_id INT REFERENCES academic_year(id) ON DELETE CASCADE;
@@ -0,0 +1 @@
+ALTER TABLE imported_event ALTER COLUMN uid TYPE VARCHAR(255);
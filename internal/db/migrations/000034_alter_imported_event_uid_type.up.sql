@@ -0,0 +1,2 @@
+-- UIDs have no length limit in RFC 5545, long ones used to fail the whole import
+ALTER TABLE imported_event ALTER COLUMN uid TYPE TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE "group" DROP COLUMN school_id;
+ALTER TABLE class DROP COLUMN school_id;
@@ -0,0 +1,19 @@
+-- classes and groups belonged to the school only through the class teacher and the class,
+-- so the ones without them couldn't be listed, rows which can't be traced to a school stay NULL
+ALTER TABLE class
+ADD COLUMN school_id INT REFERENCES school(id);
+
+UPDATE class c SET school_id = ct.school_id
+FROM users ct
+WHERE ct.id = c.class_teacher_id;
+
+CREATE INDEX idx_class_school_id ON class (school_id);
+
+ALTER TABLE "group"
+ADD COLUMN school_id INT REFERENCES school(id);
+
+UPDATE "group" g SET school_id = c.school_id
+FROM class c
+WHERE c.id = g.class_id;
+
+CREATE INDEX idx_group_school_id ON "group" (school_id);
@@ -2,20 +2,44 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
 	"time"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	AbsenceUnexcused = "unexcused"
+	// AbsencePending absences have an excuse waiting for the class teacher's decision
+	AbsencePending = "pending"
+	AbsenceExcused = "excused"
+)
+
+var absenceStatuses = []string{AbsenceUnexcused, AbsencePending, AbsenceExcused}
+
 type Absence struct {
+	id     int
 	userId uuid.UUID
 	start  time.Time
 	end    time.Time
+	status string
+}
+
+func parseAbsenceStatus(f url.Values) (*string, *utils.ParseError) {
+	status := f.Get("status")
+	if status == "" {
+		return nil, nil
+	} else if !slices.Contains(absenceStatuses, status) {
+		return nil, utils.NewParserError(nil, "Invalid status (has to be unexcused, pending or excused)")
+	}
+	return &status, nil
 }
 
 func ParseAbsence(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
@@ -34,24 +58,245 @@ func ParseAbsence(f url.Values, parserCtx context.Context, handlerCtx *context.C
 	if err != nil {
 		return utils.NewParserError(err, "Invalid end time")
 	}
+	status := AbsenceUnexcused
+	if s, parseErr := parseAbsenceStatus(f); parseErr != nil {
+		return parseErr
+	} else if s != nil {
+		status = *s
+	}
 
 	*handlerCtx = context.WithValue(*handlerCtx, "absence", Absence{
+		id:     -1,
 		userId: userId,
 		start:  start,
 		end:    end,
+		status: status,
 	})
 
 	return nil
 }
 
-func (a Absence) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(context.TODO(),
-		"insert into absence (user_id, span) values ($1, $2)",
+func (a *Absence) SaveToDB(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		"insert into absence (user_id, span, status) values ($1, $2, $3) returning id, user_id, lower(span), upper(span), status",
 		a.userId, fmt.Sprintf(
 			"[%s, %s]",
 			a.start.Format(time.RFC3339),
 			a.end.Format(time.RFC3339),
-		),
+		), a.status,
 	)
+	if err != nil {
+		return err
+	}
+	*a, err = pgx.CollectOneRow(rows, scanAbsence)
+	if err != nil {
+		return err
+	}
+	return reconcileAttendance(tx, a.id)
+}
+
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the user is in another school
+func (a *Absence) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, userRef(a.userId)); err != nil {
+			return err
+		}
+		return a.SaveToDB(tx)
+	}
+}
+
+type AbsenceFilter struct {
+	userId *uuid.UUID
+	from   *time.Time
+	to     *time.Time
+	termId *int
+	status *string
+}
+
+func ParseAbsenceFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing absence filter")
+
+	userId, err := parseOptionalUuid(span, f, "user_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid user id")
+	}
+	from, err := parseOptionalTime(span, f, "from", time.RFC3339)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid from time")
+	}
+	to, err := parseOptionalTime(span, f, "to", time.RFC3339)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid to time")
+	}
+	termId, err := parseOptionalInt(span, f, "term_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+	status, parseErr := parseAbsenceStatus(f)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "absence filter", AbsenceFilter{
+		userId: userId,
+		from:   from,
+		to:     to,
+		termId: termId,
+		status: status,
+	})
+
+	return nil
+}
+
+const absenceSelect = `
+	select a.id, a.user_id, lower(a.span), upper(a.span), a.status
+	from absence a
+	join users u on u.id = a.user_id`
+
+func scanAbsence(row pgx.CollectableRow) (a Absence, err error) {
+	err = row.Scan(&a.id, &a.userId, &a.start, &a.end, &a.status)
 	return
 }
+
+// ListAbsences returns absences overlapping with the from-to interval
+func ListAbsences(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f AbsenceFilter) (Page[Absence], error) {
+	b := listBuilder{}
+	b.where("u.school_id = ?", schoolId)
+	b.where("a.span is not null")
+	if f.userId != nil {
+		b.where("a.user_id = ?", *f.userId)
+	}
+	if f.from != nil {
+		b.where("upper(a.span) >= ?", *f.from)
+	}
+	if f.to != nil {
+		b.where("lower(a.span) <= ?", *f.to)
+	}
+	if f.termId != nil {
+		b.where("a.span && ("+termTimeRange+")", *f.termId)
+	}
+	if f.status != nil {
+		b.where("a.status = ?", *f.status)
+	}
+
+	query, args, err := b.build(absenceSelect, []string{"a.id"}, q)
+	if err != nil {
+		return Page[Absence]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Absence]{}, err
+	}
+
+	return collectPage(rows, q, scanAbsence, func(a Absence) []string {
+		return []string{fmt.Sprint(a.id)}
+	})
+}
+
+func GetAbsence(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Absence, error) {
+	rows, err := db.Query(ctx, absenceSelect+" where u.school_id = $1 and a.id = $2 and a.span is not null", schoolId, id)
+	if err != nil {
+		return Absence{}, err
+	}
+	return pgx.CollectOneRow(rows, scanAbsence)
+}
+
+func (a Absence) Id() int {
+	return a.id
+}
+
+func (a Absence) Span() (time.Time, time.Time) {
+	return a.start, a.end
+}
+
+func (a Absence) Status() string {
+	return a.status
+}
+
+func (a Absence) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id     int       `json:"id"`
+		UserId uuid.UUID `json:"userId"`
+		Start  string    `json:"start"`
+		End    string    `json:"end"`
+		Status string    `json:"status"`
+	}{
+		Id:     a.id,
+		UserId: a.userId,
+		Start:  a.start.Format(time.RFC3339),
+		End:    a.end.Format(time.RFC3339),
+		Status: a.status,
+	})
+}
+
+type AbsenceUpdate struct {
+	start  *time.Time
+	end    *time.Time
+	status *string
+}
+
+func ParseAbsenceUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing absence update")
+
+	start, err := parseOptionalTime(span, f, "start", time.RFC3339)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid start time")
+	}
+	end, err := parseOptionalTime(span, f, "end", time.RFC3339)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid end time")
+	}
+	status, parseErr := parseAbsenceStatus(f)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	if start != nil && end != nil && end.Before(*start) {
+		return utils.NewParserError(nil, "End can't be before start")
+	} else if start == nil && end == nil && status == nil {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "absence update", AbsenceUpdate{
+		start:  start,
+		end:    end,
+		status: status,
+	})
+
+	return nil
+}
+
+func (u AbsenceUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var start, end any //nil keeps the current bound
+		if u.start != nil {
+			start = u.start.Format(time.RFC3339)
+		}
+		if u.end != nil {
+			end = u.end.Format(time.RFC3339)
+		}
+
+		b := updateBuilder{}
+		if u.start != nil || u.end != nil {
+			b.setExpr("span", "tsrange(coalesce(?::timestamp, lower(span)), coalesce(?::timestamp, upper(span)), '[]')", start, end)
+		}
+		if u.status != nil {
+			b.set("status", *u.status)
+		}
+		if err := b.exec(tx, "absence", "id = ? and exists (select 1 from users u where u.id = absence.user_id and u.school_id = ?)", id, schoolId); err != nil {
+			return err
+		}
+		return reconcileAttendance(tx, id)
+	}
+}
+
+func DeleteAbsence(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx,
+			"delete from absence where id = $1 and exists (select 1 from users u where u.id = absence.user_id and u.school_id = $2)",
+			id, schoolId,
+		)
+	}
+}
@@ -0,0 +1,352 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExcusePending  = "pending"
+	ExcuseApproved = "approved"
+	ExcuseRejected = "rejected"
+)
+
+var (
+	ErrAbsenceAlreadyExcused = errors.New("Absence is already excused")
+	ErrExcuseAlreadyReviewed = errors.New("Excuse was already reviewed")
+	ErrNotClassTeacher       = errors.New("Only the class teacher of the student can review the excuse")
+)
+
+// AbsenceExcuse is an explanation of a child's absence a parent submits for the class teacher to review
+type AbsenceExcuse struct {
+	id             int
+	absenceId      int
+	studentId      uuid.UUID
+	submittedBy    uuid.UUID
+	submittedAt    time.Time
+	reason         string
+	attachmentName *string
+	attachmentType *string
+	attachment     []byte
+	status         string
+	reviewedBy     *uuid.UUID
+	reviewedAt     *time.Time
+	reviewNote     *string
+}
+
+func ParseAbsenceExcuse(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing absence excuse")
+
+	reason := strings.TrimSpace(f.Get("reason"))
+	if reason == "" {
+		return utils.NewParserError(nil, "No reason was provided")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "absence excuse", AbsenceExcuse{
+		id:     -1,
+		reason: reason,
+		status: ExcusePending,
+	})
+
+	return nil
+}
+
+// Attach adds a file, e.g. a doctor's note, to the excuse
+func (e *AbsenceExcuse) Attach(name, contentType string, data []byte) {
+	e.attachmentName = &name
+	e.attachmentType = &contentType
+	e.attachment = data
+}
+
+// SubmitForChild saves the excuse of the absence and marks the absence pending. pgx.ErrNoRows means
+// the absence isn't of a child of the parent, a pending excuse of the same absence is a unique violation
+func (e *AbsenceExcuse) SubmitForChild(parentId string, absenceId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var status string
+		err := tx.QueryRow(context.TODO(), `
+			select a.status::text
+			from absence a
+			join parent_child pc on pc.child_id = a.user_id
+			where a.id = $1 and pc.parent_id::text = $2
+			for update of a`,
+			absenceId, parentId,
+		).Scan(&status)
+		if err != nil {
+			return err
+		}
+		if status == AbsenceExcused {
+			return ErrAbsenceAlreadyExcused
+		}
+
+		rows, err := tx.Query(context.TODO(), `
+			with e as (
+				insert into absence_excuse (absence_id, submitted_by, reason, attachment_name, attachment_type, attachment)
+				values ($1, $2, $3, $4, $5, $6)
+				returning *
+			)
+			select `+absenceExcuseColumns+`
+			from e
+			join absence a on a.id = e.absence_id`,
+			absenceId, parentId, e.reason, e.attachmentName, e.attachmentType, e.attachment,
+		)
+		if err != nil {
+			return err
+		}
+		*e, err = pgx.CollectOneRow(rows, scanAbsenceExcuse)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(context.TODO(), "update absence set status = 'pending' where id = $1", absenceId)
+		return err
+	}
+}
+
+type AbsenceExcuseFilter struct {
+	absenceId *int
+	studentId *uuid.UUID
+	status    *string
+}
+
+func ParseAbsenceExcuseFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing absence excuse filter")
+
+	absenceId, err := parseOptionalInt(span, f, "absence_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid absence id")
+	}
+	studentId, err := parseOptionalUuid(span, f, "student_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid student id")
+	}
+	status := optionalString(span, f, "status")
+	if status != nil && *status != ExcusePending && *status != ExcuseApproved && *status != ExcuseRejected {
+		return utils.NewParserError(nil, "Invalid status (has to be pending, approved or rejected)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "absence excuse filter", AbsenceExcuseFilter{
+		absenceId: absenceId,
+		studentId: studentId,
+		status:    status,
+	})
+
+	return nil
+}
+
+const absenceExcuseColumns = `
+	e.id, e.absence_id, a.user_id, e.submitted_by, e.submitted_at, e.reason, e.attachment_name,
+	e.attachment_type, e.status::text, e.reviewed_by, e.reviewed_at, e.review_note`
+
+const absenceExcuseSelect = `
+	select ` + absenceExcuseColumns + `
+	from absence_excuse e
+	join absence a on a.id = e.absence_id
+	join users s on s.id = a.user_id`
+
+func scanAbsenceExcuse(row pgx.CollectableRow) (e AbsenceExcuse, err error) {
+	err = row.Scan(
+		&e.id, &e.absenceId, &e.studentId, &e.submittedBy, &e.submittedAt, &e.reason, &e.attachmentName,
+		&e.attachmentType, &e.status, &e.reviewedBy, &e.reviewedAt, &e.reviewNote,
+	)
+	return
+}
+
+// studentRecordVisibleTo limits records of students (aliased s) to the ones the user can see: staff
+// see records of every student of the school, parents the ones of their children and students their own
+func studentRecordVisibleTo(b *listBuilder, schoolId int, userId string, role utils.Role) {
+	b.where("s.school_id = ?", schoolId)
+	b.where(`(
+		?::text in ('admin', 'teacher')
+		or (?::text = 'student' and s.id::text = ?)
+		or (?::text = 'parent' and exists (
+			select 1 from parent_child pc where pc.parent_id::text = ? and pc.child_id = s.id
+		))
+	)`, string(role), string(role), userId, string(role), userId)
+}
+
+func ListAbsenceExcuses(ctx context.Context, db *pgxpool.Pool, schoolId int, userId string, role utils.Role, q ListQuery, f AbsenceExcuseFilter) (Page[AbsenceExcuse], error) {
+	b := listBuilder{}
+	studentRecordVisibleTo(&b, schoolId, userId, role)
+	if f.absenceId != nil {
+		b.where("e.absence_id = ?", *f.absenceId)
+	}
+	if f.studentId != nil {
+		b.where("a.user_id = ?", *f.studentId)
+	}
+	if f.status != nil {
+		b.where("e.status = ?", *f.status)
+	}
+
+	query, args, err := b.build(absenceExcuseSelect, []string{"e.id"}, q)
+	if err != nil {
+		return Page[AbsenceExcuse]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[AbsenceExcuse]{}, err
+	}
+
+	return collectPage(rows, q, scanAbsenceExcuse, func(e AbsenceExcuse) []string {
+		return []string{fmt.Sprint(e.id)}
+	})
+}
+
+func GetAbsenceExcuse(ctx context.Context, db *pgxpool.Pool, schoolId int, userId string, role utils.Role, id int) (AbsenceExcuse, error) {
+	b := listBuilder{}
+	studentRecordVisibleTo(&b, schoolId, userId, role)
+	b.where("e.id = ?", id)
+
+	rows, err := db.Query(ctx, absenceExcuseSelect+" where "+strings.Join(b.conds, " and "), b.args...)
+	if err != nil {
+		return AbsenceExcuse{}, err
+	}
+	return pgx.CollectOneRow(rows, scanAbsenceExcuse)
+}
+
+// GetAbsenceExcuseAttachment returns the excuse with its attachment, which is left out
+// everywhere else, pgx.ErrNoRows means the excuse isn't visible or has no attachment
+func GetAbsenceExcuseAttachment(ctx context.Context, db *pgxpool.Pool, schoolId int, userId string, role utils.Role, id int) (AbsenceExcuse, error) {
+	excuse, err := GetAbsenceExcuse(ctx, db, schoolId, userId, role, id)
+	if err != nil {
+		return AbsenceExcuse{}, err
+	}
+	err = db.QueryRow(ctx,
+		"select attachment from absence_excuse where id = $1 and attachment is not null", id,
+	).Scan(&excuse.attachment)
+	return excuse, err
+}
+
+func (e AbsenceExcuse) Id() int {
+	return e.id
+}
+
+func (e AbsenceExcuse) Attachment() (name, contentType string, data []byte) {
+	if e.attachmentName != nil {
+		name = *e.attachmentName
+	}
+	if e.attachmentType != nil {
+		contentType = *e.attachmentType
+	}
+	return name, contentType, e.attachment
+}
+
+func (e AbsenceExcuse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id             int        `json:"id"`
+		AbsenceId      int        `json:"absenceId"`
+		StudentId      uuid.UUID  `json:"studentId"`
+		SubmittedBy    uuid.UUID  `json:"submittedBy"`
+		SubmittedAt    time.Time  `json:"submittedAt"`
+		Reason         string     `json:"reason"`
+		AttachmentName *string    `json:"attachmentName"`
+		AttachmentType *string    `json:"attachmentType"`
+		Status         string     `json:"status"`
+		ReviewedBy     *uuid.UUID `json:"reviewedBy"`
+		ReviewedAt     *time.Time `json:"reviewedAt"`
+		ReviewNote     *string    `json:"reviewNote"`
+	}{
+		Id:             e.id,
+		AbsenceId:      e.absenceId,
+		StudentId:      e.studentId,
+		SubmittedBy:    e.submittedBy,
+		SubmittedAt:    e.submittedAt,
+		Reason:         e.reason,
+		AttachmentName: e.attachmentName,
+		AttachmentType: e.attachmentType,
+		Status:         e.status,
+		ReviewedBy:     e.reviewedBy,
+		ReviewedAt:     e.reviewedAt,
+		ReviewNote:     e.reviewNote,
+	})
+}
+
+// ExcuseReview is the class teacher's decision about a pending excuse
+type ExcuseReview struct {
+	status string
+	note   *string
+}
+
+func ParseExcuseReview(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing excuse review")
+
+	status := f.Get("status")
+	if status != ExcuseApproved && status != ExcuseRejected {
+		return utils.NewParserError(nil, "Invalid status (has to be approved or rejected)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "excuse review", ExcuseReview{
+		status: status,
+		note:   optionalString(span, f, "note"),
+	})
+
+	return nil
+}
+
+// ReviewInDB decides the excuse, the absence becomes excused when it is approved and unexcused
+// when it is rejected, attendance of lessons during the absence follows. Only the class teacher
+// of the student or an admin can review, pgx.ErrNoRows means the excuse isn't in the school
+func (r ExcuseReview) ReviewInDB(schoolId int, reviewerId string, role utils.Role, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var absenceId int
+		var status string
+		var classTeacher bool
+		err := tx.QueryRow(context.TODO(), `
+			select e.absence_id, e.status::text, exists (
+				select 1
+				from users_group ug
+				join "group" g on g.id = ug.group_id
+				join class c on c.id = g.class_id
+				where ug.user_id = a.user_id and c.class_teacher_id::text = $3
+			)
+			from absence_excuse e
+			join absence a on a.id = e.absence_id
+			join users s on s.id = a.user_id
+			where e.id = $1 and s.school_id = $2
+			for update of e, a`,
+			id, schoolId, reviewerId,
+		).Scan(&absenceId, &status, &classTeacher)
+		if err != nil {
+			return err
+		}
+		if role != utils.RoleAdmin && !classTeacher {
+			return ErrNotClassTeacher
+		}
+		if status != ExcusePending {
+			return ErrExcuseAlreadyReviewed
+		}
+
+		_, err = tx.Exec(context.TODO(),
+			"update absence_excuse set status = $1, reviewed_by = $2, reviewed_at = now(), review_note = $3 where id = $4",
+			r.status, reviewerId, r.note, id,
+		)
+		if err != nil {
+			return err
+		}
+
+		absenceStatus := AbsenceUnexcused
+		if r.status == ExcuseApproved {
+			absenceStatus = AbsenceExcused
+		}
+		if _, err := tx.Exec(context.TODO(), "update absence set status = $1 where id = $2", absenceStatus, absenceId); err != nil {
+			return err
+		}
+		if err := notifyOf(NotificationExcuse, id)(tx); err != nil {
+			return err
+		}
+		return reconcileAttendance(tx, absenceId)
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AbsenceTotals sums absences of every absent user of the school during the term.
+// Like other reports its fields are exported
+type AbsenceTotals struct {
+	From   string         `json:"from"`
+	To     string         `json:"to"`
+	Totals []AbsenceTotal `json:"totals"`
+}
+
+type AbsenceTotal struct {
+	UserId string `json:"userId"`
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	// Hours only count the part of absences within the term, the rest splits them by status
+	Hours          float64 `json:"hours"`
+	ExcusedHours   float64 `json:"excusedHours"`
+	PendingHours   float64 `json:"pendingHours"`
+	UnexcusedHours float64 `json:"unexcusedHours"`
+}
+
+// AbsenceTotalsQuery selects the term the same way GradebookQuery does
+type AbsenceTotalsQuery struct {
+	userId *uuid.UUID
+	termId *int
+	from   *time.Time
+	to     *time.Time
+}
+
+func ParseAbsenceTotalsQuery(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing absence totals query")
+
+	userId, err := parseOptionalUuid(span, f, "user_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid user id")
+	}
+	termId, err := parseOptionalInt(span, f, "term_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+	from, err := parseOptionalTime(span, f, "from", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid from date")
+	}
+	to, err := parseOptionalTime(span, f, "to", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid to date")
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return utils.NewParserError(nil, "To can't be before from")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "absence totals query", AbsenceTotalsQuery{
+		userId: userId,
+		termId: termId,
+		from:   from,
+		to:     to,
+	})
+
+	return nil
+}
+
+func (q AbsenceTotalsQuery) Totals(ctx context.Context, db *pgxpool.Pool, schoolId int) (AbsenceTotals, error) {
+	from, to, err := termWindow(ctx, db, schoolId, q.termId, q.from, q.to)
+	if err != nil {
+		return AbsenceTotals{}, err
+	}
+
+	rows, err := db.Query(ctx, `
+		select
+			u.id::text, u.name || ' ' || u.surname, count(*),
+			sum(a.hours),
+			coalesce(sum(a.hours) filter (where a.status = 'excused'), 0),
+			coalesce(sum(a.hours) filter (where a.status = 'pending'), 0),
+			coalesce(sum(a.hours) filter (where a.status = 'unexcused'), 0)
+		from (
+			select a.*, extract(epoch from upper(a.span * w.span) - lower(a.span * w.span)) / 3600 as hours
+			from absence a
+			cross join (select tsrange($2::date, $3::date + 1) as span) w
+			where a.span && w.span
+		) a
+		join users u on u.id = a.user_id
+		where u.school_id = $1 and ($4::uuid is null or a.user_id = $4)
+		group by u.id, u.name, u.surname
+		order by u.surname, u.name, u.id`,
+		schoolId, from.Format(time.DateOnly), to.Format(time.DateOnly), q.userId,
+	)
+	if err != nil {
+		return AbsenceTotals{}, err
+	}
+	totals, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (t AbsenceTotal, err error) {
+		err = row.Scan(&t.UserId, &t.Name, &t.Count, &t.Hours, &t.ExcusedHours, &t.PendingHours, &t.UnexcusedHours)
+		t.Hours = round2(t.Hours)
+		t.ExcusedHours = round2(t.ExcusedHours)
+		t.PendingHours = round2(t.PendingHours)
+		t.UnexcusedHours = round2(t.UnexcusedHours)
+		return
+	})
+	if err != nil {
+		return AbsenceTotals{}, err
+	}
+
+	return AbsenceTotals{
+		From:   from.Format(time.DateOnly),
+		To:     to.Format(time.DateOnly),
+		Totals: totals,
+	}, nil
+}
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrAcademicYearNotFound = errors.New("Academic year doesn't exist")
+
+type AcademicYear struct {
+	id       int
+	schoolId int
+	name     string
+	start    time.Time
+	end      time.Time
+}
+
+// parseDateSpan parses start and end dates, both of them are part of the span
+func parseDateSpan(span trace.Span, f url.Values) (time.Time, time.Time, *utils.ParseError) {
+	start, err := utils.ParseTime(span, "start", f.Get("start"), time.DateOnly)
+	if err != nil {
+		return time.Time{}, time.Time{}, utils.NewParserError(err, "Invalid start date")
+	}
+	end, err := utils.ParseTime(span, "end", f.Get("end"), time.DateOnly)
+	if err != nil {
+		return time.Time{}, time.Time{}, utils.NewParserError(err, "Invalid end date")
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, utils.NewParserError(nil, "End can't be before start")
+	}
+	return start, end, nil
+}
+
+func dateSpan(start, end time.Time) string {
+	return fmt.Sprintf("[%s, %s]", start.Format(time.DateOnly), end.Format(time.DateOnly))
+}
+
+func ParseAcademicYear(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing academic year")
+
+	name := f.Get("name")
+	span.SetAttributes(attribute.String("name", name))
+	if name == "" {
+		return utils.NewParserError(nil, "Name not provided")
+	}
+	start, end, parseErr := parseDateSpan(span, f)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "academic year", AcademicYear{
+		id:       -1,
+		schoolId: -1,
+		name:     name,
+		start:    start,
+		end:      end,
+	})
+
+	return nil
+}
+
+func (y *AcademicYear) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		rows, err := tx.Query(context.TODO(),
+			"insert into academic_year (school_id, name, span) values ($1, $2, $3) returning id, school_id, name, lower(span), upper(span) - 1",
+			schoolId, y.name, dateSpan(y.start, y.end),
+		)
+		if err != nil {
+			return err
+		}
+		*y, err = pgx.CollectOneRow(rows, scanAcademicYear)
+		return err
+	}
+}
+
+const academicYearSelect = "select y.id, y.school_id, y.name, lower(y.span), upper(y.span) - 1 from academic_year y"
+
+// checkAcademicYearInSchool is used by records referencing academic years, the foreign key alone
+// would let them reference years of other schools
+func checkAcademicYearInSchool(tx pgx.Tx, schoolId, id int) error {
+	var exists bool
+	err := tx.QueryRow(context.TODO(),
+		"select exists (select 1 from academic_year where id = $1 and school_id = $2)", id, schoolId,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	} else if !exists {
+		return ErrAcademicYearNotFound
+	}
+	return nil
+}
+
+func scanAcademicYear(row pgx.CollectableRow) (y AcademicYear, err error) {
+	err = row.Scan(&y.id, &y.schoolId, &y.name, &y.start, &y.end)
+	return
+}
+
+func ListAcademicYears(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery) (Page[AcademicYear], error) {
+	b := listBuilder{}
+	b.where("y.school_id = ?", schoolId)
+
+	query, args, err := b.build(academicYearSelect, []string{"y.id"}, q)
+	if err != nil {
+		return Page[AcademicYear]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[AcademicYear]{}, err
+	}
+
+	return collectPage(rows, q, scanAcademicYear, func(y AcademicYear) []string {
+		return []string{fmt.Sprint(y.id)}
+	})
+}
+
+func GetAcademicYear(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (AcademicYear, error) {
+	rows, err := db.Query(ctx, academicYearSelect+" where y.school_id = $1 and y.id = $2", schoolId, id)
+	if err != nil {
+		return AcademicYear{}, err
+	}
+	return pgx.CollectOneRow(rows, scanAcademicYear)
+}
+
+// currentAcademicYear returns the first and the last day of the academic year containing the day,
+// schools which haven't set up their academic years get defaultAcademicYear
+func currentAcademicYear(ctx context.Context, db querier, schoolId int, day time.Time) (time.Time, time.Time, error) {
+	rows, err := db.Query(ctx,
+		"select lower(span), upper(span) - 1 from academic_year where school_id = $1 and span @> $2::date",
+		schoolId, day.Format(time.DateOnly),
+	)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, to, err := collectDateSpan(rows)
+	if errors.Is(err, pgx.ErrNoRows) {
+		from, to = defaultAcademicYear(day)
+		return from, to, nil
+	}
+	return from, to, err
+}
+
+// collectDateSpan reads the first and the last day of the span from the row
+func collectDateSpan(rows pgx.Rows) (time.Time, time.Time, error) {
+	span, err := pgx.CollectOneRow(rows, func(row pgx.CollectableRow) (span [2]time.Time, err error) {
+		err = row.Scan(&span[0], &span[1])
+		return
+	})
+	return span[0], span[1], err
+}
+
+// defaultAcademicYear is the academic year containing the day when the school has none set up,
+// it runs from September to June and summer holidays belong to the upcoming one
+func defaultAcademicYear(day time.Time) (time.Time, time.Time) {
+	year := day.Year()
+	if day.Month() < time.July {
+		year--
+	}
+	return time.Date(year, time.September, 1, 0, 0, 0, 0, time.UTC), time.Date(year+1, time.June, 30, 0, 0, 0, 0, time.UTC)
+}
+
+func (y AcademicYear) Id() int {
+	return y.id
+}
+
+func (y AcademicYear) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id       int    `json:"id"`
+		SchoolId int    `json:"schoolId"`
+		Name     string `json:"name"`
+		Start    string `json:"start"`
+		End      string `json:"end"`
+	}{
+		Id:       y.id,
+		SchoolId: y.schoolId,
+		Name:     y.name,
+		Start:    y.start.Format(time.DateOnly),
+		End:      y.end.Format(time.DateOnly),
+	})
+}
+
+// dateSpanUpdate changes name and bounds of academic years and terms
+type dateSpanUpdate struct {
+	name  *string
+	start *time.Time
+	end   *time.Time
+}
+
+func parseDateSpanUpdate(span trace.Span, f url.Values) (dateSpanUpdate, *utils.ParseError) {
+	start, err := parseOptionalTime(span, f, "start", time.DateOnly)
+	if err != nil {
+		return dateSpanUpdate{}, utils.NewParserError(err, "Invalid start date")
+	}
+	end, err := parseOptionalTime(span, f, "end", time.DateOnly)
+	if err != nil {
+		return dateSpanUpdate{}, utils.NewParserError(err, "Invalid end date")
+	}
+	if start != nil && end != nil && end.Before(*start) {
+		return dateSpanUpdate{}, utils.NewParserError(nil, "End can't be before start")
+	}
+
+	update := dateSpanUpdate{
+		name:  optionalString(span, f, "name"),
+		start: start,
+		end:   end,
+	}
+	if update.name != nil && *update.name == "" {
+		return dateSpanUpdate{}, utils.NewParserError(nil, "Name can't be empty")
+	} else if update == (dateSpanUpdate{}) {
+		return dateSpanUpdate{}, nothingToUpdate()
+	}
+	return update, nil
+}
+
+func ParseAcademicYearUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing academic year update")
+
+	update, parseErr := parseDateSpanUpdate(span, f)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "academic year update", AcademicYearUpdate{update})
+
+	return nil
+}
+
+func (u dateSpanUpdate) apply(b *updateBuilder) {
+	if u.name != nil {
+		b.set("name", *u.name)
+	}
+	if u.start != nil || u.end != nil {
+		var start, end any //nil keeps the current bound
+		if u.start != nil {
+			start = u.start.Format(time.DateOnly)
+		}
+		if u.end != nil {
+			end = u.end.Format(time.DateOnly)
+		}
+		b.setExpr("span", "daterange(coalesce(?::date, lower(span)), coalesce(?::date, upper(span) - 1), '[]')", start, end)
+	}
+}
+
+type AcademicYearUpdate struct {
+	dateSpanUpdate
+}
+
+func (u AcademicYearUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		u.apply(&b)
+		return b.exec(tx, "academic_year", "id = ? and school_id = ?", id, schoolId)
+	}
+}
+
+func DeleteAcademicYear(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, "delete from academic_year where id = $1 and school_id = $2", id, schoolId)
+	}
+}
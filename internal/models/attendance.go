@@ -0,0 +1,215 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	AttendancePresent = "present"
+	AttendanceAbsent  = "absent"
+	AttendanceLate    = "late"
+	AttendanceExcused = "excused"
+)
+
+var ErrStudentNotInLesson = errors.New("Student isn't in any group of the lesson")
+
+// reportLessonSpan is the time of the lesson a report (aliased r) was filed for. Regular
+// lessons take place on the day of the report, substitute ones on their date
+const reportLessonSpan = `
+	select coalesce(e.span, tsrange(
+		coalesce(st.date, r.reported_at::date) + lower(p.span),
+		coalesce(st.date, r.reported_at::date) + upper(p.span)
+	))
+	from timetable t
+	left join academic_timetable at on at.id = t.id
+	left join period p on p.id = at.period_id
+	left join substitute_timetable st on st.id = t.id
+	left join event_timetable e on e.id = t.id
+	where t.id = r.timetable_id`
+
+// Attendance are the marks a teacher gives students of the lesson when filing its report.
+// Student ids come in lists named after the status (present, absent, late, excused),
+// students who aren't in any of them were present
+type Attendance struct {
+	marks map[uuid.UUID]string
+}
+
+func ParseAttendance(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing attendance")
+
+	attendance := Attendance{marks: map[uuid.UUID]string{}}
+	for _, status := range []string{AttendancePresent, AttendanceAbsent, AttendanceLate, AttendanceExcused} {
+		for _, v := range f[status] {
+			studentId, err := utils.ParseUuid(span, status, v)
+			if err != nil {
+				return utils.NewParserError(err, "Invalid student id")
+			}
+			if _, ok := attendance.marks[studentId]; ok {
+				return utils.NewParserError(nil, "Student can't have more than one status")
+			}
+			attendance.marks[studentId] = status
+		}
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "attendance", attendance)
+
+	return nil
+}
+
+// SaveForReport replaces the attendance of the report with the marks. It is reconciled
+// with absences overlapping with the lesson: unless the teacher saw the student in the lesson,
+// the student is excused when the absence is and absent otherwise. pgx.ErrNoRows means
+// the report isn't in the school, ErrStudentNotInLesson that a marked student isn't in the lesson's groups
+func (a Attendance) SaveForReport(schoolId, reportId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		ctx := context.TODO()
+		if err := checkReportInSchool(ctx, tx, schoolId, reportId); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `
+			select distinct s.id, (
+				select max(a.status)::text from absence a where a.user_id = s.id and a.span && (`+reportLessonSpan+`)
+			)
+			from report r
+			join timetable_group tg on tg.timetable_id = r.timetable_id
+			join users_group ug on ug.group_id = tg.group_id
+			join users s on s.id = ug.user_id
+			where r.id = $1 and s.role = 'student'`,
+			reportId,
+		)
+		if err != nil {
+			return err
+		}
+		type lessonStudent struct {
+			id uuid.UUID
+			//absence is the most excused status of the student's absences during the lesson
+			absence *string
+		}
+		students, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (s lessonStudent, err error) {
+			err = row.Scan(&s.id, &s.absence)
+			return
+		})
+		if err != nil {
+			return err
+		}
+
+		inLesson := map[uuid.UUID]bool{}
+		for _, s := range students {
+			inLesson[s.id] = true
+		}
+		for studentId := range a.marks {
+			if !inLesson[studentId] {
+				return ErrStudentNotInLesson
+			}
+		}
+
+		batch := &pgx.Batch{}
+		batch.Queue("delete from attendance where report_id = $1", reportId)
+		for _, s := range students {
+			status, marked := a.marks[s.id]
+			switch {
+			case s.absence == nil && !marked:
+				status = AttendancePresent
+			case s.absence == nil:
+				//without an absence the teacher's mark stands
+			case *s.absence == AbsenceExcused && (!marked || status == AttendanceAbsent):
+				status = AttendanceExcused
+			case *s.absence != AbsenceExcused && (!marked || status == AttendanceExcused):
+				status = AttendanceAbsent
+			}
+			batch.Queue(
+				"insert into attendance (report_id, student_id, status) values ($1, $2, $3)",
+				reportId, s.id, status,
+			)
+		}
+		return tx.SendBatch(ctx, batch).Close()
+	}
+}
+
+func checkReportInSchool(ctx context.Context, db querier, schoolId, reportId int) error {
+	rows, err := db.Query(ctx,
+		"select r.id from report r join timetable t on t.id = r.timetable_id where r.id = $1 and t.school_id = $2",
+		reportId, schoolId,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = pgx.CollectOneRow(rows, pgx.RowTo[int])
+	return err
+}
+
+// AttendanceMark is the attendance of a student in the lesson of a report
+type AttendanceMark struct {
+	StudentId string `json:"studentId"`
+	Student   string `json:"student"`
+	// Status is nil for students who joined the lesson's groups after the report was filed
+	Status *string `json:"status"`
+	// AbsenceId is an absence of the student overlapping with the lesson
+	AbsenceId     *int    `json:"absenceId"`
+	AbsenceStatus *string `json:"absenceStatus"`
+}
+
+// GetReportAttendance lists students of the lesson's groups together with students
+// who were marked before they left them, pgx.ErrNoRows means the report isn't in the school
+func GetReportAttendance(ctx context.Context, db querier, schoolId, reportId int) ([]AttendanceMark, error) {
+	if err := checkReportInSchool(ctx, db, schoolId, reportId); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		with students as (
+			select ug.user_id
+			from report r
+			join timetable_group tg on tg.timetable_id = r.timetable_id
+			join users_group ug on ug.group_id = tg.group_id
+			where r.id = $1
+			union
+			select student_id from attendance where report_id = $1
+		)
+		select s.id::text, s.name || ' ' || s.surname, att.status::text, a.id, a.status::text
+		from report r
+		cross join students
+		join users s on s.id = students.user_id
+		left join attendance att on att.report_id = r.id and att.student_id = s.id
+		left join lateral (
+			select a.id, a.status from absence a
+			where a.user_id = s.id and a.span && (`+reportLessonSpan+`)
+			order by a.status desc, a.id
+			limit 1
+		) a on true
+		where r.id = $1 and (s.role = 'student' or att.status is not null)
+		order by s.surname, s.name, s.id::text`,
+		reportId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (m AttendanceMark, err error) {
+		err = row.Scan(&m.StudentId, &m.Student, &m.Status, &m.AbsenceId, &m.AbsenceStatus)
+		return
+	})
+}
+
+// reconcileAttendance makes marks of missed lessons which overlap with the absence follow
+// its status, so absences reported or excused after the lesson reconcile with its attendance.
+// Pending absences leave the marks as they are until the excuse is decided
+func reconcileAttendance(tx pgx.Tx, absenceId int) error {
+	_, err := tx.Exec(context.TODO(), `
+		update attendance att
+		set status = case when a.status = 'excused' then 'excused' else 'absent' end::attendance_status
+		from report r, absence a
+		where r.id = att.report_id and a.id = $1 and att.student_id = a.user_id and a.status <> 'pending'
+			and att.status in ('absent', 'excused') and a.span && (`+reportLessonSpan+`)`,
+		absenceId,
+	)
+	return err
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Calendar is the personal timetable of a user over the school year as iCalendar events
+type Calendar struct {
+	Name   string
+	Events []utils.ICalEvent
+}
+
+// ResetCalendarToken gives the user a new calendar token, the feed under the old one stops working
+func ResetCalendarToken(userId string, token *string) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		*token = hex.EncodeToString(b)
+
+		return execAffectingRow(tx, "update users set calendar_token = $1 where id = $2", *token, userId)
+	}
+}
+
+// GetCalendar builds the calendar of the token owner for the academic year the day belongs to.
+// Lessons come from the effective timetable (see ResolveWeekTimetable), so they are expanded
+// for every week with substitutions and cancellations, together with events and
+// dated homework and tests of the lessons
+func GetCalendar(ctx context.Context, db *pgxpool.Pool, token string, day time.Time) (Calendar, error) {
+	span := trace.SpanFromContext(ctx)
+
+	var userId, name string
+	var role utils.Role
+	var schoolId int
+	err := db.QueryRow(ctx,
+		"select id::text, name || ' ' || surname, role, school_id from users where calendar_token = $1",
+		token,
+	).Scan(&userId, &name, &role, &schoolId)
+	if err != nil {
+		return Calendar{}, err
+	}
+	span.SetAttributes(attribute.String("user_id", userId))
+
+	f, err := PersonalWeekTimetableFilter(ctx, db, userId, role)
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	from, to, err := currentAcademicYear(ctx, db, schoolId, day)
+	if err != nil {
+		return Calendar{}, err
+	}
+	first := weekStart(from)
+	last := weekStart(to).AddDate(0, 0, len(Weekdays)-1)
+
+	periods, err := loadWeekPeriods(ctx, db, schoolId)
+	if err != nil {
+		return Calendar{}, err
+	}
+	lessons, err := loadWeekLessons(ctx, db, schoolId, first, last)
+	if err != nil {
+		return Calendar{}, err
+	}
+	events, err := loadWeekEvents(ctx, db, schoolId, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	calendar := Calendar{Name: fmt.Sprintf("Timetable of %s", name), Events: []utils.ICalEvent{}}
+	for monday := first; !monday.After(last); monday = monday.AddDate(0, 0, 7) {
+		week := resolveWeek(monday, periods, lessons, events, f)
+		for d, weekDay := range week.Days {
+			date := monday.AddDate(0, 0, d)
+			if date.Before(from) || date.After(to) {
+				continue
+			}
+			for slot, p := range periods {
+				start, end := periodOnDate(p, date)
+				for _, l := range weekDay.Slots[slot] {
+					calendar.Events = append(calendar.Events, lessonICalEvent(l, start, end))
+				}
+			}
+		}
+	}
+
+	for _, e := range events {
+		if !f.matchesEvent(e) {
+			continue
+		}
+		calendar.Events = append(calendar.Events, utils.ICalEvent{
+			UID:         utils.ICalUID("event", e.Id),
+			Start:       e.start,
+			End:         e.end,
+			Summary:     e.Name,
+			Description: e.Description,
+		})
+	}
+
+	noteEvents, err := loadNoteICalEvents(ctx, db, schoolId, from, to, lessons, f)
+	if err != nil {
+		return Calendar{}, err
+	}
+	calendar.Events = append(calendar.Events, noteEvents...)
+
+	return calendar, nil
+}
+
+func lessonICalEvent(l WeekLesson, start, end time.Time) utils.ICalEvent {
+	teachers := make([]string, len(l.Teachers))
+	for i, t := range l.Teachers {
+		teachers[i] = t.Name
+	}
+	summary := l.Subject
+	if l.Substitute {
+		summary += " (substitution)"
+	}
+
+	return utils.ICalEvent{
+		//the date makes occurrences of a regular lesson distinct
+		UID:         utils.ICalUID("lesson", l.TimetableId, start.Format("20060102")),
+		Start:       start,
+		End:         end,
+		Summary:     summary,
+		Description: strings.Join(teachers, ", "),
+		Location:    l.Room,
+		Cancelled:   l.Cancelled,
+	}
+}
+
+// loadNoteICalEvents returns dated notes of the lessons matching the filter as all day events
+func loadNoteICalEvents(ctx context.Context, db *pgxpool.Pool, schoolId int, from, to time.Time, lessons []weekLesson, f WeekTimetableFilter) ([]utils.ICalEvent, error) {
+	rows, err := db.Query(ctx, `
+		select n.id, n.timetable_id, n.type, n.content, n.date
+		from note_with_date n
+		join timetable t on t.id = n.timetable_id
+		where t.school_id = $1 and n.date between $2 and $3
+		order by n.date, n.id`,
+		schoolId, from.Format(time.DateOnly), to.Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	noteEvents := []utils.ICalEvent{}
+	var id, timetableId int
+	var noteType, content string
+	var date time.Time
+	_, err = pgx.ForEachRow(rows, []any{&id, &timetableId, &noteType, &content, &date}, func() error {
+		for _, l := range lessons {
+			if l.TimetableId != timetableId || !f.matchesLesson(l) {
+				continue
+			}
+			noteEvents = append(noteEvents, utils.ICalEvent{
+				UID:         utils.ICalUID("note", id),
+				Start:       date,
+				End:         date.AddDate(0, 0, 1),
+				AllDay:      true,
+				Summary:     fmt.Sprintf("%s%s: %s", strings.ToUpper(noteType[:1]), noteType[1:], l.Subject),
+				Description: content,
+			})
+			break
+		}
+		return nil
+	})
+	return noteEvents, err
+}
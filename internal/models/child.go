@@ -0,0 +1,222 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Child is a student linked to the parent through parent_child. It can only be obtained
+// through LinkedChild or LinkedChildren, so queries of the parent portal, which are its methods,
+// can't be asked about children of somebody else
+type Child struct {
+	id       uuid.UUID
+	name     string
+	schoolId int
+}
+
+const childSelect = `
+	select c.id, c.name || ' ' || c.surname, c.school_id
+	from parent_child pc
+	join users c on c.id = pc.child_id
+	where pc.parent_id::text = $1 and c.school_id = $2`
+
+func scanChild(row pgx.CollectableRow) (c Child, err error) {
+	err = row.Scan(&c.id, &c.name, &c.schoolId)
+	return
+}
+
+func LinkedChildren(ctx context.Context, db *pgxpool.Pool, schoolId int, parentId string) ([]Child, error) {
+	rows, err := db.Query(ctx, childSelect+" order by c.name, c.surname, c.id", parentId, schoolId)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanChild)
+}
+
+// LinkedChild returns the child of the parent, pgx.ErrNoRows means they aren't linked
+func LinkedChild(ctx context.Context, db *pgxpool.Pool, schoolId int, parentId string, childId uuid.UUID) (Child, error) {
+	rows, err := db.Query(ctx, childSelect+" and c.id = $3", parentId, schoolId, childId)
+	if err != nil {
+		return Child{}, err
+	}
+	return pgx.CollectOneRow(rows, scanChild)
+}
+
+func (c Child) Id() uuid.UUID {
+	return c.id
+}
+
+func (c Child) Name() string {
+	return c.name
+}
+
+func (c Child) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id   uuid.UUID `json:"id"`
+		Name string    `json:"name"`
+	}{
+		Id:   c.id,
+		Name: c.name,
+	})
+}
+
+// ChildQuery selects the days of the term the same way GradebookQuery does
+type ChildQuery struct {
+	from   *time.Time
+	to     *time.Time
+	termId *int
+}
+
+func ParseChildQuery(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing child query")
+
+	from, err := parseOptionalTime(span, f, "from", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid from date")
+	}
+	to, err := parseOptionalTime(span, f, "to", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid to date")
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return utils.NewParserError(nil, "To can't be before from")
+	}
+	termId, err := parseOptionalInt(span, f, "term_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "child query", ChildQuery{
+		from:   from,
+		to:     to,
+		termId: termId,
+	})
+
+	return nil
+}
+
+// NewChildQuery selects the days from-to
+func NewChildQuery(from, to time.Time) ChildQuery {
+	return ChildQuery{from: &from, to: &to}
+}
+
+// WeekTimetable is the timetable of the child's groups in the week of the day
+func (c Child) WeekTimetable(ctx context.Context, db *pgxpool.Pool, day time.Time) (WeekTimetable, error) {
+	filter, err := groupsFilter(ctx, db, "select group_id from users_group where user_id = $1", c.id)
+	if err != nil {
+		return WeekTimetable{}, err
+	}
+	return ResolveWeekTimetable(ctx, db, c.schoolId, filter, day)
+}
+
+func (c Child) Gradebook(ctx context.Context, db *pgxpool.Pool, q ChildQuery) (StudentGradebook, error) {
+	return GradebookQuery{from: q.from, to: q.to, termId: q.termId}.StudentGradebook(ctx, db, c.schoolId, c.id)
+}
+
+// ChildNote is a homework or a test in a lesson of the child
+type ChildNote struct {
+	Id          int    `json:"id"`
+	TimetableId int    `json:"timetableId"`
+	Subject     string `json:"subject"`
+	Type        string `json:"type"`
+	Content     string `json:"content"`
+	Date        string `json:"date"`
+}
+
+// studentLessons are timetables of the groups of the student $1
+const studentLessons = `
+	select tg.timetable_id
+	from timetable_group tg
+	join users_group ug on ug.group_id = tg.group_id
+	where ug.user_id = $1`
+
+// Notes lists homework and tests of the child's lessons dated within the term
+func (c Child) Notes(ctx context.Context, db *pgxpool.Pool, q ChildQuery) ([]ChildNote, error) {
+	from, to, err := termWindow(ctx, db, c.schoolId, q.termId, q.from, q.to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		select nd.id, nd.timetable_id, coalesce(s.name, ''), nd.type, nd.content, to_char(nd.date, 'YYYY-MM-DD')
+		from note_with_date nd
+		left join academic_timetable at on at.id = nd.timetable_id
+		left join subject s on s.id = at.subject_id
+		where nd.timetable_id in (`+studentLessons+`) and nd.date between $2 and $3
+		order by nd.date, nd.id`,
+		c.id, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (n ChildNote, err error) {
+		err = row.Scan(&n.Id, &n.TimetableId, &n.Subject, &n.Type, &n.Content, &n.Date)
+		return
+	})
+}
+
+// Absences lists absences of the child overlapping with the term
+func (c Child) Absences(ctx context.Context, db *pgxpool.Pool, q ChildQuery) ([]Absence, error) {
+	from, to, err := termWindow(ctx, db, c.schoolId, q.termId, q.from, q.to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx,
+		absenceSelect+" where a.user_id = $1 and a.span && tsrange($2::date, $3::date + 1) order by lower(a.span), a.id",
+		c.id, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanAbsence)
+}
+
+// ChildReport is a lesson of the child with the covered topic and the child's attendance
+type ChildReport struct {
+	Id           int    `json:"id"`
+	TimetableId  int    `json:"timetableId"`
+	Subject      string `json:"subject"`
+	ReportedAt   string `json:"reportedAt"`
+	TopicCovered string `json:"topicCovered"`
+	// Attendance is nil when the lesson was reported before the child was in its groups
+	Attendance *string `json:"attendance"`
+}
+
+// Reports lists reports of the child's lessons filed during the term
+func (c Child) Reports(ctx context.Context, db *pgxpool.Pool, q ChildQuery) ([]ChildReport, error) {
+	from, to, err := termWindow(ctx, db, c.schoolId, q.termId, q.from, q.to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		select r.id, r.timetable_id, coalesce(s.name, ''), r.reported_at, r.topic_covered, att.status::text
+		from report r
+		left join academic_timetable at on at.id = r.timetable_id
+		left join subject s on s.id = at.subject_id
+		left join attendance att on att.report_id = r.id and att.student_id = $1
+		where (r.timetable_id in (`+studentLessons+`) or att.status is not null)
+			and r.reported_at >= $2 and r.reported_at < $3::date + 1
+		order by r.reported_at, r.id`,
+		c.id, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (r ChildReport, err error) {
+		var reportedAt time.Time
+		err = row.Scan(&r.Id, &r.TimetableId, &r.Subject, &reportedAt, &r.TopicCovered, &r.Attendance)
+		r.ReportedAt = reportedAt.Format(time.RFC3339)
+		return
+	})
+}
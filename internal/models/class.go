@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -15,6 +18,7 @@ type Class struct {
 	id             int
 	name           string
 	year           int8
+	schoolId       int
 	classTeacherId uuid.UUID
 }
 
@@ -25,10 +29,8 @@ func ParseClass(f url.Values, parserCtx context.Context, handlerCtx *context.Con
 	year, err := utils.ParseInt(span, "year", f.Get("year"))
 	if err != nil {
 		return utils.NewParserError(err, "Invalid year (not an integer)")
-	} else if year > 9 {
-		return utils.NewParserError(nil, "Invalid year (too high)")
-	} else if year <= 0 {
-		return utils.NewParserError(nil, "Invalid year (can't be 0 or less)")
+	} else if err := validateClassYear(year); err != nil {
+		return err
 	}
 
 	classTeacherId, err := utils.ParseUuid(span, "class_teacher_id", f.Get("class_teacher_id"))
@@ -54,10 +56,184 @@ func ParseClass(f url.Values, parserCtx context.Context, handlerCtx *context.Con
 	return nil
 }
 
-func (c Class) SaveToDb(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(context.TODO(),
-		"insert into class (name, year, class_teacher_id) values ($1, $2, $3)",
-		c.name, c.year, c.classTeacherId,
+func (c *Class) SaveToDb(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		"insert into class (name, year, school_id, class_teacher_id) values ($1, $2, $3, $4) returning id, name, year, school_id, class_teacher_id",
+		c.name, c.year, c.schoolId, c.classTeacherId,
 	)
+	if err != nil {
+		return err
+	}
+	*c, err = pgx.CollectOneRow(rows, scanClass)
+	return err
+}
+
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the class teacher is in another school
+func (c *Class) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, userRef(c.classTeacherId)); err != nil {
+			return err
+		}
+		c.schoolId = schoolId
+		return c.SaveToDb(tx)
+	}
+}
+
+type ClassFilter struct {
+	year           *int
+	classTeacherId *uuid.UUID
+}
+
+func ParseClassFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing class filter")
+
+	year, err := parseOptionalInt(span, f, "year")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid year (not an integer)")
+	}
+	classTeacherId, err := parseOptionalUuid(span, f, "class_teacher_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid class teacher id")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "class filter", ClassFilter{
+		year:           year,
+		classTeacherId: classTeacherId,
+	})
+
+	return nil
+}
+
+// classSelect doesn't join the class teacher, classes without one are listed too
+const classSelect = "select c.id, c.name, c.year, c.school_id, c.class_teacher_id from class c"
+
+func scanClass(row pgx.CollectableRow) (c Class, err error) {
+	err = row.Scan(&c.id, &c.name, &c.year, &c.schoolId, &c.classTeacherId)
 	return
 }
+
+func ListClasses(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f ClassFilter) (Page[Class], error) {
+	b := listBuilder{sorts: map[string]string{"name": "c.name", "year": "c.year"}}
+	b.where("c.school_id = ?", schoolId)
+	if f.year != nil {
+		b.where("c.year = ?", *f.year)
+	}
+	if f.classTeacherId != nil {
+		b.where("c.class_teacher_id = ?", *f.classTeacherId)
+	}
+
+	query, args, err := b.build(classSelect, []string{"c.id"}, q)
+	if err != nil {
+		return Page[Class]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Class]{}, err
+	}
+
+	return collectPage(rows, q, scanClass, func(c Class) []string {
+		return q.sortKey(map[string]string{"name": c.name, "year": fmt.Sprint(c.year)}, fmt.Sprint(c.id))
+	})
+}
+
+func GetClass(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Class, error) {
+	rows, err := db.Query(ctx, classSelect+" where c.school_id = $1 and c.id = $2", schoolId, id)
+	if err != nil {
+		return Class{}, err
+	}
+	return pgx.CollectOneRow(rows, scanClass)
+}
+
+func (c Class) Id() int {
+	return c.id
+}
+
+func (c Class) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id             int        `json:"id"`
+		Name           string     `json:"name"`
+		Year           int8       `json:"year"`
+		ClassTeacherId *uuid.UUID `json:"classTeacherId"`
+	}{
+		Id:             c.id,
+		Name:           c.name,
+		Year:           c.year,
+		ClassTeacherId: nullableUuid(c.classTeacherId),
+	})
+}
+
+type ClassUpdate struct {
+	name           *string
+	year           *int8
+	classTeacherId *uuid.UUID
+}
+
+func validateClassYear(year int) *utils.ParseError {
+	if year > 9 {
+		return utils.NewParserError(nil, "Invalid year (too high)")
+	} else if year <= 0 {
+		return utils.NewParserError(nil, "Invalid year (can't be 0 or less)")
+	}
+	return nil
+}
+
+func ParseClassUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing class update")
+
+	update := ClassUpdate{
+		name: optionalString(span, f, "name"),
+	}
+	if update.name != nil && *update.name == "" {
+		return utils.NewParserError(nil, "Name can't be empty")
+	}
+
+	year, err := parseOptionalInt(span, f, "year")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid year (not an integer)")
+	} else if year != nil {
+		if err := validateClassYear(*year); err != nil {
+			return err
+		}
+		yearInt8 := int8(*year)
+		update.year = &yearInt8
+	}
+
+	if update.classTeacherId, err = parseOptionalUuid(span, f, "class_teacher_id"); err != nil {
+		return utils.NewParserError(err, "Invalid class teacher id")
+	}
+
+	if update == (ClassUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "class update", update)
+
+	return nil
+}
+
+func (u ClassUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		if u.name != nil {
+			b.set("name", *u.name)
+		}
+		if u.year != nil {
+			b.set("year", *u.year)
+		}
+		if u.classTeacherId != nil {
+			b.set("class_teacher_id", *u.classTeacherId)
+		}
+		return b.exec(tx, "class", "id = ? and school_id = ?", id, schoolId)
+	}
+}
+
+func DeleteClass(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx,
+			"delete from class where id = $1 and school_id = $2",
+			id, schoolId,
+		)
+	}
+}
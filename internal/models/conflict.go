@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TimetableConflict is a pair of lessons taking place at the same time which share
+// a room, a teacher or a group. Like WeekTimetable it's a report, so fields are exported
+type TimetableConflict struct {
+	TimetableId   int    `json:"timetableId"`
+	ConflictingId int    `json:"conflictingId"`
+	Reason        string `json:"reason"`
+	PeriodId      int    `json:"periodId"`
+	Weekday       int    `json:"weekday,omitempty"`
+	Date          string `json:"date,omitempty"`
+}
+
+func (c TimetableConflict) String() string {
+	when := c.Date
+	if when == "" {
+		when = Weekdays[c.Weekday-1]
+	}
+	return fmt.Sprintf("lesson %d has the same %s as lesson %d (%s, period %d)", c.TimetableId, c.Reason, c.ConflictingId, when, c.PeriodId)
+}
+
+type ConflictError struct {
+	Conflicts []TimetableConflict
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("timetable has %d conflicts", len(e.Conflicts))
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// conflictsQuery pairs lessons in the same period on the same day, regular lessons
+// meet each other on the same weekday, substitute lessons on the same date.
+// A substitute lesson and a regular one only clash in room or teacher and only
+// when the regular lesson isn't replaced that day (see ResolveWeekTimetable),
+// sharing a group is how a substitution replaces the regular lesson after all.
+// Lessons only meet when the days they are valid on overlap, regular lessons are
+// valid during their academic year, substitute lessons on their date
+const conflictsQuery = `
+	with lessons as (
+		select
+			at.id, at.period_id, at.room_id, t.school_id, rt.weekday, st.date,
+			coalesce(rt.weekday, (enum_range(null::weekday))[extract(isodow from st.date)::int]) as day,
+			coalesce(y.span, daterange(st.date, st.date, '[]'), daterange(null, null)) as valid
+		from academic_timetable at
+		join timetable t on t.id = at.id
+		left join regular_timetable rt on rt.id = at.id
+		left join substitute_timetable st on st.id = at.id
+		left join academic_year y on y.id = rt.academic_year_id
+		where t.school_id = $1
+	),
+	replaced as (
+		select r.id, s.date
+		from lessons r
+		join lessons s on s.period_id = r.period_id and s.day = r.weekday and s.date is not null and s.valid && r.valid
+		where r.weekday is not null and case
+			when exists (select 1 from timetable_group where timetable_id = r.id) then exists (
+				select 1 from timetable_group gr join timetable_group gs on gs.group_id = gr.group_id
+				where gr.timetable_id = r.id and gs.timetable_id = s.id
+			)
+			else exists (
+				select 1 from timetable_teacher tr join timetable_teacher ts on ts.teacher_id = tr.teacher_id
+				where tr.timetable_id = r.id and ts.timetable_id = s.id
+			)
+		end
+	)
+	select a.id, b.id, c.reason, a.period_id, coalesce(a.weekday::text, ''), coalesce(to_char(a.date, 'YYYY-MM-DD'), '')
+	from lessons a
+	join lessons b on b.period_id = a.period_id and b.day = a.day and b.id <> a.id and b.valid && a.valid
+	cross join lateral (values
+		('room', a.room_id = b.room_id),
+		('teacher', exists (
+			select 1 from timetable_teacher ta join timetable_teacher tb on tb.teacher_id = ta.teacher_id
+			where ta.timetable_id = a.id and tb.timetable_id = b.id
+		)),
+		('group', exists (
+			select 1 from timetable_group ga join timetable_group gb on gb.group_id = ga.group_id
+			where ga.timetable_id = a.id and gb.timetable_id = b.id
+		))
+	) as c(reason, clashes)
+	where c.clashes and (
+		(a.date is null and b.date is null)
+		or a.date = b.date
+		or (
+			c.reason <> 'group'
+			and (a.date is null) <> (b.date is null)
+			and not exists (
+				select 1 from replaced
+				where (replaced.id = a.id and replaced.date = b.date) or (replaced.id = b.id and replaced.date = a.date)
+			)
+		)
+	)`
+
+func scanTimetableConflict(row pgx.CollectableRow) (c TimetableConflict, err error) {
+	var weekday string
+	err = row.Scan(&c.TimetableId, &c.ConflictingId, &c.Reason, &c.PeriodId, &weekday, &c.Date)
+	if weekday != "" {
+		c.Weekday = weekdayNumber(weekday)
+	}
+	return
+}
+
+// FindTimetableConflicts audits the whole timetable of the school, each clashing pair is listed once
+func FindTimetableConflicts(ctx context.Context, db querier, schoolId int) ([]TimetableConflict, error) {
+	rows, err := db.Query(ctx, conflictsQuery+" and a.id < b.id order by a.id, b.id", schoolId)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanTimetableConflict)
+}
+
+// checkTimetableConflicts makes sure the lesson doesn't clash with any other one,
+// it's called at the end of every transaction changing a lesson, its groups or teachers.
+// The advisory lock serializes these checks in a school, otherwise two concurrent
+// transactions could each miss the lesson added by the other one
+func checkTimetableConflicts(tx pgx.Tx, timetableId int) error {
+	var schoolId int
+	if err := tx.QueryRow(context.TODO(), "select school_id from timetable where id = $1", timetableId).Scan(&schoolId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(context.TODO(), "select pg_advisory_xact_lock(hashtext('timetable'), $1)", schoolId); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(context.TODO(), conflictsQuery+" and a.id = $2 order by b.id", schoolId, timetableId)
+	if err != nil {
+		return err
+	}
+	conflicts, err := pgx.CollectRows(rows, scanTimetableConflict)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
+
+	return nil
+}
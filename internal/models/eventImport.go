@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+type ImportedEvent struct {
+	UID    string `json:"uid"`
+	Id     int    `json:"id,omitempty"`
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// maxEventNameLength is the length of event_timetable.name
+const maxEventNameLength = 255
+
+// EventImport reports what importing a calendar did with each of its events
+type EventImport struct {
+	Created []ImportedEvent `json:"created"`
+	Updated []ImportedEvent `json:"updated"`
+	Skipped []ImportedEvent `json:"skipped"`
+}
+
+// ImportEvents creates events of the school from the calendar. Events are matched
+// with previously imported ones by their UID, so importing the same calendar again
+// only updates events which changed since and skips the rest
+func ImportEvents(schoolId int, events []utils.ICalEvent, problems []utils.ICalProblem, result *EventImport) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		*result = EventImport{Created: []ImportedEvent{}, Updated: []ImportedEvent{}, Skipped: []ImportedEvent{}}
+		for _, p := range problems {
+			result.Skipped = append(result.Skipped, ImportedEvent{UID: p.UID, Name: p.Summary, Reason: p.Reason})
+		}
+
+		seen := map[string]bool{}
+		for _, e := range events {
+			imported := ImportedEvent{UID: e.UID, Name: e.Summary}
+			switch {
+			case seen[e.UID]:
+				imported.Reason = "duplicate UID"
+			case e.Cancelled:
+				imported.Reason = "event is cancelled"
+			case e.Summary == "":
+				imported.Reason = "missing SUMMARY"
+			case utf8.RuneCountInString(e.Summary) > maxEventNameLength:
+				imported.Reason = fmt.Sprintf("SUMMARY longer than %d characters", maxEventNameLength)
+			}
+			seen[e.UID] = true
+			if imported.Reason != "" {
+				result.Skipped = append(result.Skipped, imported)
+				continue
+			}
+
+			var current EventTimetable
+			var start, end time.Time
+			err := tx.QueryRow(context.TODO(), `
+				select et.id, et.name, coalesce(et.description, ''), lower(et.span), upper(et.span)
+				from imported_event ie
+				join event_timetable et on et.id = ie.event_id
+				where ie.school_id = $1 and ie.uid = $2`,
+				schoolId, e.UID,
+			).Scan(&current.id, &current.name, &current.description, &start, &end)
+
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				event := EventTimetable{
+					schoolId:    schoolId,
+					start:       e.Start.Format(time.RFC3339),
+					end:         e.End.Format(time.RFC3339),
+					name:        e.Summary,
+					description: e.Description,
+				}
+				if err := event.SaveToDB(tx); err != nil {
+					return err
+				}
+				if _, err := tx.Exec(context.TODO(),
+					"insert into imported_event (school_id, uid, event_id) values ($1, $2, $3)",
+					schoolId, e.UID, event.id,
+				); err != nil {
+					return err
+				}
+				imported.Id = event.id
+				result.Created = append(result.Created, imported)
+			case err != nil:
+				return err
+			case current.name == e.Summary && current.description == e.Description && start.Equal(e.Start) && end.Equal(e.End):
+				imported.Id = current.id
+				imported.Reason = "unchanged"
+				result.Skipped = append(result.Skipped, imported)
+			default:
+				update := EventTimetableUpdate{
+					start:       &e.Start,
+					end:         &e.End,
+					name:        &e.Summary,
+					description: &e.Description,
+				}
+				if err := update.UpdateInDB(schoolId, current.id)(tx); err != nil {
+					return err
+				}
+				imported.Id = current.id
+				result.Updated = append(result.Updated, imported)
+			}
+		}
+
+		return nil
+	}
+}
@@ -2,12 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"time"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -27,13 +29,8 @@ func ParseEventTimetable(f url.Values, parserCtx context.Context, handlerCtx *co
 	span := trace.SpanFromContext(parserCtx)
 	span.AddEvent("Parsing event timetable")
 
-	schoolId, err := utils.ParseInt(span, "school_id", f.Get("school_id"))
-	if err != nil {
-		return utils.NewParserError(nil, "Invalid school id (not convertable to int)")
-	}
-
 	start := f.Get("start")
-	_, err = time.Parse(time.RFC3339, start)
+	_, err := time.Parse(time.RFC3339, start)
 	span.SetAttributes(
 		attribute.String("start_unprocessed", start),
 		attribute.String("start", start),
@@ -65,7 +62,7 @@ func ParseEventTimetable(f url.Values, parserCtx context.Context, handlerCtx *co
 
 	*handlerCtx = context.WithValue(*handlerCtx, "event timetable", EventTimetable{
 		id:          -1,
-		schoolId:    schoolId,
+		schoolId:    -1,
 		start:       start,
 		end:         end,
 		name:        name,
@@ -75,8 +72,16 @@ func ParseEventTimetable(f url.Values, parserCtx context.Context, handlerCtx *co
 	return nil
 }
 
-func (t EventTimetable) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(
+// SaveToDBWithSchoolId saves the event in the school of the admin creating it
+func (t *EventTimetable) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		t.schoolId = schoolId
+		return t.SaveToDB(tx)
+	}
+}
+
+func (t *EventTimetable) SaveToDB(tx pgx.Tx) error {
+	return tx.QueryRow(
 		context.TODO(),
 		`
 		WITH inserted_timetable AS (
@@ -87,8 +92,177 @@ func (t EventTimetable) SaveToDB(tx pgx.Tx) (err error) {
 		INSERT INTO event_timetable (id, name, description, span)
 		SELECT id, $3, $4, $5
 		FROM inserted_timetable
+		RETURNING id
 		`,
 		t.schoolId, eventTimetableType, t.name, t.description, fmt.Sprintf("[%s, %s]", t.start, t.end),
-	)
+	).Scan(&t.id)
+}
+
+type EventTimetableFilter struct {
+	from *time.Time
+	to   *time.Time
+}
+
+func ParseEventTimetableFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing event timetable filter")
+
+	from, err := parseOptionalTime(span, f, "from", time.RFC3339)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid from time")
+	}
+	to, err := parseOptionalTime(span, f, "to", time.RFC3339)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid to time")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "event timetable filter", EventTimetableFilter{
+		from: from,
+		to:   to,
+	})
+
+	return nil
+}
+
+const eventTimetableSelect = `
+	select et.id, t.school_id, lower(et.span), upper(et.span), et.name, coalesce(et.description, '')
+	from event_timetable et
+	join timetable t on t.id = et.id`
+
+func scanEventTimetable(row pgx.CollectableRow) (t EventTimetable, err error) {
+	var start, end time.Time
+	if err = row.Scan(&t.id, &t.schoolId, &start, &end, &t.name, &t.description); err != nil {
+		return
+	}
+	t.start = start.Format(time.RFC3339)
+	t.end = end.Format(time.RFC3339)
 	return
 }
+
+// ListEventTimetables returns events overlapping with the from-to interval
+func ListEventTimetables(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f EventTimetableFilter) (Page[EventTimetable], error) {
+	b := listBuilder{}
+	b.where("t.school_id = ?", schoolId)
+	if f.from != nil {
+		b.where("upper(et.span) >= ?", *f.from)
+	}
+	if f.to != nil {
+		b.where("lower(et.span) <= ?", *f.to)
+	}
+
+	query, args, err := b.build(eventTimetableSelect, []string{"et.id"}, q)
+	if err != nil {
+		return Page[EventTimetable]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[EventTimetable]{}, err
+	}
+
+	return collectPage(rows, q, scanEventTimetable, func(t EventTimetable) []string {
+		return []string{fmt.Sprint(t.id)}
+	})
+}
+
+func GetEventTimetable(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (EventTimetable, error) {
+	rows, err := db.Query(ctx, eventTimetableSelect+" where t.school_id = $1 and et.id = $2", schoolId, id)
+	if err != nil {
+		return EventTimetable{}, err
+	}
+	return pgx.CollectOneRow(rows, scanEventTimetable)
+}
+
+func (t EventTimetable) Id() int {
+	return t.id
+}
+
+func (t EventTimetable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id          int    `json:"id"`
+		SchoolId    int    `json:"schoolId"`
+		Start       string `json:"start"`
+		End         string `json:"end"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}{
+		Id:          t.id,
+		SchoolId:    t.schoolId,
+		Start:       t.start,
+		End:         t.end,
+		Name:        t.name,
+		Description: t.description,
+	})
+}
+
+type EventTimetableUpdate struct {
+	start       *time.Time
+	end         *time.Time
+	name        *string
+	description *string
+}
+
+func ParseEventTimetableUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing event timetable update")
+
+	start, err := parseOptionalTime(span, f, "start", time.RFC3339)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid start time")
+	}
+	end, err := parseOptionalTime(span, f, "end", time.RFC3339)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid end time")
+	}
+	if start != nil && end != nil && end.Before(*start) {
+		return utils.NewParserError(nil, "End can't be before start")
+	}
+
+	update := EventTimetableUpdate{
+		start:       start,
+		end:         end,
+		name:        optionalString(span, f, "name"),
+		description: optionalString(span, f, "description"),
+	}
+	if update.name != nil && *update.name == "" {
+		return utils.NewParserError(nil, "Name can't be empty")
+	} else if update == (EventTimetableUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "event timetable update", update)
+
+	return nil
+}
+
+func (u EventTimetableUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkTimetableInSchool(tx, schoolId, id, eventTimetableType); err != nil {
+			return err
+		}
+
+		b := updateBuilder{}
+		if u.name != nil {
+			b.set("name", *u.name)
+		}
+		if u.description != nil {
+			b.set("description", *u.description)
+		}
+		if u.start != nil || u.end != nil {
+			var start, end any //nil keeps the current bound
+			if u.start != nil {
+				start = u.start.Format(time.RFC3339)
+			}
+			if u.end != nil {
+				end = u.end.Format(time.RFC3339)
+			}
+			b.setExpr("span", "tsrange(coalesce(?::timestamp, lower(span)), coalesce(?::timestamp, upper(span)), '[]')", start, end)
+		}
+		return b.exec(tx, "event_timetable", "id = ?", id)
+	}
+}
+
+func DeleteEventTimetable(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return deleteTimetable(tx, schoolId, id, eventTimetableType, "event_timetable")
+	}
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FinalGrade is the grade of a student in a subject for the whole term, it goes to the report card
+type FinalGrade struct {
+	id        int
+	studentId uuid.UUID
+	termId    int
+	subjectId int
+	value     int
+}
+
+func ParseFinalGrade(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing final grade")
+
+	studentId, err := utils.ParseUuid(span, "student_id", f.Get("student_id"))
+	if err != nil {
+		return utils.NewParserError(err, "Invalid student id")
+	}
+	termId, err := utils.ParseInt(span, "term_id", f.Get("term_id"))
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+	subjectId, err := utils.ParseInt(span, "subject_id", f.Get("subject_id"))
+	if err != nil {
+		return utils.NewParserError(err, "Invalid subject id (not an int)")
+	}
+	value, err := utils.ParseInt(span, "value", f.Get("value"))
+	if err != nil {
+		return utils.NewParserError(err, "Invalid grade value (not an int)")
+	} else if err := validateGradeValue(value); err != nil {
+		return err
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "final grade", FinalGrade{
+		id:        -1,
+		studentId: studentId,
+		termId:    termId,
+		subjectId: subjectId,
+		value:     value,
+	})
+
+	return nil
+}
+
+// SaveToDBWithSchoolId saves the final grade, ErrForeignReference means the student or the term is in another school
+func (g *FinalGrade) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var inSchool bool
+		err := tx.QueryRow(context.TODO(), `
+			select
+				exists (select 1 from users where id = $1 and school_id = $3)
+				and exists (select 1 from term t join academic_year y on y.id = t.academic_year_id where t.id = $2 and y.school_id = $3)`,
+			g.studentId, g.termId, schoolId,
+		).Scan(&inSchool)
+		if err != nil {
+			return err
+		} else if !inSchool {
+			return ErrForeignReference
+		}
+
+		rows, err := tx.Query(context.TODO(),
+			"insert into final_grade (student_id, term_id, subject_id, value) values ($1, $2, $3, $4) returning id, student_id, term_id, subject_id, value",
+			g.studentId, g.termId, g.subjectId, g.value,
+		)
+		if err != nil {
+			return err
+		}
+		*g, err = pgx.CollectOneRow(rows, scanFinalGrade)
+		return err
+	}
+}
+
+type FinalGradeFilter struct {
+	studentId *uuid.UUID
+	termId    *int
+	subjectId *int
+}
+
+func ParseFinalGradeFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing final grade filter")
+
+	studentId, err := parseOptionalUuid(span, f, "student_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid student id")
+	}
+	termId, err := parseOptionalInt(span, f, "term_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+	subjectId, err := parseOptionalInt(span, f, "subject_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid subject id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "final grade filter", FinalGradeFilter{
+		studentId: studentId,
+		termId:    termId,
+		subjectId: subjectId,
+	})
+
+	return nil
+}
+
+const finalGradeSelect = `
+	select fg.id, fg.student_id, fg.term_id, fg.subject_id, fg.value
+	from final_grade fg
+	join users s on s.id = fg.student_id`
+
+func scanFinalGrade(row pgx.CollectableRow) (g FinalGrade, err error) {
+	err = row.Scan(&g.id, &g.studentId, &g.termId, &g.subjectId, &g.value)
+	return
+}
+
+func ListFinalGrades(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f FinalGradeFilter) (Page[FinalGrade], error) {
+	b := listBuilder{}
+	b.where("s.school_id = ?", schoolId)
+	if f.studentId != nil {
+		b.where("fg.student_id = ?", *f.studentId)
+	}
+	if f.termId != nil {
+		b.where("fg.term_id = ?", *f.termId)
+	}
+	if f.subjectId != nil {
+		b.where("fg.subject_id = ?", *f.subjectId)
+	}
+
+	query, args, err := b.build(finalGradeSelect, []string{"fg.id"}, q)
+	if err != nil {
+		return Page[FinalGrade]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[FinalGrade]{}, err
+	}
+
+	return collectPage(rows, q, scanFinalGrade, func(g FinalGrade) []string {
+		return []string{fmt.Sprint(g.id)}
+	})
+}
+
+func GetFinalGrade(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (FinalGrade, error) {
+	rows, err := db.Query(ctx, finalGradeSelect+" where s.school_id = $1 and fg.id = $2", schoolId, id)
+	if err != nil {
+		return FinalGrade{}, err
+	}
+	return pgx.CollectOneRow(rows, scanFinalGrade)
+}
+
+func (g FinalGrade) Id() int {
+	return g.id
+}
+
+func (g FinalGrade) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id        int       `json:"id"`
+		StudentId uuid.UUID `json:"studentId"`
+		TermId    int       `json:"termId"`
+		SubjectId int       `json:"subjectId"`
+		Value     int       `json:"value"`
+	}{
+		Id:        g.id,
+		StudentId: g.studentId,
+		TermId:    g.termId,
+		SubjectId: g.subjectId,
+		Value:     g.value,
+	})
+}
+
+type FinalGradeUpdate struct {
+	value int
+}
+
+func ParseFinalGradeUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing final grade update")
+
+	value, err := parseOptionalInt(span, f, "value")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid grade value (not an int)")
+	} else if value == nil {
+		return nothingToUpdate()
+	} else if err := validateGradeValue(*value); err != nil {
+		return err
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "final grade update", FinalGradeUpdate{value: *value})
+
+	return nil
+}
+
+func (u FinalGradeUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		b.set("value", u.value)
+		return b.exec(tx, "final_grade", "id = ? and exists (select 1 from users s where s.id = final_grade.student_id and s.school_id = ?)", id, schoolId)
+	}
+}
+
+func DeleteFinalGrade(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx,
+			"delete from final_grade where id = $1 and exists (select 1 from users s where s.id = final_grade.student_id and s.school_id = $2)",
+			id, schoolId,
+		)
+	}
+}
@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -18,6 +21,24 @@ type Grade struct {
 	weight    int
 }
 
+func validateGradeValue(value int) *utils.ParseError {
+	if value < 1 {
+		return utils.NewParserError(nil, "Invalid grade value (can't be less than 1)")
+	} else if value > 5 {
+		return utils.NewParserError(nil, "Invalid grade value (can't be more than 5)")
+	}
+	return nil
+}
+
+func validateGradeWeight(weight int) *utils.ParseError {
+	if weight < 1 {
+		return utils.NewParserError(nil, "Invalid grade weight (can't be less than 1)")
+	} else if weight > 10 {
+		return utils.NewParserError(nil, "Invalid grade weight (can't be more than 10)")
+	}
+	return nil
+}
+
 func ParseGrade(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
 	span := trace.SpanFromContext(parserCtx)
 	span.AddEvent("Parsing grade")
@@ -33,18 +54,14 @@ func ParseGrade(f url.Values, parserCtx context.Context, handlerCtx *context.Con
 	value, err := utils.ParseInt(span, "value", f.Get("value"))
 	if err != nil {
 		return utils.NewParserError(err, "Invalid grade value (not an int)")
-	} else if value < 1 {
-		return utils.NewParserError(nil, "Invalid grade value (can't be less than 1)")
-	} else if value > 5 {
-		return utils.NewParserError(nil, "Invalid grade value (can't be more than 5)")
+	} else if err := validateGradeValue(value); err != nil {
+		return err
 	}
 	weight, err := utils.ParseInt(span, "weight", f.Get("weight"))
 	if err != nil {
 		return utils.NewParserError(err, "Invalid grade weight (not an int)")
-	} else if weight < 1 {
-		return utils.NewParserError(nil, "Invalid grade weight (can't be less than 1)")
-	} else if weight > 10 {
-		return utils.NewParserError(nil, "Invalid grade weight (can't be more than 10)")
+	} else if err := validateGradeWeight(weight); err != nil {
+		return err
 	}
 
 	*handlerCtx = context.WithValue(*handlerCtx, "grade", Grade{
@@ -58,8 +75,201 @@ func ParseGrade(f url.Values, parserCtx context.Context, handlerCtx *context.Con
 	return nil
 }
 
-func (g Grade) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(context.TODO(), "insert into grade (student_id, report_id, value, weight) values ($1, $2, $3, $4)", g.studentId, g.reportId, g.value, g.weight)
+func (g *Grade) SaveToDB(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		"insert into grade (student_id, report_id, value, weight) values ($1, $2, $3, $4) returning id, student_id, report_id, value, weight",
+		g.studentId, g.reportId, g.value, g.weight,
+	)
+	if err != nil {
+		return err
+	}
+	*g, err = pgx.CollectOneRow(rows, scanGrade)
+	if err != nil {
+		return err
+	}
+	return notifyOf(NotificationGrade, g.id)(tx)
+}
 
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the student or the report is in another school
+func (g *Grade) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, userRef(g.studentId), reportRef(g.reportId)); err != nil {
+			return err
+		}
+		return g.SaveToDB(tx)
+	}
+}
+
+type GradeFilter struct {
+	studentId *uuid.UUID
+	reportId  *int
+	subjectId *int
+	termId    *int
+}
+
+func ParseGradeFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing grade filter")
+
+	studentId, err := parseOptionalUuid(span, f, "student_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid student id")
+	}
+	reportId, err := parseOptionalInt(span, f, "report_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid report id (not an int)")
+	}
+	subjectId, err := parseOptionalInt(span, f, "subject_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid subject id (not an int)")
+	}
+	termId, err := parseOptionalInt(span, f, "term_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "grade filter", GradeFilter{
+		studentId: studentId,
+		reportId:  reportId,
+		subjectId: subjectId,
+		termId:    termId,
+	})
+
+	return nil
+}
+
+const gradeSelect = `
+	select g.id, g.student_id, g.report_id, g.value, g.weight
+	from grade g
+	join users s on s.id = g.student_id`
+
+func scanGrade(row pgx.CollectableRow) (g Grade, err error) {
+	err = row.Scan(&g.id, &g.studentId, &g.reportId, &g.value, &g.weight)
 	return
 }
+
+func ListGrades(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f GradeFilter) (Page[Grade], error) {
+	b := listBuilder{}
+	b.where("s.school_id = ?", schoolId)
+	if f.studentId != nil {
+		b.where("g.student_id = ?", *f.studentId)
+	}
+	if f.reportId != nil {
+		b.where("g.report_id = ?", *f.reportId)
+	}
+	if f.subjectId != nil {
+		b.where(`exists (
+			select 1 from report r
+			join academic_timetable at on at.id = r.timetable_id
+			where r.id = g.report_id and at.subject_id = ?
+		)`, *f.subjectId)
+	}
+	if f.termId != nil {
+		b.where(`exists (
+			select 1 from report r
+			where r.id = g.report_id and r.reported_at <@ (`+termTimeRange+`)
+		)`, *f.termId)
+	}
+
+	query, args, err := b.build(gradeSelect, []string{"g.id"}, q)
+	if err != nil {
+		return Page[Grade]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Grade]{}, err
+	}
+
+	return collectPage(rows, q, scanGrade, func(g Grade) []string {
+		return []string{fmt.Sprint(g.id)}
+	})
+}
+
+func GetGrade(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Grade, error) {
+	rows, err := db.Query(ctx, gradeSelect+" where s.school_id = $1 and g.id = $2", schoolId, id)
+	if err != nil {
+		return Grade{}, err
+	}
+	return pgx.CollectOneRow(rows, scanGrade)
+}
+
+func (g Grade) Id() int {
+	return g.id
+}
+
+func (g Grade) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id        int       `json:"id"`
+		StudentId uuid.UUID `json:"studentId"`
+		ReportId  int       `json:"reportId"`
+		Value     int       `json:"value"`
+		Weight    int       `json:"weight"`
+	}{
+		Id:        g.id,
+		StudentId: g.studentId,
+		ReportId:  g.reportId,
+		Value:     g.value,
+		Weight:    g.weight,
+	})
+}
+
+type GradeUpdate struct {
+	value  *int
+	weight *int
+}
+
+func ParseGradeUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing grade update")
+
+	value, err := parseOptionalInt(span, f, "value")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid grade value (not an int)")
+	} else if value != nil {
+		if err := validateGradeValue(*value); err != nil {
+			return err
+		}
+	}
+	weight, err := parseOptionalInt(span, f, "weight")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid grade weight (not an int)")
+	} else if weight != nil {
+		if err := validateGradeWeight(*weight); err != nil {
+			return err
+		}
+	}
+
+	update := GradeUpdate{
+		value:  value,
+		weight: weight,
+	}
+	if update == (GradeUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "grade update", update)
+
+	return nil
+}
+
+func (u GradeUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		if u.value != nil {
+			b.set("value", *u.value)
+		}
+		if u.weight != nil {
+			b.set("weight", *u.weight)
+		}
+		return b.exec(tx, "grade", "id = ? and exists (select 1 from users s where s.id = grade.student_id and s.school_id = ?)", id, schoolId)
+	}
+}
+
+func DeleteGrade(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx,
+			"delete from grade where id = $1 and exists (select 1 from users s where s.id = grade.student_id and s.school_id = $2)",
+			id, schoolId,
+		)
+	}
+}
@@ -0,0 +1,329 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrStudentNotVisible = errors.New("Student isn't visible to the user")
+
+// StudentGradebook has weighted averages of one student in every subject graded during the term.
+// Like other reports its fields are exported
+type StudentGradebook struct {
+	StudentId string             `json:"studentId"`
+	From      string             `json:"from"`
+	To        string             `json:"to"`
+	Subjects  []SubjectGradebook `json:"subjects"`
+}
+
+type SubjectGradebook struct {
+	SubjectId int     `json:"subjectId"`
+	Subject   string  `json:"subject"`
+	Average   float64 `json:"average"`
+	Count     int     `json:"count"`
+	// Trend is the average of the later half of grades minus the average of the earlier half,
+	// 1 is the best grade, so a negative trend means the student is improving
+	Trend  float64          `json:"trend"`
+	Grades []GradebookGrade `json:"grades"`
+}
+
+type GradebookGrade struct {
+	Id     int    `json:"id"`
+	Value  int    `json:"value"`
+	Weight int    `json:"weight"`
+	Date   string `json:"date"`
+	Topic  string `json:"topic"`
+}
+
+// ClassGradebook is the grid of averages of every student of the class (rows) in every subject (columns)
+type ClassGradebook struct {
+	ClassId  int                     `json:"classId"`
+	From     string                  `json:"from"`
+	To       string                  `json:"to"`
+	Subjects []GradebookSubject      `json:"subjects"`
+	Students []ClassGradebookStudent `json:"students"`
+}
+
+type GradebookSubject struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type ClassGradebookStudent struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Averages has one entry for every subject (in the same order as ClassGradebook.Subjects),
+	// nil when the student has no grade in the subject
+	Averages []*float64 `json:"averages"`
+	Counts   []int      `json:"counts"`
+}
+
+// GradebookQuery selects grades given during the term, the term containing today unless
+// term_id is set. From and to narrow the term down
+type GradebookQuery struct {
+	from      *time.Time
+	to        *time.Time
+	termId    *int
+	subjectId *int
+}
+
+func ParseGradebookQuery(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing gradebook query")
+
+	from, err := parseOptionalTime(span, f, "from", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid from date")
+	}
+	to, err := parseOptionalTime(span, f, "to", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid to date")
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return utils.NewParserError(nil, "To can't be before from")
+	}
+	termId, err := parseOptionalInt(span, f, "term_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+	subjectId, err := parseOptionalInt(span, f, "subject_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid subject id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "gradebook query", GradebookQuery{
+		from:      from,
+		to:        to,
+		termId:    termId,
+		subjectId: subjectId,
+	})
+
+	return nil
+}
+
+func (q GradebookQuery) span(ctx context.Context, db *pgxpool.Pool, schoolId int) (time.Time, time.Time, error) {
+	return termWindow(ctx, db, schoolId, q.termId, q.from, q.to)
+}
+
+// StudentVisibleTo says whether the user can see grades of the student, staff can see every
+// student of the school, parents their children and students only themselves
+func StudentVisibleTo(ctx context.Context, db *pgxpool.Pool, schoolId int, userId string, role utils.Role, studentId uuid.UUID) (bool, error) {
+	var visible bool
+	err := db.QueryRow(ctx, `
+		select exists (
+			select 1 from users s
+			where s.id = $1 and s.school_id = $2 and (
+				$4 in ('admin', 'teacher')
+				or ($4 = 'student' and s.id::text = $3)
+				or ($4 = 'parent' and exists (
+					select 1 from parent_child pc where pc.parent_id::text = $3 and pc.child_id = s.id
+				))
+			)
+		)`,
+		studentId, schoolId, userId, string(role),
+	).Scan(&visible)
+	return visible, err
+}
+
+// gradedRow is a grade together with the subject it was given in
+type gradedRow struct {
+	studentId string
+	subjectId int
+	subject   string
+	grade     GradebookGrade
+}
+
+const gradebookSelect = `
+	select g.student_id::text, at.subject_id, sub.name, g.id, g.value, g.weight, r.reported_at, r.topic_covered
+	from grade g
+	join report r on r.id = g.report_id
+	join academic_timetable at on at.id = r.timetable_id
+	join subject sub on sub.id = at.subject_id
+	join users s on s.id = g.student_id
+	where s.school_id = $1 and r.reported_at >= $2 and r.reported_at < $3::date + 1
+		and ($4::int is null or at.subject_id = $4)`
+
+func loadGradedRows(ctx context.Context, db *pgxpool.Pool, query string, args ...any) ([]gradedRow, error) {
+	rows, err := db.Query(ctx, query+" order by sub.name, at.subject_id, r.reported_at, g.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (g gradedRow, err error) {
+		var reportedAt time.Time
+		err = row.Scan(
+			&g.studentId, &g.subjectId, &g.subject,
+			&g.grade.Id, &g.grade.Value, &g.grade.Weight, &reportedAt, &g.grade.Topic,
+		)
+		g.grade.Date = reportedAt.Format(time.DateOnly)
+		return
+	})
+}
+
+func (q GradebookQuery) StudentGradebook(ctx context.Context, db *pgxpool.Pool, schoolId int, studentId uuid.UUID) (StudentGradebook, error) {
+	from, to, err := q.span(ctx, db, schoolId)
+	if err != nil {
+		return StudentGradebook{}, err
+	}
+	graded, err := loadGradedRows(ctx, db, gradebookSelect+" and g.student_id = $5",
+		schoolId, from, to.Format(time.DateOnly), q.subjectId, studentId,
+	)
+	if err != nil {
+		return StudentGradebook{}, err
+	}
+
+	gradebook := StudentGradebook{
+		StudentId: studentId.String(),
+		From:      from.Format(time.DateOnly),
+		To:        to.Format(time.DateOnly),
+		Subjects:  []SubjectGradebook{},
+	}
+	for _, g := range graded {
+		last := len(gradebook.Subjects) - 1
+		if last < 0 || gradebook.Subjects[last].SubjectId != g.subjectId {
+			gradebook.Subjects = append(gradebook.Subjects, SubjectGradebook{
+				SubjectId: g.subjectId,
+				Subject:   g.subject,
+				Grades:    []GradebookGrade{},
+			})
+			last++
+		}
+		gradebook.Subjects[last].Grades = append(gradebook.Subjects[last].Grades, g.grade)
+	}
+	for i := range gradebook.Subjects {
+		s := &gradebook.Subjects[i]
+		s.Count = len(s.Grades)
+		s.Average = weightedAverage(s.Grades)
+		if s.Count > 1 {
+			s.Trend = round2(weightedAverage(s.Grades[s.Count/2:]) - weightedAverage(s.Grades[:s.Count/2]))
+		}
+	}
+
+	return gradebook, nil
+}
+
+func (q GradebookQuery) ClassGradebook(ctx context.Context, db *pgxpool.Pool, schoolId, classId int) (ClassGradebook, error) {
+	from, to, err := q.span(ctx, db, schoolId)
+	if err != nil {
+		return ClassGradebook{}, err
+	}
+	classStudents, err := loadClassStudents(ctx, db, schoolId, classId)
+	if err != nil {
+		return ClassGradebook{}, err
+	}
+	students := make([]ClassGradebookStudent, len(classStudents))
+	studentIds := make([]string, len(classStudents))
+	for i, s := range classStudents {
+		students[i] = ClassGradebookStudent{Id: s.id, Name: s.name}
+		studentIds[i] = s.id
+	}
+	graded, err := loadGradedRows(ctx, db, gradebookSelect+" and g.student_id::text = any($5)",
+		schoolId, from, to.Format(time.DateOnly), q.subjectId, studentIds,
+	)
+	if err != nil {
+		return ClassGradebook{}, err
+	}
+
+	gradebook := ClassGradebook{
+		ClassId:  classId,
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		Subjects: []GradebookSubject{},
+		Students: students,
+	}
+	grades := map[string]map[int][]GradebookGrade{}
+	for _, g := range graded {
+		last := len(gradebook.Subjects) - 1
+		if last < 0 || gradebook.Subjects[last].Id != g.subjectId {
+			gradebook.Subjects = append(gradebook.Subjects, GradebookSubject{Id: g.subjectId, Name: g.subject})
+		}
+		if grades[g.studentId] == nil {
+			grades[g.studentId] = map[int][]GradebookGrade{}
+		}
+		grades[g.studentId][g.subjectId] = append(grades[g.studentId][g.subjectId], g.grade)
+	}
+	for i := range gradebook.Students {
+		s := &gradebook.Students[i]
+		s.Averages = make([]*float64, len(gradebook.Subjects))
+		s.Counts = make([]int, len(gradebook.Subjects))
+		for j, subject := range gradebook.Subjects {
+			if subjectGrades := grades[s.Id][subject.Id]; len(subjectGrades) > 0 {
+				average := weightedAverage(subjectGrades)
+				s.Averages[j] = &average
+				s.Counts[j] = len(subjectGrades)
+			}
+		}
+	}
+
+	return gradebook, nil
+}
+
+type classStudent struct {
+	id   string
+	name string
+}
+
+// loadClassStudents returns students in groups of the class ordered by their surname,
+// pgx.ErrNoRows means the class isn't in the school
+func loadClassStudents(ctx context.Context, db *pgxpool.Pool, schoolId, classId int) ([]classStudent, error) {
+	rows, err := db.Query(ctx, `
+		select distinct s.id::text, s.name || ' ' || s.surname, s.surname, s.name
+		from class c
+		join users ct on ct.id = c.class_teacher_id
+		join "group" g on g.class_id = c.id
+		join users_group ug on ug.group_id = g.id
+		join users s on s.id = ug.user_id
+		where c.id = $1 and ct.school_id = $2 and s.role = 'student'
+		order by s.surname, s.name, s.id::text`,
+		classId, schoolId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	students, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (s classStudent, err error) {
+		var surname, name string
+		err = row.Scan(&s.id, &s.name, &surname, &name)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(students) == 0 {
+		//tells apart an empty class from a class which doesn't exist
+		var exists bool
+		err := db.QueryRow(ctx,
+			"select exists (select 1 from class c join users ct on ct.id = c.class_teacher_id where c.id = $1 and ct.school_id = $2)",
+			classId, schoolId,
+		).Scan(&exists)
+		if err != nil {
+			return nil, err
+		} else if !exists {
+			return nil, pgx.ErrNoRows
+		}
+	}
+	return students, nil
+}
+
+func weightedAverage(grades []GradebookGrade) float64 {
+	sum, weights := 0, 0
+	for _, g := range grades {
+		sum += g.Value * g.Weight
+		weights += g.Weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return round2(float64(sum) / float64(weights))
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
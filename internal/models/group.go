@@ -2,18 +2,22 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type Group struct {
-	id      int
-	classId int
-	name    string
+	id       int
+	classId  *int
+	schoolId int
+	name     string
 }
 
 func ParseGroup(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
@@ -27,7 +31,7 @@ func ParseGroup(f url.Values, parserCtx context.Context, handlerCtx *context.Con
 
 	group := Group{
 		id:      -1,
-		classId: classId,
+		classId: &classId,
 		name:    f.Get("name"),
 	}
 
@@ -41,7 +45,146 @@ func ParseGroup(f url.Values, parserCtx context.Context, handlerCtx *context.Con
 	return nil
 }
 
-func (g Group) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(context.TODO(), `insert into "group" (name, class_id) values ($1, $2)`, g.name, g.classId)
+func (g *Group) SaveToDB(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		`insert into "group" (name, class_id, school_id) values ($1, $2, $3) returning id, class_id, school_id, name`,
+		g.name, g.classId, g.schoolId,
+	)
+	if err != nil {
+		return err
+	}
+	*g, err = pgx.CollectOneRow(rows, scanGroup)
+	return err
+}
+
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the class is in another school
+func (g *Group) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, classRef(*g.classId)); err != nil {
+			return err
+		}
+		g.schoolId = schoolId
+		return g.SaveToDB(tx)
+	}
+}
+
+type GroupFilter struct {
+	classId *int
+}
+
+func ParseGroupFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing group filter")
+
+	classId, err := parseOptionalInt(span, f, "class_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid class id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "group filter", GroupFilter{
+		classId: classId,
+	})
+
+	return nil
+}
+
+// groupSelect doesn't join the class, groups without one are listed too
+const groupSelect = `select g.id, g.class_id, g.school_id, g.name from "group" g`
+
+func scanGroup(row pgx.CollectableRow) (g Group, err error) {
+	err = row.Scan(&g.id, &g.classId, &g.schoolId, &g.name)
 	return
 }
+
+func ListGroups(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f GroupFilter) (Page[Group], error) {
+	b := listBuilder{sorts: map[string]string{"name": "g.name"}}
+	b.where("g.school_id = ?", schoolId)
+	if f.classId != nil {
+		b.where("g.class_id = ?", *f.classId)
+	}
+
+	query, args, err := b.build(groupSelect, []string{"g.id"}, q)
+	if err != nil {
+		return Page[Group]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Group]{}, err
+	}
+
+	return collectPage(rows, q, scanGroup, func(g Group) []string {
+		return q.sortKey(map[string]string{"name": g.name}, fmt.Sprint(g.id))
+	})
+}
+
+func GetGroup(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Group, error) {
+	rows, err := db.Query(ctx, groupSelect+" where g.school_id = $1 and g.id = $2", schoolId, id)
+	if err != nil {
+		return Group{}, err
+	}
+	return pgx.CollectOneRow(rows, scanGroup)
+}
+
+func (g Group) Id() int {
+	return g.id
+}
+
+func (g Group) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id      int    `json:"id"`
+		ClassId *int   `json:"classId"`
+		Name    string `json:"name"`
+	}{
+		Id:      g.id,
+		ClassId: g.classId,
+		Name:    g.name,
+	})
+}
+
+type GroupUpdate struct {
+	name    *string
+	classId *int
+}
+
+func ParseGroupUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing group update")
+
+	classId, err := parseOptionalInt(span, f, "class_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid class id (not an int)")
+	}
+
+	update := GroupUpdate{
+		name:    optionalString(span, f, "name"),
+		classId: classId,
+	}
+	if update.name != nil && *update.name == "" {
+		return utils.NewParserError(nil, "Group name can't be empty")
+	} else if update == (GroupUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "group update", update)
+
+	return nil
+}
+
+func (u GroupUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		if u.name != nil {
+			b.set("name", *u.name)
+		}
+		if u.classId != nil {
+			b.set("class_id", *u.classId)
+		}
+		return b.exec(tx, `"group"`, "id = ? and school_id = ?", id, schoolId)
+	}
+}
+
+func DeleteGroup(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, `delete from "group" where id = $1 and school_id = $2`, id, schoolId)
+	}
+}
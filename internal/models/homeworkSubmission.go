@@ -0,0 +1,540 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	SubmissionSubmitted = "submitted"
+	SubmissionMissing   = "missing"
+	SubmissionReviewed  = "reviewed"
+)
+
+var (
+	ErrNotHomework               = errors.New("Note isn't homework")
+	ErrEmptySubmission           = errors.New("Submission has neither content nor files")
+	ErrSubmissionAlreadyReviewed = errors.New("Submission was already reviewed")
+	ErrNoReportForGrade          = errors.New("Lesson of the homework has no report the grade could belong to")
+)
+
+// HomeworkSubmission is the work a student hands in for a homework note
+type HomeworkSubmission struct {
+	id          int
+	noteId      int
+	studentId   uuid.UUID
+	content     *string
+	submittedAt *time.Time
+	status      string
+	late        bool
+	reviewedBy  *uuid.UUID
+	reviewedAt  *time.Time
+	reviewNote  *string
+	gradeId     *int
+	files       []SubmissionFile
+}
+
+// SubmissionFile is a file uploaded with a submission, its data is only loaded for downloads
+type SubmissionFile struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"-"`
+}
+
+func ParseHomeworkSubmission(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing homework submission")
+
+	var content *string
+	if c := strings.TrimSpace(f.Get("content")); c != "" {
+		content = &c
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "homework submission", HomeworkSubmission{
+		id:      -1,
+		content: content,
+		status:  SubmissionSubmitted,
+	})
+
+	return nil
+}
+
+// Attach adds an uploaded file to the submission
+func (s *HomeworkSubmission) Attach(name, contentType string, data []byte) {
+	s.files = append(s.files, SubmissionFile{Name: name, ContentType: contentType, Data: data})
+}
+
+// SubmitForNote hands the work in, a repeated submission replaces the previous one and its files
+// until it is reviewed. Work handed in after the date of the homework is late. pgx.ErrNoRows means
+// the note isn't in any lesson of the student
+func (s *HomeworkSubmission) SubmitForNote(studentId string, noteId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var noteType string
+		var late bool
+		err := tx.QueryRow(context.TODO(), `
+			select n.type::text, coalesce(nd.date < current_date, false)
+			from note n
+			left join note_with_date nd on nd.id = n.id
+			where n.id = $2 and n.timetable_id in (`+studentLessons+`)`,
+			studentId, noteId,
+		).Scan(&noteType, &late)
+		if err != nil {
+			return err
+		}
+		if noteType != "homework" {
+			return ErrNotHomework
+		}
+		if s.content == nil && len(s.files) == 0 {
+			return ErrEmptySubmission
+		}
+
+		var id int
+		err = tx.QueryRow(context.TODO(), `
+			insert into homework_submission (note_id, student_id, content, submitted_at, late)
+			values ($1, $2, $3, now(), $4)
+			on conflict (note_id, student_id) do update
+			set content = excluded.content, submitted_at = excluded.submitted_at, status = 'submitted',
+				late = homework_submission.late or excluded.late
+			where homework_submission.status <> 'reviewed'
+			returning id`,
+			noteId, studentId, s.content, late,
+		).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSubmissionAlreadyReviewed
+		} else if err != nil {
+			return err
+		}
+
+		batch := &pgx.Batch{}
+		batch.Queue("delete from homework_submission_file where submission_id = $1", id)
+		for _, f := range s.files {
+			batch.Queue(
+				"insert into homework_submission_file (submission_id, name, content_type, data) values ($1, $2, $3, $4)",
+				id, f.Name, f.ContentType, f.Data,
+			)
+		}
+		if err := tx.SendBatch(context.TODO(), batch).Close(); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(context.TODO(), homeworkSubmissionSelect+" where hs.id = $1", id)
+		if err != nil {
+			return err
+		}
+		*s, err = pgx.CollectOneRow(rows, scanHomeworkSubmission)
+		if err != nil {
+			return err
+		}
+		s.files, err = submissionFiles(context.TODO(), tx, id)
+		return err
+	}
+}
+
+func submissionFiles(ctx context.Context, db querier, id int) ([]SubmissionFile, error) {
+	rows, err := db.Query(ctx,
+		"select id, name, content_type from homework_submission_file where submission_id = $1 order by id", id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (f SubmissionFile, err error) {
+		err = row.Scan(&f.Id, &f.Name, &f.ContentType)
+		return
+	})
+}
+
+type HomeworkSubmissionFilter struct {
+	noteId    *int
+	studentId *uuid.UUID
+	status    *string
+}
+
+func ParseHomeworkSubmissionFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing homework submission filter")
+
+	noteId, err := parseOptionalInt(span, f, "note_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid note id (not an int)")
+	}
+	studentId, err := parseOptionalUuid(span, f, "student_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid student id")
+	}
+	status := optionalString(span, f, "status")
+	if status != nil && *status != SubmissionSubmitted && *status != SubmissionMissing && *status != SubmissionReviewed {
+		return utils.NewParserError(nil, "Invalid status (has to be submitted, missing or reviewed)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "homework submission filter", HomeworkSubmissionFilter{
+		noteId:    noteId,
+		studentId: studentId,
+		status:    status,
+	})
+
+	return nil
+}
+
+const homeworkSubmissionSelect = `
+	select hs.id, hs.note_id, hs.student_id, hs.content, hs.submitted_at, hs.status::text, hs.late,
+		hs.reviewed_by, hs.reviewed_at, hs.review_note, hs.grade_id
+	from homework_submission hs
+	join users s on s.id = hs.student_id`
+
+func scanHomeworkSubmission(row pgx.CollectableRow) (s HomeworkSubmission, err error) {
+	err = row.Scan(
+		&s.id, &s.noteId, &s.studentId, &s.content, &s.submittedAt, &s.status, &s.late,
+		&s.reviewedBy, &s.reviewedAt, &s.reviewNote, &s.gradeId,
+	)
+	return
+}
+
+// ListHomeworkSubmissions lists submissions without their files
+func ListHomeworkSubmissions(ctx context.Context, db *pgxpool.Pool, schoolId int, userId string, role utils.Role, q ListQuery, f HomeworkSubmissionFilter) (Page[HomeworkSubmission], error) {
+	b := listBuilder{}
+	studentRecordVisibleTo(&b, schoolId, userId, role)
+	if f.noteId != nil {
+		b.where("hs.note_id = ?", *f.noteId)
+	}
+	if f.studentId != nil {
+		b.where("hs.student_id = ?", *f.studentId)
+	}
+	if f.status != nil {
+		b.where("hs.status = ?", *f.status)
+	}
+
+	query, args, err := b.build(homeworkSubmissionSelect, []string{"hs.id"}, q)
+	if err != nil {
+		return Page[HomeworkSubmission]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[HomeworkSubmission]{}, err
+	}
+
+	return collectPage(rows, q, scanHomeworkSubmission, func(s HomeworkSubmission) []string {
+		return []string{fmt.Sprint(s.id)}
+	})
+}
+
+// GetHomeworkSubmission returns the submission with the names of its files
+func GetHomeworkSubmission(ctx context.Context, db *pgxpool.Pool, schoolId int, userId string, role utils.Role, id int) (HomeworkSubmission, error) {
+	b := listBuilder{}
+	studentRecordVisibleTo(&b, schoolId, userId, role)
+	b.where("hs.id = ?", id)
+
+	rows, err := db.Query(ctx, homeworkSubmissionSelect+" where "+strings.Join(b.conds, " and "), b.args...)
+	if err != nil {
+		return HomeworkSubmission{}, err
+	}
+	submission, err := pgx.CollectOneRow(rows, scanHomeworkSubmission)
+	if err != nil {
+		return HomeworkSubmission{}, err
+	}
+
+	submission.files, err = submissionFiles(ctx, db, id)
+	return submission, err
+}
+
+// GetSubmissionFile returns a file of the submission with its data,
+// pgx.ErrNoRows means the submission isn't visible or doesn't have the file
+func GetSubmissionFile(ctx context.Context, db *pgxpool.Pool, schoolId int, userId string, role utils.Role, id, fileId int) (SubmissionFile, error) {
+	if _, err := GetHomeworkSubmission(ctx, db, schoolId, userId, role, id); err != nil {
+		return SubmissionFile{}, err
+	}
+
+	var f SubmissionFile
+	err := db.QueryRow(ctx,
+		"select id, name, content_type, data from homework_submission_file where id = $1 and submission_id = $2",
+		fileId, id,
+	).Scan(&f.Id, &f.Name, &f.ContentType, &f.Data)
+	return f, err
+}
+
+func (s HomeworkSubmission) Id() int {
+	return s.id
+}
+
+func (s HomeworkSubmission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id          int              `json:"id"`
+		NoteId      int              `json:"noteId"`
+		StudentId   uuid.UUID        `json:"studentId"`
+		Content     *string          `json:"content"`
+		SubmittedAt *time.Time       `json:"submittedAt"`
+		Status      string           `json:"status"`
+		Late        bool             `json:"late"`
+		ReviewedBy  *uuid.UUID       `json:"reviewedBy"`
+		ReviewedAt  *time.Time       `json:"reviewedAt"`
+		ReviewNote  *string          `json:"reviewNote"`
+		GradeId     *int             `json:"gradeId"`
+		Files       []SubmissionFile `json:"files,omitempty"`
+	}{
+		Id:          s.id,
+		NoteId:      s.noteId,
+		StudentId:   s.studentId,
+		Content:     s.content,
+		SubmittedAt: s.submittedAt,
+		Status:      s.status,
+		Late:        s.late,
+		ReviewedBy:  s.reviewedBy,
+		ReviewedAt:  s.reviewedAt,
+		ReviewNote:  s.reviewNote,
+		GradeId:     s.gradeId,
+		Files:       s.files,
+	})
+}
+
+// checkHomeworkInSchool returns pgx.ErrNoRows when the note isn't in the school and ErrNotHomework when it is a test
+func checkHomeworkInSchool(ctx context.Context, db querier, schoolId, noteId int) error {
+	rows, err := db.Query(ctx,
+		"select n.type::text from note n join timetable t on t.id = n.timetable_id where n.id = $1 and t.school_id = $2",
+		noteId, schoolId,
+	)
+	if err != nil {
+		return err
+	}
+	noteType, err := pgx.CollectOneRow(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	if noteType != "homework" {
+		return ErrNotHomework
+	}
+	return nil
+}
+
+// SubmissionMatrixRow is a student the homework was given to with their submission, the submission
+// fields are nil when the student hasn't submitted anything yet
+type SubmissionMatrixRow struct {
+	StudentId    string     `json:"studentId"`
+	Student      string     `json:"student"`
+	SubmissionId *int       `json:"submissionId"`
+	Status       *string    `json:"status"`
+	Late         bool       `json:"late"`
+	SubmittedAt  *time.Time `json:"submittedAt"`
+	GradeId      *int       `json:"gradeId"`
+}
+
+// GetSubmissionMatrix lists students of the groups of the homework's lesson together with
+// students who submitted before they left them, pgx.ErrNoRows means the note isn't in the school
+func GetSubmissionMatrix(ctx context.Context, db querier, schoolId, noteId int) ([]SubmissionMatrixRow, error) {
+	if err := checkHomeworkInSchool(ctx, db, schoolId, noteId); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		with students as (
+			select ug.user_id
+			from note n
+			join timetable_group tg on tg.timetable_id = n.timetable_id
+			join users_group ug on ug.group_id = tg.group_id
+			where n.id = $1
+			union
+			select student_id from homework_submission where note_id = $1
+		)
+		select s.id::text, s.name || ' ' || s.surname, hs.id, hs.status::text, coalesce(hs.late, false),
+			hs.submitted_at, hs.grade_id
+		from students
+		join users s on s.id = students.user_id
+		left join homework_submission hs on hs.note_id = $1 and hs.student_id = s.id
+		where s.role = 'student' or hs.id is not null
+		order by s.surname, s.name, s.id::text`,
+		noteId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (m SubmissionMatrixRow, err error) {
+		err = row.Scan(&m.StudentId, &m.Student, &m.SubmissionId, &m.Status, &m.Late, &m.SubmittedAt, &m.GradeId)
+		return
+	})
+}
+
+const (
+	MarkMissing = "missing"
+	MarkLate    = "late"
+	MarkOnTime  = "on_time"
+)
+
+// SubmissionMark is what the teacher says about a student's homework apart from reviewing it
+type SubmissionMark struct {
+	mark string
+}
+
+func ParseSubmissionMark(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing submission mark")
+
+	mark := f.Get("mark")
+	if mark != MarkMissing && mark != MarkLate && mark != MarkOnTime {
+		return utils.NewParserError(nil, "Invalid mark (has to be missing, late or on_time)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "submission mark", SubmissionMark{mark: mark})
+
+	return nil
+}
+
+// MarkInDB marks the homework of the student missing, which also works for students who haven't
+// submitted anything, or the submission late or on time. pgx.ErrNoRows means the note isn't in the
+// school or, for late and on time, that the student hasn't submitted
+func (m SubmissionMark) MarkInDB(schoolId, noteId int, studentId uuid.UUID) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		ctx := context.TODO()
+		if err := checkHomeworkInSchool(ctx, tx, schoolId, noteId); err != nil {
+			return err
+		}
+
+		var assigned bool
+		err := tx.QueryRow(ctx, `
+			select exists (
+				select 1
+				from note n
+				join timetable_group tg on tg.timetable_id = n.timetable_id
+				join users_group ug on ug.group_id = tg.group_id
+				where n.id = $1 and ug.user_id = $2
+			) or exists (
+				select 1 from homework_submission where note_id = $1 and student_id = $2
+			)`,
+			noteId, studentId,
+		).Scan(&assigned)
+		if err != nil {
+			return err
+		}
+		if !assigned {
+			return ErrStudentNotInLesson
+		}
+
+		if m.mark == MarkMissing {
+			_, err := tx.Exec(ctx, `
+				insert into homework_submission (note_id, student_id, status) values ($1, $2, 'missing')
+				on conflict (note_id, student_id) do update set status = 'missing'`,
+				noteId, studentId,
+			)
+			return err
+		}
+		return execAffectingRow(tx,
+			"update homework_submission set late = $1 where note_id = $2 and student_id = $3",
+			m.mark == MarkLate, noteId, studentId,
+		)
+	}
+}
+
+// SubmissionReview is the teacher's feedback on a submission, which can be graded right away
+type SubmissionReview struct {
+	note     *string
+	value    *int
+	weight   *int
+	reportId *int
+}
+
+func ParseSubmissionReview(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing submission review")
+
+	review := SubmissionReview{note: optionalString(span, f, "note")}
+	var err error
+	if review.value, err = parseOptionalInt(span, f, "value"); err != nil {
+		return utils.NewParserError(err, "Invalid grade value (not an int)")
+	} else if review.value != nil {
+		if err := validateGradeValue(*review.value); err != nil {
+			return err
+		}
+	}
+	if review.weight, err = parseOptionalInt(span, f, "weight"); err != nil {
+		return utils.NewParserError(err, "Invalid grade weight (not an int)")
+	} else if review.weight != nil {
+		if err := validateGradeWeight(*review.weight); err != nil {
+			return err
+		}
+	}
+	if (review.value == nil) != (review.weight == nil) {
+		return utils.NewParserError(nil, "Grade needs both value and weight")
+	}
+	if review.reportId, err = parseOptionalInt(span, f, "report_id"); err != nil {
+		return utils.NewParserError(err, "Invalid report id (not an int)")
+	} else if review.reportId != nil && review.value == nil {
+		return utils.NewParserError(nil, "Report id is only used for grades")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "submission review", review)
+
+	return nil
+}
+
+// ReviewInDB marks the submission reviewed. With a grade value the submission is graded: a grade it
+// already has is updated, otherwise a new one is given in the report (the latest report of the
+// homework's lesson by default). pgx.ErrNoRows means the submission isn't in the school
+func (r SubmissionReview) ReviewInDB(schoolId int, reviewerId string, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		ctx := context.TODO()
+		var studentId uuid.UUID
+		var timetableId int
+		var gradeId *int
+		err := tx.QueryRow(ctx, `
+			select hs.student_id, n.timetable_id, hs.grade_id
+			from homework_submission hs
+			join users s on s.id = hs.student_id
+			join note n on n.id = hs.note_id
+			where hs.id = $1 and s.school_id = $2
+			for update of hs`,
+			id, schoolId,
+		).Scan(&studentId, &timetableId, &gradeId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			"update homework_submission set status = 'reviewed', reviewed_by = $1, reviewed_at = now(), review_note = $2 where id = $3",
+			reviewerId, r.note, id,
+		)
+		if err != nil || r.value == nil {
+			return err
+		}
+
+		if gradeId != nil {
+			_, err := tx.Exec(ctx, "update grade set value = $1, weight = $2 where id = $3", *r.value, *r.weight, *gradeId)
+			return err
+		}
+
+		var reportId int
+		if r.reportId != nil {
+			err := checkReportInSchool(ctx, tx, schoolId, *r.reportId)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrForeignReference
+			} else if err != nil {
+				return err
+			}
+			reportId = *r.reportId
+		} else {
+			err := tx.QueryRow(ctx,
+				"select id from report where timetable_id = $1 order by reported_at desc, id desc limit 1", timetableId,
+			).Scan(&reportId)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNoReportForGrade
+			} else if err != nil {
+				return err
+			}
+		}
+
+		grade := Grade{studentId: studentId, reportId: reportId, value: *r.value, weight: *r.weight}
+		if err := grade.SaveToDB(tx); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "update homework_submission set grade_id = $1 where id = $2", grade.id, id)
+		return err
+	}
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+var (
+	ErrInvalidCursor = errors.New("Invalid cursor")
+	ErrInvalidSort   = errors.New("Invalid sort")
+)
+
+// ListQuery holds the pagination and sorting shared by all list endpoints,
+// the cursor is the (opaque to the client) key of the last returned row.
+// Sort names a column the list allows sorting by, ties are broken by the key
+type ListQuery struct {
+	cursor []string
+	limit  int
+	sort   string
+	desc   bool
+}
+
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+func ParseListQuery(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing list query")
+
+	query := ListQuery{
+		limit: defaultListLimit,
+	}
+
+	if limitUnprocessed := f.Get("limit"); limitUnprocessed != "" {
+		limit, err := utils.ParseInt(span, "limit", limitUnprocessed)
+		if err != nil {
+			return utils.NewParserError(err, "Invalid limit (not an int)")
+		} else if limit < 1 {
+			return utils.NewParserError(nil, "Invalid limit (can't be less than 1)")
+		} else if limit > maxListLimit {
+			return utils.NewParserError(nil, fmt.Sprintf("Invalid limit (can't be more than %d)", maxListLimit))
+		}
+		query.limit = limit
+	}
+
+	//the columns differ by list, so the sort is checked when the list is built
+	query.sort = f.Get("sort")
+	span.SetAttributes(attribute.String("sort", query.sort))
+
+	order := f.Get("order")
+	span.SetAttributes(attribute.String("order", order))
+	switch order {
+	case "", "asc":
+	case "desc":
+		query.desc = true
+	default:
+		return utils.NewParserError(nil, "Invalid order (should be asc or desc)")
+	}
+
+	if cursorUnprocessed := f.Get("cursor"); cursorUnprocessed != "" {
+		span.SetAttributes(attribute.String("cursor", cursorUnprocessed))
+		cursor, err := base64.RawURLEncoding.DecodeString(cursorUnprocessed)
+		if err != nil {
+			return utils.NewParserError(err, "Invalid cursor")
+		}
+		//sorted values can contain anything, so the key is a json array
+		if err := json.Unmarshal(cursor, &query.cursor); err != nil || query.cursor == nil {
+			return utils.NewParserError(err, "Invalid cursor")
+		}
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "list query", query)
+
+	return nil
+}
+
+func encodeCursor(key []string) string {
+	cursor, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+// sortKey is the key of the row in a list sorted by one of values (column name to the value of the row)
+func (q ListQuery) sortKey(values map[string]string, key ...string) []string {
+	if q.sort == "" {
+		return key
+	}
+	return append([]string{values[q.sort]}, key...)
+}
+
+// listBuilder collects where conditions, "?" in a condition is replaced by
+// the next positional argument. Sorts are the columns the list can be sorted by
+type listBuilder struct {
+	conds []string
+	args  []any
+	sorts map[string]string
+}
+
+func (b *listBuilder) where(cond string, args ...any) {
+	for _, arg := range args {
+		b.args = append(b.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(b.args)), 1)
+	}
+	b.conds = append(b.conds, cond)
+}
+
+func (b *listBuilder) build(query string, keys []string, q ListQuery) (string, []any, error) {
+	if q.sort != "" {
+		column, ok := b.sorts[q.sort]
+		if !ok {
+			return "", nil, ErrInvalidSort
+		}
+		keys = append([]string{column}, keys...)
+	}
+
+	if q.cursor != nil {
+		if len(q.cursor) != len(keys) {
+			return "", nil, ErrInvalidCursor
+		}
+
+		op := ">"
+		if q.desc {
+			op = "<"
+		}
+		placeholders := make([]string, len(keys))
+		args := make([]any, len(keys))
+		for i, value := range q.cursor {
+			placeholders[i] = "?"
+			args[i] = value
+		}
+		b.where(fmt.Sprintf(
+			"(%s) %s (%s)",
+			strings.Join(keys, ", "), op, strings.Join(placeholders, ", "),
+		), args...)
+	}
+
+	if len(b.conds) > 0 {
+		query += " where " + strings.Join(b.conds, " and ")
+	}
+
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		if q.desc {
+			order[i] = key + " desc"
+		} else {
+			order[i] = key + " asc"
+		}
+	}
+	query += " order by " + strings.Join(order, ", ")
+
+	b.args = append(b.args, q.limit+1) //one more to know if there is a next page
+	query += fmt.Sprintf(" limit $%d", len(b.args))
+
+	return query, b.args, nil
+}
+
+func collectPage[T any](
+	rows pgx.Rows,
+	q ListQuery,
+	scan pgx.RowToFunc[T],
+	key func(T) []string,
+) (Page[T], error) {
+	items, err := pgx.CollectRows(rows, scan)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	page := Page[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(page.Items) > q.limit {
+		page.Items = page.Items[:q.limit]
+		page.NextCursor = encodeCursor(key(page.Items[q.limit-1]))
+	}
+
+	return page, nil
+}
+
+func parseOptionalInt(span trace.Span, f url.Values, key string) (*int, error) {
+	value := f.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	intValue, err := utils.ParseInt(span, key, value)
+	if err != nil {
+		return nil, err
+	}
+	return &intValue, nil
+}
+
+func parseOptionalUuid(span trace.Span, f url.Values, key string) (*uuid.UUID, error) {
+	value := f.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	uuidValue, err := utils.ParseUuid(span, key, value)
+	if err != nil {
+		return nil, err
+	}
+	return &uuidValue, nil
+}
+
+func parseOptionalTime(span trace.Span, f url.Values, key, format string) (*time.Time, error) {
+	value := f.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := utils.ParseTime(span, key, value, format)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// nullableUuid is used for optional foreign keys which are scanned as uuid.Nil
+// when NULL, so they are rendered as null instead of the zero uuid
+func nullableUuid(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
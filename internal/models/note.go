@@ -2,11 +2,15 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/url"
 	"time"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -17,6 +21,8 @@ type Note struct {
 	noteType    string
 	content     string
 	date        string
+	// clashes are notes the note went over the limit with, when the limit only warns
+	clashes []NoteClash
 }
 
 func ParseNote(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
@@ -64,18 +70,299 @@ func ParseNote(f url.Values, parserCtx context.Context, handlerCtx *context.Cont
 	return nil
 }
 
-func (n Note) SaveToDB(tx pgx.Tx) (err error) {
+func (n *Note) SaveToDB(tx pgx.Tx) error {
+	var rows pgx.Rows
+	var err error
 	if n.date == "" {
-		_, err = tx.Exec(context.TODO(),
-			`insert into note (type, content, timetable_id) values ($1, $2, $3)`,
+		rows, err = tx.Query(context.TODO(),
+			`insert into note (type, content, timetable_id) values ($1, $2, $3)
+			returning id, timetable_id, type, content, ''`,
 			n.noteType, n.content, n.timetableId,
 		)
 	} else {
-		_, err = tx.Exec(context.TODO(),
-			`insert into note_with_date (type, content, timetable_id, date) values ($1, $2, $3, $4)`,
+		rows, err = tx.Query(context.TODO(),
+			`insert into note_with_date (type, content, timetable_id, date) values ($1, $2, $3, $4)
+			returning id, timetable_id, type, content, to_char(date, 'YYYY-MM-DD')`,
 			n.noteType, n.content, n.timetableId, n.date,
 		)
 	}
+	if err != nil {
+		return err
+	}
+	*n, err = pgx.CollectOneRow(rows, scanNote)
+	return err
+}
+
+// SaveWithinLimits saves the note to a lesson of the school, a dated note going over the limit of its type
+// is rejected with NoteLimitError or, when the limit only warns, saved with the clashing notes
+func (n *Note) SaveWithinLimits(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		//note_with_date doesn't inherit the foreign key of note, the share lock is what keeps
+		//the timetable from being deleted under a new dated note (see deleteTimetable)
+		var found int
+		err := tx.QueryRow(context.TODO(), "select id from timetable where id = $1 and school_id = $2 for share", n.timetableId, schoolId).Scan(&found)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrForeignReference
+		} else if err != nil {
+			return err
+		}
+
+		var clashes []NoteClash
+		if n.date != "" {
+			date, err := time.Parse(time.DateOnly, n.date)
+			if err != nil {
+				return err
+			}
+			var limit *NoteLimit
+			clashes, limit, err = noteClashes(tx, schoolId, n.timetableId, -1, n.noteType, date)
+			if err != nil {
+				return err
+			}
+			if len(clashes) > 0 && limit.mode == LimitReject {
+				return &NoteLimitError{Type: n.noteType, Date: n.date, MaxPerDay: limit.maxPerDay, Clashes: clashes}
+			}
+		}
+
+		if err := n.SaveToDB(tx); err != nil {
+			return err
+		}
+		n.clashes = clashes
+		if n.noteType == "test" && n.date != "" {
+			return notifyOf(NotificationTest, n.id)(tx)
+		}
+		return nil
+	}
+}
+
+type NoteFilter struct {
+	timetableId *int
+	noteType    *string
+	from        *time.Time
+	to          *time.Time
+}
+
+func ParseNoteFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing note filter")
+
+	timetableId, err := parseOptionalInt(span, f, "timetable_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid timetable id (not an int)")
+	}
+	filter := NoteFilter{timetableId: timetableId}
 
+	if noteType := f.Get("type"); noteType != "" {
+		span.SetAttributes(attribute.String("type", noteType))
+		if noteType != "homework" && noteType != "test" {
+			return utils.NewParserError(nil, "Invalid note type")
+		}
+		filter.noteType = &noteType
+	}
+	if filter.from, err = parseOptionalTime(span, f, "from", time.DateOnly); err != nil {
+		return utils.NewParserError(err, "Invalid from date")
+	}
+	if filter.to, err = parseOptionalTime(span, f, "to", time.DateOnly); err != nil {
+		return utils.NewParserError(err, "Invalid to date")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "note filter", filter)
+
+	return nil
+}
+
+// note also selects rows of note_with_date (inheritance), the date is joined from it
+const noteSelect = `
+	select n.id, n.timetable_id, n.type, n.content, coalesce(to_char(nd.date, 'YYYY-MM-DD'), '')
+	from note n
+	left join note_with_date nd on nd.id = n.id
+	join timetable t on t.id = n.timetable_id`
+
+func scanNote(row pgx.CollectableRow) (n Note, err error) {
+	err = row.Scan(&n.id, &n.timetableId, &n.noteType, &n.content, &n.date)
 	return
 }
+
+// ListNotes returns notes, when from or to is set only notes with date are returned
+func ListNotes(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f NoteFilter) (Page[Note], error) {
+	b := listBuilder{}
+	b.where("t.school_id = ?", schoolId)
+	if f.timetableId != nil {
+		b.where("n.timetable_id = ?", *f.timetableId)
+	}
+	if f.noteType != nil {
+		b.where("n.type = ?", *f.noteType)
+	}
+	if f.from != nil {
+		b.where("nd.date >= ?", *f.from)
+	}
+	if f.to != nil {
+		b.where("nd.date <= ?", *f.to)
+	}
+
+	query, args, err := b.build(noteSelect, []string{"n.id"}, q)
+	if err != nil {
+		return Page[Note]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Note]{}, err
+	}
+
+	return collectPage(rows, q, scanNote, func(n Note) []string {
+		return []string{fmt.Sprint(n.id)}
+	})
+}
+
+func GetNote(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Note, error) {
+	rows, err := db.Query(ctx, noteSelect+" where t.school_id = $1 and n.id = $2", schoolId, id)
+	if err != nil {
+		return Note{}, err
+	}
+	return pgx.CollectOneRow(rows, scanNote)
+}
+
+func (n Note) Id() int {
+	return n.id
+}
+
+func (n Note) MarshalJSON() ([]byte, error) {
+	var date *string
+	if n.date != "" {
+		date = &n.date
+	}
+
+	return json.Marshal(struct {
+		Id          int         `json:"id"`
+		TimetableId int         `json:"timetableId"`
+		Type        string      `json:"type"`
+		Content     string      `json:"content"`
+		Date        *string     `json:"date"`
+		Clashes     []NoteClash `json:"clashes,omitempty"`
+	}{
+		Id:          n.id,
+		TimetableId: n.timetableId,
+		Type:        n.noteType,
+		Content:     n.content,
+		Date:        date,
+		Clashes:     n.clashes,
+	})
+}
+
+var ErrNoteWithoutDate = errors.New("Note doesn't have date")
+
+type NoteUpdate struct {
+	noteType *string
+	content  *string
+	date     *time.Time
+}
+
+func ParseNoteUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing note update")
+
+	update := NoteUpdate{
+		noteType: optionalString(span, f, "type"),
+		content:  optionalString(span, f, "content"),
+	}
+	if update.noteType != nil && *update.noteType != "homework" && *update.noteType != "test" {
+		return utils.NewParserError(nil, "Invalid note type")
+	} else if update.content != nil && *update.content == "" {
+		return utils.NewParserError(nil, "Content can't be empty")
+	}
+
+	var err error
+	if update.date, err = parseOptionalTime(span, f, "date", time.DateOnly); err != nil {
+		return utils.NewParserError(err, "Invalid date")
+	}
+
+	if update == (NoteUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "note update", update)
+
+	return nil
+}
+
+func checkNoteInSchool(tx pgx.Tx, schoolId, id int) error {
+	var found int
+	return tx.QueryRow(context.TODO(),
+		"select n.id from note n join timetable t on t.id = n.timetable_id where n.id = $1 and t.school_id = $2",
+		id, schoolId,
+	).Scan(&found)
+}
+
+// checkNoteWithinLimit rejects the note with NoteLimitError if its date or type put it over the limit.
+// The note is already changed, so there is no note to save with clashes of a limit which only warns
+func checkNoteWithinLimit(tx pgx.Tx, schoolId, id int) error {
+	var timetableId int
+	var noteType string
+	var date time.Time
+	err := tx.QueryRow(context.TODO(),
+		"select timetable_id, type::text, date from note_with_date where id = $1", id,
+	).Scan(&timetableId, &noteType, &date)
+	if errors.Is(err, pgx.ErrNoRows) {
+		//notes without a date have no limit
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	clashes, limit, err := noteClashes(tx, schoolId, timetableId, id, noteType, date)
+	if err != nil {
+		return err
+	}
+	if len(clashes) > 0 && limit.mode == LimitReject {
+		return &NoteLimitError{Type: noteType, Date: date.Format(time.DateOnly), MaxPerDay: limit.maxPerDay, Clashes: clashes}
+	}
+	return nil
+}
+
+// UpdateInDB updates note, date can only be changed for notes which already have one.
+// A dated note moved to another day or changed to another type is checked against the limits
+func (u NoteUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkNoteInSchool(tx, schoolId, id); err != nil {
+			return err
+		}
+
+		b := updateBuilder{}
+		if u.noteType != nil {
+			b.set("type", *u.noteType)
+		}
+		if u.content != nil {
+			b.set("content", *u.content)
+		}
+		if err := b.exec(tx, "note", "id = ?", id); err != nil {
+			return err
+		}
+
+		if u.date != nil {
+			err := execAffectingRow(tx, "update note_with_date set date = $1 where id = $2", *u.date, id)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNoteWithoutDate
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if u.noteType != nil || u.date != nil {
+			return checkNoteWithinLimit(tx, schoolId, id)
+		}
+		return nil
+	}
+}
+
+func DeleteNote(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkNoteInSchool(tx, schoolId, id); err != nil {
+			return err
+		}
+		//submissions can't reference the note (see the migration), so they go first
+		if _, err := tx.Exec(context.TODO(), "delete from homework_submission where note_id = $1", id); err != nil {
+			return err
+		}
+		//deleting from note also deletes from note_with_date because of inheritance
+		return execAffectingRow(tx, "delete from note where id = $1", id)
+	}
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxCalendarDays keeps the calendar within one school year
+const maxCalendarDays = 366
+
+type NoteCalendarQuery struct {
+	from    time.Time
+	to      time.Time
+	groupId *int
+	classId *int
+}
+
+// ParseNoteCalendarQuery reads the days from-to, four weeks from today by default
+func ParseNoteCalendarQuery(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing note calendar query")
+
+	from, err := parseOptionalTime(span, f, "from", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid from date")
+	}
+	to, err := parseOptionalTime(span, f, "to", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid to date")
+	}
+	q := NoteCalendarQuery{}
+	if from != nil {
+		q.from = *from
+	} else {
+		q.from = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	if to != nil {
+		q.to = *to
+	} else {
+		q.to = q.from.AddDate(0, 0, 27)
+	}
+	if q.to.Before(q.from) {
+		return utils.NewParserError(nil, "To can't be before from")
+	} else if q.to.Sub(q.from) >= maxCalendarDays*24*time.Hour {
+		return utils.NewParserError(nil, "Calendar can't be longer than a year")
+	}
+	if q.groupId, err = parseOptionalInt(span, f, "group_id"); err != nil {
+		return utils.NewParserError(err, "Invalid group id (not an int)")
+	}
+	if q.classId, err = parseOptionalInt(span, f, "class_id"); err != nil {
+		return utils.NewParserError(err, "Invalid class id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "note calendar query", q)
+
+	return nil
+}
+
+// CalendarNote is a dated homework or test in the calendar
+type CalendarNote struct {
+	Id          int    `json:"id"`
+	TimetableId int    `json:"timetableId"`
+	Subject     string `json:"subject"`
+	Content     string `json:"content"`
+	// scopes are the classes (or groups without a class) the note is given to
+	scopes []string
+}
+
+// NoteCalendarDay are the tests and homework dated on the day, OverLimit tells
+// some class (or group without a class) has more of them than the school allows
+type NoteCalendarDay struct {
+	Date      string         `json:"date"`
+	Tests     []CalendarNote `json:"tests"`
+	Homework  []CalendarNote `json:"homework"`
+	OverLimit bool           `json:"overLimit"`
+}
+
+type NoteCalendar struct {
+	Days   []NoteCalendarDay `json:"days"`
+	Limits []NoteLimit       `json:"limits"`
+}
+
+// GetNoteCalendar lists days with dated tests and homework of the school, or of the group or class.
+// The limits are checked against the notes in the calendar, so a calendar of a group only
+// counts the notes of the group
+func GetNoteCalendar(ctx context.Context, db *pgxpool.Pool, schoolId int, q NoteCalendarQuery) (NoteCalendar, error) {
+	limits, err := ListNoteLimits(ctx, db, schoolId)
+	if err != nil {
+		return NoteCalendar{}, err
+	}
+	if limits == nil {
+		limits = []NoteLimit{}
+	}
+
+	rows, err := db.Query(ctx, `
+		select nd.id, nd.timetable_id, nd.type::text, nd.content, to_char(nd.date, 'YYYY-MM-DD'), coalesce(s.name, ''),
+			array_remove(array_agg(distinct coalesce('class ' || g.class_id, 'group ' || g.id)), null)
+		from note_with_date nd
+		join timetable t on t.id = nd.timetable_id
+		left join timetable_group tg on tg.timetable_id = nd.timetable_id
+		left join "group" g on g.id = tg.group_id
+		left join academic_timetable at on at.id = nd.timetable_id
+		left join subject s on s.id = at.subject_id
+		where t.school_id = $1 and nd.date between $2 and $3
+			and ($4::int is null or nd.timetable_id in (select timetable_id from timetable_group where group_id = $4))
+			and ($5::int is null or nd.timetable_id in (
+				select tg.timetable_id from timetable_group tg join "group" g on g.id = tg.group_id where g.class_id = $5
+			))
+		group by nd.id, nd.timetable_id, nd.type, nd.content, nd.date, s.name
+		order by nd.date, nd.id`,
+		schoolId, q.from, q.to, q.groupId, q.classId,
+	)
+	if err != nil {
+		return NoteCalendar{}, err
+	}
+
+	type calendarRow struct {
+		note     CalendarNote
+		noteType string
+		date     string
+	}
+	notes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (r calendarRow, err error) {
+		err = row.Scan(&r.note.Id, &r.note.TimetableId, &r.noteType, &r.note.Content, &r.date, &r.note.Subject, &r.note.scopes)
+		return
+	})
+	if err != nil {
+		return NoteCalendar{}, err
+	}
+
+	maxPerDay := map[string]int{}
+	for _, l := range limits {
+		maxPerDay[l.noteType] = l.maxPerDay
+	}
+	calendar := NoteCalendar{Days: []NoteCalendarDay{}, Limits: limits}
+	//counts are per note type and scope of the current day
+	var counts map[string]int
+	for _, r := range notes {
+		if len(calendar.Days) == 0 || calendar.Days[len(calendar.Days)-1].Date != r.date {
+			calendar.Days = append(calendar.Days, NoteCalendarDay{Date: r.date, Tests: []CalendarNote{}, Homework: []CalendarNote{}})
+			counts = map[string]int{}
+		}
+		day := &calendar.Days[len(calendar.Days)-1]
+		if r.noteType == "test" {
+			day.Tests = append(day.Tests, r.note)
+		} else {
+			day.Homework = append(day.Homework, r.note)
+		}
+		for _, scope := range r.note.scopes {
+			counts[r.noteType+" "+scope]++
+			if max, ok := maxPerDay[r.noteType]; ok && counts[r.noteType+" "+scope] > max {
+				day.OverLimit = true
+			}
+		}
+	}
+	return calendar, nil
+}
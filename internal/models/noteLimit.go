@@ -0,0 +1,197 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	LimitWarn   = "warn"
+	LimitReject = "reject"
+)
+
+var ErrInvalidNoteType = errors.New("Invalid note type (has to be homework or test)")
+
+// NoteLimit is the school's rule on how many dated notes of a type a class can have on one day.
+// Groups without a class are limited on their own
+type NoteLimit struct {
+	noteType  string
+	maxPerDay int
+	mode      string
+}
+
+func ParseNoteLimit(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing note limit")
+
+	maxPerDay, err := utils.ParseInt(span, "max_per_day", f.Get("max_per_day"))
+	if err != nil {
+		return utils.NewParserError(err, "Invalid max per day (not an int)")
+	} else if maxPerDay < 1 {
+		return utils.NewParserError(nil, "Invalid max per day (can't be less than 1)")
+	}
+	mode := f.Get("mode")
+	if mode == "" {
+		mode = LimitReject
+	} else if mode != LimitWarn && mode != LimitReject {
+		return utils.NewParserError(nil, "Invalid mode (has to be warn or reject)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "note limit", NoteLimit{
+		maxPerDay: maxPerDay,
+		mode:      mode,
+	})
+
+	return nil
+}
+
+// SaveForType sets the limit of the note type, replacing the previous one
+func (l *NoteLimit) SaveForType(schoolId int, noteType string) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if noteType != "homework" && noteType != "test" {
+			return ErrInvalidNoteType
+		}
+		rows, err := tx.Query(context.TODO(), `
+			insert into note_limit (school_id, type, max_per_day, mode) values ($1, $2, $3, $4)
+			on conflict (school_id, type) do update set max_per_day = excluded.max_per_day, mode = excluded.mode
+			returning type::text, max_per_day, mode::text`,
+			schoolId, noteType, l.maxPerDay, l.mode,
+		)
+		if err != nil {
+			return err
+		}
+		*l, err = pgx.CollectOneRow(rows, scanNoteLimit)
+		return err
+	}
+}
+
+const noteLimitSelect = "select type::text, max_per_day, mode::text from note_limit"
+
+func scanNoteLimit(row pgx.CollectableRow) (l NoteLimit, err error) {
+	err = row.Scan(&l.noteType, &l.maxPerDay, &l.mode)
+	return
+}
+
+func ListNoteLimits(ctx context.Context, db querier, schoolId int) ([]NoteLimit, error) {
+	rows, err := db.Query(ctx, noteLimitSelect+" where school_id = $1 order by type", schoolId)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanNoteLimit)
+}
+
+func DeleteNoteLimit(schoolId int, noteType string) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if noteType != "homework" && noteType != "test" {
+			return ErrInvalidNoteType
+		}
+		return execAffectingRow(tx, "delete from note_limit where school_id = $1 and type = $2", schoolId, noteType)
+	}
+}
+
+func (l NoteLimit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type      string `json:"type"`
+		MaxPerDay int    `json:"maxPerDay"`
+		Mode      string `json:"mode"`
+	}{
+		Type:      l.noteType,
+		MaxPerDay: l.maxPerDay,
+		Mode:      l.mode,
+	})
+}
+
+// NoteClash is a note already dated on the day in a class (or a group without a class)
+// which reached the limit. Like TimetableConflict it's a report, so fields are exported
+type NoteClash struct {
+	ClassId     *int   `json:"classId"`
+	GroupId     *int   `json:"groupId"`
+	NoteId      int    `json:"noteId"`
+	TimetableId int    `json:"timetableId"`
+	Subject     string `json:"subject"`
+	Content     string `json:"content"`
+}
+
+func (c NoteClash) String() string {
+	scope := fmt.Sprintf("group %d", *c.GroupId)
+	if c.ClassId != nil {
+		scope = fmt.Sprintf("class %d", *c.ClassId)
+	}
+	return fmt.Sprintf("note %d of lesson %d in %s", c.NoteId, c.TimetableId, scope)
+}
+
+// NoteLimitError rejects a note which would go over the limit of its type
+type NoteLimitError struct {
+	Type      string
+	Date      string
+	MaxPerDay int
+	Clashes   []NoteClash
+}
+
+func (e *NoteLimitError) Error() string {
+	return fmt.Sprintf("at most %d notes of type %s can be dated on %s", e.MaxPerDay, e.Type, e.Date)
+}
+
+// noteClashes returns the notes dated on the day which already reached the limit of the type in any
+// class (or group without a class) of the lesson, except the note with noteId (-1 for a new note).
+// No limit of the type means no clashes. Like checkTimetableConflicts it takes an advisory lock of
+// the school, otherwise two notes saved at once could each miss the other one and both go over the limit
+func noteClashes(tx pgx.Tx, schoolId, timetableId, noteId int, noteType string, date time.Time) ([]NoteClash, *NoteLimit, error) {
+	if _, err := tx.Exec(context.TODO(), "select pg_advisory_xact_lock(hashtext('note_limit'), $1)", schoolId); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := tx.Query(context.TODO(), noteLimitSelect+" where school_id = $1 and type = $2", schoolId, noteType)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit, err := pgx.CollectOneRow(rows, scanNoteLimit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = tx.Query(context.TODO(), `
+		with scopes as (
+			select distinct g.class_id, case when g.class_id is null then g.id end as group_id
+			from timetable_group tg
+			join "group" g on g.id = tg.group_id
+			where tg.timetable_id = $1
+		), same_day as (
+			select distinct s.class_id, s.group_id, nd.id, nd.timetable_id, nd.content
+			from note_with_date nd
+			join timetable t on t.id = nd.timetable_id
+			join timetable_group tg on tg.timetable_id = nd.timetable_id
+			join "group" g on g.id = tg.group_id
+			join scopes s on s.class_id = g.class_id or s.group_id = g.id
+			where t.school_id = $2 and nd.type = $3 and nd.date = $4 and nd.id <> $6
+		)
+		select d.class_id, d.group_id, d.id, d.timetable_id, coalesce(sub.name, ''), d.content
+		from same_day d
+		left join academic_timetable at on at.id = d.timetable_id
+		left join subject sub on sub.id = at.subject_id
+		where (
+			select count(*) from same_day o
+			where o.class_id is not distinct from d.class_id and o.group_id is not distinct from d.group_id
+		) >= $5
+		order by d.class_id, d.group_id, d.id`,
+		timetableId, schoolId, noteType, date, limit.maxPerDay, noteId,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	clashes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (c NoteClash, err error) {
+		err = row.Scan(&c.ClassId, &c.GroupId, &c.NoteId, &c.TimetableId, &c.Subject, &c.Content)
+		return
+	})
+	return clashes, &limit, err
+}
@@ -0,0 +1,519 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	NotificationGrade        = "grade"
+	NotificationSubstitution = "substitution"
+	NotificationTest         = "test"
+	NotificationExcuse       = "excuse"
+
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+)
+
+var (
+	notificationKinds    = []string{NotificationGrade, NotificationSubstitution, NotificationTest, NotificationExcuse}
+	notificationChannels = []string{ChannelInApp, ChannelEmail}
+)
+
+// Notification tells a user about something which happened at school, the ones
+// delivered through the in-app channel make up the user's inbox
+type Notification struct {
+	id        int
+	userId    uuid.UUID
+	kind      string
+	title     string
+	body      string
+	link      *string
+	createdAt time.Time
+	readAt    *time.Time
+	// email is the address of the user, it is only known while the notification is delivered
+	email string
+}
+
+// TopicNotification is the outbox topic of notification events, their notifications are delivered
+// through each channel as events of the channel's topic, so a failed delivery is retried on its own
+const TopicNotification = "notification"
+
+func notificationTopic(channel string) string {
+	return TopicNotification + "." + channel
+}
+
+// notificationEvent is something users are notified about, the kind of notification and the record it is about
+type notificationEvent struct {
+	Kind string `json:"kind"`
+	Id   int    `json:"id"`
+}
+
+// notifyOf adds the event to the outbox, who should know is found and the notifications are worded
+// once the worker gets to it
+func notifyOf(kind string, id int) utils.TxFunc {
+	return AppendToOutbox(TopicNotification, notificationEvent{Kind: kind, Id: id})
+}
+
+func (e notificationEvent) notifications(ctx context.Context, db querier) ([]Notification, error) {
+	switch e.Kind {
+	case NotificationGrade:
+		return gradeNotifications(ctx, db, e.Id)
+	case NotificationSubstitution:
+		return substitutionNotifications(ctx, db, e.Id)
+	case NotificationTest:
+		return testNotifications(ctx, db, e.Id)
+	case NotificationExcuse:
+		return excuseNotifications(ctx, db, e.Id)
+	}
+	return nil, fmt.Errorf("unknown notification kind %s", e.Kind)
+}
+
+// eventRecipients are the students of the students cte (column id) and their parents. Parents
+// are told which child the notification is about (own is false for them)
+const eventRecipients = `
+	recipients as (
+		select s.id as user_id, s.email, true as own, s.id as student_id
+		from users s
+		where s.id in (select id from students)
+		union all
+		select p.id, p.email, false, pc.child_id
+		from parent_child pc
+		join users p on p.id = pc.parent_id
+		where pc.child_id in (select id from students)
+	)`
+
+// collectEventNotifications words notifications of the query which returns recipients (user_id, email,
+// own, student_id) with the student's name, the subject and two details the phrase puts together
+func collectEventNotifications(ctx context.Context, db querier, kind string, phrase func(subject, what, when string) (string, string), query string, args ...any) ([]Notification, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (n Notification, err error) {
+		var own bool
+		var studentId uuid.UUID
+		var student, subject, what, when string
+		if err = row.Scan(&n.userId, &n.email, &own, &studentId, &student, &subject, &what, &when); err != nil {
+			return
+		}
+		n.kind = kind
+		n.title, n.body = phrase(subject, what, when)
+		link := "/"
+		if !own {
+			n.body = student + ": " + n.body
+			link = "/parent?child=" + studentId.String()
+		}
+		n.link = &link
+		return
+	})
+}
+
+// gradeNotifications notify the student and their parents about the grade
+func gradeNotifications(ctx context.Context, db querier, gradeId int) ([]Notification, error) {
+	return collectEventNotifications(ctx, db, NotificationGrade,
+		func(subject, what, when string) (string, string) {
+			return "Nová známka: " + subject, fmt.Sprintf("Nová známka %s z předmětu %s (%s).", what, subject, when)
+		}, `
+		with students as (select student_id as id from grade where id = $1),`+eventRecipients+`
+		select r.user_id, r.email, r.own, r.student_id, s.name || ' ' || s.surname, coalesce(sub.name, ''),
+			g.value || ' (váha ' || g.weight || ')', to_char(rep.reported_at, 'FMDD. FMMM. YYYY')
+		from grade g
+		join recipients r on r.student_id = g.student_id
+		join users s on s.id = g.student_id
+		join report rep on rep.id = g.report_id
+		left join academic_timetable at on at.id = rep.timetable_id
+		left join subject sub on sub.id = at.subject_id
+		where g.id = $1`,
+		gradeId,
+	)
+}
+
+// substitutionNotifications notify students of the substitute lesson's groups and their parents
+func substitutionNotifications(ctx context.Context, db querier, timetableId int) ([]Notification, error) {
+	return collectEventNotifications(ctx, db, NotificationSubstitution,
+		func(subject, what, when string) (string, string) {
+			return "Suplování " + when, fmt.Sprintf("Hodina %s dne %s od %s bude suplována.", subject, when, what)
+		}, `
+		with students as (
+			select ug.user_id as id
+			from timetable_group tg
+			join users_group ug on ug.group_id = tg.group_id
+			join users u on u.id = ug.user_id
+			where tg.timetable_id = $1 and u.role = 'student'
+		),`+eventRecipients+`
+		select r.user_id, r.email, r.own, r.student_id, s.name || ' ' || s.surname, coalesce(sub.name, ''),
+			to_char(date '2000-01-01' + lower(p.span), 'HH24:MI'), to_char(st.date, 'FMDD. FMMM. YYYY')
+		from substitute_timetable st
+		join academic_timetable at on at.id = st.id
+		join period p on p.id = at.period_id
+		left join subject sub on sub.id = at.subject_id
+		cross join recipients r
+		join users s on s.id = r.student_id
+		where st.id = $1`,
+		timetableId,
+	)
+}
+
+// testNotifications notify students of the lesson's groups and their parents about a dated test
+func testNotifications(ctx context.Context, db querier, noteId int) ([]Notification, error) {
+	return collectEventNotifications(ctx, db, NotificationTest,
+		func(subject, what, when string) (string, string) {
+			return fmt.Sprintf("Test: %s %s", subject, when), fmt.Sprintf("Test z předmětu %s dne %s: %s", subject, when, what)
+		}, `
+		with students as (
+			select ug.user_id as id
+			from note_with_date nd
+			join timetable_group tg on tg.timetable_id = nd.timetable_id
+			join users_group ug on ug.group_id = tg.group_id
+			join users u on u.id = ug.user_id
+			where nd.id = $1 and u.role = 'student'
+		),`+eventRecipients+`
+		select r.user_id, r.email, r.own, r.student_id, s.name || ' ' || s.surname, coalesce(sub.name, ''),
+			nd.content, to_char(nd.date, 'FMDD. FMMM. YYYY')
+		from note_with_date nd
+		left join academic_timetable at on at.id = nd.timetable_id
+		left join subject sub on sub.id = at.subject_id
+		cross join recipients r
+		join users s on s.id = r.student_id
+		where nd.id = $1 and nd.type = 'test'`,
+		noteId,
+	)
+}
+
+// excuseNotifications notify the student and their parents about the review of the excuse
+func excuseNotifications(ctx context.Context, db querier, excuseId int) ([]Notification, error) {
+	return collectEventNotifications(ctx, db, NotificationExcuse,
+		func(_, what, when string) (string, string) {
+			decision := "schválena"
+			if what == ExcuseRejected {
+				decision = "zamítnuta"
+			}
+			return "Omluvenka " + decision, fmt.Sprintf("Omluvenka absence od %s byla %s.", when, decision)
+		}, `
+		with students as (
+			select a.user_id as id from absence_excuse e join absence a on a.id = e.absence_id where e.id = $1
+		),`+eventRecipients+`
+		select r.user_id, r.email, r.own, r.student_id, s.name || ' ' || s.surname, '',
+			e.status::text, to_char(lower(a.span), 'FMDD. FMMM. YYYY')
+		from absence_excuse e
+		join absence a on a.id = e.absence_id
+		join recipients r on r.student_id = a.user_id
+		join users s on s.id = a.user_id
+		where e.id = $1 and e.status <> 'pending'`,
+		excuseId,
+	)
+}
+
+// NotificationChannel delivers notifications, Name is the channel users turn on and off in their preferences
+type NotificationChannel interface {
+	Name() string
+	Deliver(ctx context.Context, n Notification) error
+}
+
+// InboxChannel delivers notifications to the in-app inbox
+type InboxChannel struct {
+	db *pgxpool.Pool
+}
+
+func NewInboxChannel(db *pgxpool.Pool) InboxChannel {
+	return InboxChannel{db: db}
+}
+
+func (c InboxChannel) Name() string {
+	return ChannelInApp
+}
+
+func (c InboxChannel) Deliver(ctx context.Context, n Notification) error {
+	_, err := c.db.Exec(ctx,
+		"insert into notification (user_id, kind, title, body, link) values ($1, $2, $3, $4, $5)",
+		n.userId, n.kind, n.title, n.body, n.link,
+	)
+	return err
+}
+
+// EmailChannel emails notifications to the address of the user
+type EmailChannel struct {
+	mailer *utils.Mailer
+}
+
+func NewEmailChannel(mailer *utils.Mailer) EmailChannel {
+	return EmailChannel{mailer: mailer}
+}
+
+func (c EmailChannel) Name() string {
+	return ChannelEmail
+}
+
+func (c EmailChannel) Deliver(ctx context.Context, n Notification) error {
+	return c.mailer.Send(ctx, n.email, n.title, n.body)
+}
+
+// Notifier delivers notifications of outbox events through its channels
+type Notifier struct {
+	db       *pgxpool.Pool
+	channels []NotificationChannel
+}
+
+func NewNotifier(db *pgxpool.Pool, channels ...NotificationChannel) *Notifier {
+	return &Notifier{db: db, channels: channels}
+}
+
+// Register makes the worker handle notification events and deliveries through the notifier's channels
+func (n *Notifier) Register(w *OutboxWorker) {
+	w.Handle(TopicNotification, n.fanOut)
+	for _, channel := range n.channels {
+		w.Handle(notificationTopic(channel.Name()), func(ctx context.Context, payload json.RawMessage) error {
+			var d notificationDelivery
+			if err := json.Unmarshal(payload, &d); err != nil {
+				return err
+			}
+			return channel.Deliver(ctx, d.notification())
+		})
+	}
+}
+
+// fanOut words notifications of the event and adds their delivery through every channel the recipients
+// didn't turn off to the outbox
+func (n *Notifier) fanOut(ctx context.Context, payload json.RawMessage) error {
+	span := trace.SpanFromContext(ctx)
+
+	var e notificationEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return err
+	}
+	notifications, err := e.notifications(ctx, n.db)
+	if err != nil || len(notifications) == 0 {
+		return err
+	}
+	userIds := make([]uuid.UUID, len(notifications))
+	for i, notification := range notifications {
+		userIds[i] = notification.userId
+	}
+	rows, err := n.db.Query(ctx,
+		"select user_id, channel::text from notification_preference where kind = $1 and not enabled and user_id = any($2)",
+		e.Kind, userIds,
+	)
+	if err != nil {
+		return err
+	}
+	type turnedOff struct {
+		userId  uuid.UUID
+		channel string
+	}
+	off, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (t turnedOff, err error) {
+		err = row.Scan(&t.userId, &t.channel)
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []utils.TxFunc
+	for _, notification := range notifications {
+		for _, channel := range n.channels {
+			if !slices.Contains(off, turnedOff{notification.userId, channel.Name()}) {
+				deliveries = append(deliveries, AppendToOutbox(notificationTopic(channel.Name()), newNotificationDelivery(notification)))
+			}
+		}
+	}
+	span.AddEvent(fmt.Sprintf("Adding %d deliveries of %d notifications of %s", len(deliveries), len(notifications), e.Kind))
+	return utils.HandleTx(ctx, n.db, deliveries...)
+}
+
+// notificationDelivery is a notification in the outbox waiting to be delivered through a channel
+type notificationDelivery struct {
+	UserId uuid.UUID `json:"userId"`
+	Email  string    `json:"email"`
+	Kind   string    `json:"kind"`
+	Title  string    `json:"title"`
+	Body   string    `json:"body"`
+	Link   *string   `json:"link"`
+}
+
+func newNotificationDelivery(n Notification) notificationDelivery {
+	return notificationDelivery{UserId: n.userId, Email: n.email, Kind: n.kind, Title: n.title, Body: n.body, Link: n.link}
+}
+
+func (d notificationDelivery) notification() Notification {
+	return Notification{userId: d.UserId, email: d.Email, kind: d.Kind, title: d.Title, body: d.Body, link: d.Link}
+}
+
+type NotificationFilter struct {
+	unread *bool
+	kind   *string
+}
+
+func ParseNotificationFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing notification filter")
+
+	filter := NotificationFilter{kind: optionalString(span, f, "kind")}
+	switch unread := f.Get("unread"); unread {
+	case "":
+	case "true", "false":
+		value := unread == "true"
+		filter.unread = &value
+	default:
+		return utils.NewParserError(nil, "Invalid unread (should be true or false)")
+	}
+	if filter.kind != nil && !slices.Contains(notificationKinds, *filter.kind) {
+		return utils.NewParserError(nil, "Invalid kind (has to be grade, substitution, test or excuse)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "notification filter", filter)
+
+	return nil
+}
+
+const notificationSelect = `
+	select n.id, n.user_id, n.kind::text, n.title, n.body, n.link, n.created_at, n.read_at
+	from notification n`
+
+func scanNotification(row pgx.CollectableRow) (n Notification, err error) {
+	err = row.Scan(&n.id, &n.userId, &n.kind, &n.title, &n.body, &n.link, &n.createdAt, &n.readAt)
+	return
+}
+
+// ListNotifications lists the inbox of the user, order=desc lists the newest notifications first
+func ListNotifications(ctx context.Context, db *pgxpool.Pool, userId string, q ListQuery, f NotificationFilter) (Page[Notification], error) {
+	b := listBuilder{}
+	b.where("n.user_id::text = ?", userId)
+	if f.unread != nil {
+		b.where("(n.read_at is null) = ?", *f.unread)
+	}
+	if f.kind != nil {
+		b.where("n.kind = ?", *f.kind)
+	}
+
+	query, args, err := b.build(notificationSelect, []string{"n.id"}, q)
+	if err != nil {
+		return Page[Notification]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Notification]{}, err
+	}
+
+	return collectPage(rows, q, scanNotification, func(n Notification) []string {
+		return []string{fmt.Sprint(n.id)}
+	})
+}
+
+func CountUnreadNotifications(ctx context.Context, db *pgxpool.Pool, userId string) (int, error) {
+	var count int
+	err := db.QueryRow(ctx,
+		"select count(*) from notification where user_id::text = $1 and read_at is null", userId,
+	).Scan(&count)
+	return count, err
+}
+
+// MarkNotification marks the notification of the user read or unread again
+func MarkNotification(userId string, id int, read bool) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, `
+			update notification set read_at = case when $1 then coalesce(read_at, now()) end
+			where id = $2 and user_id::text = $3`,
+			read, id, userId,
+		)
+	}
+}
+
+func MarkAllNotificationsRead(userId string) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		_, err := tx.Exec(context.TODO(),
+			"update notification set read_at = now() where user_id::text = $1 and read_at is null", userId,
+		)
+		return err
+	}
+}
+
+func (n Notification) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id        int        `json:"id"`
+		Kind      string     `json:"kind"`
+		Title     string     `json:"title"`
+		Body      string     `json:"body"`
+		Link      *string    `json:"link"`
+		CreatedAt time.Time  `json:"createdAt"`
+		ReadAt    *time.Time `json:"readAt"`
+	}{
+		Id:        n.id,
+		Kind:      n.kind,
+		Title:     n.title,
+		Body:      n.body,
+		Link:      n.link,
+		CreatedAt: n.createdAt,
+		ReadAt:    n.readAt,
+	})
+}
+
+// NotificationPreference tells whether the user gets notifications of the kind through the channel
+type NotificationPreference struct {
+	Kind    string `json:"kind"`
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+func ParseNotificationPreference(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing notification preference")
+
+	p := NotificationPreference{Kind: f.Get("kind"), Channel: f.Get("channel")}
+	if !slices.Contains(notificationKinds, p.Kind) {
+		return utils.NewParserError(nil, "Invalid kind (has to be grade, substitution, test or excuse)")
+	}
+	if !slices.Contains(notificationChannels, p.Channel) {
+		return utils.NewParserError(nil, "Invalid channel (has to be in_app or email)")
+	}
+	switch enabled := f.Get("enabled"); enabled {
+	case "true", "false":
+		p.Enabled = enabled == "true"
+	default:
+		return utils.NewParserError(nil, "Invalid enabled (should be true or false)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "notification preference", p)
+
+	return nil
+}
+
+func (p NotificationPreference) SaveForUser(userId string) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		_, err := tx.Exec(context.TODO(), `
+			insert into notification_preference (user_id, kind, channel, enabled) values ($1, $2, $3, $4)
+			on conflict (user_id, kind, channel) do update set enabled = excluded.enabled`,
+			userId, p.Kind, p.Channel, p.Enabled,
+		)
+		return err
+	}
+}
+
+// ListNotificationPreferences lists every kind and channel, the ones the user never changed are enabled
+func ListNotificationPreferences(ctx context.Context, db *pgxpool.Pool, userId string) ([]NotificationPreference, error) {
+	rows, err := db.Query(ctx, `
+		select k.kind::text, c.channel::text, coalesce(p.enabled, true)
+		from unnest(enum_range(null::notification_kind)) k(kind)
+		cross join unnest(enum_range(null::notification_channel)) c(channel)
+		left join notification_preference p on p.user_id::text = $1 and p.kind = k.kind and p.channel = c.channel
+		order by k.kind, c.channel`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (p NotificationPreference, err error) {
+		err = row.Scan(&p.Kind, &p.Channel, &p.Enabled)
+		return
+	})
+}
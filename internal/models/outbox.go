@@ -0,0 +1,225 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// outboxLease is how long a taken event is hidden from other workers, an event of
+// a worker which died while delivering it is taken again after the lease
+const outboxLease = 5 * time.Minute
+
+var errUnknownTopic = errors.New("no handler of the topic")
+
+// secretTopics are topics whose payloads hold secrets, the payload is cleared
+// once the event is delivered or dead-lettered so the secret isn't kept
+var secretTopics = []string{TopicPasswordReset}
+
+// AppendToOutbox adds an event to the outbox in the transaction, the worker delivers it
+// only once the transaction commits and doesn't know about it if it rolls back
+func AppendToOutbox(topic string, payload any) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(context.TODO(), "insert into outbox (topic, payload) values ($1, $2)", topic, data)
+		return err
+	}
+}
+
+type outboxEvent struct {
+	id       int64
+	topic    string
+	payload  json.RawMessage
+	attempts int
+}
+
+// OutboxHandler delivers payloads of a topic, an error makes the worker retry the event later
+type OutboxHandler func(ctx context.Context, payload json.RawMessage) error
+
+// OutboxWorker delivers outbox events to the handlers of their topics. Every event is delivered
+// at least once: failed deliveries are retried with backoff and after the last attempt the event
+// is dead-lettered, it stays in the outbox with the last error
+type OutboxWorker struct {
+	db       *pgxpool.Pool
+	config   utils.OutboxConfig
+	handlers map[string]OutboxHandler
+}
+
+// NewOutboxWorker makes a worker, what is missing in the config is filled with defaults
+func NewOutboxWorker(db *pgxpool.Pool, config utils.OutboxConfig) *OutboxWorker {
+	if config.PollIntervalMs <= 0 {
+		config.PollIntervalMs = 1000
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 20
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.BackoffMs <= 0 {
+		config.BackoffMs = 1000
+	}
+	if config.MaxBackoffMs <= 0 {
+		config.MaxBackoffMs = 3600000
+	}
+	config.MaxBackoffMs = max(config.MaxBackoffMs, config.BackoffMs)
+	if config.HandlerTimeoutMs <= 0 {
+		config.HandlerTimeoutMs = 30000
+	}
+	//a handler still running after the lease would deliver its event twice
+	config.HandlerTimeoutMs = min(config.HandlerTimeoutMs, int(outboxLease.Milliseconds()))
+	return &OutboxWorker{db: db, config: config, handlers: map[string]OutboxHandler{}}
+}
+
+// Handle sets the handler of the topic, it has to be set before the worker runs
+func (w *OutboxWorker) Handle(topic string, h OutboxHandler) {
+	w.handlers[topic] = h
+}
+
+// Run delivers events until the context is done. The event being delivered then is finished
+// (its handler gets a context which isn't canceled, only limited by the handler timeout),
+// the rest of the batch is released
+func (w *OutboxWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval())
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		delivered, err := w.deliverBatch(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error delivering outbox events: %s\n", err)
+		}
+		//a full batch means more events are probably waiting
+		if err == nil && delivered == w.config.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverBatch takes events which are due and delivers them one by one, it returns how many it took
+func (w *OutboxWorker) deliverBatch(ctx context.Context) (int, error) {
+	events, err := w.take(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range events {
+		if ctx.Err() != nil {
+			return len(events), w.release(events[i:])
+		}
+		handlerCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), w.config.HandlerTimeout())
+		deliveryErr := w.deliver(handlerCtx, e)
+		cancel()
+		if err := w.settle(e, deliveryErr); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// take counts an attempt of every due event and hides them from other workers for the lease
+func (w *OutboxWorker) take(ctx context.Context) ([]outboxEvent, error) {
+	rows, err := w.db.Query(ctx, `
+		update outbox o set attempts = o.attempts + 1, available_at = now() + make_interval(secs => $2)
+		from (
+			select id from outbox
+			where status = 'pending' and available_at <= now()
+			order by id
+			limit $1
+			for update skip locked
+		) due
+		where o.id = due.id
+		returning o.id, o.topic, o.payload, o.attempts`,
+		w.config.BatchSize, outboxLease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (e outboxEvent, err error) {
+		err = row.Scan(&e.id, &e.topic, &e.payload, &e.attempts)
+		return
+	})
+	slices.SortFunc(events, func(a, b outboxEvent) int {
+		return cmp.Compare(a.id, b.id)
+	})
+	return events, err
+}
+
+func (w *OutboxWorker) deliver(ctx context.Context, e outboxEvent) (err error) {
+	ctx, span := tracer.Start(ctx, "deliver outbox event")
+	defer span.End()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+	}()
+
+	handler, ok := w.handlers[e.topic]
+	if !ok {
+		return errUnknownTopic
+	}
+	return handler(ctx, e.payload)
+}
+
+// settle records the result of the delivery, so the event is done, retried after a backoff or dead-lettered
+func (w *OutboxWorker) settle(e outboxEvent, deliveryErr error) error {
+	ctx := context.Background()
+	secret := slices.Contains(secretTopics, e.topic)
+	var err error
+	switch {
+	case deliveryErr == nil:
+		_, err = w.db.Exec(ctx, `
+			update outbox set status = 'delivered', delivered_at = now(), last_error = null,
+				payload = case when $2 then '{}'::jsonb else payload end
+			where id = $1`,
+			e.id, secret,
+		)
+	case errors.Is(deliveryErr, errUnknownTopic), e.attempts >= w.config.MaxAttempts:
+		fmt.Fprintf(os.Stderr, "dead-lettering outbox event %d (%s) after %d attempts: %s\n", e.id, e.topic, e.attempts, deliveryErr)
+		_, err = w.db.Exec(ctx, `
+			update outbox set status = 'dead', last_error = $1,
+				payload = case when $3 then '{}'::jsonb else payload end
+			where id = $2`,
+			deliveryErr.Error(), e.id, secret,
+		)
+	default:
+		wait := utils.Backoff(e.attempts, w.config.Backoff(), w.config.MaxBackoff())
+		_, err = w.db.Exec(ctx,
+			"update outbox set available_at = now() + make_interval(secs => $1), last_error = $2 where id = $3",
+			wait.Seconds(), deliveryErr.Error(), e.id,
+		)
+	}
+	return err
+}
+
+// release gives back events which weren't delivered without counting their attempt
+func (w *OutboxWorker) release(events []outboxEvent) error {
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.id
+	}
+	_, err := w.db.Exec(context.Background(),
+		"update outbox set attempts = attempts - 1, available_at = now() where id = any($1)", ids,
+	)
+	return err
+}
@@ -2,11 +2,13 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -36,13 +38,110 @@ func ParseParentChild(f url.Values, parserCtx context.Context, handlerCtx *conte
 	return nil
 }
 
-func (pc ParentChild) SaveToDB(tx pgx.Tx) error {
-	_, err := tx.Exec(context.TODO(), "insert into parent_child (parent_id, child_id) values ($1, $2)",
+func (pc *ParentChild) SaveToDB(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		"insert into parent_child (parent_id, child_id) values ($1, $2) returning parent_id, child_id",
 		pc.parentId.String(), pc.childId.String(),
 	)
 	if err != nil {
 		return err
 	}
+	*pc, err = pgx.CollectOneRow(rows, scanParentChild)
+	return err
+}
+
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the parent or the child is in another school
+func (pc *ParentChild) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, userRef(pc.parentId), userRef(pc.childId)); err != nil {
+			return err
+		}
+		return pc.SaveToDB(tx)
+	}
+}
+
+type ParentChildFilter struct {
+	parentId *uuid.UUID
+	childId  *uuid.UUID
+}
+
+func ParseParentChildFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing parent child filter")
+
+	parentId, err := parseOptionalUuid(span, f, "parent_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid parent id")
+	}
+	childId, err := parseOptionalUuid(span, f, "child_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid child id")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "parent child filter", ParentChildFilter{
+		parentId: parentId,
+		childId:  childId,
+	})
 
 	return nil
 }
+
+const parentChildSelect = "select pc.parent_id, pc.child_id from parent_child pc join users p on p.id = pc.parent_id"
+
+func scanParentChild(row pgx.CollectableRow) (pc ParentChild, err error) {
+	err = row.Scan(&pc.parentId, &pc.childId)
+	return
+}
+
+func ListParentChildren(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f ParentChildFilter) (Page[ParentChild], error) {
+	b := listBuilder{}
+	b.where("p.school_id = ?", schoolId)
+	if f.parentId != nil {
+		b.where("pc.parent_id = ?", *f.parentId)
+	}
+	if f.childId != nil {
+		b.where("pc.child_id = ?", *f.childId)
+	}
+
+	query, args, err := b.build(parentChildSelect, []string{"pc.parent_id", "pc.child_id"}, q)
+	if err != nil {
+		return Page[ParentChild]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[ParentChild]{}, err
+	}
+
+	return collectPage(rows, q, scanParentChild, func(pc ParentChild) []string {
+		return []string{pc.parentId.String(), pc.childId.String()}
+	})
+}
+
+func (pc ParentChild) ParentId() uuid.UUID {
+	return pc.parentId
+}
+
+func (pc ParentChild) ChildId() uuid.UUID {
+	return pc.childId
+}
+
+func (pc ParentChild) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ParentId uuid.UUID `json:"parentId"`
+		ChildId  uuid.UUID `json:"childId"`
+	}{
+		ParentId: pc.parentId,
+		ChildId:  pc.childId,
+	})
+}
+
+func DeleteParentChild(schoolId int, parentId, childId uuid.UUID) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, `
+			delete from parent_child pc
+			where pc.parent_id = $1 and pc.child_id = $2
+				and exists (select 1 from users p where p.id = pc.parent_id and p.school_id = $3)`,
+			parentId, childId, schoolId,
+		)
+	}
+}
@@ -0,0 +1,222 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// passwordResetLifetime is how long a reset link works after it was requested
+const passwordResetLifetime = time.Hour
+
+// TopicPasswordReset is the outbox topic of reset links to email. The token is in the payload only
+// until the event is delivered or dead-lettered, the worker clears it then (see secretTopics).
+// The reset itself only keeps its hash
+const TopicPasswordReset = "password_reset"
+
+var (
+	ErrInvalidResetToken = errors.New("Reset link is invalid, expired or was already used")
+	ErrWrongPassword     = errors.New("Old password is incorrect")
+)
+
+// PasswordResetRequest asks for a reset link to be emailed to the address
+type PasswordResetRequest struct {
+	email string
+}
+
+func ParsePasswordResetRequest(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing password reset request")
+
+	email, err := mail.ParseAddress(f.Get("email"))
+	if err != nil {
+		return utils.NewParserError(err, "Invalid email provided")
+	}
+	span.SetAttributes(attribute.String("email", email.Address))
+
+	*handlerCtx = context.WithValue(*handlerCtx, "password reset request", PasswordResetRequest{email: email.Address})
+
+	return nil
+}
+
+type passwordResetEmail struct {
+	Email string `json:"email"`
+	Token string `json:"token"`
+}
+
+func hashResetToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// RequestInDB makes a reset token of the user with the email and adds its email to the outbox.
+// Nothing happens for an unknown email, so the response doesn't tell who is registered
+func (r PasswordResetRequest) RequestInDB() utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var userId string
+		err := tx.QueryRow(context.TODO(), "select id from users where email = $1", r.email).Scan(&userId)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		token := base64.RawURLEncoding.EncodeToString(secret)
+		_, err = tx.Exec(context.TODO(),
+			"insert into password_reset (token_hash, user_id, expires_at) values ($1, $2, now() + make_interval(secs => $3))",
+			hashResetToken(token), userId, passwordResetLifetime.Seconds(),
+		)
+		if err != nil {
+			return err
+		}
+		return AppendToOutbox(TopicPasswordReset, passwordResetEmail{Email: r.email, Token: token})(tx)
+	}
+}
+
+// PasswordResetMailer emails reset links, baseUrl is where users open the application
+type PasswordResetMailer struct {
+	mailer  *utils.Mailer
+	baseUrl string
+}
+
+func NewPasswordResetMailer(mailer *utils.Mailer, baseUrl string) *PasswordResetMailer {
+	return &PasswordResetMailer{mailer: mailer, baseUrl: baseUrl}
+}
+
+// Register makes the worker email reset links
+func (m *PasswordResetMailer) Register(w *OutboxWorker) {
+	w.Handle(TopicPasswordReset, m.send)
+}
+
+func (m *PasswordResetMailer) send(ctx context.Context, payload json.RawMessage) error {
+	var e passwordResetEmail
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return err
+	}
+	link := m.baseUrl + "/password/reset?" + url.Values{"token": {e.Token}}.Encode()
+	body := fmt.Sprintf(
+		"Nové heslo si nastavíte na odkazu %s\n\nOdkaz platí %d minut a lze ho použít jen jednou. Pokud jste o obnovení hesla nežádali, email ignorujte.",
+		link, int(passwordResetLifetime.Minutes()),
+	)
+	return m.mailer.Send(ctx, e.Email, "Obnovení hesla", body)
+}
+
+// PasswordReset sets a new password with the token of a reset link
+type PasswordReset struct {
+	token    string
+	password string
+}
+
+func ParsePasswordReset(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing password reset")
+
+	token := f.Get("token")
+	if token == "" {
+		return utils.NewParserError(nil, "Reset token not provided")
+	}
+	password := f.Get("password")
+	if err := validatePassword(password); err != nil {
+		return utils.NewParserError(err, "Invalid password provided")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "password reset", PasswordReset{token: token, password: password})
+
+	return nil
+}
+
+// ResetInDB uses up the token and sets the password of its user, whose id is put in userId. Every other
+// token of the user is used up with it, ErrInvalidResetToken means the token can't be used
+func (r PasswordReset) ResetInDB(userId *string) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		err := tx.QueryRow(context.TODO(), `
+			update password_reset set used_at = now()
+			where token_hash = $1 and used_at is null and expires_at > now()
+			returning user_id`,
+			hashResetToken(r.token),
+		).Scan(userId)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidResetToken
+		} else if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(context.TODO(),
+			"update password_reset set used_at = now() where user_id = $1 and used_at is null", *userId,
+		)
+		if err != nil {
+			return err
+		}
+		return setPassword(tx, *userId, r.password)
+	}
+}
+
+// PasswordChange sets a new password of a logged in user, who has to know the old one
+type PasswordChange struct {
+	oldPassword string
+	password    string
+}
+
+func ParsePasswordChange(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing password change")
+
+	oldPassword := f.Get("old_password")
+	if oldPassword == "" {
+		return utils.NewParserError(nil, "Old password not provided")
+	}
+	password := f.Get("password")
+	if err := validatePassword(password); err != nil {
+		return utils.NewParserError(err, "Invalid password provided")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "password change", PasswordChange{oldPassword: oldPassword, password: password})
+
+	return nil
+}
+
+// ChangeInDB sets the password of the user if the old one matches, ErrWrongPassword otherwise
+func (c PasswordChange) ChangeInDB(userId string) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var hash string
+		err := tx.QueryRow(context.TODO(), "select password from users where id = $1 for update", userId).Scan(&hash)
+		if err != nil {
+			return err
+		}
+		passwordsMatch, err := argon2id.ComparePasswordAndHash(c.oldPassword, hash)
+		if err != nil {
+			return err
+		}
+		if !passwordsMatch {
+			return ErrWrongPassword
+		}
+		return setPassword(tx, userId, c.password)
+	}
+}
+
+func setPassword(tx pgx.Tx, userId, password string) error {
+	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(context.TODO(), "update users set password = $1 where id = $2", hash, userId)
+	return err
+}
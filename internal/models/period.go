@@ -2,12 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"time"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -47,9 +49,11 @@ func ParsePeriod(f url.Values, parserCtx context.Context, handlerCtx *context.Co
 	return nil
 }
 
-func (p Period) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+func (p *Period) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
 	return func(tx pgx.Tx) error {
-		_, err := tx.Exec(context.Background(), "insert into period (school_id, span) values($1, $2)",
+		rows, err := tx.Query(context.Background(),
+			`insert into period (school_id, span) values($1, $2)
+			returning id, school_id, to_char(lower(span), 'HH24:MI'), to_char(upper(span), 'HH24:MI')`,
 			schoolId,
 			fmt.Sprintf(
 				"[%s, %s]",
@@ -61,7 +65,120 @@ func (p Period) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
 			return err
 		}
 
-		return nil
+		*p, err = pgx.CollectOneRow(rows, scanPeriod)
+		return err
+	}
+}
+
+const periodSelect = "select p.id, p.school_id, to_char(lower(p.span), 'HH24:MI'), to_char(upper(p.span), 'HH24:MI') from period p"
+
+func scanPeriod(row pgx.CollectableRow) (p Period, err error) {
+	var start, end string
+	if err = row.Scan(&p.id, &p.schoolId, &start, &end); err != nil {
+		return
+	}
+	if p.start, err = time.Parse(InputTimeFormat, start); err != nil {
+		return
+	}
+	p.end, err = time.Parse(InputTimeFormat, end)
+	return
+}
+
+func ListPeriods(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery) (Page[Period], error) {
+	b := listBuilder{}
+	b.where("p.school_id = ?", schoolId)
+
+	query, args, err := b.build(periodSelect, []string{"p.id"}, q)
+	if err != nil {
+		return Page[Period]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Period]{}, err
+	}
+
+	return collectPage(rows, q, scanPeriod, func(p Period) []string {
+		return []string{fmt.Sprint(p.id)}
+	})
+}
+
+func GetPeriod(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Period, error) {
+	rows, err := db.Query(ctx, periodSelect+" where p.school_id = $1 and p.id = $2", schoolId, id)
+	if err != nil {
+		return Period{}, err
+	}
+	return pgx.CollectOneRow(rows, scanPeriod)
+}
+
+func (p Period) Id() int {
+	return p.id
+}
+
+func (p Period) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id       int    `json:"id"`
+		SchoolId int    `json:"schoolId"`
+		Start    string `json:"start"`
+		End      string `json:"end"`
+	}{
+		Id:       p.id,
+		SchoolId: p.schoolId,
+		Start:    p.start.Format(InputTimeFormat),
+		End:      p.end.Format(InputTimeFormat),
+	})
+}
+
+type PeriodUpdate struct {
+	start *time.Time
+	end   *time.Time
+}
 
+func ParsePeriodUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing period update")
+
+	start, err := parseOptionalTime(span, f, "start", InputTimeFormat)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid start time")
+	}
+	end, err := parseOptionalTime(span, f, "end", InputTimeFormat)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid end time")
+	}
+
+	if start != nil && end != nil && end.Before(*start) {
+		return utils.NewParserError(nil, "End can't be before start")
+	}
+	if start == nil && end == nil {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "period update", PeriodUpdate{
+		start: start,
+		end:   end,
+	})
+
+	return nil
+}
+
+func (u PeriodUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var start, end any //nil keeps the current bound
+		if u.start != nil {
+			start = u.start.Format(time.TimeOnly)
+		}
+		if u.end != nil {
+			end = u.end.Format(time.TimeOnly)
+		}
+
+		b := updateBuilder{}
+		b.setExpr("span", "timerange(coalesce(?::time, lower(span)), coalesce(?::time, upper(span)), '[]')", start, end)
+		return b.exec(tx, "period", "id = ? and school_id = ?", id, schoolId)
+	}
+}
+
+func DeletePeriod(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, "delete from period where id = $1 and school_id = $2", id, schoolId)
 	}
 }
@@ -2,16 +2,40 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/url"
+	"slices"
+	"strconv"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 const regularTimetableType = "regular"
 
+// Weekdays are the school days as named by the weekday enum in db, monday first
+var Weekdays = []string{"Po", "Út", "St", "Čt", "Pá"}
+
+// parseWeekday converts weekday number (1 is monday) to the weekday enum used in db
+func parseWeekday(value string) (string, error) {
+	day, err := strconv.Atoi(value)
+	if err != nil {
+		return "", err
+	} else if day < 1 || day > len(Weekdays) {
+		return "", errors.New("Weekday out of range")
+	}
+	return Weekdays[day-1], nil
+}
+
+func weekdayNumber(weekday string) int {
+	return slices.Index(Weekdays, weekday) + 1
+}
+
 type RegularTimetable struct {
 	id        int
 	periodId  int
@@ -19,30 +43,22 @@ type RegularTimetable struct {
 	roomId    int
 	schoolId  int
 	weekday   string
+	// academicYearId limits the lesson to the academic year, nil means it is valid forever
+	academicYearId *int
 }
 
 func ParseRegularTimetable(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
 	span := trace.SpanFromContext(parserCtx)
 	span.AddEvent("Parsing regular timetable")
 
-	weekday := f.Get("weekday")
+	weekdayUnprocessed := f.Get("weekday")
 	span.SetAttributes(
-		attribute.String("weekday", weekday),
+		attribute.String("weekday", weekdayUnprocessed),
 	)
 
-	switch weekday {
-	case "1":
-		weekday = "Po"
-	case "2":
-		weekday = "Út"
-	case "3":
-		weekday = "St"
-	case "4":
-		weekday = "Čt"
-	case "5":
-		weekday = "Pá"
-	default:
-		return utils.NewParserError(nil, "Invalid weekday")
+	weekday, err := parseWeekday(weekdayUnprocessed)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid weekday")
 	}
 
 	periodId, err := utils.ParseInt(span, "period_id", f.Get("period_id"))
@@ -57,25 +73,40 @@ func ParseRegularTimetable(f url.Values, parserCtx context.Context, handlerCtx *
 	if err != nil {
 		return utils.NewParserError(nil, "Invalid room id (not convertable to int)")
 	}
-	schoolId, err := utils.ParseInt(span, "school_id", f.Get("school_id"))
+	academicYearId, err := parseOptionalInt(span, f, "academic_year_id")
 	if err != nil {
-		return utils.NewParserError(nil, "Invalid school id (not convertable to int)")
+		return utils.NewParserError(err, "Invalid academic year id (not an int)")
 	}
 
 	*handlerCtx = context.WithValue(*handlerCtx, "regular timetable", RegularTimetable{
-		id:        -1,
-		periodId:  periodId,
-		subjectId: subjectId,
-		roomId:    roomId,
-		schoolId:  schoolId,
-		weekday:   weekday,
+		id:             -1,
+		periodId:       periodId,
+		subjectId:      subjectId,
+		roomId:         roomId,
+		schoolId:       -1,
+		weekday:        weekday,
+		academicYearId: academicYearId,
 	})
 
 	return nil
 }
 
-func (t RegularTimetable) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(
+// SaveToDBWithSchoolId saves the lesson in the school of the admin creating it
+func (t *RegularTimetable) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		t.schoolId = schoolId
+		return t.SaveToDB(tx)
+	}
+}
+
+func (t *RegularTimetable) SaveToDB(tx pgx.Tx) error {
+	if t.academicYearId != nil {
+		if err := checkAcademicYearInSchool(tx, t.schoolId, *t.academicYearId); err != nil {
+			return err
+		}
+	}
+
+	err := tx.QueryRow(
 		context.TODO(),
 		`
 		WITH inserted_timetable AS (
@@ -88,11 +119,198 @@ func (t RegularTimetable) SaveToDB(tx pgx.Tx) (err error) {
 		    SELECT id, $3, $4, $5
 		    FROM inserted_timetable
 		)
-		INSERT INTO regular_timetable (id, weekday)
-		SELECT id, $6
+		INSERT INTO regular_timetable (id, weekday, academic_year_id)
+		SELECT id, $6, $7
 		FROM inserted_timetable
+		RETURNING id
 		`,
-		t.schoolId, regularTimetableType, t.periodId, t.subjectId, t.roomId, t.weekday)
+		t.schoolId, regularTimetableType, t.periodId, t.subjectId, t.roomId, t.weekday, t.academicYearId,
+	).Scan(&t.id)
+	if err != nil {
+		return err
+	}
+
+	return checkTimetableConflicts(tx, t.id)
+}
+
+type RegularTimetableFilter struct {
+	academicTimetableFilter
+	weekday        *string
+	academicYearId *int
+}
 
+func ParseRegularTimetableFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing regular timetable filter")
+
+	academicFilter, parseErr := parseAcademicTimetableFilter(span, f)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	filter := RegularTimetableFilter{academicTimetableFilter: academicFilter}
+	if weekdayUnprocessed := f.Get("weekday"); weekdayUnprocessed != "" {
+		span.SetAttributes(attribute.String("weekday", weekdayUnprocessed))
+		weekday, err := parseWeekday(weekdayUnprocessed)
+		if err != nil {
+			return utils.NewParserError(err, "Invalid weekday")
+		}
+		filter.weekday = &weekday
+	}
+	academicYearId, err := parseOptionalInt(span, f, "academic_year_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid academic year id (not an int)")
+	}
+	filter.academicYearId = academicYearId
+
+	*handlerCtx = context.WithValue(*handlerCtx, "regular timetable filter", filter)
+
+	return nil
+}
+
+const regularTimetableSelect = `
+	select rt.id, at.period_id, at.subject_id, at.room_id, t.school_id, rt.weekday, rt.academic_year_id
+	from regular_timetable rt
+	join academic_timetable at on at.id = rt.id
+	join timetable t on t.id = rt.id`
+
+func scanRegularTimetable(row pgx.CollectableRow) (t RegularTimetable, err error) {
+	err = row.Scan(&t.id, &t.periodId, &t.subjectId, &t.roomId, &t.schoolId, &t.weekday, &t.academicYearId)
 	return
 }
+
+func ListRegularTimetables(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f RegularTimetableFilter) (Page[RegularTimetable], error) {
+	b := listBuilder{}
+	b.where("t.school_id = ?", schoolId)
+	f.apply(&b)
+	if f.weekday != nil {
+		b.where("rt.weekday = ?", *f.weekday)
+	}
+	if f.academicYearId != nil {
+		//lessons without academic year are valid in every one of them
+		b.where("(rt.academic_year_id = ? or rt.academic_year_id is null)", *f.academicYearId)
+	}
+
+	query, args, err := b.build(regularTimetableSelect, []string{"rt.id"}, q)
+	if err != nil {
+		return Page[RegularTimetable]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[RegularTimetable]{}, err
+	}
+
+	return collectPage(rows, q, scanRegularTimetable, func(t RegularTimetable) []string {
+		return []string{fmt.Sprint(t.id)}
+	})
+}
+
+func GetRegularTimetable(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (RegularTimetable, error) {
+	rows, err := db.Query(ctx, regularTimetableSelect+" where t.school_id = $1 and rt.id = $2", schoolId, id)
+	if err != nil {
+		return RegularTimetable{}, err
+	}
+	return pgx.CollectOneRow(rows, scanRegularTimetable)
+}
+
+func (t RegularTimetable) Id() int {
+	return t.id
+}
+
+func (t RegularTimetable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id             int  `json:"id"`
+		PeriodId       int  `json:"periodId"`
+		SubjectId      int  `json:"subjectId"`
+		RoomId         int  `json:"roomId"`
+		SchoolId       int  `json:"schoolId"`
+		Weekday        int  `json:"weekday"`
+		AcademicYearId *int `json:"academicYearId"`
+	}{
+		Id:             t.id,
+		PeriodId:       t.periodId,
+		SubjectId:      t.subjectId,
+		RoomId:         t.roomId,
+		SchoolId:       t.schoolId,
+		Weekday:        weekdayNumber(t.weekday),
+		AcademicYearId: t.academicYearId,
+	})
+}
+
+type RegularTimetableUpdate struct {
+	academicTimetableUpdate
+	weekday        *string
+	academicYearId *int
+	// clearAcademicYear is set by an empty academic_year_id, the lesson becomes valid forever
+	clearAcademicYear bool
+}
+
+func ParseRegularTimetableUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing regular timetable update")
+
+	academicUpdate, parseErr := parseAcademicTimetableUpdate(span, f)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	update := RegularTimetableUpdate{academicTimetableUpdate: academicUpdate}
+	if f.Has("weekday") {
+		span.SetAttributes(attribute.String("weekday", f.Get("weekday")))
+		weekday, err := parseWeekday(f.Get("weekday"))
+		if err != nil {
+			return utils.NewParserError(err, "Invalid weekday")
+		}
+		update.weekday = &weekday
+	}
+	if f.Has("academic_year_id") {
+		academicYearId, err := parseOptionalInt(span, f, "academic_year_id")
+		if err != nil {
+			return utils.NewParserError(err, "Invalid academic year id (not an int)")
+		}
+		update.academicYearId = academicYearId
+		update.clearAcademicYear = academicYearId == nil
+	}
+	if update == (RegularTimetableUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "regular timetable update", update)
+
+	return nil
+}
+
+func (u RegularTimetableUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkTimetableInSchool(tx, schoolId, id, regularTimetableType); err != nil {
+			return err
+		}
+		if err := u.academicTimetableUpdate.updateInDB(tx, id); err != nil {
+			return err
+		}
+
+		b := updateBuilder{}
+		if u.weekday != nil {
+			b.set("weekday", *u.weekday)
+		}
+		if u.academicYearId != nil {
+			if err := checkAcademicYearInSchool(tx, schoolId, *u.academicYearId); err != nil {
+				return err
+			}
+			b.set("academic_year_id", *u.academicYearId)
+		} else if u.clearAcademicYear {
+			b.setExpr("academic_year_id", "null")
+		}
+		if err := b.exec(tx, "regular_timetable", "id = ?", id); err != nil {
+			return err
+		}
+
+		return checkTimetableConflicts(tx, id)
+	}
+}
+
+func DeleteRegularTimetable(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return deleteTimetable(tx, schoolId, id, regularTimetableType, "regular_timetable", "academic_timetable")
+	}
+}
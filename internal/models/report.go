@@ -2,12 +2,15 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 	"time"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -48,9 +51,178 @@ func ParseReport(f url.Values, parserCtx context.Context, handlerCtx *context.Co
 	return nil
 }
 
-func (r Report) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(context.TODO(), "insert into report (timetable_id, reported_by, topic_covered) values ($1, $2, $3)",
+func (r *Report) SaveToDB(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		"insert into report (timetable_id, reported_by, topic_covered) values ($1, $2, $3) returning id, timetable_id, reported_by, reported_at, topic_covered",
 		r.timetableId, r.reportedBy, r.topicCovered,
 	)
+	if err != nil {
+		return err
+	}
+	*r, err = pgx.CollectOneRow(rows, scanReport)
+	return err
+}
+
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the lesson or the reporting user is in another school
+func (r *Report) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, timetableRef(r.timetableId), userRef(r.reportedBy)); err != nil {
+			return err
+		}
+		return r.SaveToDB(tx)
+	}
+}
+
+type ReportFilter struct {
+	timetableId *int
+	reportedBy  *uuid.UUID
+	from        *time.Time
+	to          *time.Time
+	termId      *int
+}
+
+func ParseReportFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing report filter")
+
+	timetableId, err := parseOptionalInt(span, f, "timetable_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid timetable id")
+	}
+	reportedBy, err := parseOptionalUuid(span, f, "reported_by")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid reported by field")
+	}
+	from, err := parseOptionalTime(span, f, "from", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid from date")
+	}
+	to, err := parseOptionalTime(span, f, "to", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid to date")
+	}
+	termId, err := parseOptionalInt(span, f, "term_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "report filter", ReportFilter{
+		timetableId: timetableId,
+		reportedBy:  reportedBy,
+		from:        from,
+		to:          to,
+		termId:      termId,
+	})
+
+	return nil
+}
+
+const reportSelect = `
+	select r.id, r.timetable_id, r.reported_by, r.reported_at, r.topic_covered
+	from report r
+	join timetable t on t.id = r.timetable_id`
+
+func scanReport(row pgx.CollectableRow) (r Report, err error) {
+	err = row.Scan(&r.id, &r.timetableId, &r.reportedBy, &r.reportedAt, &r.topicCovered)
 	return
 }
+
+func ListReports(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f ReportFilter) (Page[Report], error) {
+	b := listBuilder{}
+	b.where("t.school_id = ?", schoolId)
+	if f.timetableId != nil {
+		b.where("r.timetable_id = ?", *f.timetableId)
+	}
+	if f.reportedBy != nil {
+		b.where("r.reported_by = ?", *f.reportedBy)
+	}
+	if f.from != nil {
+		b.where("r.reported_at::date >= ?", *f.from)
+	}
+	if f.to != nil {
+		b.where("r.reported_at::date <= ?", *f.to)
+	}
+	if f.termId != nil {
+		b.where("r.reported_at <@ ("+termTimeRange+")", *f.termId)
+	}
+
+	query, args, err := b.build(reportSelect, []string{"r.id"}, q)
+	if err != nil {
+		return Page[Report]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Report]{}, err
+	}
+
+	return collectPage(rows, q, scanReport, func(r Report) []string {
+		return []string{fmt.Sprint(r.id)}
+	})
+}
+
+func GetReport(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Report, error) {
+	rows, err := db.Query(ctx, reportSelect+" where t.school_id = $1 and r.id = $2", schoolId, id)
+	if err != nil {
+		return Report{}, err
+	}
+	return pgx.CollectOneRow(rows, scanReport)
+}
+
+func (r Report) Id() int {
+	return r.id
+}
+
+func (r Report) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id           int       `json:"id"`
+		TimetableId  int       `json:"timetableId"`
+		ReportedBy   uuid.UUID `json:"reportedBy"`
+		ReportedAt   time.Time `json:"reportedAt"`
+		TopicCovered string    `json:"topicCovered"`
+	}{
+		Id:           r.id,
+		TimetableId:  r.timetableId,
+		ReportedBy:   r.reportedBy,
+		ReportedAt:   r.reportedAt,
+		TopicCovered: r.topicCovered,
+	})
+}
+
+type ReportUpdate struct {
+	topicCovered *string
+}
+
+func ParseReportUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing report update")
+
+	topicCovered := optionalString(span, f, "topic_covered")
+	if topicCovered == nil {
+		return nothingToUpdate()
+	} else if *topicCovered == "" {
+		return utils.NewParserError(nil, "Covered topic can't be empty")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "report update", ReportUpdate{
+		topicCovered: topicCovered,
+	})
+
+	return nil
+}
+
+func (u ReportUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		b.set("topic_covered", *u.topicCovered)
+		return b.exec(tx, "report", "id = ? and exists (select 1 from timetable t where t.id = report.timetable_id and t.school_id = ?)", id, schoolId)
+	}
+}
+
+func DeleteReport(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx,
+			"delete from report where id = $1 and exists (select 1 from timetable t where t.id = report.timetable_id and t.school_id = $2)",
+			id, schoolId,
+		)
+	}
+}
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ReportCardPassedWithDistinction = "passed_with_distinction"
+	ReportCardPassed                = "passed"
+	ReportCardFailed                = "failed"
+	// ReportCardNotGraded is the result of students missing a final grade in a subject they attend
+	ReportCardNotGraded = "not_graded"
+)
+
+// gradeNames are the words report cards use for grades
+var gradeNames = []string{"výborný", "chvalitebný", "dobrý", "dostatečný", "nedostatečný"}
+
+var reportCardResultNames = map[string]string{
+	ReportCardPassedWithDistinction: "prospěl(a) s vyznamenáním",
+	ReportCardPassed:                "prospěl(a)",
+	ReportCardFailed:                "neprospěl(a)",
+	ReportCardNotGraded:             "nehodnocen(a)",
+}
+
+// ReportCard is the document a student gets at the end of the term with final grades
+// in every subject. Like other reports its fields are exported
+type ReportCard struct {
+	School       ReportCardSchool    `json:"school"`
+	ClassId      int                 `json:"classId"`
+	Class        string              `json:"class"`
+	ClassTeacher string              `json:"classTeacher"`
+	AcademicYear string              `json:"academicYear"`
+	Term         string              `json:"term"`
+	IssuedOn     string              `json:"issuedOn"`
+	StudentId    string              `json:"studentId"`
+	Student      string              `json:"student"`
+	Subjects     []ReportCardSubject `json:"subjects"`
+	// ExcusedLessons and UnexcusedLessons count lessons of the term the student missed
+	ExcusedLessons   int `json:"excusedLessons"`
+	UnexcusedLessons int `json:"unexcusedLessons"`
+	// Average is nil unless the student has final grades in all subjects
+	Average    *float64 `json:"average"`
+	Result     string   `json:"result"`
+	ResultName string   `json:"resultName"`
+}
+
+type ReportCardSchool struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type ReportCardSubject struct {
+	SubjectId int    `json:"subjectId"`
+	Subject   string `json:"subject"`
+	// Grade is nil when the final grade is still missing
+	Grade     *int   `json:"grade"`
+	GradeName string `json:"gradeName"`
+}
+
+// ReportCardQuery selects the term and optionally a single student of the class,
+// the format is the one the report cards are rendered in
+type ReportCardQuery struct {
+	termId    int
+	studentId *uuid.UUID
+	format    string
+}
+
+func ParseReportCardQuery(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing report card query")
+
+	termId, err := utils.ParseInt(span, "term_id", f.Get("term_id"))
+	if err != nil {
+		return utils.NewParserError(err, "Invalid term id (not an int)")
+	}
+	studentId, err := parseOptionalUuid(span, f, "student_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid student id")
+	}
+	format := f.Get("format")
+	if format != "" && !slices.Contains([]string{"html", "pdf", "json"}, format) {
+		return utils.NewParserError(nil, "Invalid format (has to be html, pdf or json)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "report card query", ReportCardQuery{
+		termId:    termId,
+		studentId: studentId,
+		format:    format,
+	})
+
+	return nil
+}
+
+// Format is html, pdf or json, empty when the client didn't choose any
+func (q ReportCardQuery) Format() string {
+	return q.format
+}
+
+// ClassReportCards makes report cards of students of the class for the term. Subjects
+// of a student are the ones with a final grade together with the ones the student
+// attends in the regular timetable of the academic year, so missing grades show up.
+// pgx.ErrNoRows means the class or the term isn't in the school, ErrStudentNotVisible
+// that the selected student isn't in the class
+func (q ReportCardQuery) ClassReportCards(ctx context.Context, db *pgxpool.Pool, schoolId, classId int) ([]ReportCard, error) {
+	header := ReportCard{ClassId: classId}
+	var street, zipCode, city string
+	err := db.QueryRow(ctx, `
+		select c.name, ct.name || ' ' || ct.surname, sc.name, sc.street_address, sc.zip_code, sc.city
+		from class c
+		join users ct on ct.id = c.class_teacher_id
+		join school sc on sc.id = ct.school_id
+		where c.id = $1 and ct.school_id = $2`,
+		classId, schoolId,
+	).Scan(&header.Class, &header.ClassTeacher, &header.School.Name, &street, &zipCode, &city)
+	if err != nil {
+		return nil, err
+	}
+	header.School.Address = street + ", " + zipCode + " " + city
+
+	var academicYearId int
+	var issuedOn time.Time
+	err = db.QueryRow(ctx, `
+		select t.name, y.name, y.id, upper(t.span) - 1
+		from term t
+		join academic_year y on y.id = t.academic_year_id
+		where t.id = $1 and y.school_id = $2`,
+		q.termId, schoolId,
+	).Scan(&header.Term, &header.AcademicYear, &academicYearId, &issuedOn)
+	if err != nil {
+		return nil, err
+	}
+	header.IssuedOn = issuedOn.Format(time.DateOnly)
+
+	students, err := loadClassStudents(ctx, db, schoolId, classId)
+	if err != nil {
+		return nil, err
+	}
+	if q.studentId != nil {
+		students = slices.DeleteFunc(students, func(s classStudent) bool {
+			return s.id != q.studentId.String()
+		})
+		if len(students) == 0 {
+			return nil, ErrStudentNotVisible
+		}
+	}
+	studentIds := make([]string, len(students))
+	for i, s := range students {
+		studentIds[i] = s.id
+	}
+
+	rows, err := db.Query(ctx, `
+		with attended as (
+			select distinct ug.user_id, at.subject_id
+			from users_group ug
+			join timetable_group tg on tg.group_id = ug.group_id
+			join regular_timetable rt on rt.id = tg.timetable_id
+			join academic_timetable at on at.id = rt.id
+			where ug.user_id::text = any($1) and (rt.academic_year_id is null or rt.academic_year_id = $2)
+		),
+		graded as (
+			select student_id as user_id, subject_id, value
+			from final_grade
+			where student_id::text = any($1) and term_id = $3
+		)
+		select coalesce(a.user_id, g.user_id)::text, s.id, coalesce(s.name, ''), g.value
+		from attended a
+		full join graded g on g.user_id = a.user_id and g.subject_id = a.subject_id
+		join subject s on s.id = coalesce(a.subject_id, g.subject_id)
+		order by s.name, s.id`,
+		studentIds, academicYearId, q.termId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type studentSubject struct {
+		studentId string
+		subject   ReportCardSubject
+	}
+	attended, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (s studentSubject, err error) {
+		err = row.Scan(&s.studentId, &s.subject.SubjectId, &s.subject.Subject, &s.subject.Grade)
+		if s.subject.Grade != nil {
+			s.subject.GradeName = gradeNames[*s.subject.Grade-1]
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	subjects := map[string][]ReportCardSubject{}
+	for _, s := range attended {
+		subjects[s.studentId] = append(subjects[s.studentId], s.subject)
+	}
+
+	rows, err = db.Query(ctx, `
+		select att.student_id::text, count(*) filter (where att.status = 'excused'), count(*) filter (where att.status = 'absent')
+		from attendance att
+		join report r on r.id = att.report_id
+		where att.student_id::text = any($1)
+			and r.reported_at <@ (select tsrange(lower(span), upper(span)) from term where id = $2)
+		group by att.student_id`,
+		studentIds, q.termId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type missedLessons struct {
+		studentId          string
+		excused, unexcused int
+	}
+	missed, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (m missedLessons, err error) {
+		err = row.Scan(&m.studentId, &m.excused, &m.unexcused)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	missedByStudent := map[string]missedLessons{}
+	for _, m := range missed {
+		missedByStudent[m.studentId] = m
+	}
+
+	cards := make([]ReportCard, len(students))
+	for i, s := range students {
+		card := header
+		card.StudentId = s.id
+		card.Student = s.name
+		card.Subjects = subjects[s.id]
+		if card.Subjects == nil {
+			card.Subjects = []ReportCardSubject{}
+		}
+		card.ExcusedLessons = missedByStudent[s.id].excused
+		card.UnexcusedLessons = missedByStudent[s.id].unexcused
+		card.Average, card.Result = reportCardResult(card.Subjects)
+		card.ResultName = reportCardResultNames[card.Result]
+		cards[i] = card
+	}
+
+	return cards, nil
+}
+
+// reportCardResult follows the usual rules: a student passes with distinction with
+// no grade worse than 2 and average up to 1.5, fails with any 5
+func reportCardResult(subjects []ReportCardSubject) (*float64, string) {
+	sum, worst := 0, 0
+	for _, s := range subjects {
+		if s.Grade == nil {
+			return nil, ReportCardNotGraded
+		}
+		sum += *s.Grade
+		worst = max(worst, *s.Grade)
+	}
+	if len(subjects) == 0 {
+		return nil, ReportCardNotGraded
+	}
+
+	average := round2(float64(sum) / float64(len(subjects)))
+	switch {
+	case worst == 5:
+		return &average, ReportCardFailed
+	case worst <= 2 && average <= 1.5:
+		return &average, ReportCardPassedWithDistinction
+	default:
+		return &average, ReportCardPassed
+	}
+}
@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -22,10 +25,6 @@ func ParseRoom(f url.Values, parserCtx context.Context, handlerCtx *context.Cont
 	span := trace.SpanFromContext(parserCtx)
 	span.AddEvent("Parsing room")
 
-	schoolId, err := utils.ParseInt(span, "school_id", f.Get("school_id"))
-	if err != nil {
-		return utils.NewParserError(err, "Invalid school id")
-	}
 	teacherId, err := utils.ParseUuid(span, "teacher_id", f.Get("teacher_id"))
 	if err != nil {
 		return utils.NewParserError(err, "Invalid teacher id")
@@ -40,17 +39,153 @@ func ParseRoom(f url.Values, parserCtx context.Context, handlerCtx *context.Cont
 	*handlerCtx = context.WithValue(*handlerCtx, "room", Room{
 		id:        -1,
 		name:      name,
-		schoolId:  schoolId,
+		schoolId:  -1,
 		teacherId: teacherId,
 	})
 
 	return nil
 }
 
-func (r Room) SaveToDB(tx pgx.Tx) error {
-	_, err := tx.Exec(context.Background(), "insert into room (name, school_id, teacher_id) values ($1, $2, $3)", r.name, r.schoolId, r.teacherId)
+// SaveToDBWithSchoolId saves the room in the school of the admin creating it
+func (r *Room) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		r.schoolId = schoolId
+		return r.SaveToDB(tx)
+	}
+}
+
+func (r *Room) SaveToDB(tx pgx.Tx) error {
+	rows, err := tx.Query(context.Background(),
+		"insert into room (name, school_id, teacher_id) values ($1, $2, $3) returning id, name, school_id, teacher_id",
+		r.name, r.schoolId, r.teacherId,
+	)
 	if err != nil {
 		return err
 	}
+	*r, err = pgx.CollectOneRow(rows, scanRoom)
+	return err
+}
+
+type RoomFilter struct {
+	teacherId *uuid.UUID
+}
+
+func ParseRoomFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing room filter")
+
+	teacherId, err := parseOptionalUuid(span, f, "teacher_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid teacher id")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "room filter", RoomFilter{
+		teacherId: teacherId,
+	})
+
+	return nil
+}
+
+const roomSelect = "select r.id, r.name, r.school_id, r.teacher_id from room r"
+
+func scanRoom(row pgx.CollectableRow) (r Room, err error) {
+	err = row.Scan(&r.id, &r.name, &r.schoolId, &r.teacherId)
+	return
+}
+
+func ListRooms(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f RoomFilter) (Page[Room], error) {
+	b := listBuilder{}
+	b.where("r.school_id = ?", schoolId)
+	if f.teacherId != nil {
+		b.where("r.teacher_id = ?", *f.teacherId)
+	}
+
+	query, args, err := b.build(roomSelect, []string{"r.id"}, q)
+	if err != nil {
+		return Page[Room]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Room]{}, err
+	}
+
+	return collectPage(rows, q, scanRoom, func(r Room) []string {
+		return []string{fmt.Sprint(r.id)}
+	})
+}
+
+func GetRoom(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Room, error) {
+	rows, err := db.Query(ctx, roomSelect+" where r.school_id = $1 and r.id = $2", schoolId, id)
+	if err != nil {
+		return Room{}, err
+	}
+	return pgx.CollectOneRow(rows, scanRoom)
+}
+
+func (r Room) Id() int {
+	return r.id
+}
+
+func (r Room) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id        int        `json:"id"`
+		Name      string     `json:"name"`
+		SchoolId  int        `json:"schoolId"`
+		TeacherId *uuid.UUID `json:"teacherId"`
+	}{
+		Id:        r.id,
+		Name:      r.name,
+		SchoolId:  r.schoolId,
+		TeacherId: nullableUuid(r.teacherId),
+	})
+}
+
+type RoomUpdate struct {
+	name      *string
+	teacherId *uuid.UUID
+}
+
+func ParseRoomUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing room update")
+
+	teacherId, err := parseOptionalUuid(span, f, "teacher_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid teacher id")
+	}
+	name := optionalString(span, f, "name")
+	if name != nil && *name == "" {
+		return utils.NewParserError(nil, "Name can't be empty")
+	}
+
+	update := RoomUpdate{
+		name:      name,
+		teacherId: teacherId,
+	}
+	if update == (RoomUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "room update", update)
+
 	return nil
 }
+
+func (u RoomUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		if u.name != nil {
+			b.set("name", *u.name)
+		}
+		if u.teacherId != nil {
+			b.set("teacher_id", *u.teacherId)
+		}
+		return b.exec(tx, "room", "id = ? and school_id = ?", id, schoolId)
+	}
+}
+
+func DeleteRoom(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, "delete from room where id = $1 and school_id = $2", id, schoolId)
+	}
+}
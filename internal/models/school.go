@@ -2,10 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -66,3 +70,148 @@ func (s School) SaveToDBReturningId(newSchoolId *int) utils.TxFunc {
 		return nil
 	}
 }
+
+const schoolSelect = "select s.id, s.name, s.city, s.zip_code, s.street_address from school s"
+
+func scanSchool(row pgx.CollectableRow) (s School, err error) {
+	err = row.Scan(&s.id, &s.name, &s.city, &s.zip_code, &s.streetAddress)
+	return
+}
+
+// ListSchools only ever lists the school of the caller, it exists so school
+// can be read the same way as every other resource
+func ListSchools(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery) (Page[School], error) {
+	b := listBuilder{}
+	b.where("s.id = ?", schoolId)
+
+	query, args, err := b.build(schoolSelect, []string{"s.id"}, q)
+	if err != nil {
+		return Page[School]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[School]{}, err
+	}
+
+	return collectPage(rows, q, scanSchool, func(s School) []string {
+		return []string{fmt.Sprint(s.id)}
+	})
+}
+
+func GetSchool(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (School, error) {
+	rows, err := db.Query(ctx, schoolSelect+" where s.id = $1 and s.id = $2", schoolId, id)
+	if err != nil {
+		return School{}, err
+	}
+	return pgx.CollectOneRow(rows, scanSchool)
+}
+
+func (s School) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id            int    `json:"id"`
+		Name          string `json:"name"`
+		City          string `json:"city"`
+		ZipCode       string `json:"zipCode"`
+		StreetAddress string `json:"streetAddress"`
+	}{
+		Id:            s.id,
+		Name:          s.name,
+		City:          s.city,
+		ZipCode:       s.zip_code,
+		StreetAddress: s.streetAddress,
+	})
+}
+
+type SchoolUpdate struct {
+	name          *string
+	city          *string
+	zipCode       *string
+	streetAddress *string
+}
+
+func ParseSchoolUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing school update")
+
+	update := SchoolUpdate{
+		name:          optionalString(span, f, "school_name"),
+		city:          optionalString(span, f, "city"),
+		zipCode:       optionalString(span, f, "zip_code"),
+		streetAddress: optionalString(span, f, "street_address"),
+	}
+
+	if update.name != nil && *update.name == "" {
+		return utils.NewParserError(nil, "School name can't be empty")
+	} else if update.city != nil && *update.city == "" {
+		return utils.NewParserError(nil, "City can't be empty")
+	} else if update.zipCode != nil && *update.zipCode == "" {
+		return utils.NewParserError(nil, "Zip code can't be empty")
+	} else if update.streetAddress != nil && *update.streetAddress == "" {
+		return utils.NewParserError(nil, "Street address can't be empty")
+	} else if update == (SchoolUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "school update", update)
+
+	return nil
+}
+
+func (u SchoolUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		if u.name != nil {
+			b.set("name", *u.name)
+		}
+		if u.city != nil {
+			b.set("city", *u.city)
+		}
+		if u.zipCode != nil {
+			b.set("zip_code", *u.zipCode)
+		}
+		if u.streetAddress != nil {
+			b.set("street_address", *u.streetAddress)
+		}
+		return b.exec(tx, "school", "id = ? and id = ?", id, schoolId)
+	}
+}
+
+// schoolRef is a record which has to belong to the school, its query finds the record $1 only in the school $2
+type schoolRef struct {
+	query string
+	id    any
+}
+
+func userRef(id uuid.UUID) schoolRef {
+	return schoolRef{"select 1 from users where id = $1 and school_id = $2", id}
+}
+
+func classRef(id int) schoolRef {
+	return schoolRef{"select 1 from class where id = $1 and school_id = $2", id}
+}
+
+func groupRef(id int) schoolRef {
+	return schoolRef{`select 1 from "group" where id = $1 and school_id = $2`, id}
+}
+
+func timetableRef(id int) schoolRef {
+	return schoolRef{"select 1 from timetable where id = $1 and school_id = $2", id}
+}
+
+func reportRef(id int) schoolRef {
+	return schoolRef{"select 1 from report r join timetable t on t.id = r.timetable_id where r.id = $1 and t.school_id = $2", id}
+}
+
+// checkInSchool returns ErrForeignReference unless every referenced record belongs to the school
+func checkInSchool(tx pgx.Tx, schoolId int, refs ...schoolRef) error {
+	for _, ref := range refs {
+		var exists bool
+		err := tx.QueryRow(context.TODO(), "select exists ("+ref.query+")", ref.id, schoolId).Scan(&exists)
+		if err != nil {
+			return err
+		} else if !exists {
+			return ErrForeignReference
+		}
+	}
+	return nil
+}
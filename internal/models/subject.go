@@ -2,10 +2,13 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -21,11 +24,6 @@ func ParseSubject(f url.Values, parserCtx context.Context, handlerCtx *context.C
 	span := trace.SpanFromContext(parserCtx)
 	span.AddEvent("Parsing subject")
 
-	schoolId, err := utils.ParseInt(span, "school_id", f.Get("school_id"))
-	if err != nil {
-		return utils.NewParserError(err, "Invalid school id")
-	}
-
 	mandatory := true
 	if f.Get("mandatory") == "false" {
 		mandatory = false
@@ -33,7 +31,7 @@ func ParseSubject(f url.Values, parserCtx context.Context, handlerCtx *context.C
 
 	subject := Subject{
 		id:        -1,
-		schoolId:  schoolId,
+		schoolId:  -1,
 		name:      f.Get("name"),
 		mandatory: mandatory,
 	}
@@ -51,10 +49,148 @@ func ParseSubject(f url.Values, parserCtx context.Context, handlerCtx *context.C
 	return nil
 }
 
-func (s Subject) SaveToDB(tx pgx.Tx) error {
-	_, err := tx.Exec(context.Background(), "insert into subject (name, mandatory) values ($1, $2)", s.name, s.mandatory)
+// SaveToDBWithSchoolId saves the subject in the school of the admin creating it
+func (s *Subject) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		s.schoolId = schoolId
+		return s.SaveToDB(tx)
+	}
+}
+
+func (s *Subject) SaveToDB(tx pgx.Tx) error {
+	rows, err := tx.Query(context.Background(),
+		"insert into subject (name, school_id, mandatory) values ($1, $2, $3) returning id, school_id, name, mandatory",
+		s.name, s.schoolId, s.mandatory,
+	)
 	if err != nil {
 		return err
 	}
+	*s, err = pgx.CollectOneRow(rows, scanSubject)
+	return err
+}
+
+type SubjectFilter struct {
+	mandatory *bool
+}
+
+func ParseSubjectFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing subject filter")
+
+	filter := SubjectFilter{}
+	switch mandatory := f.Get("mandatory"); mandatory {
+	case "":
+	case "true", "false":
+		value := mandatory == "true"
+		filter.mandatory = &value
+		span.SetAttributes(attribute.Bool("mandatory", value))
+	default:
+		return utils.NewParserError(nil, "Invalid mandatory (should be true or false)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "subject filter", filter)
+
 	return nil
 }
+
+const subjectSelect = "select s.id, s.school_id, s.name, s.mandatory from subject s"
+
+func scanSubject(row pgx.CollectableRow) (s Subject, err error) {
+	err = row.Scan(&s.id, &s.schoolId, &s.name, &s.mandatory)
+	return
+}
+
+func ListSubjects(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f SubjectFilter) (Page[Subject], error) {
+	b := listBuilder{}
+	b.where("s.school_id = ?", schoolId)
+	if f.mandatory != nil {
+		b.where("s.mandatory = ?", *f.mandatory)
+	}
+
+	query, args, err := b.build(subjectSelect, []string{"s.id"}, q)
+	if err != nil {
+		return Page[Subject]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Subject]{}, err
+	}
+
+	return collectPage(rows, q, scanSubject, func(s Subject) []string {
+		return []string{fmt.Sprint(s.id)}
+	})
+}
+
+func GetSubject(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Subject, error) {
+	rows, err := db.Query(ctx, subjectSelect+" where s.school_id = $1 and s.id = $2", schoolId, id)
+	if err != nil {
+		return Subject{}, err
+	}
+	return pgx.CollectOneRow(rows, scanSubject)
+}
+
+func (s Subject) Id() int {
+	return s.id
+}
+
+func (s Subject) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id        int    `json:"id"`
+		SchoolId  int    `json:"schoolId"`
+		Name      string `json:"name"`
+		Mandatory bool   `json:"mandatory"`
+	}{
+		Id:        s.id,
+		SchoolId:  s.schoolId,
+		Name:      s.name,
+		Mandatory: s.mandatory,
+	})
+}
+
+type SubjectUpdate struct {
+	name      *string
+	mandatory *bool
+}
+
+func ParseSubjectUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing subject update")
+
+	update := SubjectUpdate{
+		name: optionalString(span, f, "name"),
+	}
+	if update.name != nil && *update.name == "" {
+		return utils.NewParserError(nil, "Subject name can't be empty")
+	}
+	if f.Has("mandatory") {
+		mandatory := f.Get("mandatory") != "false"
+		update.mandatory = &mandatory
+		span.SetAttributes(attribute.Bool("mandatory", mandatory))
+	}
+	if update == (SubjectUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "subject update", update)
+
+	return nil
+}
+
+func (u SubjectUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		if u.name != nil {
+			b.set("name", *u.name)
+		}
+		if u.mandatory != nil {
+			b.set("mandatory", *u.mandatory)
+		}
+		return b.exec(tx, "subject", "id = ? and school_id = ?", id, schoolId)
+	}
+}
+
+func DeleteSubject(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, "delete from subject where id = $1 and school_id = $2", id, schoolId)
+	}
+}
@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 	"time"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -38,10 +41,6 @@ func ParseSubstituteTimetable(f url.Values, parserCtx context.Context, handlerCt
 	if err != nil {
 		return utils.NewParserError(nil, "Invalid room id (not convertable to int)")
 	}
-	schoolId, err := utils.ParseInt(span, "school_id", f.Get("school_id"))
-	if err != nil {
-		return utils.NewParserError(nil, "Invalid school id (not convertable to int)")
-	}
 	dateUnprocessed := f.Get("date")
 	date, err := time.Parse(time.DateOnly, dateUnprocessed)
 	span.SetAttributes(
@@ -57,15 +56,36 @@ func ParseSubstituteTimetable(f url.Values, parserCtx context.Context, handlerCt
 		periodId:  periodId,
 		subjectId: subjectId,
 		roomId:    roomId,
-		schoolId:  schoolId,
+		schoolId:  -1,
 		date:      date,
 	})
 
 	return nil
 }
 
-func (t SubstituteTimetable) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(
+// SaveToDBWithSchoolId saves the lesson in the school of the admin creating it
+func (t *SubstituteTimetable) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		t.schoolId = schoolId
+		return t.SaveToDB(tx)
+	}
+}
+
+func (t *SubstituteTimetable) SaveToDB(tx pgx.Tx) error {
+	if err := t.insert(tx); err != nil {
+		return err
+	}
+	if err := notifyOf(NotificationSubstitution, t.id)(tx); err != nil {
+		return err
+	}
+
+	return checkTimetableConflicts(tx, t.id)
+}
+
+// insert saves the lesson without checking conflicts, for callers which add
+// its groups and teachers in the same transaction and check them all at once
+func (t *SubstituteTimetable) insert(tx pgx.Tx) error {
+	return tx.QueryRow(
 		context.TODO(),
 		`
 		WITH inserted_timetable AS (
@@ -81,8 +101,168 @@ func (t SubstituteTimetable) SaveToDB(tx pgx.Tx) (err error) {
 		INSERT INTO substitute_timetable (id, date)
 		SELECT id, $6
 		FROM inserted_timetable
+		RETURNING id
 		`,
-		t.schoolId, substituteTimetableType, t.periodId, t.subjectId, t.roomId, t.date)
+		t.schoolId, substituteTimetableType, t.periodId, t.subjectId, t.roomId, t.date,
+	).Scan(&t.id)
+}
+
+type SubstituteTimetableFilter struct {
+	academicTimetableFilter
+	from *time.Time
+	to   *time.Time
+}
+
+func ParseSubstituteTimetableFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing substitute timetable filter")
 
+	academicFilter, parseErr := parseAcademicTimetableFilter(span, f)
+	if parseErr != nil {
+		return parseErr
+	}
+	from, err := parseOptionalTime(span, f, "from", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid from date")
+	}
+	to, err := parseOptionalTime(span, f, "to", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid to date")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "substitute timetable filter", SubstituteTimetableFilter{
+		academicTimetableFilter: academicFilter,
+		from:                    from,
+		to:                      to,
+	})
+
+	return nil
+}
+
+const substituteTimetableSelect = `
+	select st.id, at.period_id, at.subject_id, at.room_id, t.school_id, st.date
+	from substitute_timetable st
+	join academic_timetable at on at.id = st.id
+	join timetable t on t.id = st.id`
+
+func scanSubstituteTimetable(row pgx.CollectableRow) (t SubstituteTimetable, err error) {
+	err = row.Scan(&t.id, &t.periodId, &t.subjectId, &t.roomId, &t.schoolId, &t.date)
 	return
 }
+
+func ListSubstituteTimetables(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f SubstituteTimetableFilter) (Page[SubstituteTimetable], error) {
+	b := listBuilder{}
+	b.where("t.school_id = ?", schoolId)
+	f.apply(&b)
+	if f.from != nil {
+		b.where("st.date >= ?", *f.from)
+	}
+	if f.to != nil {
+		b.where("st.date <= ?", *f.to)
+	}
+
+	query, args, err := b.build(substituteTimetableSelect, []string{"st.id"}, q)
+	if err != nil {
+		return Page[SubstituteTimetable]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[SubstituteTimetable]{}, err
+	}
+
+	return collectPage(rows, q, scanSubstituteTimetable, func(t SubstituteTimetable) []string {
+		return []string{fmt.Sprint(t.id)}
+	})
+}
+
+func GetSubstituteTimetable(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (SubstituteTimetable, error) {
+	rows, err := db.Query(ctx, substituteTimetableSelect+" where t.school_id = $1 and st.id = $2", schoolId, id)
+	if err != nil {
+		return SubstituteTimetable{}, err
+	}
+	return pgx.CollectOneRow(rows, scanSubstituteTimetable)
+}
+
+func (t SubstituteTimetable) Id() int {
+	return t.id
+}
+
+func (t SubstituteTimetable) Date() string {
+	return t.date.Format(time.DateOnly)
+}
+
+func (t SubstituteTimetable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id        int    `json:"id"`
+		PeriodId  int    `json:"periodId"`
+		SubjectId int    `json:"subjectId"`
+		RoomId    int    `json:"roomId"`
+		SchoolId  int    `json:"schoolId"`
+		Date      string `json:"date"`
+	}{
+		Id:        t.id,
+		PeriodId:  t.periodId,
+		SubjectId: t.subjectId,
+		RoomId:    t.roomId,
+		SchoolId:  t.schoolId,
+		Date:      t.date.Format(time.DateOnly),
+	})
+}
+
+type SubstituteTimetableUpdate struct {
+	academicTimetableUpdate
+	date *time.Time
+}
+
+func ParseSubstituteTimetableUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing substitute timetable update")
+
+	academicUpdate, parseErr := parseAcademicTimetableUpdate(span, f)
+	if parseErr != nil {
+		return parseErr
+	}
+	date, err := parseOptionalTime(span, f, "date", time.DateOnly)
+	if err != nil {
+		return utils.NewParserError(err, "Invalid date")
+	}
+
+	update := SubstituteTimetableUpdate{
+		academicTimetableUpdate: academicUpdate,
+		date:                    date,
+	}
+	if update == (SubstituteTimetableUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "substitute timetable update", update)
+
+	return nil
+}
+
+func (u SubstituteTimetableUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkTimetableInSchool(tx, schoolId, id, substituteTimetableType); err != nil {
+			return err
+		}
+		if err := u.academicTimetableUpdate.updateInDB(tx, id); err != nil {
+			return err
+		}
+
+		b := updateBuilder{}
+		if u.date != nil {
+			b.set("date", *u.date)
+		}
+		if err := b.exec(tx, "substitute_timetable", "id = ?", id); err != nil {
+			return err
+		}
+
+		return checkTimetableConflicts(tx, id)
+	}
+}
+
+func DeleteSubstituteTimetable(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return deleteTimetable(tx, schoolId, id, substituteTimetableType, "substitute_timetable", "academic_timetable")
+	}
+}
@@ -0,0 +1,362 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrLessonNotAffected = errors.New("Lesson isn't taught by the absent teacher during the absence")
+
+// SubstitutionSuggestion is a lesson the absent teacher can't teach together with
+// teachers who could take it over, the best candidates come first
+type SubstitutionSuggestion struct {
+	Date       string                `json:"date"`
+	PeriodId   int                   `json:"periodId"`
+	Lesson     WeekLesson            `json:"lesson"`
+	Candidates []SubstituteCandidate `json:"candidates"`
+}
+
+type SubstituteCandidate struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Qualified teachers teach the subject somewhere in the regular timetable
+	Qualified bool `json:"qualified"`
+	// Load is the number of substitute lessons the teacher has in the month of the lesson
+	Load int `json:"load"`
+}
+
+type substitutionLoadKey struct {
+	teacherId string
+	month     string
+}
+
+// SuggestSubstitutions lists lessons of the effective timetable (see ResolveWeekTimetable)
+// the absent teacher should teach during the absence. Candidates are teachers who have
+// no lesson, event or absence at the time, qualified ones first, then the ones
+// with the fewest substitutions in the month
+func SuggestSubstitutions(ctx context.Context, db *pgxpool.Pool, schoolId, absenceId int) ([]SubstitutionSuggestion, error) {
+	absence, err := GetAbsence(ctx, db, schoolId, absenceId)
+	if err != nil {
+		return nil, err
+	}
+	first := weekStart(absence.start)
+	last := weekStart(absence.end).AddDate(0, 0, len(Weekdays)-1)
+
+	periods, err := loadWeekPeriods(ctx, db, schoolId)
+	if err != nil {
+		return nil, err
+	}
+	lessons, err := loadWeekLessons(ctx, db, schoolId, first, last)
+	if err != nil {
+		return nil, err
+	}
+	events, err := loadWeekEvents(ctx, db, schoolId, first, last.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	teachers, err := loadSchoolTeachers(ctx, db, schoolId)
+	if err != nil {
+		return nil, err
+	}
+	qualifications, err := loadQualifications(ctx, db, schoolId)
+	if err != nil {
+		return nil, err
+	}
+	loads, err := loadSubstitutionLoads(ctx, db, schoolId, first, last)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(ctx,
+		absenceSelect+" where u.school_id = $1 and a.id <> $2 and a.span && tsrange($3, $4, '[]')",
+		schoolId, absenceId, first, last.AddDate(0, 0, 1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	absences, err := pgx.CollectRows(rows, scanAbsence)
+	if err != nil {
+		return nil, err
+	}
+
+	absent := WeekTimetableFilter{teacherId: &absence.userId}
+	suggestions := []SubstitutionSuggestion{}
+	for monday := first; !monday.After(last); monday = monday.AddDate(0, 0, 7) {
+		own := resolveWeek(monday, periods, lessons, events, absent)
+		all := resolveWeek(monday, periods, lessons, events, WeekTimetableFilter{})
+
+		for d, day := range own.Days {
+			date := monday.AddDate(0, 0, d)
+			for slot, p := range periods {
+				start, end := periodOnDate(p, date)
+				if !start.Before(absence.end) || !end.After(absence.start) {
+					continue
+				}
+
+				for _, l := range day.Slots[slot] {
+					//substitute lessons are covered already and a lesson without groups
+					//can't be replaced by a substitute with a different teacher (see isSubstituted)
+					if l.Substitute || l.Cancelled || len(l.GroupIds) == 0 {
+						continue
+					}
+
+					busy := busyTeachers(all.Days[d].Slots[slot], events, absences, start, end)
+					busy = append(busy, absence.userId.String())
+
+					candidates := []SubstituteCandidate{}
+					for _, t := range teachers {
+						if slices.Contains(busy, t.Id) {
+							continue
+						}
+						candidates = append(candidates, SubstituteCandidate{
+							Id:        t.Id,
+							Name:      t.Name,
+							Qualified: slices.Contains(qualifications[t.Id], l.SubjectId),
+							Load:      loads[substitutionLoadKey{teacherId: t.Id, month: date.Format("2006-01")}],
+						})
+					}
+					//stable, so teachers with the same rank stay sorted by name
+					slices.SortStableFunc(candidates, func(a, b SubstituteCandidate) int {
+						if a.Qualified != b.Qualified {
+							if a.Qualified {
+								return -1
+							}
+							return 1
+						}
+						return cmp.Compare(a.Load, b.Load)
+					})
+
+					suggestions = append(suggestions, SubstitutionSuggestion{
+						Date:       day.Date,
+						PeriodId:   p.Id,
+						Lesson:     l,
+						Candidates: candidates,
+					})
+				}
+			}
+		}
+	}
+
+	return suggestions, nil
+}
+
+func busyTeachers(slot []WeekLesson, events []weekEvent, absences []Absence, start, end time.Time) []string {
+	busy := []string{}
+	for _, l := range slot {
+		busy = append(busy, teacherIds(l.Teachers)...)
+	}
+	for _, e := range events {
+		if e.start.Before(end) && e.end.After(start) {
+			busy = append(busy, e.teachers...)
+		}
+	}
+	for _, a := range absences {
+		if a.start.Before(end) && a.end.After(start) {
+			busy = append(busy, a.userId.String())
+		}
+	}
+	return busy
+}
+
+func loadSchoolTeachers(ctx context.Context, db *pgxpool.Pool, schoolId int) ([]WeekTeacher, error) {
+	rows, err := db.Query(ctx, `
+		select id::text, name || ' ' || surname
+		from users
+		where school_id = $1 and role in ('teacher', 'admin')
+		order by surname, name`,
+		schoolId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (t WeekTeacher, err error) {
+		err = row.Scan(&t.Id, &t.Name)
+		return
+	})
+}
+
+// loadQualifications returns subjects every teacher teaches in the regular timetable
+func loadQualifications(ctx context.Context, db *pgxpool.Pool, schoolId int) (map[string][]int, error) {
+	rows, err := db.Query(ctx, `
+		select distinct tt.teacher_id::text, at.subject_id
+		from regular_timetable rt
+		join academic_timetable at on at.id = rt.id
+		join timetable t on t.id = rt.id
+		join timetable_teacher tt on tt.timetable_id = rt.id
+		where t.school_id = $1`,
+		schoolId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	qualifications := map[string][]int{}
+	var teacherId string
+	var subjectId int
+	_, err = pgx.ForEachRow(rows, []any{&teacherId, &subjectId}, func() error {
+		qualifications[teacherId] = append(qualifications[teacherId], subjectId)
+		return nil
+	})
+	return qualifications, err
+}
+
+// loadSubstitutionLoads counts substitute lessons of every teacher in the months from-to touches
+func loadSubstitutionLoads(ctx context.Context, db *pgxpool.Pool, schoolId int, from, to time.Time) (map[substitutionLoadKey]int, error) {
+	rows, err := db.Query(ctx, `
+		select tt.teacher_id::text, to_char(st.date, 'YYYY-MM'), count(*)
+		from substitute_timetable st
+		join timetable t on t.id = st.id
+		join timetable_teacher tt on tt.timetable_id = st.id
+		where t.school_id = $1
+			and st.date >= date_trunc('month', $2::date)
+			and st.date < date_trunc('month', $3::date) + interval '1 month'
+		group by 1, 2`,
+		schoolId, from.Format(time.DateOnly), to.Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	loads := map[substitutionLoadKey]int{}
+	var key substitutionLoadKey
+	var count int
+	_, err = pgx.ForEachRow(rows, []any{&key.teacherId, &key.month, &count}, func() error {
+		loads[key] = count
+		return nil
+	})
+	return loads, err
+}
+
+type acceptedSubstitution struct {
+	timetableId int
+	date        time.Time
+	teacherId   uuid.UUID
+}
+
+// SubstitutionAcceptance are suggestions the admin accepted. They come as parallel arrays
+// (timetable_id, date, teacher_id), the i-th values of all of them make one substitution
+type SubstitutionAcceptance struct {
+	substitutions []acceptedSubstitution
+}
+
+func ParseSubstitutionAcceptance(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing substitution acceptance")
+
+	timetableIds, dates, teacherIds := f["timetable_id"], f["date"], f["teacher_id"]
+	if len(timetableIds) == 0 {
+		return utils.NewParserError(nil, "No substitutions provided")
+	}
+	if len(dates) != len(timetableIds) || len(teacherIds) != len(timetableIds) {
+		return utils.NewParserError(nil, "Every substitution needs timetable_id, date and teacher_id")
+	}
+
+	acceptance := SubstitutionAcceptance{}
+	for i := range timetableIds {
+		timetableId, err := utils.ParseInt(span, "timetable_id", timetableIds[i])
+		if err != nil {
+			return utils.NewParserError(err, "Invalid timetable id (not convertable to int)")
+		}
+		date, err := utils.ParseTime(span, "date", dates[i], time.DateOnly)
+		if err != nil {
+			return utils.NewParserError(err, "Invalid date")
+		}
+		teacherId, err := utils.ParseUuid(span, "teacher_id", teacherIds[i])
+		if err != nil {
+			return utils.NewParserError(err, "Invalid teacher id")
+		}
+
+		acceptance.substitutions = append(acceptance.substitutions, acceptedSubstitution{
+			timetableId: timetableId,
+			date:        date,
+			teacherId:   teacherId,
+		})
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "substitution acceptance", acceptance)
+
+	return nil
+}
+
+// Accept creates a substitute lesson for every accepted substitution, it takes over
+// the period, subject, room and groups of the regular lesson and is taught by the chosen teacher.
+// Conflicts are checked once the lesson has its groups, before that it would clash
+// with the regular lesson it replaces
+func (a SubstitutionAcceptance) Accept(schoolId, absenceId int, created *[]SubstituteTimetable) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		var id int
+		err := tx.QueryRow(context.TODO(),
+			"select a.id from absence a join users u on u.id = a.user_id where a.id = $1 and u.school_id = $2",
+			absenceId, schoolId,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range a.substitutions {
+			lesson := SubstituteTimetable{schoolId: schoolId, date: s.date}
+			var groupIds []int
+			var teacherInSchool bool
+			err := tx.QueryRow(context.TODO(), `
+				select
+					at.period_id, at.subject_id, at.room_id,
+					array(select group_id from timetable_group where timetable_id = rt.id),
+					exists (select 1 from users where id = $5 and school_id = $1)
+				from regular_timetable rt
+				join academic_timetable at on at.id = rt.id
+				join timetable t on t.id = rt.id
+				join period p on p.id = at.period_id
+				join timetable_teacher tt on tt.timetable_id = rt.id
+				join absence a on a.user_id = tt.teacher_id
+				where t.school_id = $1 and rt.id = $2 and a.id = $3
+					and rt.weekday = (enum_range(null::weekday))[extract(isodow from $4::date)::int]
+					and (rt.academic_year_id is null or exists (
+						select 1 from academic_year y where y.id = rt.academic_year_id and y.span @> $4::date
+					))
+					and a.span && tsrange($4::date + lower(p.span), $4::date + upper(p.span))`,
+				schoolId, s.timetableId, absenceId, s.date.Format(time.DateOnly), s.teacherId,
+			).Scan(&lesson.periodId, &lesson.subjectId, &lesson.roomId, &groupIds, &teacherInSchool)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrLessonNotAffected
+			} else if err != nil {
+				return err
+			}
+			if !teacherInSchool {
+				return ErrForeignReference
+			}
+
+			if err := lesson.insert(tx); err != nil {
+				return err
+			}
+			for _, groupId := range groupIds {
+				timetableGroup := TimetableGroup{timetableId: lesson.id, groupId: groupId}
+				if err := timetableGroup.insert(tx); err != nil {
+					return err
+				}
+			}
+			timetableTeacher := TimetableTeacher{timetableId: lesson.id, teacherId: s.teacherId}
+			if err := timetableTeacher.insert(tx); err != nil {
+				return err
+			}
+			if err := checkTimetableConflicts(tx, lesson.id); err != nil {
+				return err
+			}
+			if err := notifyOf(NotificationSubstitution, lesson.id)(tx); err != nil {
+				return err
+			}
+
+			*created = append(*created, lesson)
+		}
+
+		return nil
+	}
+}
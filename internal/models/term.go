@@ -0,0 +1,240 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Term is a part of an academic year grades are given for, e.g. a semester
+type Term struct {
+	id             int
+	academicYearId int
+	name           string
+	start          time.Time
+	end            time.Time
+}
+
+func ParseTerm(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing term")
+
+	academicYearId, err := utils.ParseInt(span, "academic_year_id", f.Get("academic_year_id"))
+	if err != nil {
+		return utils.NewParserError(err, "Invalid academic year id (not an int)")
+	}
+	name := f.Get("name")
+	span.SetAttributes(attribute.String("name", name))
+	if name == "" {
+		return utils.NewParserError(nil, "Name not provided")
+	}
+	start, end, parseErr := parseDateSpan(span, f)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "term", Term{
+		id:             -1,
+		academicYearId: academicYearId,
+		name:           name,
+		start:          start,
+		end:            end,
+	})
+
+	return nil
+}
+
+func (t *Term) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkAcademicYearInSchool(tx, schoolId, t.academicYearId); err != nil {
+			return err
+		}
+		rows, err := tx.Query(context.TODO(),
+			"insert into term (academic_year_id, name, span) values ($1, $2, $3) returning id, academic_year_id, name, lower(span), upper(span) - 1",
+			t.academicYearId, t.name, dateSpan(t.start, t.end),
+		)
+		if err != nil {
+			return err
+		}
+		*t, err = pgx.CollectOneRow(rows, scanTerm)
+		return err
+	}
+}
+
+type TermFilter struct {
+	academicYearId *int
+}
+
+func ParseTermFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing term filter")
+
+	academicYearId, err := parseOptionalInt(span, f, "academic_year_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid academic year id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "term filter", TermFilter{
+		academicYearId: academicYearId,
+	})
+
+	return nil
+}
+
+const termSelect = `
+	select t.id, t.academic_year_id, t.name, lower(t.span), upper(t.span) - 1
+	from term t
+	join academic_year y on y.id = t.academic_year_id`
+
+// termTimeRange selects the term as a range of timestamps, it is used by filters of
+// records which happen at a time, e.g. reports or absences
+const termTimeRange = "select tsrange(lower(span), upper(span)) from term where id = ?"
+
+func scanTerm(row pgx.CollectableRow) (t Term, err error) {
+	err = row.Scan(&t.id, &t.academicYearId, &t.name, &t.start, &t.end)
+	return
+}
+
+func ListTerms(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f TermFilter) (Page[Term], error) {
+	b := listBuilder{}
+	b.where("y.school_id = ?", schoolId)
+	if f.academicYearId != nil {
+		b.where("t.academic_year_id = ?", *f.academicYearId)
+	}
+
+	query, args, err := b.build(termSelect, []string{"t.id"}, q)
+	if err != nil {
+		return Page[Term]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[Term]{}, err
+	}
+
+	return collectPage(rows, q, scanTerm, func(t Term) []string {
+		return []string{fmt.Sprint(t.id)}
+	})
+}
+
+func GetTerm(ctx context.Context, db *pgxpool.Pool, schoolId, id int) (Term, error) {
+	rows, err := db.Query(ctx, termSelect+" where y.school_id = $1 and t.id = $2", schoolId, id)
+	if err != nil {
+		return Term{}, err
+	}
+	return pgx.CollectOneRow(rows, scanTerm)
+}
+
+// termSpan returns the first and the last day of the term of the school
+func termSpan(ctx context.Context, db querier, schoolId, id int) (time.Time, time.Time, error) {
+	rows, err := db.Query(ctx, termSelect+" where y.school_id = $1 and t.id = $2", schoolId, id)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	t, err := pgx.CollectOneRow(rows, scanTerm)
+	return t.start, t.end, err
+}
+
+// currentTerm returns the first and the last day of the term containing the day,
+// schools which haven't set up their terms get the whole academic year
+func currentTerm(ctx context.Context, db querier, schoolId int, day time.Time) (time.Time, time.Time, error) {
+	rows, err := db.Query(ctx, `
+		select lower(t.span), upper(t.span) - 1
+		from term t
+		join academic_year y on y.id = t.academic_year_id
+		where y.school_id = $1 and t.span @> $2::date`,
+		schoolId, day.Format(time.DateOnly),
+	)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, to, err := collectDateSpan(rows)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return currentAcademicYear(ctx, db, schoolId, day)
+	}
+	return from, to, err
+}
+
+// termWindow resolves days covered by queries filtering by term, it is the term unless
+// termId is nil, then the term containing today. From and to narrow the term down.
+// pgx.ErrNoRows means the term isn't in the school
+func termWindow(ctx context.Context, db querier, schoolId int, termId *int, from, to *time.Time) (start, end time.Time, err error) {
+	if termId != nil {
+		start, end, err = termSpan(ctx, db, schoolId, *termId)
+	} else {
+		start, end, err = currentTerm(ctx, db, schoolId, time.Now())
+	}
+	if from != nil {
+		start = *from
+	}
+	if to != nil {
+		end = *to
+	}
+	return
+}
+
+func (t Term) Id() int {
+	return t.id
+}
+
+func (t Term) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id             int    `json:"id"`
+		AcademicYearId int    `json:"academicYearId"`
+		Name           string `json:"name"`
+		Start          string `json:"start"`
+		End            string `json:"end"`
+	}{
+		Id:             t.id,
+		AcademicYearId: t.academicYearId,
+		Name:           t.name,
+		Start:          t.start.Format(time.DateOnly),
+		End:            t.end.Format(time.DateOnly),
+	})
+}
+
+type TermUpdate struct {
+	dateSpanUpdate
+}
+
+func ParseTermUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing term update")
+
+	update, parseErr := parseDateSpanUpdate(span, f)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "term update", TermUpdate{update})
+
+	return nil
+}
+
+func (u TermUpdate) UpdateInDB(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		u.apply(&b)
+		return b.exec(tx, "term",
+			"id = ? and exists (select 1 from academic_year y where y.id = term.academic_year_id and y.school_id = ?)",
+			id, schoolId,
+		)
+	}
+}
+
+func DeleteTerm(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx,
+			"delete from term where id = $1 and exists (select 1 from academic_year y where y.id = term.academic_year_id and y.school_id = $2)",
+			id, schoolId,
+		)
+	}
+}
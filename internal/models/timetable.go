@@ -0,0 +1,160 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// academicTimetableFilter holds the filters shared by regular and substitute timetables,
+// the academic_timetable table is expected to be aliased as "at"
+type academicTimetableFilter struct {
+	periodId  *int
+	subjectId *int
+	roomId    *int
+	groupId   *int
+	teacherId *uuid.UUID
+}
+
+func parseAcademicTimetableFilter(span trace.Span, f url.Values) (academicTimetableFilter, *utils.ParseError) {
+	periodId, err := parseOptionalInt(span, f, "period_id")
+	if err != nil {
+		return academicTimetableFilter{}, utils.NewParserError(err, "Invalid period id (not convertable to int)")
+	}
+	subjectId, err := parseOptionalInt(span, f, "subject_id")
+	if err != nil {
+		return academicTimetableFilter{}, utils.NewParserError(err, "Invalid subject id (not convertable to int)")
+	}
+	roomId, err := parseOptionalInt(span, f, "room_id")
+	if err != nil {
+		return academicTimetableFilter{}, utils.NewParserError(err, "Invalid room id (not convertable to int)")
+	}
+	groupId, err := parseOptionalInt(span, f, "group_id")
+	if err != nil {
+		return academicTimetableFilter{}, utils.NewParserError(err, "Invalid group id (not convertable to int)")
+	}
+	teacherId, err := parseOptionalUuid(span, f, "teacher_id")
+	if err != nil {
+		return academicTimetableFilter{}, utils.NewParserError(err, "Invalid teacher id")
+	}
+
+	return academicTimetableFilter{
+		periodId:  periodId,
+		subjectId: subjectId,
+		roomId:    roomId,
+		groupId:   groupId,
+		teacherId: teacherId,
+	}, nil
+}
+
+func (f academicTimetableFilter) apply(b *listBuilder) {
+	if f.periodId != nil {
+		b.where("at.period_id = ?", *f.periodId)
+	}
+	if f.subjectId != nil {
+		b.where("at.subject_id = ?", *f.subjectId)
+	}
+	if f.roomId != nil {
+		b.where("at.room_id = ?", *f.roomId)
+	}
+	if f.groupId != nil {
+		b.where("exists (select 1 from timetable_group tg where tg.timetable_id = at.id and tg.group_id = ?)", *f.groupId)
+	}
+	if f.teacherId != nil {
+		b.where("exists (select 1 from timetable_teacher tt where tt.timetable_id = at.id and tt.teacher_id = ?)", *f.teacherId)
+	}
+}
+
+type academicTimetableUpdate struct {
+	periodId  *int
+	subjectId *int
+	roomId    *int
+}
+
+func parseAcademicTimetableUpdate(span trace.Span, f url.Values) (academicTimetableUpdate, *utils.ParseError) {
+	periodId, err := parseOptionalInt(span, f, "period_id")
+	if err != nil {
+		return academicTimetableUpdate{}, utils.NewParserError(err, "Invalid period id (not convertable to int)")
+	}
+	subjectId, err := parseOptionalInt(span, f, "subject_id")
+	if err != nil {
+		return academicTimetableUpdate{}, utils.NewParserError(err, "Invalid subject id (not convertable to int)")
+	}
+	roomId, err := parseOptionalInt(span, f, "room_id")
+	if err != nil {
+		return academicTimetableUpdate{}, utils.NewParserError(err, "Invalid room id (not convertable to int)")
+	}
+
+	return academicTimetableUpdate{
+		periodId:  periodId,
+		subjectId: subjectId,
+		roomId:    roomId,
+	}, nil
+}
+
+func (u academicTimetableUpdate) updateInDB(tx pgx.Tx, id int) error {
+	b := updateBuilder{}
+	if u.periodId != nil {
+		b.set("period_id", *u.periodId)
+	}
+	if u.subjectId != nil {
+		b.set("subject_id", *u.subjectId)
+	}
+	if u.roomId != nil {
+		b.set("room_id", *u.roomId)
+	}
+	return b.exec(tx, "academic_timetable", "id = ?", id)
+}
+
+// checkTimetableInSchool makes sure timetable of given type exists and belongs to the school,
+// it locks the row so it can't be deleted while being updated
+func checkTimetableInSchool(tx pgx.Tx, schoolId, id int, timetableType string) error {
+	var found int
+	return tx.QueryRow(context.TODO(),
+		"select id from timetable where id = $1 and school_id = $2 and type = $3 for update",
+		id, schoolId, timetableType,
+	).Scan(&found)
+}
+
+// ErrTimetableHasNotes means the timetable can't be deleted because notes are dated to it
+var ErrTimetableHasNotes = errors.New("Can't delete, the timetable still has notes")
+
+// deleteTimetable deletes timetable of given type together with its groups and teachers.
+// Reports and undated notes referencing it are left in place so the delete fails on their foreign keys,
+// note_with_date doesn't inherit the foreign key of note, so dated notes are checked explicitly
+func deleteTimetable(tx pgx.Tx, schoolId, id int, timetableType string, subtables ...string) error {
+	if err := checkTimetableInSchool(tx, schoolId, id, timetableType); err != nil {
+		return err
+	}
+
+	var hasNotes bool
+	err := tx.QueryRow(context.TODO(), "select exists (select 1 from note_with_date where timetable_id = $1)", id).Scan(&hasNotes)
+	if err != nil {
+		return err
+	} else if hasNotes {
+		return ErrTimetableHasNotes
+	}
+
+	queries := []string{
+		"delete from timetable_group where timetable_id = $1",
+		"delete from timetable_teacher where timetable_id = $1",
+	}
+	//subtables have to be deleted from the most specific one, e.g. regular_timetable before academic_timetable
+	for _, table := range subtables {
+		queries = append(queries, fmt.Sprintf("delete from %s where id = $1", table))
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(context.TODO(), query, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(context.TODO(), "delete from timetable where id = $1", id)
+	return err
+}
@@ -0,0 +1,551 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrForeignReference = errors.New("Referenced record doesn't belong to the school")
+
+// timetableRequirement says that the group has hours of the subject every week taught by the teacher
+type timetableRequirement struct {
+	groupId   int
+	subjectId int
+	teacherId uuid.UUID
+	hours     int
+}
+
+// TimetableRequirements are the input of the timetable generator. Requirements come as parallel
+// arrays (group_id, subject_id, teacher_id, hours), the i-th values of all of them make one requirement.
+// Room suitability comes the same way (room_id, room_subject_id), subjects without any suitable
+// room listed can be taught in every room of the school. Lessons are generated for the academic year,
+// the one going on is used when it isn't given
+type TimetableRequirements struct {
+	requirements   []timetableRequirement
+	roomSubjects   map[int][]int
+	academicYearId *int
+}
+
+func ParseTimetableRequirements(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing timetable requirements")
+
+	groupIds, subjectIds, teacherIds, hours := f["group_id"], f["subject_id"], f["teacher_id"], f["hours"]
+	if len(groupIds) == 0 {
+		return utils.NewParserError(nil, "No requirements provided")
+	}
+	if len(subjectIds) != len(groupIds) || len(teacherIds) != len(groupIds) || len(hours) != len(groupIds) {
+		return utils.NewParserError(nil, "Every requirement needs group_id, subject_id, teacher_id and hours")
+	}
+
+	requirements := TimetableRequirements{roomSubjects: map[int][]int{}}
+	for i := range groupIds {
+		groupId, err := utils.ParseInt(span, "group_id", groupIds[i])
+		if err != nil {
+			return utils.NewParserError(err, "Invalid group id (not convertable to int)")
+		}
+		subjectId, err := utils.ParseInt(span, "subject_id", subjectIds[i])
+		if err != nil {
+			return utils.NewParserError(err, "Invalid subject id (not convertable to int)")
+		}
+		teacherId, err := utils.ParseUuid(span, "teacher_id", teacherIds[i])
+		if err != nil {
+			return utils.NewParserError(err, "Invalid teacher id")
+		}
+		weeklyHours, err := utils.ParseInt(span, "hours", hours[i])
+		if err != nil {
+			return utils.NewParserError(err, "Invalid hours (not convertable to int)")
+		} else if weeklyHours < 1 {
+			return utils.NewParserError(nil, "Hours have to be at least 1")
+		}
+
+		requirements.requirements = append(requirements.requirements, timetableRequirement{
+			groupId:   groupId,
+			subjectId: subjectId,
+			teacherId: teacherId,
+			hours:     weeklyHours,
+		})
+	}
+
+	roomIds, roomSubjectIds := f["room_id"], f["room_subject_id"]
+	if len(roomIds) != len(roomSubjectIds) {
+		return utils.NewParserError(nil, "Every room_id needs room_subject_id")
+	}
+	for i := range roomIds {
+		roomId, err := utils.ParseInt(span, "room_id", roomIds[i])
+		if err != nil {
+			return utils.NewParserError(err, "Invalid room id (not convertable to int)")
+		}
+		subjectId, err := utils.ParseInt(span, "room_subject_id", roomSubjectIds[i])
+		if err != nil {
+			return utils.NewParserError(err, "Invalid room subject id (not convertable to int)")
+		}
+		requirements.roomSubjects[subjectId] = append(requirements.roomSubjects[subjectId], roomId)
+	}
+
+	academicYearId, err := parseOptionalInt(span, f, "academic_year_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid academic year id (not convertable to int)")
+	}
+	requirements.academicYearId = academicYearId
+
+	*handlerCtx = context.WithValue(*handlerCtx, "timetable requirements", requirements)
+
+	return nil
+}
+
+type draftLesson struct {
+	groupId   int
+	subjectId int
+	teacherId uuid.UUID
+	roomId    int
+	periodId  int
+	weekday   string
+}
+
+type TimetableDraft struct {
+	id        int
+	schoolId  int
+	createdBy uuid.UUID
+	createdAt time.Time
+	// academicYearId is the year the lessons are committed into, nil means they are valid forever
+	academicYearId *int
+	lessons        []draftLesson
+	// unplaced requirements, hours are the hours which didn't fit into the timetable
+	unplaced []timetableRequirement
+}
+
+func (d TimetableDraft) Id() int {
+	return d.id
+}
+
+type timetableSlot struct {
+	weekday  string
+	periodId int
+}
+
+// timetableOccupancy tracks who and what is busy in every slot of the week
+type timetableOccupancy struct {
+	groups   map[timetableSlot][]int
+	teachers map[timetableSlot][]uuid.UUID
+	rooms    map[timetableSlot][]int
+}
+
+func (o timetableOccupancy) isFree(slot timetableSlot, groupId int, teacherId uuid.UUID) bool {
+	return !slices.Contains(o.groups[slot], groupId) && !slices.Contains(o.teachers[slot], teacherId)
+}
+
+func (o timetableOccupancy) occupy(slot timetableSlot, groupIds []int, teacherIds []uuid.UUID, roomId int) {
+	o.groups[slot] = append(o.groups[slot], groupIds...)
+	o.teachers[slot] = append(o.teachers[slot], teacherIds...)
+	o.rooms[slot] = append(o.rooms[slot], roomId)
+}
+
+// GenerateTimetableDraft places the required lessons around the existing regular timetable
+// of the school and saves the result as a draft, nothing in the timetable itself changes
+func (r TimetableRequirements) GenerateTimetableDraft(schoolId int, createdBy uuid.UUID, draft *TimetableDraft) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := r.checkInSchool(tx, schoolId); err != nil {
+			return err
+		}
+		academicYearId := r.academicYearId
+		if academicYearId != nil {
+			if err := checkAcademicYearInSchool(tx, schoolId, *academicYearId); err != nil {
+				return err
+			}
+		} else {
+			//schools without academic years get lessons valid forever
+			rows, err := tx.Query(context.TODO(), "select id from academic_year where school_id = $1 and span @> current_date", schoolId)
+			if err != nil {
+				return err
+			}
+			current, err := pgx.CollectRows(rows, pgx.RowTo[int])
+			if err != nil {
+				return err
+			}
+			if len(current) > 0 {
+				academicYearId = &current[0]
+			}
+		}
+
+		rows, err := tx.Query(context.TODO(), "select id from period where school_id = $1 order by lower(span)", schoolId)
+		if err != nil {
+			return err
+		}
+		periodIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+		rows, err = tx.Query(context.TODO(), "select id from room where school_id = $1 order by id", schoolId)
+		if err != nil {
+			return err
+		}
+		roomIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+		occupancy, err := loadTimetableOccupancy(tx, schoolId, academicYearId)
+		if err != nil {
+			return err
+		}
+
+		*draft = TimetableDraft{schoolId: schoolId, createdBy: createdBy, academicYearId: academicYearId}
+		draft.lessons, draft.unplaced = r.generate(periodIds, roomIds, occupancy)
+
+		return draft.saveToDB(tx)
+	}
+}
+
+func (r TimetableRequirements) checkInSchool(tx pgx.Tx, schoolId int) error {
+	var groupIds, subjectIds, roomIds []int
+	var teacherIds []uuid.UUID
+	for _, req := range r.requirements {
+		groupIds = append(groupIds, req.groupId)
+		subjectIds = append(subjectIds, req.subjectId)
+		teacherIds = append(teacherIds, req.teacherId)
+	}
+	for subjectId, rooms := range r.roomSubjects {
+		subjectIds = append(subjectIds, subjectId)
+		roomIds = append(roomIds, rooms...)
+	}
+
+	var foreign bool
+	err := tx.QueryRow(context.TODO(), `
+		select
+			exists (
+				select 1 from unnest($2::int[]) as requested(id)
+				where not exists (
+					select 1 from "group" g
+					join class c on c.id = g.class_id
+					join users ct on ct.id = c.class_teacher_id
+					where g.id = requested.id and ct.school_id = $1
+				)
+			)
+			or exists (
+				select 1 from unnest($3::uuid[]) as requested(id)
+				where not exists (select 1 from users u where u.id = requested.id and u.school_id = $1)
+			)
+			or exists (
+				select 1 from unnest($4::int[]) as requested(id)
+				where not exists (select 1 from room r where r.id = requested.id and r.school_id = $1)
+			)
+			or exists (
+				select 1 from unnest($5::int[]) as requested(id)
+				where not exists (select 1 from subject s where s.id = requested.id and s.school_id = $1)
+			)`,
+		schoolId, groupIds, teacherIds, roomIds, subjectIds,
+	).Scan(&foreign)
+	if err != nil {
+		return err
+	}
+	if foreign {
+		return ErrForeignReference
+	}
+
+	return nil
+}
+
+// loadTimetableOccupancy loads lessons of the academic year and lessons valid forever,
+// without a year it loads every lesson which hasn't ended yet
+func loadTimetableOccupancy(tx pgx.Tx, schoolId int, academicYearId *int) (timetableOccupancy, error) {
+	occupancy := timetableOccupancy{
+		groups:   map[timetableSlot][]int{},
+		teachers: map[timetableSlot][]uuid.UUID{},
+		rooms:    map[timetableSlot][]int{},
+	}
+
+	rows, err := tx.Query(context.TODO(), `
+		select
+			rt.weekday, at.period_id, at.room_id,
+			array(select group_id from timetable_group where timetable_id = rt.id),
+			array(select teacher_id from timetable_teacher where timetable_id = rt.id)
+		from regular_timetable rt
+		join academic_timetable at on at.id = rt.id
+		join timetable t on t.id = rt.id
+		left join academic_year y on y.id = rt.academic_year_id
+		where t.school_id = $1 and case
+			when $2::int is null then y.id is null or upper(y.span) > current_date
+			else y.id is null or y.id = $2
+		end`,
+		schoolId, academicYearId,
+	)
+	if err != nil {
+		return occupancy, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var slot timetableSlot
+		var roomId int
+		var groupIds []int
+		var teacherIds []uuid.UUID
+		if err := rows.Scan(&slot.weekday, &slot.periodId, &roomId, &groupIds, &teacherIds); err != nil {
+			return occupancy, err
+		}
+		occupancy.occupy(slot, groupIds, teacherIds, roomId)
+	}
+
+	return occupancy, rows.Err()
+}
+
+// generate places lessons greedily, the requirements with fewest suitable rooms and most hours
+// go first as they are the hardest to place. Lessons of a subject are spread over the week,
+// a day where the group doesn't have the subject yet is always preferred
+func (r TimetableRequirements) generate(periodIds, roomIds []int, occupancy timetableOccupancy) ([]draftLesson, []timetableRequirement) {
+	suitableRooms := func(subjectId int) []int {
+		if rooms, ok := r.roomSubjects[subjectId]; ok {
+			return rooms
+		}
+		return roomIds
+	}
+
+	requirements := slices.Clone(r.requirements)
+	slices.SortStableFunc(requirements, func(a, b timetableRequirement) int {
+		if diff := len(suitableRooms(a.subjectId)) - len(suitableRooms(b.subjectId)); diff != 0 {
+			return diff
+		}
+		return b.hours - a.hours
+	})
+
+	lessons := []draftLesson{}
+	unplaced := []timetableRequirement{}
+	for _, req := range requirements {
+		//how many lessons of the subject the group has on each day
+		perDay := map[string]int{}
+		missing := 0
+
+		for range req.hours {
+			days := slices.Clone(Weekdays)
+			slices.SortStableFunc(days, func(a, b string) int {
+				return perDay[a] - perDay[b]
+			})
+
+			placed := false
+			for _, weekday := range days {
+				for _, periodId := range periodIds {
+					slot := timetableSlot{weekday: weekday, periodId: periodId}
+					if placed || !occupancy.isFree(slot, req.groupId, req.teacherId) {
+						continue
+					}
+
+					for _, roomId := range suitableRooms(req.subjectId) {
+						if slices.Contains(occupancy.rooms[slot], roomId) {
+							continue
+						}
+
+						occupancy.occupy(slot, []int{req.groupId}, []uuid.UUID{req.teacherId}, roomId)
+						lessons = append(lessons, draftLesson{
+							groupId:   req.groupId,
+							subjectId: req.subjectId,
+							teacherId: req.teacherId,
+							roomId:    roomId,
+							periodId:  periodId,
+							weekday:   weekday,
+						})
+						perDay[weekday]++
+						placed = true
+						break
+					}
+				}
+			}
+
+			if !placed {
+				missing++
+			}
+		}
+
+		if missing > 0 {
+			req.hours = missing
+			unplaced = append(unplaced, req)
+		}
+	}
+
+	return lessons, unplaced
+}
+
+func (d *TimetableDraft) saveToDB(tx pgx.Tx) error {
+	err := tx.QueryRow(context.TODO(),
+		"insert into timetable_draft (school_id, created_by, academic_year_id) values ($1, $2, $3) returning id, created_at",
+		d.schoolId, d.createdBy, d.academicYearId,
+	).Scan(&d.id, &d.createdAt)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, l := range d.lessons {
+		batch.Queue(
+			`insert into timetable_draft_lesson (draft_id, group_id, subject_id, teacher_id, room_id, period_id, weekday)
+			values ($1, $2, $3, $4, $5, $6, $7)`,
+			d.id, l.groupId, l.subjectId, l.teacherId, l.roomId, l.periodId, l.weekday,
+		)
+	}
+	for _, u := range d.unplaced {
+		batch.Queue(
+			"insert into timetable_draft_unplaced (draft_id, group_id, subject_id, teacher_id, hours) values ($1, $2, $3, $4, $5)",
+			d.id, u.groupId, u.subjectId, u.teacherId, u.hours,
+		)
+	}
+
+	return tx.SendBatch(context.TODO(), batch).Close()
+}
+
+func GetTimetableDraft(ctx context.Context, db querier, schoolId, id int) (TimetableDraft, error) {
+	rows, err := db.Query(ctx,
+		"select id, school_id, created_by, created_at, academic_year_id from timetable_draft where school_id = $1 and id = $2",
+		schoolId, id,
+	)
+	if err != nil {
+		return TimetableDraft{}, err
+	}
+	draft, err := pgx.CollectOneRow(rows, func(row pgx.CollectableRow) (d TimetableDraft, err error) {
+		err = row.Scan(&d.id, &d.schoolId, &d.createdBy, &d.createdAt, &d.academicYearId)
+		return
+	})
+	if err != nil {
+		return TimetableDraft{}, err
+	}
+
+	rows, err = db.Query(ctx, `
+		select group_id, subject_id, teacher_id, room_id, period_id, weekday
+		from timetable_draft_lesson
+		where draft_id = $1
+		order by weekday, period_id, group_id`,
+		id,
+	)
+	if err != nil {
+		return TimetableDraft{}, err
+	}
+	draft.lessons, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (l draftLesson, err error) {
+		err = row.Scan(&l.groupId, &l.subjectId, &l.teacherId, &l.roomId, &l.periodId, &l.weekday)
+		return
+	})
+	if err != nil {
+		return TimetableDraft{}, err
+	}
+
+	rows, err = db.Query(ctx,
+		"select group_id, subject_id, teacher_id, hours from timetable_draft_unplaced where draft_id = $1",
+		id,
+	)
+	if err != nil {
+		return TimetableDraft{}, err
+	}
+	draft.unplaced, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (u timetableRequirement, err error) {
+		err = row.Scan(&u.groupId, &u.subjectId, &u.teacherId, &u.hours)
+		return
+	})
+	if err != nil {
+		return TimetableDraft{}, err
+	}
+
+	return draft, nil
+}
+
+// CommitTimetableDraft turns the draft into regular timetable, the lessons go through the same
+// SaveToDB as lessons created one by one, so anything added to the timetable since the draft
+// was generated is caught by the conflict check and the whole commit is rolled back
+func CommitTimetableDraft(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if _, err := tx.Exec(context.TODO(), "select 1 from timetable_draft where id = $1 for update", id); err != nil {
+			return err
+		}
+		draft, err := GetTimetableDraft(context.TODO(), tx, schoolId, id)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range draft.lessons {
+			timetable := RegularTimetable{
+				periodId:       l.periodId,
+				subjectId:      l.subjectId,
+				roomId:         l.roomId,
+				schoolId:       schoolId,
+				weekday:        l.weekday,
+				academicYearId: draft.academicYearId,
+			}
+			if err := timetable.SaveToDB(tx); err != nil {
+				return err
+			}
+			timetableGroup := TimetableGroup{timetableId: timetable.id, groupId: l.groupId}
+			if err := timetableGroup.SaveToDB(tx); err != nil {
+				return err
+			}
+			timetableTeacher := TimetableTeacher{timetableId: timetable.id, teacherId: l.teacherId}
+			if err := timetableTeacher.SaveToDB(tx); err != nil {
+				return err
+			}
+		}
+
+		return deleteTimetableDraft(tx, schoolId, id)
+	}
+}
+
+func DeleteTimetableDraft(schoolId, id int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return deleteTimetableDraft(tx, schoolId, id)
+	}
+}
+
+func deleteTimetableDraft(tx pgx.Tx, schoolId, id int) error {
+	return execAffectingRow(tx, "delete from timetable_draft where school_id = $1 and id = $2", schoolId, id)
+}
+
+func (l draftLesson) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		GroupId   int       `json:"groupId"`
+		SubjectId int       `json:"subjectId"`
+		TeacherId uuid.UUID `json:"teacherId"`
+		RoomId    int       `json:"roomId"`
+		PeriodId  int       `json:"periodId"`
+		Weekday   int       `json:"weekday"`
+	}{
+		GroupId:   l.groupId,
+		SubjectId: l.subjectId,
+		TeacherId: l.teacherId,
+		RoomId:    l.roomId,
+		PeriodId:  l.periodId,
+		Weekday:   weekdayNumber(l.weekday),
+	})
+}
+
+func (r timetableRequirement) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		GroupId   int       `json:"groupId"`
+		SubjectId int       `json:"subjectId"`
+		TeacherId uuid.UUID `json:"teacherId"`
+		Hours     int       `json:"hours"`
+	}{
+		GroupId:   r.groupId,
+		SubjectId: r.subjectId,
+		TeacherId: r.teacherId,
+		Hours:     r.hours,
+	})
+}
+
+func (d TimetableDraft) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id             int                    `json:"id"`
+		CreatedBy      uuid.UUID              `json:"createdBy"`
+		CreatedAt      time.Time              `json:"createdAt"`
+		AcademicYearId *int                   `json:"academicYearId"`
+		Lessons        []draftLesson          `json:"lessons"`
+		Unplaced       []timetableRequirement `json:"unplaced"`
+	}{
+		Id:             d.id,
+		CreatedBy:      d.createdBy,
+		CreatedAt:      d.createdAt,
+		AcademicYearId: d.academicYearId,
+		Lessons:        d.lessons,
+		Unplaced:       d.unplaced,
+	})
+}
@@ -2,10 +2,13 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -35,9 +38,118 @@ func ParseTimetableGroup(f url.Values, parserCtx context.Context, handlerCtx *co
 	return nil
 }
 
-func (tg TimetableGroup) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(context.TODO(),
-		"insert into timetable_group (timetable_id, group_id) values ($1, $2)",
-		tg.timetableId, tg.groupId)
+func (tg *TimetableGroup) SaveToDB(tx pgx.Tx) error {
+	if err := tg.insert(tx); err != nil {
+		return err
+	}
+
+	return checkTimetableConflicts(tx, tg.timetableId)
+}
+
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the lesson or the group is in another school
+func (tg *TimetableGroup) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, timetableRef(tg.timetableId), groupRef(tg.groupId)); err != nil {
+			return err
+		}
+		return tg.SaveToDB(tx)
+	}
+}
+
+func (tg *TimetableGroup) insert(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		"insert into timetable_group (timetable_id, group_id) values ($1, $2) returning timetable_id, group_id",
+		tg.timetableId, tg.groupId,
+	)
+	if err != nil {
+		return err
+	}
+	*tg, err = pgx.CollectOneRow(rows, scanTimetableGroup)
+	return err
+}
+
+type TimetableGroupFilter struct {
+	timetableId *int
+	groupId     *int
+}
+
+func ParseTimetableGroupFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing timetable group filter")
+
+	timetableId, err := parseOptionalInt(span, f, "timetable_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid timetable id (not an int)")
+	}
+	groupId, err := parseOptionalInt(span, f, "group_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid group id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "timetable_group filter", TimetableGroupFilter{
+		timetableId: timetableId,
+		groupId:     groupId,
+	})
+
+	return nil
+}
+
+const timetableGroupSelect = "select tg.timetable_id, tg.group_id from timetable_group tg join timetable t on t.id = tg.timetable_id"
+
+func scanTimetableGroup(row pgx.CollectableRow) (tg TimetableGroup, err error) {
+	err = row.Scan(&tg.timetableId, &tg.groupId)
 	return
 }
+
+func ListTimetableGroups(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f TimetableGroupFilter) (Page[TimetableGroup], error) {
+	b := listBuilder{}
+	b.where("t.school_id = ?", schoolId)
+	if f.timetableId != nil {
+		b.where("tg.timetable_id = ?", *f.timetableId)
+	}
+	if f.groupId != nil {
+		b.where("tg.group_id = ?", *f.groupId)
+	}
+
+	query, args, err := b.build(timetableGroupSelect, []string{"tg.timetable_id", "tg.group_id"}, q)
+	if err != nil {
+		return Page[TimetableGroup]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[TimetableGroup]{}, err
+	}
+
+	return collectPage(rows, q, scanTimetableGroup, func(tg TimetableGroup) []string {
+		return []string{fmt.Sprint(tg.timetableId), fmt.Sprint(tg.groupId)}
+	})
+}
+
+func (tg TimetableGroup) TimetableId() int {
+	return tg.timetableId
+}
+
+func (tg TimetableGroup) GroupId() int {
+	return tg.groupId
+}
+
+func (tg TimetableGroup) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TimetableId int `json:"timetableId"`
+		GroupId     int `json:"groupId"`
+	}{
+		TimetableId: tg.timetableId,
+		GroupId:     tg.groupId,
+	})
+}
+
+func DeleteTimetableGroup(schoolId, timetableId, groupId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, `
+			delete from timetable_group tg
+			where tg.timetable_id = $1 and tg.group_id = $2
+				and exists (select 1 from timetable t where t.id = tg.timetable_id and t.school_id = $3)`,
+			timetableId, groupId, schoolId,
+		)
+	}
+}
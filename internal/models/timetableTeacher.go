@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -36,9 +39,118 @@ func ParseTimetableTeacher(f url.Values, parserCtx context.Context, handlerCtx *
 	return nil
 }
 
-func (tt TimetableTeacher) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(context.TODO(),
-		"insert into timetable_teacher (timetable_id, teacher_id) values ($1, $2)",
-		tt.timetableId, tt.teacherId)
+func (tt *TimetableTeacher) SaveToDB(tx pgx.Tx) error {
+	if err := tt.insert(tx); err != nil {
+		return err
+	}
+
+	return checkTimetableConflicts(tx, tt.timetableId)
+}
+
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the lesson or the teacher is in another school
+func (tt *TimetableTeacher) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, timetableRef(tt.timetableId), userRef(tt.teacherId)); err != nil {
+			return err
+		}
+		return tt.SaveToDB(tx)
+	}
+}
+
+func (tt *TimetableTeacher) insert(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		"insert into timetable_teacher (timetable_id, teacher_id) values ($1, $2) returning timetable_id, teacher_id",
+		tt.timetableId, tt.teacherId,
+	)
+	if err != nil {
+		return err
+	}
+	*tt, err = pgx.CollectOneRow(rows, scanTimetableTeacher)
+	return err
+}
+
+type TimetableTeacherFilter struct {
+	timetableId *int
+	teacherId   *uuid.UUID
+}
+
+func ParseTimetableTeacherFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing timetable teacher filter")
+
+	timetableId, err := parseOptionalInt(span, f, "timetable_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid timetable id (not an int)")
+	}
+	teacherId, err := parseOptionalUuid(span, f, "teacher_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid teacherId")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "timetable_teacher filter", TimetableTeacherFilter{
+		timetableId: timetableId,
+		teacherId:   teacherId,
+	})
+
+	return nil
+}
+
+const timetableTeacherSelect = "select tt.timetable_id, tt.teacher_id from timetable_teacher tt join timetable t on t.id = tt.timetable_id"
+
+func scanTimetableTeacher(row pgx.CollectableRow) (tt TimetableTeacher, err error) {
+	err = row.Scan(&tt.timetableId, &tt.teacherId)
 	return
 }
+
+func ListTimetableTeachers(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f TimetableTeacherFilter) (Page[TimetableTeacher], error) {
+	b := listBuilder{}
+	b.where("t.school_id = ?", schoolId)
+	if f.timetableId != nil {
+		b.where("tt.timetable_id = ?", *f.timetableId)
+	}
+	if f.teacherId != nil {
+		b.where("tt.teacher_id = ?", *f.teacherId)
+	}
+
+	query, args, err := b.build(timetableTeacherSelect, []string{"tt.timetable_id", "tt.teacher_id"}, q)
+	if err != nil {
+		return Page[TimetableTeacher]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[TimetableTeacher]{}, err
+	}
+
+	return collectPage(rows, q, scanTimetableTeacher, func(tt TimetableTeacher) []string {
+		return []string{fmt.Sprint(tt.timetableId), tt.teacherId.String()}
+	})
+}
+
+func (tt TimetableTeacher) TimetableId() int {
+	return tt.timetableId
+}
+
+func (tt TimetableTeacher) TeacherId() uuid.UUID {
+	return tt.teacherId
+}
+
+func (tt TimetableTeacher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TimetableId int       `json:"timetableId"`
+		TeacherId   uuid.UUID `json:"teacherId"`
+	}{
+		TimetableId: tt.timetableId,
+		TeacherId:   tt.teacherId,
+	})
+}
+
+func DeleteTimetableTeacher(schoolId, timetableId int, teacherId uuid.UUID) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, `
+			delete from timetable_teacher tt
+			where tt.timetable_id = $1 and tt.teacher_id = $2
+				and exists (select 1 from timetable t where t.id = tt.timetable_id and t.school_id = $3)`,
+			timetableId, teacherId, schoolId,
+		)
+	}
+}
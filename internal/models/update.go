@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// updateBuilder collects columns to be set by partial update, "?" in an
+// expression is replaced by the next positional argument
+type updateBuilder struct {
+	sets []string
+	args []any
+}
+
+func (b *updateBuilder) placeholders(expr string, args ...any) string {
+	for _, arg := range args {
+		b.args = append(b.args, arg)
+		expr = strings.Replace(expr, "?", fmt.Sprintf("$%d", len(b.args)), 1)
+	}
+	return expr
+}
+
+func (b *updateBuilder) set(column string, value any) {
+	b.sets = append(b.sets, column+" = "+b.placeholders("?", value))
+}
+
+func (b *updateBuilder) setExpr(column, expr string, args ...any) {
+	b.sets = append(b.sets, column+" = "+b.placeholders(expr, args...))
+}
+
+func (b *updateBuilder) empty() bool {
+	return len(b.sets) == 0
+}
+
+// exec runs the update, pgx.ErrNoRows is returned when where doesn't match any row
+func (b *updateBuilder) exec(tx pgx.Tx, table, where string, args ...any) error {
+	if b.empty() {
+		return nil
+	}
+
+	query := fmt.Sprintf("update %s set %s where %s", table, strings.Join(b.sets, ", "), b.placeholders(where, args...))
+	tag, err := tx.Exec(context.TODO(), query, b.args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// execAffectingRow is used for deletes and ownership checks, pgx.ErrNoRows
+// is returned when nothing was affected
+func execAffectingRow(tx pgx.Tx, query string, args ...any) error {
+	tag, err := tx.Exec(context.TODO(), query, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func optionalString(span trace.Span, f url.Values, key string) *string {
+	if !f.Has(key) {
+		return nil
+	}
+	value := f.Get(key)
+	span.SetAttributes(attribute.String(key, value))
+	return &value
+}
+
+func nothingToUpdate() *utils.ParseError {
+	return utils.NewParserError(nil, "Nothing to update")
+}
@@ -2,15 +2,13 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"net/http"
 	"net/mail"
 	"net/url"
-	"time"
 
 	"github.com/alexedwards/argon2id"
 	"github.com/dr0th3r/learnscape/internal/utils"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -24,6 +22,7 @@ type User struct {
 	surname  string
 	email    string
 	schoolId int
+	role     utils.Role
 	password string
 }
 
@@ -61,12 +60,21 @@ func ParseRegister(f url.Values, parserCtx context.Context, handlerCtx *context.
 		}
 	}
 
+	role := utils.RoleStudent
+	if roleUnprocessed := f.Get("role"); roleUnprocessed != "" {
+		role, err = utils.ParseRole(roleUnprocessed)
+		if err != nil {
+			return utils.NewParserError(err, "Invalid role")
+		}
+	}
+
 	user := User{
 		id:       uuid.NewString(),
 		name:     f.Get("user_name"),
 		surname:  f.Get("surname"),
 		email:    email.Address,
 		schoolId: schoolId,
+		role:     role,
 		password: password,
 	}
 
@@ -74,6 +82,7 @@ func ParseRegister(f url.Values, parserCtx context.Context, handlerCtx *context.
 		attribute.String("id", user.id),
 		attribute.String("name", user.name),
 		attribute.String("surname", user.surname),
+		attribute.String("role", string(user.role)),
 	)
 
 	if user.name == "" {
@@ -122,19 +131,21 @@ func (u *User) SaveToDBWithSchoolId(schoolId *int) utils.TxFunc { //used to if w
 	}
 }
 
-func (u User) SaveToDB(tx pgx.Tx) error {
+func (u *User) SaveToDB(tx pgx.Tx) error {
 	password_hash, err := argon2id.CreateHash(u.password, argon2id.DefaultParams)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(context.Background(), "insert into users (id, name, surname, email, password, school_id) values ($1, $2, $3, $4, $5, $6)",
-		u.id, u.name, u.surname, u.email, password_hash, u.schoolId)
+	rows, err := tx.Query(context.Background(),
+		"insert into users (id, name, surname, email, password, school_id, role) values ($1, $2, $3, $4, $5, $6, $7) returning id, name, surname, email, school_id, role",
+		u.id, u.name, u.surname, u.email, password_hash, u.schoolId, u.role)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	*u, err = pgx.CollectOneRow(rows, scanUser)
+	return err
 }
 
 func (u *User) Login(db *pgxpool.Pool) error {
@@ -143,8 +154,8 @@ func (u *User) Login(db *pgxpool.Pool) error {
 	var dbPassword string
 	if err := db.QueryRow(
 		ctx,
-		"select name, surname, password, school_id from users where email=$1", u.email).Scan(
-		&u.name, &u.surname, &dbPassword, &u.schoolId,
+		"select id, name, surname, password, school_id, role from users where email=$1", u.email).Scan(
+		&u.id, &u.name, &u.surname, &dbPassword, &u.schoolId, &u.role,
 	); err != nil {
 		return err
 	}
@@ -162,36 +173,179 @@ func (u *User) Login(db *pgxpool.Pool) error {
 
 //TODO: split user to user with and without school id
 
-func (u User) CreateTokenCookie(secret []byte, exp time.Time) (*http.Cookie, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		utils.UserClaims{
-			Id:       u.id,
-			Name:     u.name,
-			Surname:  u.surname,
-			Email:    u.email,
-			SchoolId: u.schoolId,
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(exp),
-			},
-		},
-	)
+// Claims are the claims of the user's access tokens
+func (u User) Claims() utils.UserClaims {
+	return utils.UserClaims{
+		Id:       u.id,
+		Name:     u.name,
+		Surname:  u.surname,
+		Email:    u.email,
+		SchoolId: u.schoolId,
+		Role:     u.role,
+	}
+}
 
-	tokenStr, err := token.SignedString(secret)
+func (u User) HasSchoolId() bool {
+	return u.schoolId != -1
+}
+
+func (u User) Role() utils.Role {
+	return u.role
+}
+
+func (u *User) SetRole(role utils.Role) {
+	u.role = role
+}
+
+type UserFilter struct {
+	role *utils.Role
+}
+
+func ParseUserFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing user filter")
+
+	filter := UserFilter{}
+	if roleUnprocessed := f.Get("role"); roleUnprocessed != "" {
+		span.SetAttributes(attribute.String("role", roleUnprocessed))
+		role, err := utils.ParseRole(roleUnprocessed)
+		if err != nil {
+			return utils.NewParserError(err, "Invalid role")
+		}
+		filter.role = &role
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "user filter", filter)
+
+	return nil
+}
+
+const userSelect = "select u.id, u.name, u.surname, u.email, u.school_id, u.role from users u"
+
+func scanUser(row pgx.CollectableRow) (u User, err error) {
+	err = row.Scan(&u.id, &u.name, &u.surname, &u.email, &u.schoolId, &u.role)
+	return
+}
+
+func ListUsers(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f UserFilter) (Page[User], error) {
+	b := listBuilder{}
+	b.where("u.school_id = ?", schoolId)
+	if f.role != nil {
+		b.where("u.role = ?", *f.role)
+	}
+
+	query, args, err := b.build(userSelect, []string{"u.id"}, q)
 	if err != nil {
-		return nil, err
+		return Page[User]{}, err
 	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[User]{}, err
+	}
+
+	return collectPage(rows, q, scanUser, func(u User) []string {
+		return []string{u.id}
+	})
+}
 
-	tokenCookie := http.Cookie{
-		Name:     "token",
-		Value:    tokenStr,
-		Expires:  exp,
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode, //TODO: add other config by OWASP later
+func GetUser(ctx context.Context, db *pgxpool.Pool, schoolId int, id uuid.UUID) (User, error) {
+	rows, err := db.Query(ctx, userSelect+" where u.school_id = $1 and u.id = $2", schoolId, id)
+	if err != nil {
+		return User{}, err
 	}
+	return pgx.CollectOneRow(rows, scanUser)
+}
 
-	return &tokenCookie, nil
+func (u User) Id() string {
+	return u.id
 }
 
-func (u User) HasSchoolId() bool {
-	return u.schoolId != -1
+func (u User) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id       string     `json:"id"`
+		Name     string     `json:"name"`
+		Surname  string     `json:"surname"`
+		Email    string     `json:"email"`
+		SchoolId int        `json:"schoolId"`
+		Role     utils.Role `json:"role"`
+	}{
+		Id:       u.id,
+		Name:     u.name,
+		Surname:  u.surname,
+		Email:    u.email,
+		SchoolId: u.schoolId,
+		Role:     u.role,
+	})
+}
+
+type UserUpdate struct {
+	name    *string
+	surname *string
+	email   *string
+	role    *utils.Role
+}
+
+func ParseUserUpdate(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing user update")
+
+	update := UserUpdate{
+		name:    optionalString(span, f, "user_name"),
+		surname: optionalString(span, f, "surname"),
+	}
+	if update.name != nil && *update.name == "" {
+		return utils.NewParserError(nil, "User name can't be empty")
+	} else if update.surname != nil && *update.surname == "" {
+		return utils.NewParserError(nil, "Surname can't be empty")
+	}
+
+	if f.Has("email") {
+		email, err := mail.ParseAddress(f.Get("email"))
+		if err != nil {
+			return utils.NewParserError(err, "Invalid email provided")
+		}
+		span.SetAttributes(attribute.String("email", email.Address))
+		update.email = &email.Address
+	}
+	if f.Has("role") {
+		role, err := utils.ParseRole(f.Get("role"))
+		if err != nil {
+			return utils.NewParserError(err, "Invalid role")
+		}
+		span.SetAttributes(attribute.String("role", string(role)))
+		update.role = &role
+	}
+
+	if update == (UserUpdate{}) {
+		return nothingToUpdate()
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "user update", update)
+
+	return nil
+}
+
+func (u UserUpdate) UpdateInDB(schoolId int, id uuid.UUID) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		b := updateBuilder{}
+		if u.name != nil {
+			b.set("name", *u.name)
+		}
+		if u.surname != nil {
+			b.set("surname", *u.surname)
+		}
+		if u.email != nil {
+			b.set("email", *u.email)
+		}
+		if u.role != nil {
+			b.set("role", *u.role)
+		}
+		return b.exec(tx, "users", "id = ? and school_id = ?", id, schoolId)
+	}
+}
+
+func DeleteUser(schoolId int, id uuid.UUID) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, "delete from users where id = $1 and school_id = $2", id, schoolId)
+	}
 }
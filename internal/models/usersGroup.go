@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -36,9 +39,110 @@ func ParseUsersGroup(f url.Values, parserCtx context.Context, handlerCtx *contex
 	return nil
 }
 
-func (ug UsersGroup) SaveToDB(tx pgx.Tx) (err error) {
-	_, err = tx.Exec(context.TODO(),
-		"insert into users_group (user_id, group_id) values ($1, $2)",
-		ug.userId, ug.groupId)
+func (ug *UsersGroup) SaveToDB(tx pgx.Tx) error {
+	rows, err := tx.Query(context.TODO(),
+		"insert into users_group (user_id, group_id) values ($1, $2) returning user_id, group_id",
+		ug.userId, ug.groupId,
+	)
+	if err != nil {
+		return err
+	}
+	*ug, err = pgx.CollectOneRow(rows, scanUsersGroup)
+	return err
+}
+
+// SaveToDBWithSchoolId is SaveToDB for a user of the school, ErrForeignReference means the user or the group is in another school
+func (ug *UsersGroup) SaveToDBWithSchoolId(schoolId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		if err := checkInSchool(tx, schoolId, userRef(ug.userId), groupRef(ug.groupId)); err != nil {
+			return err
+		}
+		return ug.SaveToDB(tx)
+	}
+}
+
+type UsersGroupFilter struct {
+	userId  *uuid.UUID
+	groupId *int
+}
+
+func ParseUsersGroupFilter(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("parsing users group filter")
+
+	userId, err := parseOptionalUuid(span, f, "user_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid user id")
+	}
+	groupId, err := parseOptionalInt(span, f, "group_id")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid group id (not an int)")
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "users_group filter", UsersGroupFilter{
+		userId:  userId,
+		groupId: groupId,
+	})
+
+	return nil
+}
+
+const usersGroupSelect = "select ug.user_id, ug.group_id from users_group ug join users u on u.id = ug.user_id"
+
+func scanUsersGroup(row pgx.CollectableRow) (ug UsersGroup, err error) {
+	err = row.Scan(&ug.userId, &ug.groupId)
 	return
 }
+
+func ListUsersGroups(ctx context.Context, db *pgxpool.Pool, schoolId int, q ListQuery, f UsersGroupFilter) (Page[UsersGroup], error) {
+	b := listBuilder{}
+	b.where("u.school_id = ?", schoolId)
+	if f.userId != nil {
+		b.where("ug.user_id = ?", *f.userId)
+	}
+	if f.groupId != nil {
+		b.where("ug.group_id = ?", *f.groupId)
+	}
+
+	query, args, err := b.build(usersGroupSelect, []string{"ug.user_id", "ug.group_id"}, q)
+	if err != nil {
+		return Page[UsersGroup]{}, err
+	}
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return Page[UsersGroup]{}, err
+	}
+
+	return collectPage(rows, q, scanUsersGroup, func(ug UsersGroup) []string {
+		return []string{ug.userId.String(), fmt.Sprint(ug.groupId)}
+	})
+}
+
+func (ug UsersGroup) UserId() uuid.UUID {
+	return ug.userId
+}
+
+func (ug UsersGroup) GroupId() int {
+	return ug.groupId
+}
+
+func (ug UsersGroup) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		UserId  uuid.UUID `json:"userId"`
+		GroupId int       `json:"groupId"`
+	}{
+		UserId:  ug.userId,
+		GroupId: ug.groupId,
+	})
+}
+
+func DeleteUsersGroup(schoolId int, userId uuid.UUID, groupId int) utils.TxFunc {
+	return func(tx pgx.Tx) error {
+		return execAffectingRow(tx, `
+			delete from users_group ug
+			where ug.user_id = $1 and ug.group_id = $2
+				and exists (select 1 from users u where u.id = ug.user_id and u.school_id = $3)`,
+			userId, groupId, schoolId,
+		)
+	}
+}
@@ -0,0 +1,436 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WeekTimetable is the timetable somebody actually has in one school week,
+// it is built from the regular timetable with substitutions and events laid over it.
+// Unlike other models its fields are exported so templates can render it directly
+type WeekTimetable struct {
+	Monday  string       `json:"monday"`
+	Periods []WeekPeriod `json:"periods"`
+	Days    []WeekDay    `json:"days"`
+}
+
+type WeekPeriod struct {
+	Id    int    `json:"id"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type WeekDay struct {
+	Date    string `json:"date"`
+	Weekday int    `json:"weekday"`
+	// Slots has one entry for every period (in the same order as WeekTimetable.Periods),
+	// a slot can hold more lessons, e.g. when a teacher's view is asked for a group split in halves
+	Slots  [][]WeekLesson `json:"slots"`
+	Events []WeekEvent    `json:"events"`
+}
+
+type WeekLesson struct {
+	TimetableId int           `json:"timetableId"`
+	SubjectId   int           `json:"subjectId"`
+	Subject     string        `json:"subject"`
+	RoomId      int           `json:"roomId"`
+	Room        string        `json:"room"`
+	GroupIds    []int         `json:"groupIds"`
+	Teachers    []WeekTeacher `json:"teachers"`
+	Substitute  bool          `json:"substitute"`
+	// Cancelled is set when an event takes place during the lesson
+	Cancelled bool `json:"cancelled"`
+}
+
+type WeekTeacher struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type WeekEvent struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+}
+
+// WeekTimetableFilter selects lessons of any of the groups (when groupIds isn't nil),
+// of the teacher and in the room, all of the set conditions have to match
+type WeekTimetableFilter struct {
+	groupIds  []int
+	teacherId *uuid.UUID
+	roomId    *int
+}
+
+// PersonalWeekTimetableFilter selects the lessons the user attends, teachers (and admins,
+// who usually teach as well) get lessons they teach, students lessons of their groups
+// and parents lessons of their children's groups
+func PersonalWeekTimetableFilter(ctx context.Context, db *pgxpool.Pool, userId string, role utils.Role) (WeekTimetableFilter, error) {
+	switch role {
+	case utils.RoleTeacher, utils.RoleAdmin:
+		teacherId, err := uuid.Parse(userId)
+		if err != nil {
+			return WeekTimetableFilter{}, err
+		}
+		return WeekTimetableFilter{teacherId: &teacherId}, nil
+	case utils.RoleParent:
+		return groupsFilter(ctx, db, `
+			select distinct ug.group_id from users_group ug
+			join parent_child pc on pc.child_id = ug.user_id
+			where pc.parent_id = $1`,
+			userId,
+		)
+	default:
+		return groupsFilter(ctx, db, "select group_id from users_group where user_id = $1", userId)
+	}
+}
+
+func groupsFilter(ctx context.Context, db *pgxpool.Pool, query string, args ...any) (WeekTimetableFilter, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return WeekTimetableFilter{}, err
+	}
+	groupIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return WeekTimetableFilter{}, err
+	}
+	//user without groups shouldn't see lessons of everybody
+	if groupIds == nil {
+		groupIds = []int{}
+	}
+	return WeekTimetableFilter{groupIds: groupIds}, nil
+}
+
+type WeekTimetableQuery struct {
+	filter WeekTimetableFilter
+	week   time.Time
+}
+
+func ParseWeekTimetableQuery(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+	span := trace.SpanFromContext(parserCtx)
+	span.AddEvent("Parsing week timetable query")
+
+	groupId, err := parseOptionalInt(span, f, "group")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid group id (not convertable to int)")
+	}
+	teacherId, err := parseOptionalUuid(span, f, "teacher")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid teacher id")
+	}
+	roomId, err := parseOptionalInt(span, f, "room")
+	if err != nil {
+		return utils.NewParserError(err, "Invalid room id (not convertable to int)")
+	}
+	if groupId == nil && teacherId == nil && roomId == nil {
+		return utils.NewParserError(nil, "Group, teacher or room has to be provided")
+	}
+
+	week := time.Now()
+	if f.Get("week") != "" {
+		week, err = utils.ParseTime(span, "week", f.Get("week"), time.DateOnly)
+		if err != nil {
+			return utils.NewParserError(err, "Invalid week (expected date in format YYYY-MM-DD)")
+		}
+	}
+
+	filter := WeekTimetableFilter{teacherId: teacherId, roomId: roomId}
+	if groupId != nil {
+		filter.groupIds = []int{*groupId}
+	}
+
+	*handlerCtx = context.WithValue(*handlerCtx, "week timetable query", WeekTimetableQuery{
+		filter: filter,
+		week:   week,
+	})
+
+	return nil
+}
+
+func (q WeekTimetableQuery) Resolve(ctx context.Context, db *pgxpool.Pool, schoolId int) (WeekTimetable, error) {
+	return ResolveWeekTimetable(ctx, db, schoolId, q.filter, q.week)
+}
+
+// weekLesson is a lesson from regular or substitute timetable before it's placed into the grid
+type weekLesson struct {
+	WeekLesson
+	periodId int
+	weekday  string // set for regular lessons
+	date     string // set for substitute lessons
+	// first and last day of the academic year of regular lessons, empty when they are valid forever
+	validFrom string
+	validTo   string
+}
+
+func (l weekLesson) validOn(date string) bool {
+	return (l.validFrom == "" || l.validFrom <= date) && (l.validTo == "" || date <= l.validTo)
+}
+
+type weekEvent struct {
+	WeekEvent
+	start    time.Time
+	end      time.Time
+	groupIds []int
+	teachers []string
+}
+
+// weekStart returns monday of the week the day belongs to, weekend belongs to the week before it
+func weekStart(day time.Time) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// ResolveWeekTimetable builds the timetable of the week the day belongs to.
+// Regular lessons are replaced by substitutions taking place in the same period
+// for the same group (or for the same teacher when the lesson has no groups),
+// lessons overlapping with an event of the same group or teacher (or a school wide
+// event without any groups and teachers) are marked as cancelled
+func ResolveWeekTimetable(ctx context.Context, db *pgxpool.Pool, schoolId int, f WeekTimetableFilter, day time.Time) (WeekTimetable, error) {
+	span := trace.SpanFromContext(ctx)
+	monday := weekStart(day)
+	friday := monday.AddDate(0, 0, len(Weekdays)-1)
+	span.SetAttributes(attribute.String("week_monday", monday.Format(time.DateOnly)))
+
+	periods, err := loadWeekPeriods(ctx, db, schoolId)
+	if err != nil {
+		return WeekTimetable{}, err
+	}
+	lessons, err := loadWeekLessons(ctx, db, schoolId, monday, friday)
+	if err != nil {
+		return WeekTimetable{}, err
+	}
+	events, err := loadWeekEvents(ctx, db, schoolId, monday, friday.AddDate(0, 0, 1))
+	if err != nil {
+		return WeekTimetable{}, err
+	}
+
+	return resolveWeek(monday, periods, lessons, events, f), nil
+}
+
+func loadWeekPeriods(ctx context.Context, db *pgxpool.Pool, schoolId int) ([]WeekPeriod, error) {
+	rows, err := db.Query(ctx, `
+		select id, to_char(lower(span), 'HH24:MI'), to_char(upper(span), 'HH24:MI')
+		from period
+		where school_id = $1
+		order by lower(span)`,
+		schoolId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (p WeekPeriod, err error) {
+		err = row.Scan(&p.Id, &p.Start, &p.End)
+		return
+	})
+}
+
+func loadWeekLessons(ctx context.Context, db *pgxpool.Pool, schoolId int, monday, friday time.Time) ([]weekLesson, error) {
+	rows, err := db.Query(ctx, `
+		select
+			at.id, at.period_id, at.subject_id, s.name, at.room_id, r.name,
+			coalesce(rt.weekday::text, ''), coalesce(to_char(st.date, 'YYYY-MM-DD'), ''),
+			coalesce(to_char(lower(y.span), 'YYYY-MM-DD'), ''), coalesce(to_char(upper(y.span) - 1, 'YYYY-MM-DD'), ''),
+			array(select tg.group_id from timetable_group tg where tg.timetable_id = at.id order by tg.group_id),
+			array(
+				select u.id::text from timetable_teacher tt join users u on u.id = tt.teacher_id
+				where tt.timetable_id = at.id order by u.id
+			),
+			array(
+				select u.name || ' ' || u.surname from timetable_teacher tt join users u on u.id = tt.teacher_id
+				where tt.timetable_id = at.id order by u.id
+			)
+		from academic_timetable at
+		join timetable t on t.id = at.id
+		join subject s on s.id = at.subject_id
+		join room r on r.id = at.room_id
+		left join regular_timetable rt on rt.id = at.id
+		left join substitute_timetable st on st.id = at.id
+		left join academic_year y on y.id = rt.academic_year_id
+		where t.school_id = $1 and (
+			(rt.id is not null and (y.id is null or y.span && daterange($2, $3, '[]')))
+			or st.date between $2 and $3
+		)`,
+		schoolId, monday.Format(time.DateOnly), friday.Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (l weekLesson, err error) {
+		var teacherIds, teacherNames []string
+		err = row.Scan(
+			&l.TimetableId, &l.periodId, &l.SubjectId, &l.Subject, &l.RoomId, &l.Room,
+			&l.weekday, &l.date, &l.validFrom, &l.validTo, &l.GroupIds, &teacherIds, &teacherNames,
+		)
+		l.Teachers = make([]WeekTeacher, 0, len(teacherIds))
+		for i := range teacherIds {
+			l.Teachers = append(l.Teachers, WeekTeacher{Id: teacherIds[i], Name: teacherNames[i]})
+		}
+		l.Substitute = l.date != ""
+		return
+	})
+}
+
+func loadWeekEvents(ctx context.Context, db *pgxpool.Pool, schoolId int, from, to time.Time) ([]weekEvent, error) {
+	rows, err := db.Query(ctx, `
+		select
+			et.id, et.name, coalesce(et.description, ''), lower(et.span), upper(et.span),
+			array(select tg.group_id from timetable_group tg where tg.timetable_id = et.id),
+			array(select tt.teacher_id::text from timetable_teacher tt where tt.timetable_id = et.id)
+		from event_timetable et
+		join timetable t on t.id = et.id
+		where t.school_id = $1 and et.span && tsrange($2, $3)
+		order by lower(et.span)`,
+		schoolId, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (e weekEvent, err error) {
+		if err = row.Scan(&e.Id, &e.Name, &e.Description, &e.start, &e.end, &e.groupIds, &e.teachers); err != nil {
+			return
+		}
+		e.Start = e.start.Format(time.RFC3339)
+		e.End = e.end.Format(time.RFC3339)
+		return
+	})
+}
+
+func resolveWeek(monday time.Time, periods []WeekPeriod, lessons []weekLesson, events []weekEvent, f WeekTimetableFilter) WeekTimetable {
+	week := WeekTimetable{
+		Monday:  monday.Format(time.DateOnly),
+		Periods: periods,
+	}
+
+	for i := range Weekdays {
+		date := monday.AddDate(0, 0, i)
+		day := WeekDay{
+			Date:    date.Format(time.DateOnly),
+			Weekday: i + 1,
+			Slots:   make([][]WeekLesson, len(periods)),
+			Events:  []WeekEvent{},
+		}
+
+		dayEvents := []weekEvent{}
+		for _, e := range events {
+			if e.start.Before(date.AddDate(0, 0, 1)) && e.end.After(date) && f.matchesEvent(e) {
+				dayEvents = append(dayEvents, e)
+				day.Events = append(day.Events, e.WeekEvent)
+			}
+		}
+
+		for slot, p := range periods {
+			start, end := periodOnDate(p, date)
+			day.Slots[slot] = []WeekLesson{}
+
+			for _, l := range lessons {
+				if l.periodId != p.Id || !f.matchesLesson(l) {
+					continue
+				}
+				if l.Substitute && l.date != day.Date {
+					continue
+				}
+				if !l.Substitute && (l.weekday != Weekdays[i] || !l.validOn(day.Date) || isSubstituted(l, lessons, day.Date)) {
+					continue
+				}
+
+				lesson := l.WeekLesson
+				for _, e := range dayEvents {
+					if e.start.Before(end) && e.end.After(start) && eventConcernsLesson(e, l) {
+						lesson.Cancelled = true
+					}
+				}
+				day.Slots[slot] = append(day.Slots[slot], lesson)
+			}
+		}
+
+		week.Days = append(week.Days, day)
+	}
+
+	return week
+}
+
+func periodOnDate(p WeekPeriod, date time.Time) (time.Time, time.Time) {
+	onDate := func(clock string) time.Time {
+		t, err := time.Parse("15:04", clock)
+		if err != nil {
+			return date
+		}
+		return date.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	}
+	return onDate(p.Start), onDate(p.End)
+}
+
+func isSubstituted(regular weekLesson, lessons []weekLesson, date string) bool {
+	for _, s := range lessons {
+		if !s.Substitute || s.date != date || s.periodId != regular.periodId {
+			continue
+		}
+		if len(regular.GroupIds) > 0 && sharesAny(s.GroupIds, regular.GroupIds) {
+			return true
+		}
+		if len(regular.GroupIds) == 0 && sharesAny(teacherIds(s.Teachers), teacherIds(regular.Teachers)) {
+			return true
+		}
+	}
+	return false
+}
+
+func eventConcernsLesson(e weekEvent, l weekLesson) bool {
+	if len(e.groupIds) == 0 && len(e.teachers) == 0 {
+		return true
+	}
+	return sharesAny(e.groupIds, l.GroupIds) || sharesAny(e.teachers, teacherIds(l.Teachers))
+}
+
+func (f WeekTimetableFilter) matchesLesson(l weekLesson) bool {
+	if f.groupIds != nil && !sharesAny(l.GroupIds, f.groupIds) {
+		return false
+	}
+	if f.teacherId != nil && !slices.Contains(teacherIds(l.Teachers), f.teacherId.String()) {
+		return false
+	}
+	if f.roomId != nil && l.RoomId != *f.roomId {
+		return false
+	}
+	return true
+}
+
+func (f WeekTimetableFilter) matchesEvent(e weekEvent) bool {
+	if len(e.groupIds) == 0 && len(e.teachers) == 0 {
+		return true
+	}
+	if sharesAny(e.groupIds, f.groupIds) {
+		return true
+	}
+	if f.teacherId != nil && slices.Contains(e.teachers, f.teacherId.String()) {
+		return true
+	}
+	return false
+}
+
+func teacherIds(teachers []WeekTeacher) []string {
+	ids := make([]string, len(teachers))
+	for i, t := range teachers {
+		ids[i] = t.Id
+	}
+	return ids
+}
+
+func sharesAny[T comparable](a, b []T) bool {
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -17,8 +17,10 @@ func NewServer(db *pgxpool.Pool, config utils.AppConfig) http.Handler {
 	mux.Handle("GET /css/", http.StripPrefix("/css/", css))
 	mux.Handle("GET /js/", http.StripPrefix("/js/", js))
 
-	addRoutes(mux, db, config.JwtSecret)
+	utils.SetJwtSecret(config.JwtSecret)
+	addRoutes(mux, db)
 	var handler http.Handler = mux
+	handler = utils.WithNegotiation(handler)
 	handler = otelhttp.NewHandler(handler, "server")
 	return handler
 }
@@ -26,73 +28,655 @@ func NewServer(db *pgxpool.Pool, config utils.AppConfig) http.Handler {
 func addRoutes(
 	mux *http.ServeMux,
 	db *pgxpool.Pool,
-	jwtSecret string,
 ) {
+	admin := []utils.Role{utils.RoleAdmin}
+	staff := []utils.Role{utils.RoleAdmin, utils.RoleTeacher}
 
 	mux.Handle("GET /health_check", c.HealthCheck())
 	mux.Handle("POST /register_user", utils.ParseForm(
-		c.RegisterUser(db, jwtSecret), m.ParseRegister,
+		c.RegisterUser(db), m.ParseRegister,
 	))
 	mux.Handle("POST /login", utils.ParseForm(
-		c.Login(db, jwtSecret), m.ParseLogin,
+		c.Login(db), m.ParseLogin,
 	))
 	mux.Handle("POST /register_school", utils.ParseForm(
-		c.RegisterSchool(db, jwtSecret), m.ParseRegister, m.ParseSchool,
+		c.RegisterSchool(db), m.ParseRegister, m.ParseSchool,
 	))
+	mux.Handle("POST /logout", utils.WithAuth(c.Logout()))
+	mux.Handle("POST /password/forgot", utils.ParseForm(
+		c.ForgotPassword(db), m.ParsePasswordResetRequest,
+	))
+	mux.Handle("POST /password/reset", utils.ParseForm(
+		c.ResetPassword(db), m.ParsePasswordReset,
+	))
+	mux.Handle("POST /password/change", utils.WithAuth(utils.ParseForm(
+		c.ChangePassword(db), m.ParsePasswordChange,
+	)))
+	mux.Handle("GET /session", utils.WithAuth(c.ListSessions()))
+	mux.Handle("DELETE /session", utils.WithAuth(c.RevokeOtherSessions()))
+	mux.Handle("DELETE /session/{id}", utils.WithAuth(c.RevokeSession()))
+	mux.Handle("DELETE /user/{id}/session",
+		utils.WithAuth(utils.WithRoles(c.RevokeUserSessions(db), admin...)),
+	)
+	mux.Handle("POST /user",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateUser(db), m.ParseRegister,
+		), admin...)),
+	)
 	mux.Handle("POST /period",
-		utils.WithAuth(utils.ParseForm(
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
 			c.CreatePeriod(db), m.ParsePeriod,
-		)),
+		), admin...)),
+	)
+	mux.Handle("POST /room",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateRoom(db), m.ParseRoom,
+		), admin...)),
+	)
+	mux.Handle("POST /academic_year",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateAcademicYear(db), m.ParseAcademicYear,
+		), admin...)),
+	)
+	mux.Handle("POST /term",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateTerm(db), m.ParseTerm,
+		), admin...)),
+	)
+	mux.Handle("POST /subject",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateSubject(db), m.ParseSubject,
+		), admin...)),
+	)
+	mux.Handle("POST /regular_timetable",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateRegularTimetable(db), m.ParseRegularTimetable,
+		), admin...)),
+	)
+	mux.Handle("POST /substitute_timetable",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateSubstituteTimetable(db), m.ParseSubstituteTimetable,
+		), admin...)),
+	)
+	mux.Handle("POST /event_timetable",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateEventTimetable(db), m.ParseEventTimetable,
+		), admin...)),
+	)
+	mux.Handle("POST /event_timetable/import",
+		utils.WithAuth(utils.WithRoles(c.ImportEventTimetables(db), admin...)),
+	)
+	mux.Handle("POST /report",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateReport(db), m.ParseReport, m.ParseAttendance,
+		), staff...)),
+	)
+	mux.Handle("POST /class",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateClass(db), m.ParseClass,
+		), admin...)),
+	)
+	mux.Handle("POST /group",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateGroup(db), m.ParseGroup,
+		), admin...)),
+	)
+	mux.Handle("POST /users_group",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateUsersGroup(db), m.ParseUsersGroup,
+		), admin...)),
+	)
+	mux.Handle("POST /timetable_group",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateTimetableGroup(db),
+			m.ParseTimetableGroup,
+		), admin...)),
+	)
+	mux.Handle("POST /timetable_teacher",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateTimetableTeacher(db),
+			m.ParseTimetableTeacher,
+		), admin...)),
+	)
+	mux.Handle("POST /final_grade",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateFinalGrade(db), m.ParseFinalGrade,
+		), staff...)),
+	)
+	mux.Handle("POST /grade",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateGrade(db),
+			m.ParseGrade,
+		), staff...)),
+	)
+	mux.Handle("POST /note",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateNote(db), m.ParseNote,
+		), staff...)),
+	)
+	mux.Handle("POST /parent_child",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateParentChild(db), m.ParseParentChild,
+		), admin...)),
+	)
+	mux.Handle("POST /absence",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateAbsence(db), m.ParseAbsence,
+		), staff...)),
+	)
+	mux.Handle("POST /absence/{id}/excuse",
+		utils.WithAuth(utils.WithRoles(utils.WithMultipartForm(utils.ParseForm(
+			c.SubmitAbsenceExcuse(db), m.ParseAbsenceExcuse,
+		), c.MaxExcuseSize), utils.RoleParent)),
+	)
+	mux.Handle("POST /note/{id}/submission",
+		utils.WithAuth(utils.WithRoles(utils.WithMultipartForm(utils.ParseForm(
+			c.SubmitHomework(db), m.ParseHomeworkSubmission,
+		), c.MaxSubmissionSize), utils.RoleStudent)),
+	)
+	mux.Handle("POST /homework_submission/{id}/review",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ReviewHomeworkSubmission(db), m.ParseSubmissionReview,
+		), staff...)),
+	)
+	mux.Handle("POST /absence_excuse/{id}/review",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ReviewAbsenceExcuse(db), m.ParseExcuseReview,
+		), staff...)),
+	)
+	mux.Handle("GET /school", utils.WithAuth(utils.ParseForm(
+		c.ListSchools(db), m.ParseListQuery,
+	)))
+	mux.Handle("GET /school/{id}", utils.WithAuth(c.GetSchool(db)))
+	mux.Handle("GET /user",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListUsers(db), m.ParseListQuery, m.ParseUserFilter,
+		), staff...)),
+	)
+	mux.Handle("GET /user/{id}",
+		utils.WithAuth(utils.WithRoles(c.GetUser(db), staff...)),
+	)
+	mux.Handle("GET /period", utils.WithAuth(utils.ParseForm(
+		c.ListPeriods(db), m.ParseListQuery,
+	)))
+	mux.Handle("GET /period/{id}", utils.WithAuth(c.GetPeriod(db)))
+	mux.Handle("GET /room", utils.WithAuth(utils.ParseForm(
+		c.ListRooms(db), m.ParseListQuery, m.ParseRoomFilter,
+	)))
+	mux.Handle("GET /room/{id}", utils.WithAuth(c.GetRoom(db)))
+	mux.Handle("GET /academic_year", utils.WithAuth(utils.ParseForm(
+		c.ListAcademicYears(db), m.ParseListQuery,
+	)))
+	mux.Handle("GET /academic_year/{id}", utils.WithAuth(c.GetAcademicYear(db)))
+	mux.Handle("GET /term", utils.WithAuth(utils.ParseForm(
+		c.ListTerms(db), m.ParseListQuery, m.ParseTermFilter,
+	)))
+	mux.Handle("GET /term/{id}", utils.WithAuth(c.GetTerm(db)))
+	mux.Handle("GET /subject", utils.WithAuth(utils.ParseForm(
+		c.ListSubjects(db), m.ParseListQuery, m.ParseSubjectFilter,
+	)))
+	mux.Handle("GET /subject/{id}", utils.WithAuth(c.GetSubject(db)))
+	mux.Handle("GET /timetable", utils.WithAuth(utils.ParseForm(
+		c.GetWeekTimetable(db), m.ParseWeekTimetableQuery,
+	)))
+	mux.Handle("POST /calendar/token", utils.WithAuth(c.CreateCalendarToken(db)))
+	mux.Handle("GET /calendar/{file}", c.GetCalendar(db))
+	mux.Handle("GET /timetable/conflicts",
+		utils.WithAuth(utils.WithRoles(c.GetTimetableConflicts(db), staff...)),
+	)
+	mux.Handle("POST /timetable/draft",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.CreateTimetableDraft(db), m.ParseTimetableRequirements,
+		), admin...)),
+	)
+	mux.Handle("GET /timetable/draft/{id}",
+		utils.WithAuth(utils.WithRoles(c.GetTimetableDraft(db), admin...)),
+	)
+	mux.Handle("POST /timetable/draft/{id}/commit",
+		utils.WithAuth(utils.WithRoles(c.CommitTimetableDraft(db), admin...)),
+	)
+	mux.Handle("DELETE /timetable/draft/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteTimetableDraft(db), admin...)),
+	)
+	mux.Handle("GET /regular_timetable", utils.WithAuth(utils.ParseForm(
+		c.ListRegularTimetables(db), m.ParseListQuery, m.ParseRegularTimetableFilter,
+	)))
+	mux.Handle("GET /regular_timetable/{id}", utils.WithAuth(c.GetRegularTimetable(db)))
+	mux.Handle("GET /substitute_timetable", utils.WithAuth(utils.ParseForm(
+		c.ListSubstituteTimetables(db), m.ParseListQuery, m.ParseSubstituteTimetableFilter,
+	)))
+	mux.Handle("GET /substitute_timetable/{id}", utils.WithAuth(c.GetSubstituteTimetable(db)))
+	mux.Handle("GET /event_timetable", utils.WithAuth(utils.ParseForm(
+		c.ListEventTimetables(db), m.ParseListQuery, m.ParseEventTimetableFilter,
+	)))
+	mux.Handle("GET /event_timetable/{id}", utils.WithAuth(c.GetEventTimetable(db)))
+	mux.Handle("GET /report",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListReports(db), m.ParseListQuery, m.ParseReportFilter,
+		), staff...)),
+	)
+	mux.Handle("GET /report/{id}",
+		utils.WithAuth(utils.WithRoles(c.GetReport(db), staff...)),
+	)
+	mux.Handle("GET /report/{id}/attendance",
+		utils.WithAuth(utils.WithRoles(c.GetReportAttendance(db), staff...)),
+	)
+	mux.Handle("GET /class", utils.WithAuth(utils.ParseForm(
+		c.ListClasses(db), m.ParseListQuery, m.ParseClassFilter,
+	)))
+	mux.Handle("GET /class/{id}", utils.WithAuth(c.GetClass(db)))
+	mux.Handle("GET /group", utils.WithAuth(utils.ParseForm(
+		c.ListGroups(db), m.ParseListQuery, m.ParseGroupFilter,
+	)))
+	mux.Handle("GET /group/{id}", utils.WithAuth(c.GetGroup(db)))
+	mux.Handle("GET /users_group",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListUsersGroups(db), m.ParseListQuery, m.ParseUsersGroupFilter,
+		), staff...)),
+	)
+	mux.Handle("GET /timetable_group", utils.WithAuth(utils.ParseForm(
+		c.ListTimetableGroups(db), m.ParseListQuery, m.ParseTimetableGroupFilter,
+	)))
+	mux.Handle("GET /timetable_teacher", utils.WithAuth(utils.ParseForm(
+		c.ListTimetableTeachers(db), m.ParseListQuery, m.ParseTimetableTeacherFilter,
+	)))
+	mux.Handle("GET /grade",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListGrades(db), m.ParseListQuery, m.ParseGradeFilter,
+		), staff...)),
+	)
+	mux.Handle("GET /grade/{id}",
+		utils.WithAuth(utils.WithRoles(c.GetGrade(db), staff...)),
+	)
+	mux.Handle("GET /final_grade",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListFinalGrades(db), m.ParseListQuery, m.ParseFinalGradeFilter,
+		), staff...)),
+	)
+	mux.Handle("GET /final_grade/{id}",
+		utils.WithAuth(utils.WithRoles(c.GetFinalGrade(db), staff...)),
+	)
+	mux.Handle("GET /report_card/class/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.GetClassReportCards(db), m.ParseReportCardQuery,
+		), staff...)),
+	)
+	mux.Handle("GET /gradebook/student/{id}", utils.WithAuth(utils.ParseForm(
+		c.GetStudentGradebook(db), m.ParseGradebookQuery,
+	)))
+	mux.Handle("GET /gradebook/class/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.GetClassGradebook(db), m.ParseGradebookQuery,
+		), staff...)),
+	)
+	mux.Handle("GET /note", utils.WithAuth(utils.ParseForm(
+		c.ListNotes(db), m.ParseListQuery, m.ParseNoteFilter,
+	)))
+	mux.Handle("GET /note/calendar", utils.WithAuth(utils.ParseForm(
+		c.GetNoteCalendar(db), m.ParseNoteCalendarQuery,
+	)))
+	mux.Handle("GET /note/{id}", utils.WithAuth(c.GetNote(db)))
+	mux.Handle("GET /note_limit", utils.WithAuth(c.ListNoteLimits(db)))
+	mux.Handle("GET /note/{id}/submission",
+		utils.WithAuth(utils.WithRoles(c.GetSubmissionMatrix(db), staff...)),
+	)
+	mux.Handle("GET /homework_submission", utils.WithAuth(utils.ParseForm(
+		c.ListHomeworkSubmissions(db), m.ParseListQuery, m.ParseHomeworkSubmissionFilter,
+	)))
+	mux.Handle("GET /homework_submission/{id}", utils.WithAuth(c.GetHomeworkSubmission(db)))
+	mux.Handle("GET /homework_submission/{id}/file/{file_id}", utils.WithAuth(c.GetSubmissionFile(db)))
+	mux.Handle("GET /parent_child",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListParentChildren(db), m.ParseListQuery, m.ParseParentChildFilter,
+		), staff...)),
+	)
+	mux.Handle("GET /absence",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListAbsences(db), m.ParseListQuery, m.ParseAbsenceFilter,
+		), staff...)),
+	)
+	mux.Handle("GET /absence/totals",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.GetAbsenceTotals(db), m.ParseAbsenceTotalsQuery,
+		), staff...)),
+	)
+	mux.Handle("GET /absence/{id}",
+		utils.WithAuth(utils.WithRoles(c.GetAbsence(db), staff...)),
+	)
+	mux.Handle("GET /parent", utils.WithAuth(utils.WithRoles(c.GetParentPortal(db), utils.RoleParent)))
+	mux.Handle("GET /child", utils.WithAuth(utils.WithRoles(c.ListChildren(db), utils.RoleParent)))
+	mux.Handle("GET /child/{id}/timetable",
+		utils.WithAuth(utils.WithRoles(c.GetChildTimetable(db), utils.RoleParent)),
+	)
+	mux.Handle("GET /child/{id}/gradebook",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.GetChildGradebook(db), m.ParseChildQuery,
+		), utils.RoleParent)),
+	)
+	mux.Handle("GET /child/{id}/note",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListChildNotes(db), m.ParseChildQuery,
+		), utils.RoleParent)),
+	)
+	mux.Handle("GET /child/{id}/absence",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListChildAbsences(db), m.ParseChildQuery,
+		), utils.RoleParent)),
+	)
+	mux.Handle("GET /child/{id}/report",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.ListChildReports(db), m.ParseChildQuery,
+		), utils.RoleParent)),
+	)
+	mux.Handle("GET /absence_excuse", utils.WithAuth(utils.ParseForm(
+		c.ListAbsenceExcuses(db), m.ParseListQuery, m.ParseAbsenceExcuseFilter,
+	)))
+	mux.Handle("GET /absence_excuse/{id}", utils.WithAuth(c.GetAbsenceExcuse(db)))
+	mux.Handle("GET /absence_excuse/{id}/attachment", utils.WithAuth(c.GetAbsenceExcuseAttachment(db)))
+	mux.Handle("GET /notification", utils.WithAuth(utils.ParseForm(
+		c.ListNotifications(db), m.ParseListQuery, m.ParseNotificationFilter,
+	)))
+	mux.Handle("GET /notification/unread_count", utils.WithAuth(c.GetUnreadNotificationCount(db)))
+	mux.Handle("POST /notification/{id}/read", utils.WithAuth(c.MarkNotification(db, true)))
+	mux.Handle("POST /notification/{id}/unread", utils.WithAuth(c.MarkNotification(db, false)))
+	mux.Handle("POST /notification/read", utils.WithAuth(c.MarkAllNotificationsRead(db)))
+	mux.Handle("GET /notification/preference", utils.WithAuth(c.ListNotificationPreferences(db)))
+	mux.Handle("PUT /notification/preference", utils.WithAuth(utils.ParseForm(
+		c.SetNotificationPreference(db), m.ParseNotificationPreference,
+	)))
+	mux.Handle("GET /absence/{id}/substitutions",
+		utils.WithAuth(utils.WithRoles(c.GetSubstitutionSuggestions(db), admin...)),
+	)
+	mux.Handle("POST /absence/{id}/substitutions",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.AcceptSubstitutions(db), m.ParseSubstitutionAcceptance,
+		), admin...)),
+	)
+	mux.Handle("PATCH /school/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateSchool(db), m.ParseSchoolUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /school/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateSchool(db),
+			utils.RequireFields("school_name", "city", "zip_code", "street_address"),
+			m.ParseSchoolUpdate,
+		), admin...)),
+	)
+	mux.Handle("PATCH /user/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateUser(db), m.ParseUserUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /user/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateUser(db),
+			utils.RequireFields("user_name", "surname", "email", "role"),
+			m.ParseUserUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /user/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteUser(db), admin...)),
+	)
+	mux.Handle("PATCH /period/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdatePeriod(db), m.ParsePeriodUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /period/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdatePeriod(db),
+			utils.RequireFields("start", "end"),
+			m.ParsePeriodUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /period/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeletePeriod(db), admin...)),
+	)
+	mux.Handle("PATCH /room/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateRoom(db), m.ParseRoomUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /room/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateRoom(db),
+			utils.RequireFields("name"),
+			m.ParseRoomUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /room/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteRoom(db), admin...)),
+	)
+	mux.Handle("PATCH /academic_year/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateAcademicYear(db), m.ParseAcademicYearUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /academic_year/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateAcademicYear(db),
+			utils.RequireFields("name", "start", "end"),
+			m.ParseAcademicYearUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /academic_year/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteAcademicYear(db), admin...)),
+	)
+	mux.Handle("PATCH /term/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateTerm(db), m.ParseTermUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /term/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateTerm(db),
+			utils.RequireFields("name", "start", "end"),
+			m.ParseTermUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /term/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteTerm(db), admin...)),
+	)
+	mux.Handle("PATCH /subject/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateSubject(db), m.ParseSubjectUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /subject/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateSubject(db),
+			utils.RequireFields("name", "mandatory"),
+			m.ParseSubjectUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /subject/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteSubject(db), admin...)),
+	)
+	mux.Handle("PATCH /regular_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateRegularTimetable(db), m.ParseRegularTimetableUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /regular_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateRegularTimetable(db),
+			utils.RequireFields("period_id", "subject_id", "room_id", "weekday"),
+			m.ParseRegularTimetableUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /regular_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteRegularTimetable(db), admin...)),
+	)
+	mux.Handle("PATCH /substitute_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateSubstituteTimetable(db), m.ParseSubstituteTimetableUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /substitute_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateSubstituteTimetable(db),
+			utils.RequireFields("period_id", "subject_id", "room_id", "date"),
+			m.ParseSubstituteTimetableUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /substitute_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteSubstituteTimetable(db), admin...)),
+	)
+	mux.Handle("PATCH /event_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateEventTimetable(db), m.ParseEventTimetableUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /event_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateEventTimetable(db),
+			utils.RequireFields("name", "start", "end"),
+			m.ParseEventTimetableUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /event_timetable/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteEventTimetable(db), admin...)),
+	)
+	mux.Handle("PATCH /report/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateReport(db), m.ParseReportUpdate,
+		), staff...)),
+	)
+	mux.Handle("PUT /report/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateReport(db),
+			utils.RequireFields("topic_covered"),
+			m.ParseReportUpdate,
+		), staff...)),
+	)
+	mux.Handle("PUT /report/{id}/attendance",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateReportAttendance(db), m.ParseAttendance,
+		), staff...)),
+	)
+	mux.Handle("DELETE /report/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteReport(db), staff...)),
+	)
+	mux.Handle("PATCH /class/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateClass(db), m.ParseClassUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /class/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateClass(db),
+			utils.RequireFields("name", "year", "class_teacher_id"),
+			m.ParseClassUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /class/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteClass(db), admin...)),
+	)
+	mux.Handle("PATCH /group/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateGroup(db), m.ParseGroupUpdate,
+		), admin...)),
+	)
+	mux.Handle("PUT /group/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateGroup(db),
+			utils.RequireFields("name", "class_id"),
+			m.ParseGroupUpdate,
+		), admin...)),
+	)
+	mux.Handle("DELETE /group/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteGroup(db), admin...)),
+	)
+	mux.Handle("PATCH /grade/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateGrade(db), m.ParseGradeUpdate,
+		), staff...)),
+	)
+	mux.Handle("PUT /grade/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateGrade(db),
+			utils.RequireFields("value", "weight"),
+			m.ParseGradeUpdate,
+		), staff...)),
+	)
+	mux.Handle("DELETE /grade/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteGrade(db), staff...)),
+	)
+	mux.Handle("PATCH /final_grade/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateFinalGrade(db), m.ParseFinalGradeUpdate,
+		), staff...)),
+	)
+	mux.Handle("PUT /final_grade/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateFinalGrade(db),
+			utils.RequireFields("value"),
+			m.ParseFinalGradeUpdate,
+		), staff...)),
+	)
+	mux.Handle("DELETE /final_grade/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteFinalGrade(db), staff...)),
+	)
+	mux.Handle("PATCH /note/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateNote(db), m.ParseNoteUpdate,
+		), staff...)),
+	)
+	mux.Handle("PUT /note/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateNote(db),
+			utils.RequireFields("type", "content"),
+			m.ParseNoteUpdate,
+		), staff...)),
+	)
+	mux.Handle("PUT /note/{id}/submission/{student_id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.MarkHomeworkSubmission(db), m.ParseSubmissionMark,
+		), staff...)),
+	)
+	mux.Handle("PUT /note_limit/{type}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.SetNoteLimit(db), m.ParseNoteLimit,
+		), admin...)),
+	)
+	mux.Handle("DELETE /note_limit/{type}",
+		utils.WithAuth(utils.WithRoles(c.DeleteNoteLimit(db), admin...)),
+	)
+	mux.Handle("DELETE /note/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteNote(db), staff...)),
+	)
+	mux.Handle("PATCH /absence/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateAbsence(db), m.ParseAbsenceUpdate,
+		), staff...)),
+	)
+	mux.Handle("PUT /absence/{id}",
+		utils.WithAuth(utils.WithRoles(utils.ParseForm(
+			c.UpdateAbsence(db),
+			utils.RequireFields("start", "end", "status"),
+			m.ParseAbsenceUpdate,
+		), staff...)),
+	)
+	mux.Handle("DELETE /absence/{id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteAbsence(db), staff...)),
+	)
+	mux.Handle("DELETE /users_group/{user_id}/{group_id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteUsersGroup(db), admin...)),
+	)
+	mux.Handle("DELETE /timetable_group/{timetable_id}/{group_id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteTimetableGroup(db), admin...)),
+	)
+	mux.Handle("DELETE /timetable_teacher/{timetable_id}/{teacher_id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteTimetableTeacher(db), admin...)),
+	)
+	mux.Handle("DELETE /parent_child/{parent_id}/{child_id}",
+		utils.WithAuth(utils.WithRoles(c.DeleteParentChild(db), admin...)),
 	)
-	mux.Handle("POST /room", utils.ParseForm(
-		c.CreateRoom(db), m.ParseRoom,
-	))
-	mux.Handle("POST /subject", utils.ParseForm(
-		c.CreateSubject(db), m.ParseSubject,
-	))
-	mux.Handle("POST /regular_timetable", utils.ParseForm(
-		c.CreateRegularTimetable(db), m.ParseRegularTimetable,
-	))
-	mux.Handle("POST /substitute_timetable", utils.ParseForm(
-		c.CreateSubstituteTimetable(db), m.ParseSubstituteTimetable,
-	))
-	mux.Handle("POST /event_timetable", utils.ParseForm(
-		c.CreateEventTimetable(db), m.ParseEventTimetable,
-	))
-	mux.Handle("POST /report", utils.ParseForm(
-		c.CreateReport(db), m.ParseReport,
-	))
-	mux.Handle("POST /class", utils.ParseForm(
-		c.CreateClass(db), m.ParseClass,
-	))
-	mux.Handle("POST /group", utils.ParseForm(
-		c.CreateGroup(db), m.ParseGroup,
-	))
-	mux.Handle("POST /users_group", utils.ParseForm(
-		c.CreateUsersGroup(db), m.ParseUsersGroup,
-	))
-	mux.Handle("POST /timetable_group", utils.ParseForm(
-		c.CreateTimetableGroup(db),
-		m.ParseTimetableGroup,
-	))
-	mux.Handle("POST /timetable_teacher", utils.ParseForm(
-		c.CreateTimetableTeacher(db),
-		m.ParseTimetableTeacher,
-	))
-	mux.Handle("POST /grade", utils.ParseForm(
-		c.CreateGrade(db),
-		m.ParseGrade,
-	))
-	mux.Handle("POST /note", utils.ParseForm(
-		c.CreateNote(db), m.ParseNote,
-	))
-	mux.Handle("POST /parent_child", utils.ParseForm(
-		c.CreateParentChild(db), m.ParseParentChild,
-	))
-	mux.Handle("POST /absence", utils.ParseForm(
-		c.CreateAbsence(db), m.ParseAbsence,
-	))
 	mux.Handle("GET /", utils.WithAuth(c.GetHomepage(db)))
 	mux.Handle("GET /register", c.GetRegister())
 	mux.Handle("GET /login", c.GetLogin())
+	mux.Handle("GET /password/forgot", c.GetForgotPassword())
+	mux.Handle("GET /password/reset", c.GetResetPassword())
 }
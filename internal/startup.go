@@ -17,6 +17,7 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	m "github.com/dr0th3r/learnscape/internal/models"
 	u "github.com/dr0th3r/learnscape/internal/utils"
 )
 
@@ -49,6 +50,18 @@ func Run(ctx context.Context, config *u.Config) (err error) {
 	}
 	defer db.Close()
 
+	//sessions are kept in memory without redis, so they don't survive a restart
+	if config.Redis.Host != "" {
+		redis := u.NewRedisClient(config.Redis)
+		defer redis.Close()
+		if err := redis.Ping(ctx).Err(); err != nil {
+			return errors.New("error connecting to redis: " + err.Error())
+		}
+		u.SetSessionStore(u.NewRedisSessionStore(redis))
+	} else {
+		u.SetSessionStore(u.NewMemorySessionStore())
+	}
+
 	otelShutdown, err := u.SetupOTelSDK(ctx)
 	if err != nil {
 		return errors.New("error setting up otel " + err.Error())
@@ -66,6 +79,20 @@ func Run(ctx context.Context, config *u.Config) (err error) {
 		Handler: srv,
 	}
 
+	//the worker stops with the server, it finishes the event it is delivering
+	worker := m.NewOutboxWorker(db, config.Outbox)
+	//without a mail server reset links can't be sent, their events are dead-lettered
+	channels := []m.NotificationChannel{m.NewInboxChannel(db)}
+	mailer, err := u.NewMailer(config.App.Mail)
+	if err != nil {
+		return errors.New("error setting up mailer " + err.Error())
+	}
+	if mailer.Enabled() {
+		channels = append(channels, m.NewEmailChannel(mailer))
+		m.NewPasswordResetMailer(mailer, config.App.BaseUrl).Register(worker)
+	}
+	m.NewNotifier(db, channels...).Register(worker)
+
 	go func() {
 		log.Printf("listening on %s\n", httpServer.Addr)
 		fmt.Println("listening")
@@ -74,7 +101,11 @@ func Run(ctx context.Context, config *u.Config) (err error) {
 		}
 	}()
 	var wg sync.WaitGroup
-	wg.Add(1)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		worker.Run(ctx)
+	}()
 	go func() {
 		defer wg.Done()
 		<-ctx.Done()
@@ -0,0 +1,13 @@
+package utils
+
+import "time"
+
+// Backoff is how long to wait before the next attempt after the given number of failed ones,
+// the wait doubles with each failure starting at base and never gets longer than max
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < attempts && wait < max; i++ {
+		wait *= 2
+	}
+	return min(wait, max)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const configPath = "config/config.json"
@@ -14,6 +15,8 @@ type Config struct {
 	Server ServerConfig `json:"server"`
 	DB     DBConfig     `json:"db"`
 	App    AppConfig    `json:"app"`
+	Outbox OutboxConfig `json:"outbox"`
+	Redis  RedisConfig  `json:"redis"`
 }
 
 type ServerConfig struct {
@@ -40,6 +43,36 @@ func (c DBConfig) GetConnectionUrlWithoutName() string {
 
 type AppConfig struct {
 	JwtSecret string `json:"jwtSecret"`
+	// BaseUrl is where users open the application, links in emails lead there
+	BaseUrl string     `json:"baseUrl"`
+	Mail    MailConfig `json:"mail"`
+}
+
+// OutboxConfig tunes the worker delivering outbox events, times are in milliseconds
+type OutboxConfig struct {
+	PollIntervalMs int `json:"pollIntervalMs"`
+	BatchSize      int `json:"batchSize"`
+	MaxAttempts    int `json:"maxAttempts"`
+	BackoffMs      int `json:"backoffMs"`
+	MaxBackoffMs   int `json:"maxBackoffMs"`
+	// HandlerTimeoutMs is how long a handler can deliver one event, so the worker stops in bounded time
+	HandlerTimeoutMs int `json:"handlerTimeoutMs"`
+}
+
+func (c OutboxConfig) PollInterval() time.Duration {
+	return time.Duration(c.PollIntervalMs) * time.Millisecond
+}
+
+func (c OutboxConfig) Backoff() time.Duration {
+	return time.Duration(c.BackoffMs) * time.Millisecond
+}
+
+func (c OutboxConfig) MaxBackoff() time.Duration {
+	return time.Duration(c.MaxBackoffMs) * time.Millisecond
+}
+
+func (c OutboxConfig) HandlerTimeout() time.Duration {
+	return time.Duration(c.HandlerTimeoutMs) * time.Millisecond
 }
 
 func getProjectRoot() (string, error) {
@@ -57,6 +90,16 @@ func getProjectRoot() (string, error) {
 	return "", nil
 }
 
+// WebPath returns path of a file in the web directory, it works
+// from the test directory too (where the server runs during tests)
+func WebPath(name string) string {
+	projectRoot, err := getProjectRoot()
+	if err != nil {
+		projectRoot = ""
+	}
+	return filepath.Join(projectRoot, "web", name)
+}
+
 func ParseConfig() (*Config, error) {
 	projectRoot, err := getProjectRoot()
 	if err != nil {
@@ -26,12 +26,21 @@ func HandleError(w http.ResponseWriter, err error, code int, msg string, ctx con
 		span.RecordError(err)
 	}
 
-	w.WriteHeader(code)
 	if msg == "" {
-		fmt.Fprintf(w, "<p>%s</p>", err.Error())
-	} else {
-		fmt.Fprintf(w, "<p>%s</p>", msg)
+		msg = err.Error()
+	}
+
+	if GetResponseFormat(ctx) == FormatJSON {
+		WriteJSON(w, code, struct {
+			Error string `json:"error"`
+		}{
+			Error: msg,
+		}, ctx)
+		return
 	}
+
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "<p>%s</p>", msg)
 }
 
 func UnexpectedError(w http.ResponseWriter, err error, ctx context.Context) {
@@ -50,6 +59,12 @@ func NewParserError(cause error, msg string) *ParseError {
 	}
 }
 
+// HandleError responds with 400, or 413 when the body was over its size limit
 func (e *ParseError) HandleError(w http.ResponseWriter, ctx context.Context) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(e.cause, &maxBytesErr) {
+		HandleError(w, e.cause, http.StatusRequestEntityTooLarge, e.msg, ctx)
+		return
+	}
 	HandleError(w, e.cause, http.StatusBadRequest, e.msg, ctx)
 }
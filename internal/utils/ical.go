@@ -0,0 +1,343 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icalDateTime = "20060102T150405"
+	icalDate     = "20060102"
+	// icalLineLength is the maximum length of a content line in octets (RFC 5545 section 3.1)
+	icalLineLength = 75
+)
+
+// ICalEvent is a VEVENT of an iCalendar feed. Times are floating (without a time zone),
+// the same as timestamps in the database, so clients show them in their local time
+type ICalEvent struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Summary     string
+	Description string
+	Location    string
+	Cancelled   bool
+}
+
+// ICalProblem is an event ParseICalendar couldn't read
+type ICalProblem struct {
+	UID     string
+	Summary string
+	Reason  string
+}
+
+var ErrInvalidICalendar = errors.New("Not an iCalendar file")
+
+// WriteICalendar renders the events as a VCALENDAR (RFC 5545), stamp is the time the feed was made
+func WriteICalendar(w io.Writer, name string, stamp time.Time, events []ICalEvent) error {
+	var b bytes.Buffer
+	line := func(name, value string) {
+		writeICalLine(&b, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//learnscape//timetable//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("X-WR-CALNAME", escapeICalText(name))
+	for _, e := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", stamp.UTC().Format(icalDateTime)+"Z")
+		if e.AllDay {
+			line("DTSTART;VALUE=DATE", e.Start.Format(icalDate))
+			line("DTEND;VALUE=DATE", e.End.Format(icalDate))
+		} else {
+			line("DTSTART", e.Start.Format(icalDateTime))
+			line("DTEND", e.End.Format(icalDateTime))
+		}
+		line("SUMMARY", escapeICalText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escapeICalText(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION", escapeICalText(e.Location))
+		}
+		if e.Cancelled {
+			line("STATUS", "CANCELLED")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeICalLine folds lines longer than 75 octets, continuation lines start with a space.
+// Lines are only split between runes, so multi-byte characters stay intact
+func writeICalLine(b *bytes.Buffer, line string) {
+	limit := icalLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = icalLineLength - 1 //the leading space counts as well
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+var icalTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escapeICalText(text string) string {
+	return icalTextEscaper.Replace(text)
+}
+
+// ICalUID makes globally unique ids of events, kind and id have to identify the event
+// the same way in every feed, otherwise clients would duplicate it instead of updating
+func ICalUID(kind string, id ...any) string {
+	parts := make([]string, len(id))
+	for i, v := range id {
+		parts[i] = fmt.Sprint(v)
+	}
+	return fmt.Sprintf("%s-%s@learnscape", kind, strings.Join(parts, "-"))
+}
+
+// ParseICalendar reads VEVENTs of a VCALENDAR (RFC 5545). Times with a time zone
+// are read as floating in that zone, UTC times stay in UTC. Events which can't be
+// represented, e.g. recurring ones, are returned as problems instead of failing the whole file
+func ParseICalendar(r io.Reader) ([]ICalEvent, []ICalProblem, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, nil, ErrInvalidICalendar
+	}
+
+	events := []ICalEvent{}
+	problems := []ICalProblem{}
+	var properties map[string]icalProperty
+	depth := 0 //nested components of the event, e.g. VALARM
+	for _, line := range lines {
+		name, params, value, ok := parseICalLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT") && properties == nil:
+			properties = map[string]icalProperty{}
+		case properties == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+		case name == "END" && depth > 0:
+			depth--
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			event, problem := icalEventFromProperties(properties)
+			if problem != "" {
+				problems = append(problems, ICalProblem{
+					UID:     properties["UID"].value,
+					Summary: unescapeICalText(properties["SUMMARY"].value),
+					Reason:  problem,
+				})
+			} else {
+				events = append(events, event)
+			}
+			properties = nil
+		case depth == 0:
+			//the first occurrence wins, properties used here can't repeat anyway
+			if _, ok := properties[name]; !ok {
+				properties[name] = icalProperty{params: params, value: value}
+			}
+		}
+	}
+
+	return events, problems, nil
+}
+
+type icalProperty struct {
+	params map[string]string
+	value  string
+}
+
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseICalLine splits a content line into the upper cased name, parameters and the value
+func parseICalLine(line string) (string, map[string]string, string, bool) {
+	params := map[string]string{}
+
+	//the value starts after the first colon which isn't quoted in a parameter
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	for _, p := range parts[1:] {
+		if key, value, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:], true
+}
+
+func icalEventFromProperties(properties map[string]icalProperty) (ICalEvent, string) {
+	if properties["UID"].value == "" {
+		return ICalEvent{}, "missing UID"
+	}
+	if _, ok := properties["RRULE"]; ok {
+		return ICalEvent{}, "recurring events aren't supported"
+	}
+	if _, ok := properties["RECURRENCE-ID"]; ok {
+		return ICalEvent{}, "recurring events aren't supported"
+	}
+
+	event := ICalEvent{
+		UID:         properties["UID"].value,
+		Summary:     unescapeICalText(properties["SUMMARY"].value),
+		Description: unescapeICalText(properties["DESCRIPTION"].value),
+		Location:    unescapeICalText(properties["LOCATION"].value),
+		Cancelled:   strings.EqualFold(properties["STATUS"].value, "CANCELLED"),
+	}
+
+	dtstart, ok := properties["DTSTART"]
+	if !ok {
+		return ICalEvent{}, "missing DTSTART"
+	}
+	var err error
+	event.Start, event.AllDay, err = parseICalTime(dtstart)
+	if err != nil {
+		return ICalEvent{}, "invalid DTSTART"
+	}
+
+	if dtend, ok := properties["DTEND"]; ok {
+		if event.End, _, err = parseICalTime(dtend); err != nil {
+			return ICalEvent{}, "invalid DTEND"
+		}
+	} else if duration, ok := properties["DURATION"]; ok {
+		d, err := parseICalDuration(duration.value)
+		if err != nil {
+			return ICalEvent{}, "invalid DURATION"
+		}
+		event.End = event.Start.Add(d)
+	} else if event.AllDay {
+		event.End = event.Start.AddDate(0, 0, 1)
+	} else {
+		event.End = event.Start
+	}
+	if event.End.Before(event.Start) {
+		return ICalEvent{}, "event ends before it starts"
+	}
+
+	return event, ""
+}
+
+func parseICalTime(p icalProperty) (time.Time, bool, error) {
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(p.value) == len(icalDate) {
+		t, err := time.Parse(icalDate, p.value)
+		return t, true, err
+	}
+	if utc, ok := strings.CutSuffix(p.value, "Z"); ok {
+		t, err := time.Parse(icalDateTime, utc)
+		return t, false, err
+	}
+	t, err := time.Parse(icalDateTime, p.value)
+	return t, false, err
+}
+
+// parseICalDuration reads durations like P1D, PT1H30M or P1W, negative durations are rejected
+func parseICalDuration(value string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(value, "+"), "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := map[byte]time.Duration{
+		'W': 7 * 24 * time.Hour,
+		'D': 24 * time.Hour,
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+	}
+	var d time.Duration
+	number := ""
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == 'T':
+			continue
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			unit, ok := units[c]
+			if !ok || number == "" {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, err
+			}
+			d += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+var icalTextUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, ";",
+	`\,`, ",",
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+func unescapeICalText(text string) string {
+	return icalTextUnescaper.Replace(text)
+}
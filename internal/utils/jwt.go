@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.opentelemetry.io/otel"
@@ -14,50 +15,164 @@ var (
 	tracer = otel.Tracer("jwt")
 )
 
+// AccessTokenLifetime is how long a token lasts, WithAuth issues a new one with the refresh
+// token after that, so a revoked session is noticed within it at the latest
+const AccessTokenLifetime = 15 * time.Minute
+
+var jwtSecret = []byte("my secret")
+
+// ErrInvalidAccessToken means the token cookie is expired, malformed or wasn't signed with the secret
+var ErrInvalidAccessToken = errors.New("Invalid access token")
+
+// SetJwtSecret sets the secret tokens are signed and checked with
+func SetJwtSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
 type UserClaims struct {
 	Id       string `json:"id"`
 	Name     string `json:"name"`
 	Surname  string `json:"surname"`
 	Email    string `json:"email"`
 	SchoolId int    `json:"schoolId"`
+	Role     Role   `json:"role"`
 	jwt.RegisteredClaims
 }
 
+// AccessTokenCookie makes the token of the session, its id (jti) is the id of the session
+func AccessTokenCookie(s Session) (*http.Cookie, error) {
+	exp := time.Now().Add(AccessTokenLifetime)
+	claims := s.Claims
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        s.Id,
+		ExpiresAt: jwt.NewNumericDate(exp),
+	}
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Cookie{
+		Name:     "token",
+		Value:    tokenStr,
+		Path:     "/",
+		Expires:  exp,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode, //TODO: add other config by OWASP later
+	}, nil
+}
+
+// RefreshTokenCookie keeps the refresh token for as long as its session lasts
+func RefreshTokenCookie(refreshToken string, exp time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     "/",
+		Expires:  exp,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// ClearTokenCookies makes the client forget both tokens
+func ClearTokenCookies(w http.ResponseWriter) {
+	for _, name := range []string{"token", "refresh_token"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
+
+// SetTokenCookies sets the access token of the session and the refresh token, unless it is empty
+func SetTokenCookies(w http.ResponseWriter, s Session, refreshToken string) error {
+	tokenCookie, err := AccessTokenCookie(s)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, tokenCookie)
+	if refreshToken != "" {
+		http.SetCookie(w, RefreshTokenCookie(refreshToken, s.ExpiresAt))
+	}
+	return nil
+}
+
+// WithAuth lets through requests with a token of a session which wasn't revoked. When the token
+// can't be used (it expired, the browser already dropped it or the secret changed), the refresh
+// token is used to issue a new one transparently, so the htmx UI keeps working without logging in again
 func WithAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqCtx := r.Context()
 		ctx, span := tracer.Start(reqCtx, "validating user is authenticated")
 		defer span.End()
 
-		tokenStr, err := r.Cookie("token")
-
-		if err != nil {
-			switch {
-			case errors.Is(err, http.ErrNoCookie):
-				HandleError(w, err, http.StatusBadRequest, "Token cookie provided", ctx)
-			default:
-				UnexpectedError(w, err, ctx)
+		claims, err := accessClaims(r)
+		if err == nil {
+			span.AddEvent("Checking session of the token")
+			var session Session
+			session, err = sessions.Get(ctx, claims.ID)
+			if err == nil && session.UserId != claims.Id {
+				err = ErrSessionNotFound
 			}
-			return
 		}
 
-		token, err := jwt.ParseWithClaims(tokenStr.Value, &UserClaims{}, func(t *jwt.Token) (interface{}, error) {
-			return []byte("my secret"), nil
-		})
-		if err != nil {
-			UnexpectedError(w, err, ctx)
-			return
+		refreshCookie, refreshErr := r.Cookie("refresh_token")
+		canRefresh := refreshErr == nil && (errors.Is(err, http.ErrNoCookie) || errors.Is(err, ErrInvalidAccessToken))
+		if canRefresh {
+			span.AddEvent("Refreshing access token")
+			var session Session
+			var refreshToken string
+			session, refreshToken, err = RefreshSession(ctx, refreshCookie.Value)
+			if err == nil {
+				err = SetTokenCookies(w, session, refreshToken)
+				claims = &session.Claims
+				claims.ID = session.Id
+			}
 		}
-		fmt.Println("token: ", token.Claims)
 
-		if claims, ok := token.Claims.(*UserClaims); !ok {
-			fmt.Println("not ok")
+		switch {
+		case err == nil:
+		case errors.Is(err, http.ErrNoCookie):
+			HandleError(w, err, http.StatusBadRequest, "Token cookie provided", ctx)
+			return
+		case errors.Is(err, ErrInvalidAccessToken), errors.Is(err, ErrSessionNotFound),
+			errors.Is(err, ErrRefreshTokenReused), errors.Is(err, ErrInvalidRefreshToken):
+			ClearTokenCookies(w)
+			if r.Header.Get("HX-Request") == "true" {
+				w.Header().Set("HX-Redirect", "/login")
+			}
+			HandleError(w, err, http.StatusUnauthorized, "", ctx)
+			return
+		default:
 			UnexpectedError(w, err, ctx)
 			return
-		} else {
-			ctx = context.WithValue(reqCtx, "claims", claims)
 		}
 
+		ctx = context.WithValue(reqCtx, "claims", claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// accessClaims reads claims of the token cookie, http.ErrNoCookie means there is none
+// and ErrInvalidAccessToken (wrapping the cause) that it can't be trusted
+func accessClaims(r *http.Request) (*UserClaims, error) {
+	tokenStr, err := r.Cookie("token")
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenStr.Value, &UserClaims{}, func(t *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAccessToken, err)
+	}
+	claims, ok := token.Claims.(*UserClaims)
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected claims %T", ErrInvalidAccessToken, token.Claims)
+	}
+	return claims, nil
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// MailConfig is the SMTP server emails are sent through, emails are off without a host
+type MailConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	From     string `json:"from"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Mailer sends plain text emails over SMTP. It authenticates only when a username is set,
+// net/smtp only allows that over TLS or to localhost
+type Mailer struct {
+	config MailConfig
+	// sender is the bare address of From, SMTP servers only take that in MAIL FROM
+	sender string
+}
+
+// NewMailer checks the sender when emails are on, From can have a display name ("Learnscape <noreply@...>")
+func NewMailer(config MailConfig) (*Mailer, error) {
+	m := &Mailer{config: config}
+	if config.Host == "" {
+		return m, nil
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	m.sender = from.Address
+	return m, nil
+}
+
+// Enabled tells whether there is a server to send emails through
+func (m *Mailer) Enabled() bool {
+	return m != nil && m.config.Host != ""
+}
+
+// mailTimeout bounds sending of one email, a server which stops answering doesn't hold up the caller
+const mailTimeout = 30 * time.Second
+
+// Send emails the body to the address, giving up when ctx is done or after mailTimeout
+func (m *Mailer) Send(ctx context.Context, to, subject, body string) error {
+	msg, err := MailMessage(m.config.From, to, subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	//the deadline doesn't notice cancellation, closing the connection does
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := m.send(conn, to, msg); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// send is smtp.SendMail over an already open connection
+func (m *Mailer) send(conn net.Conn, to string, msg []byte) error {
+	c, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.config.Host}); err != nil {
+			return err
+		}
+	}
+	if m.config.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.sender); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// MailMessage formats the email (RFC 5322). The subject is encoded word (RFC 2047)
+// and the body quoted-printable, so Czech text passes through servers without 8BITMIME
+func MailMessage(from, to, subject, body string, date time.Time) ([]byte, error) {
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&msg)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	msg.WriteString("\r\n")
+	return msg.Bytes(), nil
+}
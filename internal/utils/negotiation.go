@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type ResponseFormat int
+
+const (
+	// the client didn't say what it wants, handlers keep their defaults
+	FormatUnspecified ResponseFormat = iota
+	FormatHTML
+	FormatJSON
+)
+
+// WithNegotiation decides in which format the client wants the response
+// and stores it in the request context, so that handlers and error helpers
+// don't need the request to find out
+func WithNegotiation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), "response format", negotiateFormat(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func negotiateFormat(r *http.Request) ResponseFormat {
+	if r.Header.Get("HX-Request") == "true" {
+		return FormatHTML
+	}
+
+	if format := formatFromAccept(r.Header.Get("Accept")); format != FormatUnspecified {
+		return format
+	}
+
+	if isJSON(r.Header.Get("Content-Type")) {
+		return FormatJSON
+	}
+
+	return FormatUnspecified
+}
+
+// formatFromAccept picks html or json by the highest quality in the accept
+// header, wildcards don't express any preference
+func formatFromAccept(accept string) ResponseFormat {
+	format := FormatUnspecified
+	bestQuality := 0.0
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality <= bestQuality {
+			continue
+		}
+
+		switch mediaType {
+		case "text/html":
+			format, bestQuality = FormatHTML, quality
+		case "application/json":
+			format, bestQuality = FormatJSON, quality
+		}
+	}
+
+	return format
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+func GetResponseFormat(ctx context.Context) ResponseFormat {
+	format, ok := ctx.Value("response format").(ResponseFormat)
+	if !ok {
+		return FormatUnspecified
+	}
+	return format
+}
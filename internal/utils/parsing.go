@@ -2,6 +2,11 @@ package utils
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -38,10 +43,20 @@ func ParseForm(next http.Handler, parserFuncs ...parserFunc) http.Handler {
 		parserCtx, span := tracerParser.Start(reqCtx, "parsing formdata")
 		defer span.End()
 
-		span.AddEvent("Parsing form data")
-		if err := r.ParseForm(); err != nil {
-			HandleError(w, err, http.StatusInternalServerError, "Error parsing formdata", parserCtx)
-			return
+		if isJSON(r.Header.Get("Content-Type")) {
+			span.AddEvent("Parsing json body")
+			form, err := parseJSONBody(w, r)
+			if err != nil {
+				err.HandleError(w, parserCtx)
+				return
+			}
+			r.Form = form
+		} else {
+			span.AddEvent("Parsing form data")
+			if err := r.ParseForm(); err != nil {
+				HandleError(w, err, http.StatusInternalServerError, "Error parsing formdata", parserCtx)
+				return
+			}
 		}
 
 		handlerCtx := reqCtx
@@ -60,6 +75,121 @@ func ParseForm(next http.Handler, parserFuncs ...parserFunc) http.Handler {
 	})
 }
 
+// WithMultipartForm parses multipart bodies of up to maxSize bytes before ParseForm,
+// so parsers see their fields and the handler can read the files from r.MultipartForm.
+// Other bodies are left to ParseForm
+func WithMultipartForm(next http.Handler, maxSize int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "multipart/form-data" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		if err := r.ParseMultipartForm(maxSize); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				HandleError(w, err, http.StatusRequestEntityTooLarge, "Request body is too large", r.Context())
+			} else {
+				HandleError(w, err, http.StatusBadRequest, "Invalid multipart form", r.Context())
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+const maxJSONBodySize = 1 << 20
+
+// parseJSONBody turns a json object into the same url.Values the form parsers
+// already work with, so both kinds of clients go through the same validation.
+// Arrays become repeated values (like student_id=1&student_id=2 in a form)
+// and nulls are treated as if the field wasn't sent at all.
+// Every endpoint handles a single entity, so top level arrays (batches) are
+// rejected on purpose, clients send one object per request
+func parseJSONBody(w http.ResponseWriter, r *http.Request) (url.Values, *ParseError) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBodySize))
+	decoder.UseNumber()
+
+	var decoded any
+	if err := decoder.Decode(&decoded); err != nil && !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, NewParserError(err, "Request body is too large")
+		}
+		return nil, NewParserError(err, "Invalid json body, expected an object")
+	}
+	if decoder.More() {
+		return nil, NewParserError(nil, "Invalid json body, expected a single object")
+	}
+	var body map[string]any
+	switch v := decoded.(type) {
+	case nil:
+	case map[string]any:
+		body = v
+	case []any:
+		return nil, NewParserError(nil, "Invalid json body, batches aren't supported, send one object per request")
+	default:
+		return nil, NewParserError(nil, "Invalid json body, expected an object")
+	}
+
+	form := r.URL.Query()
+	for key, value := range body {
+		values, err := jsonFormValues(value)
+		if err != nil {
+			return nil, NewParserError(err, fmt.Sprintf("Invalid value of field %s", key))
+		}
+		for _, v := range values {
+			form.Add(key, v)
+		}
+	}
+
+	return form, nil
+}
+
+func jsonFormValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case json.Number:
+		return []string{v.String()}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, ok := item.([]any); ok {
+				return nil, errors.New("nested arrays are not supported")
+			}
+			itemValues, err := jsonFormValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	default:
+		return nil, errors.New("nested objects are not supported")
+	}
+}
+
+// RequireFields is used for PUT where the whole resource is replaced,
+// so the update parsers can stay partial
+func RequireFields(fields ...string) parserFunc {
+	return func(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *ParseError {
+		for _, field := range fields {
+			if !f.Has(field) {
+				return NewParserError(nil, fmt.Sprintf("Field %s not provided", field))
+			}
+		}
+		return nil
+	}
+}
+
 func ParseInt(span trace.Span, key, value string) (int, error) {
 	intValue, err := strconv.Atoi(value)
 	span.SetAttributes(
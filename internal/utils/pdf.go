@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 in points, the unit of PDF coordinates
+const (
+	PDFPageWidth  = 595.28
+	PDFPageHeight = 841.89
+)
+
+// pdfExtraGlyphs are characters outside of Latin-1 which Czech documents need, they get
+// codes from 128 on in the font encoding. Standard fonts have glyphs for all of them
+var pdfExtraGlyphs = []struct {
+	char rune
+	name string
+}{
+	{'Č', "Ccaron"}, {'č', "ccaron"}, {'Ď', "Dcaron"}, {'ď', "dcaron"},
+	{'Ě', "Ecaron"}, {'ě', "ecaron"}, {'Ň', "Ncaron"}, {'ň', "ncaron"},
+	{'Ř', "Rcaron"}, {'ř', "rcaron"}, {'Š', "Scaron"}, {'š', "scaron"},
+	{'Ť', "Tcaron"}, {'ť', "tcaron"}, {'Ů', "Uring"}, {'ů', "uring"},
+	{'Ž', "Zcaron"}, {'ž', "zcaron"},
+}
+
+// PDF is a minimal writer of text documents (PDF 1.4). It only uses the standard
+// Helvetica fonts, so no font has to be embedded and documents stay small
+type PDF struct {
+	pages []*PDFPage
+}
+
+type PDFPage struct {
+	content bytes.Buffer
+}
+
+func (p *PDF) AddPage() *PDFPage {
+	page := &PDFPage{}
+	p.pages = append(p.pages, page)
+	return page
+}
+
+// Text writes a line of text, x and y are its baseline measured from the top left corner of the page
+func (pg *PDFPage) Text(x, y, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&pg.content, "BT /%s %.2f Tf %.2f %.2f Td (", font, size, x, PDFPageHeight-y)
+	pg.content.Write(encodePDFText(text))
+	pg.content.WriteString(") Tj ET\n")
+}
+
+// Line draws a thin line, coordinates are measured from the top left corner of the page
+func (pg *PDFPage) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&pg.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PDFPageHeight-y1, x2, PDFPageHeight-y2)
+}
+
+// encodePDFText converts the text to the encoding of the fonts and escapes it for a string
+// literal, characters the fonts can't show are replaced by a question mark
+func encodePDFText(text string) []byte {
+	b := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b = append(b, '\\', byte(r))
+		case r >= ' ' && r < 127, r >= 160 && r <= 255:
+			b = append(b, byte(r))
+		default:
+			code := byte('?')
+			for i, g := range pdfExtraGlyphs {
+				if g.char == r {
+					code = byte(128 + i)
+					break
+				}
+			}
+			b = append(b, code)
+		}
+	}
+	return b
+}
+
+// WriteTo renders the document, the cross-reference table needs byte offsets of
+// all objects, so the document is built in memory first
+func (p *PDF) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	//the comment with bytes above 127 tells tools the file is binary
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	const firstPage = 6 //pages come after the catalog, the page tree, the encoding and two fonts
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	glyphs := make([]string, len(pdfExtraGlyphs))
+	for i, g := range pdfExtraGlyphs {
+		glyphs[i] = "/" + g.name
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object(fmt.Sprintf("<< /Type /Encoding /BaseEncoding /WinAnsiEncoding /Differences [128 %s] >>", strings.Join(glyphs, " ")))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding 3 0 R >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding 3 0 R >>")
+	for i, page := range p.pages {
+		object(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents %d 0 R >>",
+			PDFPageWidth, PDFPageHeight, firstPage+2*i+1,
+		))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", page.content.Len(), page.content.String()))
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type Role string
+
+const (
+	RoleAdmin   Role = "admin"
+	RoleTeacher Role = "teacher"
+	RoleStudent Role = "student"
+	RoleParent  Role = "parent"
+)
+
+func ParseRole(value string) (Role, error) {
+	switch role := Role(value); role {
+	case RoleAdmin, RoleTeacher, RoleStudent, RoleParent:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role %q", value)
+	}
+}
+
+// WithRoles only lets through users whose claims carry one of the given roles,
+// it has to be wrapped by WithAuth so the claims are already in the context
+func WithRoles(next http.Handler, roles ...Role) http.Handler {
+	allowed := make([]string, len(roles))
+	for i, role := range roles {
+		allowed[i] = string(role)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCtx := r.Context()
+		ctx, span := tracer.Start(reqCtx, "validating user role")
+		defer span.End()
+
+		span.SetAttributes(attribute.StringSlice("allowed_roles", allowed))
+
+		claims, ok := reqCtx.Value("claims").(*UserClaims)
+		if !ok {
+			UnexpectedError(w, errors.New("claims not found in context, is the route wrapped by WithAuth?"), ctx)
+			return
+		}
+		span.SetAttributes(
+			attribute.String("user_id", claims.Id),
+			attribute.String("role", string(claims.Role)),
+		)
+
+		if !slices.Contains(roles, claims.Role) {
+			reason := fmt.Sprintf("role %q is not allowed to access %s %s", claims.Role, r.Method, r.URL.Path)
+			span.SetAttributes(attribute.String("forbidden_reason", reason))
+			HandleError(w, errors.New(reason), http.StatusForbidden, "You are not allowed to do this", ctx)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig is the Redis server sessions are kept in, they are kept in memory without a host
+type RedisConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+func NewRedisClient(config RedisConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		Password: config.Password,
+		DB:       config.DB,
+	})
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+func WriteJSON(w http.ResponseWriter, code int, v any, ctx context.Context) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		UnexpectedError(w, err, ctx)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
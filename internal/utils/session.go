@@ -0,0 +1,392 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// RefreshTokenLifetime is how long a session lasts without being used, every refresh extends it
+	RefreshTokenLifetime = 72 * time.Hour
+	// maxSessionLifetime is how long a session can be extended for, then the user logs in again
+	maxSessionLifetime = 30 * 24 * time.Hour
+	// refreshGrace is how long the previous refresh token still gets access tokens, requests the
+	// browser sent at once all refresh with it and only the first one rotates it
+	refreshGrace = 30 * time.Second
+)
+
+var (
+	ErrSessionNotFound     = errors.New("Session expired or revoked")
+	ErrInvalidRefreshToken = errors.New("Invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("Refresh token was already used, the session was revoked")
+	// errRefreshConflict means the session was rotated by somebody else in the meantime
+	errRefreshConflict = errors.New("refresh token was rotated concurrently")
+)
+
+// Session is a login of a user and the family of its refresh tokens. Its id is the id (jti) of
+// the user's access tokens, which are only valid while the session is in the store, so deleting
+// the session revokes them and the refresh token
+type Session struct {
+	Id        string    `json:"id"`
+	UserId    string    `json:"userId"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Claims are put in access tokens issued with the refresh token
+	Claims UserClaims `json:"claims"`
+	// only hashes of refresh tokens are stored, the previous one to tell reuse from a concurrent refresh
+	RefreshHash         string    `json:"refreshHash"`
+	PreviousRefreshHash string    `json:"previousRefreshHash"`
+	RotatedAt           time.Time `json:"rotatedAt"`
+}
+
+type SessionStore interface {
+	Create(ctx context.Context, s Session) error
+	// Get returns ErrSessionNotFound for sessions which expired or were deleted
+	Get(ctx context.Context, id string) (Session, error)
+	// Rotate replaces the session only if its refresh hash is still previousHash, errRefreshConflict otherwise
+	Rotate(ctx context.Context, s Session, previousHash string) error
+	Delete(ctx context.Context, id string) error
+	// List lists sessions of the user which didn't expire, the oldest first
+	List(ctx context.Context, userId string) ([]Session, error)
+	// DeleteForUser deletes every session of the user except the one with the id (if not empty)
+	DeleteForUser(ctx context.Context, userId string, except string) error
+}
+
+// sessions is the store WithAuth checks tokens against, Run sets it from the config
+var sessions SessionStore = NewMemorySessionStore()
+
+func SetSessionStore(s SessionStore) {
+	sessions = s
+}
+
+// newRefreshToken makes a refresh token of the session (its id and a random secret) and the hash of the secret
+func newRefreshToken(sessionId string) (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return sessionId + "." + encoded, hashRefreshSecret(encoded), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateSession starts a session of the user with the claims, it returns the session with its refresh token
+func CreateSession(ctx context.Context, claims UserClaims, userAgent string) (Session, string, error) {
+	now := time.Now().UTC()
+	s := Session{
+		Id:        uuid.NewString(),
+		UserId:    claims.Id,
+		UserAgent: userAgent,
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTokenLifetime),
+		Claims:    claims,
+		RotatedAt: now,
+	}
+	s.Claims.RegisteredClaims = jwt.RegisteredClaims{}
+	refreshToken, hash, err := newRefreshToken(s.Id)
+	if err != nil {
+		return Session{}, "", err
+	}
+	s.RefreshHash = hash
+	return s, refreshToken, sessions.Create(ctx, s)
+}
+
+// RefreshSession rotates the refresh token and extends the session. Using a refresh token which
+// was already rotated revokes the session, as the token (or the one it was rotated to) was stolen.
+// The new refresh token is empty when the previous one is used within the grace of a concurrent refresh
+func RefreshSession(ctx context.Context, refreshToken string) (Session, string, error) {
+	id, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return Session{}, "", ErrInvalidRefreshToken
+	}
+	hash := hashRefreshSecret(secret)
+
+	for {
+		s, err := sessions.Get(ctx, id)
+		if err != nil {
+			return Session{}, "", err
+		}
+
+		switch {
+		case hash == s.RefreshHash:
+			newToken, newHash, err := newRefreshToken(s.Id)
+			if err != nil {
+				return Session{}, "", err
+			}
+			now := time.Now().UTC()
+			s.PreviousRefreshHash, s.RefreshHash, s.RotatedAt = s.RefreshHash, newHash, now
+			s.ExpiresAt = now.Add(RefreshTokenLifetime)
+			if limit := s.CreatedAt.Add(maxSessionLifetime); s.ExpiresAt.After(limit) {
+				s.ExpiresAt = limit
+			}
+			err = sessions.Rotate(ctx, s, hash)
+			if errors.Is(err, errRefreshConflict) {
+				//another request rotated it first, its hash is previous now
+				continue
+			}
+			return s, newToken, err
+		case hash == s.PreviousRefreshHash && time.Since(s.RotatedAt) < refreshGrace:
+			return s, "", nil
+		default:
+			if err := sessions.Delete(ctx, id); err != nil {
+				return Session{}, "", err
+			}
+			return Session{}, "", ErrRefreshTokenReused
+		}
+	}
+}
+
+func ListSessions(ctx context.Context, userId string) ([]Session, error) {
+	return sessions.List(ctx, userId)
+}
+
+// RevokeSession deletes the session if it belongs to the user, ErrSessionNotFound otherwise
+func RevokeSession(ctx context.Context, userId, id string) error {
+	s, err := sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if s.UserId != userId {
+		return ErrSessionNotFound
+	}
+	return sessions.Delete(ctx, id)
+}
+
+// RevokeUserSessions deletes every session of the user except the one with the id (if not empty)
+func RevokeUserSessions(ctx context.Context, userId, except string) error {
+	return sessions.DeleteForUser(ctx, userId, except)
+}
+
+// MemorySessionStore keeps sessions in the process, they are lost on restart and
+// not shared between instances, so it is meant for tests and development
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]Session{}}
+}
+
+func (m *MemorySessionStore) Create(ctx context.Context, s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.Id] = s
+	return nil
+}
+
+func (m *MemorySessionStore) Get(ctx context.Context, id string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok || !s.ExpiresAt.After(time.Now()) {
+		delete(m.sessions, id)
+		return Session{}, ErrSessionNotFound
+	}
+	return s, nil
+}
+
+func (m *MemorySessionStore) Rotate(ctx context.Context, s Session, previousHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.sessions[s.Id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if current.RefreshHash != previousHash {
+		return errRefreshConflict
+	}
+	m.sessions[s.Id] = s
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemorySessionStore) List(ctx context.Context, userId string) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := []Session{}
+	for id, s := range m.sessions {
+		if !s.ExpiresAt.After(time.Now()) {
+			delete(m.sessions, id)
+		} else if s.UserId == userId {
+			list = append(list, s)
+		}
+	}
+	sortSessions(list)
+	return list, nil
+}
+
+func (m *MemorySessionStore) DeleteForUser(ctx context.Context, userId string, except string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, s := range m.sessions {
+		if s.UserId == userId && id != except {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// RedisSessionStore keeps every session under its own key expiring with it
+// and a set of session ids of each user to list them
+type RedisSessionStore struct {
+	client *redis.Client
+}
+
+func NewRedisSessionStore(client *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{client: client}
+}
+
+func sessionKey(id string) string {
+	return "session:" + id
+}
+
+func userSessionsKey(userId string) string {
+	return "user_sessions:" + userId
+}
+
+func (r *RedisSessionStore) Create(ctx context.Context, s Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Set(ctx, sessionKey(s.Id), data, time.Until(s.ExpiresAt)).Err(); err != nil {
+		return err
+	}
+	if err := r.client.SAdd(ctx, userSessionsKey(s.UserId), s.Id).Err(); err != nil {
+		return err
+	}
+	//no session outlives its maximum lifetime, so the set lives that long after the newest one is created
+	return r.client.PExpire(ctx, userSessionsKey(s.UserId), maxSessionLifetime).Err()
+}
+
+// rotateScript replaces the session (KEYS[1]) only if its refresh hash is still ARGV[1],
+// in a script so no other rotation can happen between the check and the write
+var rotateScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return -1
+end
+if cjson.decode(current).refreshHash ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+func (r *RedisSessionStore) Rotate(ctx context.Context, s Session, previousHash string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(s.ExpiresAt).Milliseconds()
+	rotated, err := rotateScript.Run(ctx, r.client, []string{sessionKey(s.Id)}, previousHash, data, ttl).Int()
+	if err != nil {
+		return err
+	}
+	switch rotated {
+	case 1:
+		return nil
+	case 0:
+		return errRefreshConflict
+	default:
+		return ErrSessionNotFound
+	}
+}
+
+func (r *RedisSessionStore) Get(ctx context.Context, id string) (Session, error) {
+	data, err := r.client.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Session{}, ErrSessionNotFound
+	} else if err != nil {
+		return Session{}, err
+	}
+	var s Session
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+func (r *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	s, err := r.Get(ctx, id)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := r.client.Del(ctx, sessionKey(id)).Err(); err != nil {
+		return err
+	}
+	return r.client.SRem(ctx, userSessionsKey(s.UserId), id).Err()
+}
+
+func (r *RedisSessionStore) List(ctx context.Context, userId string) ([]Session, error) {
+	ids, err := r.client.SMembers(ctx, userSessionsKey(userId)).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := []Session{}
+	for _, id := range ids {
+		s, err := r.Get(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			//the session expired, only its id was left in the set
+			if err := r.client.SRem(ctx, userSessionsKey(userId), id).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	sortSessions(list)
+	return list, nil
+}
+
+func (r *RedisSessionStore) DeleteForUser(ctx context.Context, userId string, except string) error {
+	ids, err := r.client.SMembers(ctx, userSessionsKey(userId)).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id == except {
+			continue
+		}
+		if err := r.client.Del(ctx, sessionKey(id)).Err(); err != nil {
+			return err
+		}
+		if err := r.client.SRem(ctx, userSessionsKey(userId), id).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortSessions(list []Session) {
+	slices.SortFunc(list, func(a, b Session) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestAbsenceExcuse(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	classTeacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	otherTeacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	parentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	strangerId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	childId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	classId, err := createClass(conn, classTeacherId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, `
+		with g as (insert into "group" (name, class_id, school_id) values ('whole class', $1, (select school_id from class where id = $1)) returning id)
+		insert into users_group (user_id, group_id) select $2, id from g`,
+		classId, childId,
+	); err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into parent_child (parent_id, child_id) values ($1, $2)", parentId, childId); err != nil {
+		t.Error(err)
+	}
+	var absenceId string
+	if err := conn.QueryRow(ctx,
+		"insert into absence (user_id, span) values ($1, '[2024-10-01 08:00, 2024-10-01 12:00]') returning id::text", childId,
+	).Scan(&absenceId); err != nil {
+		t.Error(err)
+	}
+
+	claimsOf := func(id string, role utils.Role) http.Cookie {
+		claims, err := createUserJWT(id, schoolId, role)
+		if err != nil {
+			t.Error(err)
+		}
+		return claims
+	}
+	parent := claimsOf(parentId, utils.RoleParent)
+	stranger := claimsOf(strangerId, utils.RoleParent)
+	classTeacher := claimsOf(classTeacherId, utils.RoleTeacher)
+	otherTeacher := claimsOf(otherTeacherId, utils.RoleTeacher)
+
+	absenceStatus := func(t *testing.T) string {
+		res, err := getWithCookie("http://localhost:8080/absence/"+absenceId, classTeacher)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var absence struct {
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&absence); err != nil {
+			t.Error(err)
+		}
+		return absence.Status
+	}
+	submit := func(t *testing.T, cookie http.Cookie) *http.Response {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("reason", "doctor's appointment")
+		file, err := form.CreateFormFile("attachment", "note.txt")
+		if err != nil {
+			t.Error(err)
+		}
+		file.Write([]byte("confirmed by the doctor"))
+		form.Close()
+
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/absence/"+absenceId+"/excuse", &body)
+		if err != nil {
+			t.Error(err)
+		}
+		req.AddCookie(&cookie)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+		}
+		return res
+	}
+
+	var excuseId string
+	t.Run("parent submits an excuse with an attachment", func(t *testing.T) {
+		res := submit(t, parent)
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		location := res.Header.Get("Location")
+		excuseId = location[strings.LastIndex(location, "/")+1:]
+
+		if got := absenceStatus(t); got != "pending" {
+			t.Errorf("Got %q, want pending", got)
+		}
+	})
+
+	t.Run("only one excuse can be pending", func(t *testing.T) {
+		res := submit(t, parent)
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusConflict {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusConflict)
+		}
+	})
+
+	t.Run("parent of another child can't excuse the absence", func(t *testing.T) {
+		res := submit(t, stranger)
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	review := func(t *testing.T, cookie http.Cookie, want int) {
+		res, err := postFormWithCookie("http://localhost:8080/absence_excuse/"+excuseId+"/review", cookie, url.Values{
+			"status": {"approved"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != want {
+			t.Errorf("Got %d, want %d", res.StatusCode, want)
+		}
+	}
+
+	t.Run("only the class teacher reviews", func(t *testing.T) {
+		review(t, otherTeacher, http.StatusForbidden)
+		review(t, classTeacher, http.StatusNoContent)
+		review(t, classTeacher, http.StatusConflict)
+
+		if got := absenceStatus(t); got != "excused" {
+			t.Errorf("Got %q, want excused", got)
+		}
+	})
+
+	t.Run("parent downloads the attachment", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/absence_excuse/"+excuseId+"/attachment", parent)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(body) != "confirmed by the doctor" {
+			t.Errorf("Got %q, want the attached file", body)
+		}
+	})
+
+	t.Run("excuses of other children aren't visible", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/absence_excuse/"+excuseId, stranger)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+}
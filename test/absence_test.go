@@ -48,7 +48,11 @@ func TestAbsence(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	userId, err := createUser(conn, -1)
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	userId, err := createUser(conn, schoolId)
 	if err != nil {
 		t.Error(err)
 	}
@@ -56,10 +60,19 @@ func TestAbsence(t *testing.T) {
 	start := time.Now().Format(time.RFC3339)
 	end := time.Now().Add(1 * time.Hour * 168).Format(time.RFC3339)
 
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_url := "http://localhost:8080/absence"
 
 	t.Run("can't create absence without user id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"start": {start},
 			"end":   {end},
 		})
@@ -76,7 +89,7 @@ func TestAbsence(t *testing.T) {
 	})
 
 	t.Run("can't create absence without end", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"user_id": {userId},
 		})
 		if err != nil {
@@ -92,7 +105,7 @@ func TestAbsence(t *testing.T) {
 	})
 
 	t.Run("can create absence", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"user_id": {userId},
 			"start":   {start},
 			"end":     {end},
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestAcademicYear(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	groupId, err := createGroup(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, adminId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	lessonId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_group (timetable_id, group_id) values ($1, $2)", lessonId, groupId); err != nil {
+		t.Error(err)
+	}
+	for _, span := range []string{"[2024-10-01 08:00, 2024-10-01 12:00]", "[2025-01-31 20:00, 2025-02-01 04:00]"} {
+		if _, err := conn.Exec(ctx, "insert into absence (user_id, span) values ($1, $2)", adminId, span); err != nil {
+			t.Error(err)
+		}
+	}
+
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	create := func(t *testing.T, endpoint string, data url.Values, want int) string {
+		res, err := postFormWithCookie("http://localhost:8080"+endpoint, claims, data)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != want {
+			t.Errorf("Got %d, want %d", res.StatusCode, want)
+		}
+		return res.Header.Get("Location")
+	}
+	idOf := func(location string) string {
+		return location[strings.LastIndex(location, "/")+1:]
+	}
+
+	var lastYearId, yearId, termId string
+	t.Run("academic years can't overlap", func(t *testing.T) {
+		lastYearId = idOf(create(t, "/academic_year", url.Values{
+			"name": {"2023/24"}, "start": {"2023-09-01"}, "end": {"2024-06-30"},
+		}, http.StatusCreated))
+		yearId = idOf(create(t, "/academic_year", url.Values{
+			"name": {"2024/25"}, "start": {"2024-09-01"}, "end": {"2025-06-30"},
+		}, http.StatusCreated))
+		create(t, "/academic_year", url.Values{
+			"name": {"overlapping"}, "start": {"2024-06-01"}, "end": {"2024-09-30"},
+		}, http.StatusConflict)
+	})
+
+	t.Run("terms have to be within their academic year", func(t *testing.T) {
+		termId = idOf(create(t, "/term", url.Values{
+			"academic_year_id": {yearId}, "name": {"first semester"}, "start": {"2024-09-01"}, "end": {"2025-01-31"},
+		}, http.StatusCreated))
+		create(t, "/term", url.Values{
+			"academic_year_id": {yearId}, "name": {"overlapping"}, "start": {"2025-01-01"}, "end": {"2025-03-31"},
+		}, http.StatusConflict)
+		create(t, "/term", url.Values{
+			"academic_year_id": {yearId}, "name": {"outside"}, "start": {"2025-06-01"}, "end": {"2025-07-31"},
+		}, http.StatusBadRequest)
+		create(t, "/term", url.Values{
+			"academic_year_id": {"-1"}, "name": {"unknown year"}, "start": {"2024-09-01"}, "end": {"2025-01-31"},
+		}, http.StatusBadRequest)
+
+		res, err := sendFormWithCookie(http.MethodPatch, "http://localhost:8080/academic_year/"+yearId, claims, url.Values{
+			"start": {"2024-10-01"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d when shrinking the year past its term", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("regular timetable of last year doesn't leak into this one", func(t *testing.T) {
+		res, err := sendFormWithCookie(http.MethodPatch, "http://localhost:8080/regular_timetable/"+lessonId, claims, url.Values{
+			"academic_year_id": {lastYearId},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+
+		//the same slot and room is free this year
+		create(t, "/regular_timetable", url.Values{
+			"period_id": {periodId}, "subject_id": {subjectId}, "room_id": {roomId}, "weekday": {"1"},
+			"school_id": {fmt.Sprint(schoolId)}, "academic_year_id": {yearId},
+		}, http.StatusCreated)
+
+		for week, want := range map[string]int{"2023-09-04": 1, "2024-09-02": 0} {
+			res, err := getWithCookie("http://localhost:8080/timetable?group="+groupId+"&week="+week, claims)
+			if err != nil {
+				t.Error(err)
+			}
+			var timetable struct {
+				Days []struct {
+					Slots [][]struct {
+						TimetableId int `json:"timetableId"`
+					} `json:"slots"`
+				} `json:"days"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&timetable); err != nil {
+				t.Error(err)
+			}
+			res.Body.Close()
+			if len(timetable.Days) != 5 || len(timetable.Days[0].Slots) != 1 {
+				t.Fatalf("Got %d days, want 5 days with one period", len(timetable.Days))
+			}
+			if got := len(timetable.Days[0].Slots[0]); got != want {
+				t.Errorf("Got %d lessons in the week of %s, want %d", got, week, want)
+			}
+		}
+	})
+
+	t.Run("absence totals are clipped to the term", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/absence/totals?term_id="+termId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		var got struct {
+			From   string `json:"from"`
+			To     string `json:"to"`
+			Totals []struct {
+				UserId string  `json:"userId"`
+				Count  int     `json:"count"`
+				Hours  float64 `json:"hours"`
+			} `json:"totals"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if got.From != "2024-09-01" || got.To != "2025-01-31" {
+			t.Errorf("Got %s - %s, want the term", got.From, got.To)
+		}
+		if len(got.Totals) != 1 || got.Totals[0].Count != 2 || got.Totals[0].Hours != 8 {
+			t.Errorf("Got %+v, want 2 absences with 8 hours", got.Totals)
+		}
+	})
+
+	t.Run("unknown term", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/absence/totals?term_id=-1", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestAttendance(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	lateId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	sickId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	strangerId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	groupId, err := createGroup(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, id := range []string{lateId, sickId} {
+		if _, err := conn.Exec(ctx, "insert into users_group (user_id, group_id) values ($1, $2)", id, groupId); err != nil {
+			t.Error(err)
+		}
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, teacherId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	timetableId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_group (timetable_id, group_id) values ($1, $2)", timetableId, groupId); err != nil {
+		t.Error(err)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	allDay := url.Values{
+		"start": {today.AddDate(0, 0, -1).Format(time.RFC3339)},
+		"end":   {today.AddDate(0, 0, 2).Format(time.RFC3339)},
+	}
+	if _, err := conn.Exec(ctx, "insert into absence (user_id, span, status) values ($1, tsrange($2, $3), 'excused')", sickId, allDay.Get("start"), allDay.Get("end")); err != nil {
+		t.Error(err)
+	}
+
+	claims, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var reportId string
+	attendance := func(t *testing.T) map[string]string {
+		res, err := getWithCookie("http://localhost:8080/report/"+reportId+"/attendance", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		var got struct {
+			Attendance []struct {
+				StudentId string  `json:"studentId"`
+				Status    *string `json:"status"`
+			} `json:"attendance"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		statuses := map[string]string{}
+		for _, m := range got.Attendance {
+			if m.Status != nil {
+				statuses[m.StudentId] = *m.Status
+			}
+		}
+		return statuses
+	}
+
+	t.Run("attendance is marked with the report", func(t *testing.T) {
+		res, err := postFormWithCookie("http://localhost:8080/report", claims, url.Values{
+			"timetable_id":  {timetableId},
+			"reported_by":   {teacherId},
+			"topic_covered": {"linear algebra"},
+			"late":          {lateId},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		location := res.Header.Get("Location")
+		reportId = location[strings.LastIndex(location, "/")+1:]
+
+		got := attendance(t)
+		if got[lateId] != "late" || got[sickId] != "excused" || len(got) != 2 {
+			t.Errorf("Got %v, want the late student and the one with an absence excused", got)
+		}
+	})
+
+	t.Run("excused absence reported later excuses the student", func(t *testing.T) {
+		res, err := sendFormWithCookie(http.MethodPut, "http://localhost:8080/report/"+reportId+"/attendance", claims, url.Values{
+			"absent": {lateId},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+		if got := attendance(t); got[lateId] != "absent" {
+			t.Errorf("Got %q, want absent", got[lateId])
+		}
+
+		absence := url.Values{"user_id": {lateId}, "status": {"excused"}}
+		for k, v := range allDay {
+			absence[k] = v
+		}
+		res, err = postFormWithCookie("http://localhost:8080/absence", claims, absence)
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		if got := attendance(t); got[lateId] != "excused" {
+			t.Errorf("Got %q, want excused", got[lateId])
+		}
+	})
+
+	t.Run("student outside of the lesson can't be marked", func(t *testing.T) {
+		res, err := sendFormWithCookie(http.MethodPut, "http://localhost:8080/report/"+reportId+"/attendance", claims, url.Values{
+			"absent": {strangerId},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("unknown report", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/report/-1/attendance", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+}
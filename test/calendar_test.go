@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestCalendar(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	studentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	groupId, err := createGroup(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into users_group (user_id, group_id) values ($1, $2)", studentId, groupId); err != nil {
+		t.Error(err)
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, studentId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	lessonId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_group (timetable_id, group_id) values ($1, $2)", lessonId, groupId); err != nil {
+		t.Error(err)
+	}
+
+	//first two mondays of the current school year
+	year := time.Now().Year()
+	if time.Now().Month() < time.July {
+		year--
+	}
+	monday := time.Date(year, time.September, 1, 0, 0, 0, 0, time.UTC)
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, 1)
+	}
+	nextMonday := monday.AddDate(0, 0, 7)
+
+	substituteId, err := createSubstituteTimetable(conn, periodId, subjectId, roomId, nextMonday.Format(time.DateOnly), schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_group (timetable_id, group_id) values ($1, $2)", substituteId, groupId); err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx,
+		"insert into note_with_date (type, content, timetable_id, date) values ('homework', 'Exercises 1-3', $1, $2)",
+		lessonId, monday.Format(time.DateOnly),
+	); err != nil {
+		t.Error(err)
+	}
+
+	claims, err := createUserJWT(studentId, schoolId, utils.RoleStudent)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var feedUrl string
+	t.Run("token is created", func(t *testing.T) {
+		res, err := postFormWithCookie("http://localhost:8080/calendar/token", claims, url.Values{})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		var body struct {
+			Url string `json:"url"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if !strings.HasSuffix(body.Url, ".ics") {
+			t.Errorf("Got url %q, want .ics feed", body.Url)
+		}
+		feedUrl = "http://localhost:8080" + body.Url
+	})
+
+	t.Run("feed has lessons, substitutions and homework", func(t *testing.T) {
+		res, err := http.Get(feedUrl)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/calendar") {
+			t.Errorf("Got content type %q, want text/calendar", got)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		feed := string(body)
+
+		for _, want := range []string{
+			"BEGIN:VCALENDAR\r\n",
+			fmt.Sprintf("UID:lesson-%s-%s@learnscape\r\n", lessonId, monday.Format("20060102")),
+			fmt.Sprintf("DTSTART:%s\r\n", monday.Format("20060102")+"T080000"),
+			fmt.Sprintf("UID:lesson-%s-%s@learnscape\r\n", substituteId, nextMonday.Format("20060102")),
+			"SUMMARY:Homework: Math\r\n",
+			"DESCRIPTION:Exercises 1-3\r\n",
+		} {
+			if !strings.Contains(feed, want) {
+				t.Errorf("Feed doesn't contain %q", want)
+			}
+		}
+		replaced := fmt.Sprintf("UID:lesson-%s-%s@learnscape", lessonId, nextMonday.Format("20060102"))
+		if strings.Contains(feed, replaced) {
+			t.Errorf("Feed contains %q, which was substituted", replaced)
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		res, err := http.Get("http://localhost:8080/calendar/unknown.ics")
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+}
+
+func TestWriteICalendar(t *testing.T) {
+	var b strings.Builder
+	start := time.Date(2024, time.September, 2, 8, 0, 0, 0, time.UTC)
+	err := utils.WriteICalendar(&b, "test", start, []utils.ICalEvent{{
+		UID:     "lesson-1@learnscape",
+		Start:   start,
+		End:     start.Add(45 * time.Minute),
+		Summary: "Math; algebra, " + strings.Repeat("č", 40),
+	}})
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line %q is longer than 75 octets", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	if want := `SUMMARY:Math\; algebra\, ` + strings.Repeat("č", 40) + "\r\n"; !strings.Contains(unfolded, want) {
+		t.Errorf("Got %q, want it to contain %q", unfolded, want)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestParentPortal(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	parentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	childId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	otherChildId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into parent_child (parent_id, child_id) values ($1, $2)", parentId, childId); err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx,
+		"insert into absence (user_id, span) values ($1, tsrange(now()::date, now()::date + 1)), ($2, tsrange(now()::date, now()::date + 1))",
+		childId, otherChildId,
+	); err != nil {
+		t.Error(err)
+	}
+
+	parent, err := createUserJWT(parentId, schoolId, utils.RoleParent)
+	if err != nil {
+		t.Error(err)
+	}
+	teacher, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
+	t.Run("parent lists linked children", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/child", parent)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var got struct {
+			Children []struct {
+				Id string `json:"id"`
+			} `json:"children"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Children) != 1 || got.Children[0].Id != childId {
+			t.Errorf("Got %+v, want only the linked child", got.Children)
+		}
+	})
+
+	t.Run("absences of the child", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/child/"+childId+"/absence", parent)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		var got struct {
+			Absences []struct {
+				UserId string `json:"userId"`
+			} `json:"absences"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Absences) != 1 || got.Absences[0].UserId != childId {
+			t.Errorf("Got %+v, want the absence of the child", got.Absences)
+		}
+	})
+
+	t.Run("data of children who aren't linked aren't visible", func(t *testing.T) {
+		for _, view := range []string{"timetable", "gradebook", "note", "absence", "report"} {
+			res, err := getWithCookie("http://localhost:8080/child/"+otherChildId+"/"+view, parent)
+			if err != nil {
+				t.Error(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != http.StatusNotFound {
+				t.Errorf("Got %d for %s, want %d", res.StatusCode, view, http.StatusNotFound)
+			}
+		}
+	})
+
+	t.Run("only parents use the portal", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/child", teacher)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusForbidden)
+		}
+	})
+
+	t.Run("parent portal page", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/", parent)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.Request.URL.Path != "/parent" {
+			t.Errorf("Got %s, want parents redirected to /parent", res.Request.URL.Path)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if !strings.Contains(string(body), "/parent?child="+childId) {
+			t.Error("Page doesn't switch to the linked child")
+		}
+		if strings.Contains(string(body), otherChildId) {
+			t.Error("Page shows a child who isn't linked")
+		}
+	})
+}
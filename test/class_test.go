@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -47,7 +48,19 @@ func TestClass(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	teacherId, err := createUser(conn, -1)
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
 	if err != nil {
 		t.Error(err)
 	}
@@ -55,7 +68,7 @@ func TestClass(t *testing.T) {
 	create_url := "http://localhost:8080/class"
 
 	t.Run("can't create class without name", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"year":             {"1"},
 			"class_teacher_id": {teacherId},
 		})
@@ -72,7 +85,7 @@ func TestClass(t *testing.T) {
 	})
 
 	t.Run("can create class", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"name":             {"it{}"},
 			"year":             {"1"},
 			"class_teacher_id": {teacherId},
@@ -88,4 +101,64 @@ func TestClass(t *testing.T) {
 			t.Errorf("Got %d, want %d", got, want)
 		}
 	})
+
+	t.Run("can list classes sorted by name with ones without class teacher", func(t *testing.T) {
+		if _, err := conn.Exec(ctx,
+			"insert into class (name, year, school_id) values ($1, $2, $3)",
+			"a, without teacher", 2, schoolId,
+		); err != nil {
+			t.Error(err)
+		}
+
+		var page struct {
+			Items []struct {
+				Name           string  `json:"name"`
+				ClassTeacherId *string `json:"classTeacherId"`
+			} `json:"items"`
+			NextCursor string `json:"nextCursor"`
+		}
+
+		res, err := getWithCookie(create_url+"?sort=name&limit=1", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			t.Error(err)
+		}
+		if len(page.Items) != 1 || page.Items[0].Name != "a, without teacher" || page.Items[0].ClassTeacherId != nil {
+			t.Errorf("Got %+v, want the class without class teacher first", page.Items)
+		}
+
+		res, err = getWithCookie(create_url+"?sort=name&limit=1&cursor="+page.NextCursor, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			t.Error(err)
+		}
+		if len(page.Items) != 1 || page.Items[0].Name != "it{}" || page.NextCursor != "" {
+			t.Errorf("Got %+v with cursor %q, want the created class last", page.Items, page.NextCursor)
+		}
+	})
+
+	t.Run("can't sort classes by unknown column", func(t *testing.T) {
+		res, err := getWithCookie(create_url+"?sort=class_teacher_id", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusBadRequest
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
 }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+const schoolCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:holiday-1@school\r\n" +
+	"DTSTART;VALUE=DATE:20241028\r\n" +
+	"DTEND;VALUE=DATE:20241031\r\n" +
+	"SUMMARY:Autumn holiday\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:parents-1@school\r\n" +
+	"DTSTART;TZID=Europe/Prague:20241105T170000\r\n" +
+	"DURATION:PT2H\r\n" +
+	"SUMMARY:Parent evening\r\n" +
+	"DESCRIPTION:Bring the\\, report cards\\nand questions\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"DESCRIPTION:Reminder\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:club-1@school\r\n" +
+	"DTSTART:20240904T140000Z\r\n" +
+	"RRULE:FREQ=WEEKLY\r\n" +
+	"SUMMARY:Chess club\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICalendar(t *testing.T) {
+	events, problems, err := utils.ParseICalendar(strings.NewReader(schoolCalendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Got %d events, want 2", len(events))
+	}
+	holiday, evening := events[0], events[1]
+	if !holiday.AllDay || !holiday.End.Equal(time.Date(2024, time.October, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Got holiday %+v, want all day event ending on 2024-10-31", holiday)
+	}
+	if !evening.End.Equal(time.Date(2024, time.November, 5, 19, 0, 0, 0, time.UTC)) {
+		t.Errorf("Got end %s, want start plus the duration", evening.End)
+	}
+	if evening.Description != "Bring the, report cards\nand questions" {
+		t.Errorf("Got description %q, the alarm shouldn't override it", evening.Description)
+	}
+	if len(problems) != 1 || problems[0].UID != "club-1@school" {
+		t.Errorf("Got problems %+v, want the recurring event", problems)
+	}
+
+	t.Run("written calendar is read back", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := utils.WriteICalendar(&b, "test", time.Now(), events); err != nil {
+			t.Error(err)
+		}
+		read, _, err := utils.ParseICalendar(&b)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(read) != len(events) || read[1].Description != evening.Description || !read[1].Start.Equal(evening.Start) {
+			t.Errorf("Got %+v, want %+v", read, events)
+		}
+	})
+
+	t.Run("not a calendar", func(t *testing.T) {
+		if _, _, err := utils.ParseICalendar(strings.NewReader("name,start\n")); err == nil {
+			t.Error("Got no error for a csv file")
+		}
+	})
+}
+
+func TestImportEvents(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	type result struct {
+		Created []struct {
+			UID string `json:"uid"`
+		} `json:"created"`
+		Updated []struct {
+			UID string `json:"uid"`
+		} `json:"updated"`
+		Skipped []struct {
+			UID    string `json:"uid"`
+			Reason string `json:"reason"`
+		} `json:"skipped"`
+	}
+	upload := func(t *testing.T, calendar string) result {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/event_timetable/import", strings.NewReader(calendar))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "text/calendar")
+		req.AddCookie(&claims)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		var got result
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	t.Run("first import creates events", func(t *testing.T) {
+		got := upload(t, schoolCalendar)
+		if len(got.Created) != 2 || len(got.Updated) != 0 || len(got.Skipped) != 1 {
+			t.Errorf("Got %+v, want 2 created and the recurring event skipped", got)
+		}
+
+		var count int
+		if err := conn.QueryRow(ctx, "select count(*) from timetable where school_id = $1 and type = 'event'", schoolId).Scan(&count); err != nil {
+			t.Error(err)
+		}
+		if count != 2 {
+			t.Errorf("Got %d events, want 2", count)
+		}
+	})
+
+	t.Run("reimport only updates changed events", func(t *testing.T) {
+		changed := strings.Replace(schoolCalendar, "SUMMARY:Parent evening", "SUMMARY:Parent-teacher evening", 1)
+		got := upload(t, changed)
+		if len(got.Created) != 0 || len(got.Updated) != 1 || got.Updated[0].UID != "parents-1@school" {
+			t.Errorf("Got %+v, want only the parent evening updated", got)
+		}
+		if len(got.Skipped) != 2 {
+			t.Errorf("Got %d skipped, want the unchanged holiday and the recurring event", len(got.Skipped))
+		}
+
+		var name string
+		if err := conn.QueryRow(ctx,
+			"select et.name from event_timetable et join imported_event ie on ie.event_id = et.id where ie.uid = 'parents-1@school'",
+		).Scan(&name); err != nil {
+			t.Error(err)
+		}
+		if name != "Parent-teacher evening" {
+			t.Errorf("Got name %q, want the updated one", name)
+		}
+	})
+
+	t.Run("long uid is imported and long summary skipped", func(t *testing.T) {
+		longUID := strings.Repeat("u", 300) + "@school"
+		calendar := "BEGIN:VCALENDAR\r\n" +
+			"VERSION:2.0\r\n" +
+			"BEGIN:VEVENT\r\n" +
+			"UID:" + longUID + "\r\n" +
+			"DTSTART;VALUE=DATE:20241223\r\n" +
+			"DTEND;VALUE=DATE:20250103\r\n" +
+			"SUMMARY:Christmas holiday\r\n" +
+			"END:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\n" +
+			"UID:long-summary@school\r\n" +
+			"DTSTART;VALUE=DATE:20250203\r\n" +
+			"DTEND;VALUE=DATE:20250208\r\n" +
+			"SUMMARY:" + strings.Repeat("s", 300) + "\r\n" +
+			"END:VEVENT\r\n" +
+			"END:VCALENDAR\r\n"
+		got := upload(t, calendar)
+		if len(got.Created) != 1 || got.Created[0].UID != longUID {
+			t.Errorf("Got %+v, want the event with the long uid created", got)
+		}
+		if len(got.Skipped) != 1 || got.Skipped[0].UID != "long-summary@school" || got.Skipped[0].Reason == "" {
+			t.Errorf("Got %+v, want the event with the long summary skipped with a reason", got)
+		}
+	})
+}
@@ -57,12 +57,19 @@ func TestEventTimetable(t *testing.T) {
 	start := time.Now().Add(time.Hour * 24).Format(time.RFC3339)
 	end := time.Now().Add(time.Hour * 24).Format(time.RFC3339)
 
-	schoolIdStr := fmt.Sprint(schoolId)
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
 
 	create_url := "http://localhost:8080/event_timetable"
 
 	t.Run("incomplete body returns 400 bad request", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"name": {name},
 		})
 
@@ -79,11 +86,10 @@ func TestEventTimetable(t *testing.T) {
 	})
 
 	t.Run("can create without description", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
-			"name":      {name},
-			"start":     {start},
-			"end":       {end},
-			"school_id": {schoolIdStr},
+		res, err := postFormWithCookie(create_url, claims, url.Values{
+			"name":  {name},
+			"start": {start},
+			"end":   {end},
 		})
 
 		if err != nil {
@@ -99,12 +105,11 @@ func TestEventTimetable(t *testing.T) {
 	})
 
 	t.Run("can create valid event timetable", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"name":        {name},
 			"description": {description},
 			"start":       {start},
 			"end":         {end},
-			"school_id":   {schoolIdStr},
 		})
 
 		if err != nil {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -76,10 +77,19 @@ func TestGrade(t *testing.T) {
 		t.Error(err)
 	}
 
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_url := "http://localhost:8080/grade"
 
 	t.Run("can't create grade without report_id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"student_id": {studentId},
 			"value":      {"1"},
 			"weight":     {"6"},
@@ -96,8 +106,62 @@ func TestGrade(t *testing.T) {
 		}
 	})
 
+	t.Run("student can't create grade", func(t *testing.T) {
+		studentClaims, err := createUserJWT(studentId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, err := postFormWithCookie(create_url, studentClaims, url.Values{
+			"report_id":  {reportId},
+			"student_id": {studentId},
+			"value":      {"1"},
+			"weight":     {"6"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusForbidden
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("can't grade student of other school", func(t *testing.T) {
+		foreignStudentId, err := createUser(conn, -1)
+		if err != nil {
+			t.Error(err)
+		}
+		res, err := postFormWithCookie(create_url, claims, url.Values{
+			"report_id":  {reportId},
+			"student_id": {foreignStudentId},
+			"value":      {"1"},
+			"weight":     {"6"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusBadRequest
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+		var count int
+		if err := conn.QueryRow(context.Background(), "select count(*) from grade where student_id = $1", foreignStudentId).Scan(&count); err != nil {
+			t.Error(err)
+		}
+		if count != 0 {
+			t.Errorf("Got %d grades of the student, want none", count)
+		}
+	})
+
 	t.Run("can create grade", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"report_id":  {reportId},
 			"student_id": {studentId},
 			"value":      {"1"},
@@ -114,4 +178,65 @@ func TestGrade(t *testing.T) {
 			t.Errorf("Got %d, want %d", got, want)
 		}
 	})
+
+	t.Run("can filter grades by student", func(t *testing.T) {
+		otherStudentId, err := createUser(conn, schoolId)
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, err := getWithCookie(create_url+"?student_id="+otherStudentId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var page struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			t.Error(err)
+		}
+
+		got := len(page.Items)
+		want := 0
+		if got != want {
+			t.Errorf("Got %d grades, want %d", got, want)
+		}
+	})
+
+	t.Run("can't delete report with grades", func(t *testing.T) {
+		res, err := deleteWithCookie("http://localhost:8080/report/"+reportId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusConflict
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("invalid grade value can't be updated", func(t *testing.T) {
+		var gradeId string
+		if err := conn.QueryRow(ctx, "select id from grade where report_id=$1 limit 1", reportId).Scan(&gradeId); err != nil {
+			t.Error(err)
+		}
+
+		res, err := sendFormWithCookie(http.MethodPatch, create_url+"/"+gradeId, claims, url.Values{
+			"value": {"42"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusBadRequest
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestGradebook(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	studentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	classmateId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	classId, err := createClass(conn, teacherId)
+	if err != nil {
+		t.Error(err)
+	}
+	var groupId string
+	if err := conn.QueryRow(ctx, `insert into "group" (name, class_id, school_id) values ($1, $2, (select school_id from class where id = $2)) returning id::text`, "whole class", classId).Scan(&groupId); err != nil {
+		t.Error(err)
+	}
+	for _, id := range []string{studentId, classmateId} {
+		if _, err := conn.Exec(ctx, "insert into users_group (user_id, group_id) values ($1, $2)", id, groupId); err != nil {
+			t.Error(err)
+		}
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, teacherId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	timetableId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	reportId, err := createReport(conn, teacherId, timetableId)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, g := range [][2]int{{1, 1}, {3, 2}, {5, 1}} {
+		if _, err := conn.Exec(ctx,
+			"insert into grade (student_id, report_id, value, weight) values ($1, $2, $3, $4)",
+			studentId, reportId, g[0], g[1],
+		); err != nil {
+			t.Error(err)
+		}
+	}
+
+	teacherClaims, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+	classmateClaims, err := createUserJWT(classmateId, schoolId, utils.RoleStudent)
+	if err != nil {
+		t.Error(err)
+	}
+
+	term := fmt.Sprintf("from=%s&to=%s",
+		time.Now().AddDate(0, 0, -1).Format(time.DateOnly),
+		time.Now().AddDate(0, 0, 1).Format(time.DateOnly),
+	)
+
+	t.Run("weighted average, count and trend", func(t *testing.T) {
+		res, err := getWithCookie(fmt.Sprintf("http://localhost:8080/gradebook/student/%s?%s", studentId, term), teacherClaims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		var got struct {
+			Subjects []struct {
+				SubjectId int     `json:"subjectId"`
+				Average   float64 `json:"average"`
+				Count     int     `json:"count"`
+				Trend     float64 `json:"trend"`
+			} `json:"subjects"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Subjects) != 1 {
+			t.Fatalf("Got %d subjects, want 1", len(got.Subjects))
+		}
+		s := got.Subjects[0]
+		if fmt.Sprint(s.SubjectId) != subjectId || s.Average != 3 || s.Count != 3 || s.Trend != 2.67 {
+			t.Errorf("Got %+v, want average 3, count 3 and trend 2.67", s)
+		}
+	})
+
+	t.Run("students can't see grades of classmates", func(t *testing.T) {
+		res, err := getWithCookie(fmt.Sprintf("http://localhost:8080/gradebook/student/%s?%s", studentId, term), classmateClaims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("class grid", func(t *testing.T) {
+		res, err := getWithCookie(fmt.Sprintf("http://localhost:8080/gradebook/class/%s?%s", classId, term), teacherClaims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		var got struct {
+			Subjects []struct {
+				Id int `json:"id"`
+			} `json:"subjects"`
+			Students []struct {
+				Id       string     `json:"id"`
+				Averages []*float64 `json:"averages"`
+			} `json:"students"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Subjects) != 1 || len(got.Students) != 2 {
+			t.Fatalf("Got %+v, want 1 subject and 2 students", got)
+		}
+		for _, s := range got.Students {
+			switch {
+			case s.Id == studentId && (s.Averages[0] == nil || *s.Averages[0] != 3):
+				t.Errorf("Got %v, want average 3 for the graded student", s.Averages)
+			case s.Id == classmateId && s.Averages[0] != nil:
+				t.Errorf("Got %v, want no average for the student without grades", *s.Averages[0])
+			}
+		}
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -47,7 +48,11 @@ func TestGroup(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	teacherId, err := createUser(conn, -1)
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
 	if err != nil {
 		t.Error(err)
 	}
@@ -55,11 +60,19 @@ func TestGroup(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
 
 	create_url := "http://localhost:8080/group"
 
 	t.Run("can't create group without name", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"class_id": {classId},
 		})
 		if err != nil {
@@ -74,8 +87,25 @@ func TestGroup(t *testing.T) {
 		}
 	})
 
+	t.Run("can't create group of unknown class", func(t *testing.T) {
+		res, err := postFormWithCookie(create_url, claims, url.Values{
+			"name":     {"{} P1"},
+			"class_id": {"-1"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusBadRequest
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
 	t.Run("can create group", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"name":     {"{} P1"},
 			"class_id": {classId},
 		})
@@ -90,4 +120,29 @@ func TestGroup(t *testing.T) {
 			t.Errorf("Got %d, want %d", got, want)
 		}
 	})
+
+	t.Run("can list groups without class", func(t *testing.T) {
+		if _, err := conn.Exec(ctx, `insert into "group" (name, school_id) values ($1, $2)`, "a without class", schoolId); err != nil {
+			t.Error(err)
+		}
+
+		res, err := getWithCookie(create_url+"?sort=name", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var page struct {
+			Items []struct {
+				Name    string `json:"name"`
+				ClassId *int   `json:"classId"`
+			} `json:"items"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			t.Error(err)
+		}
+		if len(page.Items) != 2 || page.Items[0].Name != "a without class" || page.Items[0].ClassId != nil {
+			t.Errorf("Got %+v, want the group without class first", page.Items)
+		}
+	})
 }
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestHomeworkSubmission(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	studentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	lazyId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	strangerId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	groupId, err := createGroup(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, id := range []string{studentId, lazyId} {
+		if _, err := conn.Exec(ctx, "insert into users_group (user_id, group_id) values ($1, $2)", id, groupId); err != nil {
+			t.Error(err)
+		}
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, teacherId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	timetableId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_group (timetable_id, group_id) values ($1, $2)", timetableId, groupId); err != nil {
+		t.Error(err)
+	}
+	if _, err := createReport(conn, teacherId, timetableId); err != nil {
+		t.Error(err)
+	}
+	var noteId string
+	if err := conn.QueryRow(ctx,
+		"insert into note_with_date (type, content, timetable_id, date) values ('homework', 'essay', $1, current_date - 1) returning id::text",
+		timetableId,
+	).Scan(&noteId); err != nil {
+		t.Error(err)
+	}
+
+	claimsOf := func(id string, role utils.Role) http.Cookie {
+		claims, err := createUserJWT(id, schoolId, role)
+		if err != nil {
+			t.Error(err)
+		}
+		return claims
+	}
+	teacher := claimsOf(teacherId, utils.RoleTeacher)
+	student := claimsOf(studentId, utils.RoleStudent)
+	stranger := claimsOf(strangerId, utils.RoleStudent)
+
+	submit := func(t *testing.T, cookie http.Cookie) *http.Response {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("content", "my essay")
+		file, err := form.CreateFormFile("file", "essay.txt")
+		if err != nil {
+			t.Error(err)
+		}
+		file.Write([]byte("once upon a time"))
+		form.Close()
+
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/note/"+noteId+"/submission", &body)
+		if err != nil {
+			t.Error(err)
+		}
+		req.AddCookie(&cookie)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+		}
+		return res
+	}
+	type matrixRow struct {
+		StudentId string  `json:"studentId"`
+		Status    *string `json:"status"`
+		Late      bool    `json:"late"`
+	}
+	matrix := func(t *testing.T) map[string]matrixRow {
+		res, err := getWithCookie("http://localhost:8080/note/"+noteId+"/submission", teacher)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var got struct {
+			Submissions []matrixRow `json:"submissions"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		rows := map[string]matrixRow{}
+		for _, row := range got.Submissions {
+			rows[row.StudentId] = row
+		}
+		return rows
+	}
+
+	var submission struct {
+		Id      int    `json:"id"`
+		Late    bool   `json:"late"`
+		GradeId *int   `json:"gradeId"`
+		Status  string `json:"status"`
+		Files   []struct {
+			Id int `json:"id"`
+		} `json:"files"`
+	}
+	t.Run("student submits homework with a file", func(t *testing.T) {
+		res := submit(t, student)
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		if err := json.NewDecoder(res.Body).Decode(&submission); err != nil {
+			t.Error(err)
+		}
+		if !submission.Late {
+			t.Error("Homework was due yesterday, want it late")
+		}
+		if len(submission.Files) != 1 {
+			t.Errorf("Got %d files, want 1", len(submission.Files))
+		}
+	})
+
+	t.Run("students outside the lesson can't submit", func(t *testing.T) {
+		res := submit(t, stranger)
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("teacher marks missing homework", func(t *testing.T) {
+		res, err := sendFormWithCookie(http.MethodPut, "http://localhost:8080/note/"+noteId+"/submission/"+lazyId, teacher, url.Values{
+			"mark": {"missing"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+
+		rows := matrix(t)
+		if len(rows) != 2 {
+			t.Errorf("Got %d students, want 2", len(rows))
+		}
+		if row := rows[studentId]; row.Status == nil || *row.Status != "submitted" || !row.Late {
+			t.Errorf("Got %+v, want a late submission", row)
+		}
+		if row := rows[lazyId]; row.Status == nil || *row.Status != "missing" {
+			t.Errorf("Got %+v, want missing", row)
+		}
+	})
+
+	t.Run("review grades the submission", func(t *testing.T) {
+		res, err := postFormWithCookie(fmt.Sprintf("http://localhost:8080/homework_submission/%d/review", submission.Id), teacher, url.Values{
+			"note":   {"well done"},
+			"value":  {"1"},
+			"weight": {"3"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+
+		res, err = getWithCookie(fmt.Sprintf("http://localhost:8080/homework_submission/%d", submission.Id), student)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+		if err := json.NewDecoder(res.Body).Decode(&submission); err != nil {
+			t.Error(err)
+		}
+		if submission.Status != "reviewed" || submission.GradeId == nil {
+			t.Fatalf("Got %+v, want reviewed with a grade", submission)
+		}
+
+		res, err = getWithCookie(fmt.Sprintf("http://localhost:8080/grade/%d", *submission.GradeId), teacher)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+		var grade struct {
+			StudentId string `json:"studentId"`
+			Value     int    `json:"value"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&grade); err != nil {
+			t.Error(err)
+		}
+		if grade.StudentId != studentId || grade.Value != 1 {
+			t.Errorf("Got %+v, want the student's grade 1", grade)
+		}
+	})
+
+	t.Run("reviewed submission can't be replaced", func(t *testing.T) {
+		res := submit(t, student)
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusConflict {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusConflict)
+		}
+	})
+
+	t.Run("student downloads the file", func(t *testing.T) {
+		res, err := getWithCookie(fmt.Sprintf("http://localhost:8080/homework_submission/%d/file/%d", submission.Id, submission.Files[0].Id), student)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if !strings.Contains(string(body), "once upon a time") {
+			t.Errorf("Got %q, want the uploaded file", body)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestNoteLimit(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	classId, err := createClass(conn, teacherId)
+	if err != nil {
+		t.Error(err)
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, teacherId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	//two lessons of different halves of the class
+	var timetableIds []string
+	for _, name := range []string{"first half", "second half"} {
+		timetableId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+		if err != nil {
+			t.Error(err)
+		}
+		if _, err := conn.Exec(ctx, `
+			with g as (insert into "group" (name, class_id, school_id) values ($1, $2, (select school_id from class where id = $2)) returning id)
+			insert into timetable_group (timetable_id, group_id) select $3, id from g`,
+			name, classId, timetableId,
+		); err != nil {
+			t.Error(err)
+		}
+		timetableIds = append(timetableIds, timetableId)
+	}
+
+	admin, err := createUserJWT(teacherId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+	teacher, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
+	const date = "2024-10-01"
+	setLimit := func(t *testing.T, mode string) {
+		res, err := sendFormWithCookie(http.MethodPut, "http://localhost:8080/note_limit/test", admin, url.Values{
+			"max_per_day": {"1"},
+			"mode":        {mode},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+	}
+	createTest := func(t *testing.T, timetableId string) *http.Response {
+		res, err := postFormWithCookie("http://localhost:8080/note", teacher, url.Values{
+			"timetable_id": {timetableId},
+			"type":         {"test"},
+			"content":      {"fractions"},
+			"date":         {date},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		return res
+	}
+	type clashes struct {
+		Clashes []struct {
+			NoteId int `json:"noteId"`
+		} `json:"clashes"`
+	}
+
+	t.Run("teacher can't set limits", func(t *testing.T) {
+		res, err := sendFormWithCookie(http.MethodPut, "http://localhost:8080/note_limit/test", teacher, url.Values{
+			"max_per_day": {"1"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusForbidden)
+		}
+	})
+
+	t.Run("second test of the class on the day is rejected", func(t *testing.T) {
+		setLimit(t, "reject")
+
+		res := createTest(t, timetableIds[0])
+		res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+
+		res = createTest(t, timetableIds[1])
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusConflict {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusConflict)
+		}
+		var got clashes
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Clashes) != 1 {
+			t.Errorf("Got %+v, want the first test", got.Clashes)
+		}
+	})
+
+	t.Run("warning limit creates the test with the clashes", func(t *testing.T) {
+		setLimit(t, "warn")
+
+		res := createTest(t, timetableIds[1])
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		var got clashes
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Clashes) != 1 {
+			t.Errorf("Got %+v, want the first test", got.Clashes)
+		}
+	})
+
+	t.Run("calendar shows the day over the limit", func(t *testing.T) {
+		res, err := getWithCookie(fmt.Sprintf("http://localhost:8080/note/calendar?from=%s&to=%s&class_id=%s", date, date, classId), teacher)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var got struct {
+			Days []struct {
+				Date  string `json:"date"`
+				Tests []struct {
+					Id int `json:"id"`
+				} `json:"tests"`
+				OverLimit bool `json:"overLimit"`
+			} `json:"days"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Days) != 1 || len(got.Days[0].Tests) != 2 || !got.Days[0].OverLimit {
+			t.Errorf("Got %+v, want one day with two tests over the limit", got.Days)
+		}
+	})
+
+	postNote := func(t *testing.T, timetableId, noteType, date string) (int, int) {
+		res, err := postFormWithCookie("http://localhost:8080/note", teacher, url.Values{
+			"timetable_id": {timetableId},
+			"type":         {noteType},
+			"content":      {"equations"},
+			"date":         {date},
+		})
+		if err != nil {
+			t.Error(err)
+			return 0, 0
+		}
+		defer res.Body.Close()
+		var note struct {
+			Id int `json:"id"`
+		}
+		json.NewDecoder(res.Body).Decode(&note)
+		return res.StatusCode, note.Id
+	}
+	updateNote := func(t *testing.T, id int, values url.Values) int {
+		res, err := sendFormWithCookie(http.MethodPatch, fmt.Sprintf("http://localhost:8080/note/%d", id), teacher, values)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	t.Run("moving a test to a full day is rejected", func(t *testing.T) {
+		setLimit(t, "reject")
+
+		code, id := postNote(t, timetableIds[1], "test", "2024-10-02")
+		if code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d", code, http.StatusCreated)
+		}
+		if code := updateNote(t, id, url.Values{"content": {"other equations"}, "type": {"test"}}); code != http.StatusNoContent {
+			t.Errorf("Got %d, want the test not to clash with itself", code)
+		}
+		if code := updateNote(t, id, url.Values{"date": {date}}); code != http.StatusConflict {
+			t.Errorf("Got %d, want %d", code, http.StatusConflict)
+		}
+	})
+
+	t.Run("changing homework on a full day to a test is rejected", func(t *testing.T) {
+		code, id := postNote(t, timetableIds[1], "homework", date)
+		if code != http.StatusCreated {
+			t.Fatalf("Got %d, want %d", code, http.StatusCreated)
+		}
+		if code := updateNote(t, id, url.Values{"type": {"test"}}); code != http.StatusConflict {
+			t.Errorf("Got %d, want %d", code, http.StatusConflict)
+		}
+	})
+
+	t.Run("tests created at once don't both go over the limit", func(t *testing.T) {
+		var wg sync.WaitGroup
+		codes := make([]int, len(timetableIds))
+		for i, timetableId := range timetableIds {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes[i], _ = postNote(t, timetableId, "test", "2024-10-03")
+			}()
+		}
+		wg.Wait()
+
+		created := 0
+		for _, code := range codes {
+			if code == http.StatusCreated {
+				created++
+			}
+		}
+		if created != 1 {
+			t.Errorf("Got %v, want only one test created", codes)
+		}
+	})
+}
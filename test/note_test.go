@@ -72,13 +72,22 @@ func TestNote(t *testing.T) {
 		t.Error(err)
 	}
 
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_url := "http://localhost:8080/note"
 
 	//date := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
 	date := "2024-05-08"
 
 	t.Run("can't create note without timetable_id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"type":    {"homework"},
 			"content": {"testing note"},
 			"date":    {date},
@@ -98,7 +107,7 @@ func TestNote(t *testing.T) {
 	//NOTE: add more tests later
 
 	t.Run("can create note", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"timetable_id": {timetableId},
 			"type":         {"homework"},
 			"content":      {"testing note"},
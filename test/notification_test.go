@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// smtpSink accepts one email on the listener and sends its data to the channel
+func smtpSink(t *testing.T, l net.Listener, received chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		close(received)
+		return
+	}
+	defer conn.Close()
+
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 sink")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			close(received)
+			return
+		}
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "MAIL":
+			//the envelope sender is a bare address, servers reject display names there
+			sender := strings.TrimSuffix(strings.TrimPrefix(line, "MAIL FROM:<"), ">")
+			if addr, err := mail.ParseAddress(sender); err != nil || addr.Address != sender || line != "MAIL FROM:<"+sender+">" {
+				t.Errorf("Got %q, want MAIL FROM with a bare address", line)
+				c.PrintfLine("501 invalid sender")
+				continue
+			}
+			c.PrintfLine("250 ok")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				t.Error(err)
+			}
+			received <- string(data)
+			c.PrintfLine("250 ok")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestMailer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go smtpSink(t, l, received)
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	mailer, err := utils.NewMailer(utils.MailConfig{
+		Host: "127.0.0.1",
+		Port: portNum,
+		From: "Learnscape <noreply@learnscape.local>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mailer.Enabled() {
+		t.Fatal("Mailer with a host should be enabled")
+	}
+
+	const subject, body = "Nová známka: Čeština", "Nová známka 1 z předmětu Čeština."
+	if err := mailer.Send(context.Background(), "student@test.com", subject, body); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(<-received)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotSubject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Error(err)
+	}
+	if gotSubject != subject {
+		t.Errorf("Got subject %q, want %q", gotSubject, subject)
+	}
+	gotBody, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Error(err)
+	}
+	if strings.TrimSpace(string(gotBody)) != body {
+		t.Errorf("Got body %q, want %q", gotBody, body)
+	}
+	if to := msg.Header.Get("To"); to != "student@test.com" {
+		t.Errorf("Got recipient %q, want student@test.com", to)
+	}
+	if from := msg.Header.Get("From"); from != "Learnscape <noreply@learnscape.local>" {
+		t.Errorf("Got sender %q, want the display name kept in the header", from)
+	}
+
+	t.Run("mailer without a host is disabled", func(t *testing.T) {
+		if mailer, err := utils.NewMailer(utils.MailConfig{}); err != nil || mailer.Enabled() {
+			t.Error("Mailer without a host shouldn't be enabled")
+		}
+	})
+
+	t.Run("unresponsive server is given up on with the context", func(t *testing.T) {
+		silent, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer silent.Close()
+		//the connection is accepted, but the greeting never comes
+		go func() {
+			conn, err := silent.Accept()
+			if err == nil {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}
+		}()
+		_, port, _ := net.SplitHostPort(silent.Addr().String())
+		portNum, _ := strconv.Atoi(port)
+		mailer, err := utils.NewMailer(utils.MailConfig{Host: "127.0.0.1", Port: portNum, From: "noreply@learnscape.local"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := mailer.Send(ctx, "student@test.com", "subject", "body"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Got %v, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Took %v, want to give up with the context", elapsed)
+		}
+	})
+
+	t.Run("invalid sender is rejected", func(t *testing.T) {
+		if _, err := utils.NewMailer(utils.MailConfig{Host: "127.0.0.1", From: "not an address"}); err == nil {
+			t.Error("Got no error, want invalid sender rejected")
+		}
+	})
+}
+
+func TestNotification(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+	//emails are tested against the sink in TestMailer
+	config.App.Mail.Host = ""
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	studentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	parentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into parent_child (parent_id, child_id) values ($1, $2)", parentId, studentId); err != nil {
+		t.Error(err)
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, teacherId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	timetableId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	reportId, err := createReport(conn, teacherId, timetableId)
+	if err != nil {
+		t.Error(err)
+	}
+
+	teacher, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+	student, err := createUserJWT(studentId, schoolId, utils.RoleStudent)
+	if err != nil {
+		t.Error(err)
+	}
+	parent, err := createUserJWT(parentId, schoolId, utils.RoleParent)
+	if err != nil {
+		t.Error(err)
+	}
+
+	createGrade := func(t *testing.T) {
+		res, err := postFormWithCookie("http://localhost:8080/grade", teacher, url.Values{
+			"report_id":  {reportId},
+			"student_id": {studentId},
+			"value":      {"1"},
+			"weight":     {"6"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+	}
+	type notification struct {
+		Id     int     `json:"id"`
+		Kind   string  `json:"kind"`
+		Body   string  `json:"body"`
+		Link   *string `json:"link"`
+		ReadAt *string `json:"readAt"`
+	}
+	inbox := func(t *testing.T, cookie http.Cookie, query string) []notification {
+		res, err := getWithCookie("http://localhost:8080/notification?"+query, cookie)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+		var got struct {
+			Items []notification `json:"items"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		return got.Items
+	}
+	//notifications are delivered by the outbox worker after the request
+	waitForInbox := func(t *testing.T, cookie http.Cookie, want int) []notification {
+		var got []notification
+		for range 50 {
+			if got = inbox(t, cookie, ""); len(got) >= want {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return got
+	}
+
+	t.Run("grade notifies the student and the parent", func(t *testing.T) {
+		createGrade(t)
+
+		got := waitForInbox(t, student, 1)
+		if len(got) != 1 || got[0].Kind != "grade" || got[0].ReadAt != nil {
+			t.Errorf("Got %+v, want one unread grade notification", got)
+		}
+		got = waitForInbox(t, parent, 1)
+		if len(got) != 1 || !strings.HasPrefix(got[0].Body, "test idk: ") ||
+			got[0].Link == nil || *got[0].Link != "/parent?child="+studentId {
+			t.Errorf("Got %+v, want the grade of the child", got)
+		}
+	})
+
+	t.Run("student marks the notification read", func(t *testing.T) {
+		id := inbox(t, student, "")[0].Id
+		res, err := postFormWithCookie(fmt.Sprintf("http://localhost:8080/notification/%d/read", id), student, url.Values{})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+
+		if got := inbox(t, student, "unread=true"); len(got) != 0 {
+			t.Errorf("Got %+v, want no unread notifications", got)
+		}
+		res, err = getWithCookie("http://localhost:8080/notification/unread_count", student)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+		var count struct {
+			Unread int `json:"unread"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&count); err != nil {
+			t.Error(err)
+		}
+		if count.Unread != 0 {
+			t.Errorf("Got %d unread, want 0", count.Unread)
+		}
+	})
+
+	t.Run("parent can't mark notification of the student", func(t *testing.T) {
+		id := inbox(t, student, "")[0].Id
+		res, err := postFormWithCookie(fmt.Sprintf("http://localhost:8080/notification/%d/unread", id), parent, url.Values{})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("turned off channel isn't delivered to", func(t *testing.T) {
+		res, err := sendFormWithCookie(http.MethodPut, "http://localhost:8080/notification/preference", student, url.Values{
+			"kind":    {"grade"},
+			"channel": {"in_app"},
+			"enabled": {"false"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+
+		createGrade(t)
+
+		if got := waitForInbox(t, parent, 2); len(got) != 2 {
+			t.Errorf("Got %+v, want both grades", got)
+		}
+		if got := inbox(t, student, ""); len(got) != 1 {
+			t.Errorf("Got %+v, want only the first grade", got)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/models"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{10, time.Minute},
+		{1000, time.Minute},
+	}
+	for _, c := range cases {
+		if got := utils.Backoff(c.attempts, time.Second, time.Minute); got != c.want {
+			t.Errorf("Got %s after %d attempts, want %s", got, c.attempts, c.want)
+		}
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+	config.Outbox.PollIntervalMs = 100
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	stopped := make(chan struct{})
+	go func() {
+		i.Run(ctx, config)
+		close(stopped)
+	}()
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	studentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	teacher, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
+	//waitForStatus waits until the worker gets the event to the status
+	waitForStatus := func(t *testing.T, id int, want string) {
+		var status string
+		for range 50 {
+			if err := conn.QueryRow(ctx, "select status::text from outbox where id = $1", id).Scan(&status); err != nil {
+				t.Error(err)
+				return
+			}
+			if status == want {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Errorf("Got status %s, want %s", status, want)
+	}
+
+	t.Run("event of unknown topic is dead-lettered", func(t *testing.T) {
+		var id int
+		if err := conn.QueryRow(ctx,
+			"insert into outbox (topic, payload) values ('unknown', '{}') returning id",
+		).Scan(&id); err != nil {
+			t.Error(err)
+		}
+
+		waitForStatus(t, id, "dead")
+
+		var lastError *string
+		if err := conn.QueryRow(ctx, "select last_error from outbox where id = $1", id).Scan(&lastError); err != nil {
+			t.Error(err)
+		}
+		if lastError == nil {
+			t.Error("Got no last error, want why the event is dead")
+		}
+	})
+
+	t.Run("rolled back change leaves no event", func(t *testing.T) {
+		var before int
+		if err := conn.QueryRow(ctx, "select count(*) from outbox").Scan(&before); err != nil {
+			t.Error(err)
+		}
+
+		//the report doesn't exist, so the grade isn't saved
+		res, err := postFormWithCookie("http://localhost:8080/grade", teacher, url.Values{
+			"report_id":  {"999999"},
+			"student_id": {studentId},
+			"value":      {"1"},
+			"weight":     {"6"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusCreated {
+			t.Errorf("Got %d, want the grade to fail", res.StatusCode)
+		}
+
+		var after int
+		if err := conn.QueryRow(ctx, "select count(*) from outbox").Scan(&after); err != nil {
+			t.Error(err)
+		}
+		if after != before {
+			t.Errorf("Got %d events, want %d", after, before)
+		}
+	})
+
+	//stops the application, so it has to be the last one
+	t.Run("blocked handler doesn't keep the worker from stopping", func(t *testing.T) {
+		cancel()
+		select {
+		case <-stopped:
+		case <-time.After(15 * time.Second):
+			t.Fatal("Application didn't stop")
+		}
+
+		pool, err := pgxpool.New(context.Background(), config.DB.GetConnectionUrl())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pool.Close()
+		worker := models.NewOutboxWorker(pool, utils.OutboxConfig{PollIntervalMs: 50, HandlerTimeoutMs: 500})
+		blocked := make(chan struct{})
+		worker.Handle("blocking", func(ctx context.Context, payload json.RawMessage) error {
+			close(blocked)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		var id int
+		if err := conn.QueryRow(context.Background(),
+			"insert into outbox (topic, payload) values ('blocking', '{}') returning id",
+		).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+
+		workerCtx, stopWorker := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			worker.Run(workerCtx)
+			close(done)
+		}()
+		select {
+		case <-blocked:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Handler didn't get the event")
+		}
+		stopWorker()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Worker didn't stop while the handler was blocked")
+		}
+
+		//the interrupted delivery is retried later
+		var status string
+		var lastError *string
+		if err := conn.QueryRow(context.Background(), "select status::text, last_error from outbox where id = $1", id).Scan(&status, &lastError); err != nil {
+			t.Error(err)
+		}
+		if status != "pending" || lastError == nil {
+			t.Errorf("Got %s with error %v, want the event pending with the error of the timeout", status, lastError)
+		}
+	})
+}
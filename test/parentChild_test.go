@@ -47,11 +47,23 @@ func TestParentChild(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	parentId, err := createUser(conn, -1)
+	schoolId, err := createSchool(conn)
 	if err != nil {
 		t.Error(err)
 	}
-	childId, err := createUser(conn, -1)
+	parentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	childId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
 	if err != nil {
 		t.Error(err)
 	}
@@ -59,7 +71,7 @@ func TestParentChild(t *testing.T) {
 	create_url := "http://localhost:8080/parent_child"
 
 	t.Run("can't create parent_child without parent id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"child_id": {childId},
 		})
 		if err != nil {
@@ -75,7 +87,7 @@ func TestParentChild(t *testing.T) {
 	})
 
 	t.Run("can't create parent_child without child id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"parent_id": {parentId},
 		})
 		if err != nil {
@@ -91,7 +103,7 @@ func TestParentChild(t *testing.T) {
 	})
 
 	t.Run("can create parent_child", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"parent_id": {parentId},
 			"child_id":  {childId},
 		})
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+)
+
+func TestParseFormJSON(t *testing.T) {
+	var parsed url.Values
+	handler := utils.WithNegotiation(utils.ParseForm(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+		func(f url.Values, parserCtx context.Context, handlerCtx *context.Context) *utils.ParseError {
+			parsed = f
+			if !f.Has("name") {
+				return utils.NewParserError(nil, "Name not provided")
+			}
+			return nil
+		},
+	))
+
+	newRequest := func(body string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "/room?school_id=1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	t.Run("json object is parsed like form", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newRequest(`{"name": "my room", "weight": 3, "mandatory": true, "ids": [1, 2], "teacher_id": null}`))
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.Code, http.StatusOK)
+		}
+		want := url.Values{
+			"school_id": {"1"},
+			"name":      {"my room"},
+			"weight":    {"3"},
+			"mandatory": {"true"},
+			"ids":       {"1", "2"},
+		}
+		if parsed.Encode() != want.Encode() {
+			t.Errorf("Got %v, want %v", parsed, want)
+		}
+	})
+
+	t.Run("validation errors are returned as json", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newRequest(`{"weight": 3}`))
+
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", res.Code, http.StatusBadRequest)
+		}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if body.Error != "Name not provided" {
+			t.Errorf("Got %q, want %q", body.Error, "Name not provided")
+		}
+	})
+
+	t.Run("nested objects are rejected", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newRequest(`{"name": {"first": "my"}}`))
+
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", res.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("top level arrays are rejected", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newRequest(`[{"name": "my room"}, {"name": "other room"}]`))
+
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", res.Code, http.StatusBadRequest)
+		}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if !strings.Contains(body.Error, "batches aren't supported") {
+			t.Errorf("Got %q, want batch rejection", body.Error)
+		}
+	})
+
+	t.Run("too large body returns 413", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newRequest(`{"name": "`+strings.Repeat("a", 1<<20)+`"}`))
+
+		if res.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Got %d, want %d", res.Code, http.StatusRequestEntityTooLarge)
+		}
+	})
+
+	t.Run("accept header wins over content type", func(t *testing.T) {
+		req := newRequest(`{}`)
+		req.Header.Set("Accept", "text/html, application/json;q=0.5")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if body := res.Body.String(); body != "<p>Name not provided</p>" {
+			t.Errorf("Got %q, want html error", body)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestPassword(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+	config.Outbox.PollIntervalMs = 100
+
+	//reset links are emailed to the sink
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go smtpSink(t, l, received)
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	config.App.Mail.Host = "127.0.0.1"
+	config.App.Mail.Port, _ = strconv.Atoi(port)
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	userId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	var email string
+	if err := conn.QueryRow(context.Background(), "select email from users where id = $1", userId).Scan(&email); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(t *testing.T, endpoint string, data url.Values, cookies ...http.Cookie) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(data.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, cookie := range cookies {
+			req.AddCookie(&cookie)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+	status := func(t *testing.T, endpoint string, cookie http.Cookie) int {
+		res, err := getWithCookie(endpoint, cookie)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	const newPassword = "new password"
+
+	t.Run("unknown email gets the same response without a reset", func(t *testing.T) {
+		res := post(t, "http://localhost:8080/password/forgot", url.Values{"email": {"nobody@test.com"}})
+		if res.StatusCode != http.StatusAccepted {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusAccepted)
+		}
+		var count int
+		if err := conn.QueryRow(context.Background(), "select count(*) from password_reset").Scan(&count); err != nil {
+			t.Error(err)
+		}
+		if count != 0 {
+			t.Errorf("Got %d resets, want none", count)
+		}
+	})
+
+	t.Run("user resets password with the emailed link", func(t *testing.T) {
+		session, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+
+		res := post(t, "http://localhost:8080/password/forgot", url.Values{"email": {email}})
+		if res.StatusCode != http.StatusAccepted {
+			t.Fatalf("Got %d, want %d", res.StatusCode, http.StatusAccepted)
+		}
+
+		var data string
+		select {
+		case data = <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Reset link wasn't emailed")
+		}
+		msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if to := msg.Header.Get("To"); to != email {
+			t.Errorf("Got email to %s, want %s", to, email)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := regexp.MustCompile(`/password/reset\?token=([\w-]+)`).FindSubmatch(body)
+		if match == nil {
+			t.Fatalf("Got %s, want a reset link", body)
+		}
+		token := string(match[1])
+
+		//the worker settles the event right after the email is sent
+		var payload string
+		for range 50 {
+			err := conn.QueryRow(context.Background(),
+				"select payload::text from outbox where topic = 'password_reset' and status = 'delivered'",
+			).Scan(&payload)
+			if err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if payload != "{}" {
+			t.Errorf("Got payload %s, want the token cleared once delivered", payload)
+		}
+
+		if res := post(t, "http://localhost:8080/password/reset", url.Values{"token": {token}, "password": {newPassword}}); res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+		if code := status(t, "http://localhost:8080/session", session); code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want sessions from before the reset revoked", code)
+		}
+		if res := post(t, "http://localhost:8080/password/reset", url.Values{"token": {token}, "password": {"another password"}}); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("Got %d, want used token rejected", res.StatusCode)
+		}
+		if res := post(t, "http://localhost:8080/login", url.Values{"email": {email}, "password": {newPassword}}); res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want login with the new password", res.StatusCode)
+		}
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		hash := sha256.Sum256([]byte("expired"))
+		_, err := conn.Exec(context.Background(),
+			"insert into password_reset (token_hash, user_id, expires_at) values ($1, $2, now() - interval '1 minute')",
+			hex.EncodeToString(hash[:]), userId,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := post(t, "http://localhost:8080/password/reset", url.Values{"token": {"expired"}, "password": {"another password"}}); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("user changes password knowing the old one", func(t *testing.T) {
+		current, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+		other, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+
+		wrong := url.Values{"old_password": {"wrong password"}, "password": {"changed password"}}
+		if res := post(t, "http://localhost:8080/password/change", wrong, current); res.StatusCode != http.StatusForbidden {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusForbidden)
+		}
+
+		change := url.Values{"old_password": {newPassword}, "password": {"changed password"}}
+		if res := post(t, "http://localhost:8080/password/change", change, current); res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+		if code := status(t, "http://localhost:8080/session", other); code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want other sessions revoked", code)
+		}
+		if code := status(t, "http://localhost:8080/session", current); code != http.StatusOK {
+			t.Errorf("Got %d, want current session kept", code)
+		}
+		if res := post(t, "http://localhost:8080/login", url.Values{"email": {email}, "password": {"changed password"}}); res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want login with the changed password", res.StatusCode)
+		}
+	})
+}
@@ -58,7 +58,7 @@ func TestPeriod(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	claims, err := createUserJWT(userId, schoolId)
+	claims, err := createUserJWT(userId, schoolId, utils.RoleAdmin)
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dr0th3r/learnscape/internal/utils"
+)
+
+func TestWithRoles(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := utils.WithRoles(ok, utils.RoleAdmin, utils.RoleTeacher)
+
+	newRequest := func(role utils.Role) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "/grade", nil)
+		claims := &utils.UserClaims{Id: "idk", SchoolId: 1, Role: role}
+		return req.WithContext(context.WithValue(req.Context(), "claims", claims))
+	}
+
+	t.Run("allowed role passes", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newRequest(utils.RoleTeacher))
+
+		got := res.Result().StatusCode
+		want := http.StatusOK
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("other role is forbidden", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, newRequest(utils.RoleStudent))
+
+		got := res.Result().StatusCode
+		want := http.StatusForbidden
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("missing claims is an error", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/grade", nil)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		got := res.Result().StatusCode
+		want := http.StatusInternalServerError
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	i "github.com/dr0th3r/learnscape/internal"
@@ -67,10 +69,19 @@ func TestRegularTimetable(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_url := "http://localhost:8080/regular_timetable"
 
 	t.Run("incomplete body returns 400 bad request", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"weekday": {"1"},
 		})
 
@@ -87,10 +98,9 @@ func TestRegularTimetable(t *testing.T) {
 	})
 
 	t.Run("ids must be numbers", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"period_id":  {"1"},
 			"subject_id": {"1"},
-			"school_id":  {"1"},
 			"room_id":    {"random"},
 			"weekday":    {"1"},
 		})
@@ -108,11 +118,10 @@ func TestRegularTimetable(t *testing.T) {
 	})
 
 	t.Run("invalid weekday returns 400 bad request", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"period_id":  {"1"},
 			"subject_id": {"1"},
 			"room_id":    {"1"},
-			"school_id":  {"1"},
 			"weekday":    {"random"},
 		})
 
@@ -129,11 +138,10 @@ func TestRegularTimetable(t *testing.T) {
 	})
 
 	t.Run("can create valid regular timetable", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"period_id":  {periodId},
 			"subject_id": {subjectId},
 			"room_id":    {roomId},
-			"school_id":  {fmt.Sprint(schoolId)},
 			"weekday":    {"1"},
 		})
 
@@ -148,4 +156,137 @@ func TestRegularTimetable(t *testing.T) {
 			t.Errorf("Got %d, want %d", got, want)
 		}
 	})
+
+	t.Run("lesson is created in the school of the admin", func(t *testing.T) {
+		otherSchoolId, err := createSchool(conn)
+		if err != nil {
+			t.Error(err)
+		}
+		res, err := postFormWithCookie(create_url, claims, url.Values{
+			"period_id":  {periodId},
+			"subject_id": {subjectId},
+			"room_id":    {roomId},
+			"school_id":  {fmt.Sprint(otherSchoolId)},
+			"weekday":    {"3"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var timetable struct {
+			Id int `json:"id"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&timetable); err != nil {
+			t.Error(err)
+		}
+		var gotSchoolId int
+		if err := conn.QueryRow(context.Background(), "select school_id from timetable where id = $1", timetable.Id).Scan(&gotSchoolId); err != nil {
+			t.Error(err)
+		}
+		if gotSchoolId != schoolId {
+			t.Errorf("Got lesson in school %d, want %d", gotSchoolId, schoolId)
+		}
+	})
+
+	t.Run("lesson in occupied room returns 409 with conflicts", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, create_url, strings.NewReader(url.Values{
+			"period_id":  {periodId},
+			"subject_id": {subjectId},
+			"room_id":    {roomId},
+			"weekday":    {"1"},
+		}.Encode()))
+		if err != nil {
+			t.Error(err)
+		}
+		req.AddCookie(&claims)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusConflict {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusConflict)
+		}
+		var body struct {
+			Conflicts []struct {
+				Reason string `json:"reason"`
+			} `json:"conflicts"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if len(body.Conflicts) != 1 || body.Conflicts[0].Reason != "room" {
+			t.Errorf("Got %+v, want one room conflict", body.Conflicts)
+		}
+	})
+
+	t.Run("conflicts of existing data are reported", func(t *testing.T) {
+		//inserted directly so it skips the validation
+		if _, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId); err != nil {
+			t.Error(err)
+		}
+
+		res, err := getWithCookie("http://localhost:8080/timetable/conflicts", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var body struct {
+			Conflicts []struct {
+				Reason string `json:"reason"`
+			} `json:"conflicts"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		if len(body.Conflicts) != 1 || body.Conflicts[0].Reason != "room" {
+			t.Errorf("Got %+v, want one room conflict", body.Conflicts)
+		}
+	})
+
+	t.Run("can't delete lesson with dated notes", func(t *testing.T) {
+		timetableId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+		if err != nil {
+			t.Error(err)
+		}
+		if _, err := conn.Exec(context.Background(),
+			"insert into note_with_date (type, content, timetable_id, date) values ('test', 'test', $1, '2024-09-02')", timetableId,
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := deleteWithCookie(create_url+"/"+timetableId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusConflict {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusConflict)
+		}
+		var count int
+		if err := conn.QueryRow(context.Background(), "select count(*) from timetable where id = $1", timetableId).Scan(&count); err != nil {
+			t.Error(err)
+		}
+		if count != 1 {
+			t.Error("Lesson with notes was deleted")
+		}
+
+		if _, err := conn.Exec(context.Background(), "delete from note_with_date where timetable_id = $1", timetableId); err != nil {
+			t.Fatal(err)
+		}
+		res, err = deleteWithCookie(create_url+"/"+timetableId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+	})
 }
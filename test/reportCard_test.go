@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestWritePDF(t *testing.T) {
+	pdf := &utils.PDF{}
+	pdf.AddPage().Text(60, 70, 12, true, "Vysvědčení (Žluťoučký kůň)")
+	pdf.AddPage().Line(60, 80, 200, 80)
+
+	var b bytes.Buffer
+	if _, err := pdf.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	doc := b.String()
+
+	if !strings.HasPrefix(doc, "%PDF-1.4\n") || !strings.HasSuffix(doc, "%%EOF\n") {
+		t.Errorf("Got %q, want a PDF document", doc)
+	}
+	if !strings.Contains(doc, "/Count 2") {
+		t.Error("Document doesn't have 2 pages")
+	}
+	if !strings.Contains(doc, "(Vysv\x85d\x81en\xed \\(\x90lu\x8dou\x81k\xfd k\x8f\x87\\)) Tj") {
+		t.Error("Czech characters or parentheses aren't encoded")
+	}
+
+	//every entry of the cross-reference table points to its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(doc)
+	if startxref == nil {
+		t.Fatal("Missing startxref")
+	}
+	xref, _ := strconv.Atoi(startxref[1])
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(doc[xref:], -1)
+	if len(entries) != 9 {
+		t.Fatalf("Got %d objects, want 9", len(entries))
+	}
+	for n, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj", n+1); !strings.HasPrefix(doc[offset:], want) {
+			t.Errorf("Offset %d doesn't point to %q", offset, want)
+		}
+	}
+}
+
+func TestReportCards(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	studentId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	classmateId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	classId, err := createClass(conn, teacherId)
+	if err != nil {
+		t.Error(err)
+	}
+	var groupId string
+	if err := conn.QueryRow(ctx, `insert into "group" (name, class_id, school_id) values ($1, $2, (select school_id from class where id = $2)) returning id::text`, "whole class", classId).Scan(&groupId); err != nil {
+		t.Error(err)
+	}
+	for _, id := range []string{studentId, classmateId} {
+		if _, err := conn.Exec(ctx, "insert into users_group (user_id, group_id) values ($1, $2)", id, groupId); err != nil {
+			t.Error(err)
+		}
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, teacherId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	timetableId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_group (timetable_id, group_id) values ($1, $2)", timetableId, groupId); err != nil {
+		t.Error(err)
+	}
+	var termId string
+	if err := conn.QueryRow(ctx, `
+		with year as (
+			insert into academic_year (school_id, name, span) values ($1, '2024/25', '[2024-09-01, 2025-06-30]') returning id
+		)
+		insert into term (academic_year_id, name, span) select id, 'first semester', '[2024-09-01, 2025-01-31]' from year
+		returning id::text`,
+		schoolId,
+	).Scan(&termId); err != nil {
+		t.Error(err)
+	}
+
+	claims, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
+	t.Run("final grade is created", func(t *testing.T) {
+		res, err := postFormWithCookie("http://localhost:8080/final_grade", claims, url.Values{
+			"student_id": {studentId}, "term_id": {termId}, "subject_id": {subjectId}, "value": {"1"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+	})
+
+	endpoint := fmt.Sprintf("http://localhost:8080/report_card/class/%s?term_id=%s", classId, termId)
+
+	t.Run("results of the class", func(t *testing.T) {
+		res, err := getWithCookie(endpoint+"&format=json", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		var got struct {
+			ReportCards []struct {
+				StudentId string `json:"studentId"`
+				Result    string `json:"result"`
+				Subjects  []struct {
+					Grade *int `json:"grade"`
+				} `json:"subjects"`
+			} `json:"reportCards"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.ReportCards) != 2 {
+			t.Fatalf("Got %d report cards, want 2", len(got.ReportCards))
+		}
+		for _, c := range got.ReportCards {
+			if len(c.Subjects) != 1 {
+				t.Errorf("Got %d subjects, want the attended one", len(c.Subjects))
+			}
+			switch {
+			case c.StudentId == studentId && c.Result != "passed_with_distinction":
+				t.Errorf("Got %q, want passed with distinction", c.Result)
+			case c.StudentId == classmateId && c.Result != "not_graded":
+				t.Errorf("Got %q, want not graded for the student without the final grade", c.Result)
+			}
+		}
+	})
+
+	t.Run("batch pdf", func(t *testing.T) {
+		res, err := getWithCookie(endpoint+"&format=pdf", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		if got := res.Header.Get("Content-Type"); got != "application/pdf" {
+			t.Errorf("Got content type %q, want application/pdf", got)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if !bytes.HasPrefix(body, []byte("%PDF-")) || !bytes.Contains(body, []byte("/Count 2")) {
+			t.Error("Want a PDF with a page for every student")
+		}
+	})
+
+	t.Run("html of a single student", func(t *testing.T) {
+		res, err := getWithCookie(endpoint+"&student_id="+studentId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if got := strings.Count(string(body), `class="card"`); got != 1 {
+			t.Errorf("Got %d report cards, want 1", got)
+		}
+		if !strings.Contains(string(body), "výborný") {
+			t.Error("Report card doesn't contain the final grade")
+		}
+	})
+
+	t.Run("unknown term", func(t *testing.T) {
+		res, err := getWithCookie(fmt.Sprintf("http://localhost:8080/report_card/class/%s?term_id=-1", classId), claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+}
@@ -73,10 +73,15 @@ func TestRegularReport(t *testing.T) {
 		t.Error(err)
 	}
 
+	claims, err := createUserJWT(teacherId, schoolId, utils.RoleTeacher)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_url := "http://localhost:8080/report"
 
 	t.Run("incomplete body returns 400 bad request", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"topic_covered": {"linear algebra"},
 		})
 
@@ -93,7 +98,7 @@ func TestRegularReport(t *testing.T) {
 	})
 
 	t.Run("regular_timetable_id must be numbers", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"regular_timetable_id": {"idk"},
 			"topic_covered":        {"linear algebra"},
 		})
@@ -111,7 +116,7 @@ func TestRegularReport(t *testing.T) {
 	})
 
 	t.Run("can create valid regular report", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"timetable_id":  {timetableId},
 			"reported_by":   {teacherId},
 			"topic_covered": {"linear algebra"},
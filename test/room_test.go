@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	i "github.com/dr0th3r/learnscape/internal"
 	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -56,11 +60,19 @@ func TestRoom(t *testing.T) {
 		t.Error(err)
 	}
 
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_room_url := "http://localhost:8080/room"
 
 	t.Run("can't create room with invalid body", func(t *testing.T) {
-		res, err := http.PostForm(create_room_url, url.Values{
-			"school_id":  {fmt.Sprint(schoolId)},
+		res, err := postFormWithCookie(create_room_url, claims, url.Values{
 			"teacher_id": {teacher_id},
 			//name is missing
 		})
@@ -77,8 +89,7 @@ func TestRoom(t *testing.T) {
 	})
 
 	t.Run("can create valid room", func(t *testing.T) {
-		res, err := http.PostForm(create_room_url, url.Values{
-			"school_id":  {fmt.Sprint(schoolId)},
+		res, err := postFormWithCookie(create_room_url, claims, url.Values{
 			"teacher_id": {teacher_id},
 			"name":       {"my room"},
 		})
@@ -92,5 +103,239 @@ func TestRoom(t *testing.T) {
 		if got != want {
 			t.Errorf("Got %d, want %d", got, want)
 		}
+
+		var room struct {
+			Id   int    `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&room); err != nil {
+			t.Error(err)
+		}
+		if room.Name != "my room" {
+			t.Errorf("Got %s, want %s", room.Name, "my room")
+		}
+		if location := res.Header.Get("Location"); location != fmt.Sprintf("/room/%d", room.Id) {
+			t.Errorf("Got location %s, want /room/%d", location, room.Id)
+		}
+	})
+
+	t.Run("can't create room of unknown teacher", func(t *testing.T) {
+		res, err := postFormWithCookie(create_room_url, claims, url.Values{
+			"teacher_id": {uuid.NewString()},
+			"name":       {"room without teacher"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusBadRequest
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("room is created in the school of the admin", func(t *testing.T) {
+		otherSchoolId, err := createSchool(conn)
+		if err != nil {
+			t.Error(err)
+		}
+		res, err := postFormWithCookie(create_room_url, claims, url.Values{
+			"school_id":  {fmt.Sprint(otherSchoolId)},
+			"teacher_id": {teacher_id},
+			"name":       {"other room"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var room struct {
+			Id int `json:"id"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&room); err != nil {
+			t.Error(err)
+		}
+		var gotSchoolId int
+		if err := conn.QueryRow(context.Background(), "select school_id from room where id = $1", room.Id).Scan(&gotSchoolId); err != nil {
+			t.Error(err)
+		}
+		if gotSchoolId != schoolId {
+			t.Errorf("Got room in school %d, want %d", gotSchoolId, schoolId)
+		}
+	})
+
+	t.Run("htmx gets html fragment of created room", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, create_room_url, strings.NewReader(url.Values{
+			"teacher_id": {teacher_id},
+			"name":       {"htmx room"},
+		}.Encode()))
+		if err != nil {
+			t.Error(err)
+		}
+		req.AddCookie(&claims)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		if contentType := res.Header.Get("Content-Type"); contentType != "text/html" {
+			t.Errorf("Got content type %s, want text/html", contentType)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if !strings.Contains(string(body), "htmx room") {
+			t.Errorf("Fragment %q doesn't contain room name", body)
+		}
+	})
+
+	t.Run("can list rooms page by page", func(t *testing.T) {
+		//two rooms were already created by the previous tests
+		if _, err := createRoom(conn, teacher_id, schoolId); err != nil {
+			t.Error(err)
+		}
+
+		var page struct {
+			Items []struct {
+				Id   int    `json:"id"`
+				Name string `json:"name"`
+			} `json:"items"`
+			NextCursor string `json:"nextCursor"`
+		}
+
+		res, err := getWithCookie(create_room_url+"?limit=2", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			t.Error(err)
+		}
+		if len(page.Items) != 2 || page.NextCursor == "" {
+			t.Errorf("Got %d rooms with cursor %q, want 2 rooms with cursor", len(page.Items), page.NextCursor)
+		}
+
+		res, err = getWithCookie(create_room_url+"?limit=2&cursor="+page.NextCursor, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			t.Error(err)
+		}
+		if len(page.Items) != 1 || page.NextCursor != "" {
+			t.Errorf("Got %d rooms with cursor %q, want 1 room without cursor", len(page.Items), page.NextCursor)
+		}
+	})
+
+	t.Run("can't read room of other school", func(t *testing.T) {
+		otherSchoolId, err := createSchool(conn)
+		if err != nil {
+			t.Error(err)
+		}
+		otherRoomId, err := createRoom(conn, teacher_id, otherSchoolId)
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, err := getWithCookie(create_room_url+"/"+otherRoomId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusNotFound
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("update room", func(t *testing.T) {
+		roomId, err := createRoom(conn, teacher_id, schoolId)
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, err := sendFormWithCookie(http.MethodPatch, create_room_url+"/"+roomId, claims, url.Values{
+			"name": {"renamed room"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+
+		var name string
+		if err := conn.QueryRow(ctx, "select name from room where id=$1", roomId).Scan(&name); err != nil {
+			t.Error(err)
+		}
+		if name != "renamed room" {
+			t.Errorf("Got %s, want %s", name, "renamed room")
+		}
+	})
+
+	t.Run("put requires all fields", func(t *testing.T) {
+		roomId, err := createRoom(conn, teacher_id, schoolId)
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, err := sendFormWithCookie(http.MethodPut, create_room_url+"/"+roomId, claims, url.Values{
+			"teacher_id": {teacher_id},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("delete room", func(t *testing.T) {
+		roomId, err := createRoom(conn, teacher_id, schoolId)
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, err := deleteWithCookie(create_room_url+"/"+roomId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+
+		res, err = deleteWithCookie(create_room_url+"/"+roomId, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
 	})
 }
@@ -87,7 +87,7 @@ func TestSchool(t *testing.T) {
 		gotCookies := res.Cookies()
 
 		gotCookiesLen := len(gotCookies)
-		wantCookiesLen := 1
+		wantCookiesLen := 2
 		if gotCookiesLen != wantCookiesLen {
 			t.Errorf("Got %d cookies, wanted %d", gotCookiesLen, wantCookiesLen)
 		}
@@ -95,5 +95,8 @@ func TestSchool(t *testing.T) {
 		if gotCookies[0].Name != "token" || !gotCookies[0].HttpOnly || gotCookies[0].SameSite != http.SameSiteStrictMode {
 			t.Errorf("Got %s invalid cookie", gotCookies[0])
 		}
+		if gotCookies[1].Name != "refresh_token" || !gotCookies[1].HttpOnly || gotCookies[1].SameSite != http.SameSiteStrictMode {
+			t.Errorf("Got %s invalid cookie", gotCookies[1])
+		}
 	})
 }
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeRedis answers the commands the session store sends, keys don't expire
+// and the only script it runs is the one rotating refresh tokens
+func fakeRedis(t *testing.T) utils.RedisConfig {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var mu sync.Mutex
+	values := map[string]string{}
+	sets := map[string]map[string]bool{}
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, n)
+			for i := range args {
+				line, _ := r.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+				data := make([]byte, size+2)
+				io.ReadFull(r, data)
+				args[i] = string(data[:size])
+			}
+
+			mu.Lock()
+			var reply string
+			switch strings.ToUpper(args[0]) {
+			case "PING", "SET":
+				if len(args) > 2 {
+					values[args[1]] = args[2]
+				}
+				reply = "+OK\r\n"
+			case "GET":
+				if v, ok := values[args[1]]; ok {
+					reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+				} else {
+					reply = "$-1\r\n"
+				}
+			case "DEL":
+				delete(values, args[1])
+				reply = ":1\r\n"
+			case "SADD":
+				if sets[args[1]] == nil {
+					sets[args[1]] = map[string]bool{}
+				}
+				sets[args[1]][args[2]] = true
+				reply = ":1\r\n"
+			case "SREM":
+				delete(sets[args[1]], args[2])
+				reply = ":1\r\n"
+			case "SMEMBERS":
+				reply = fmt.Sprintf("*%d\r\n", len(sets[args[1]]))
+				for m := range sets[args[1]] {
+					reply += fmt.Sprintf("$%d\r\n%s\r\n", len(m), m)
+				}
+			case "PEXPIRE":
+				reply = ":1\r\n"
+			case "EVALSHA":
+				//scripts aren't cached, so the client falls back to EVAL
+				reply = "-NOSCRIPT No matching script\r\n"
+			case "EVAL":
+				key, previousHash, session := args[3], args[4], args[5]
+				var current struct {
+					RefreshHash string `json:"refreshHash"`
+				}
+				if v, ok := values[key]; !ok {
+					reply = ":-1\r\n"
+				} else if json.Unmarshal([]byte(v), &current); current.RefreshHash != previousHash {
+					reply = ":0\r\n"
+				} else {
+					values[key] = session
+					reply = ":1\r\n"
+				}
+			default:
+				reply = "-ERR unknown command\r\n"
+			}
+			mu.Unlock()
+			conn.Write([]byte(reply))
+		}
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return utils.RedisConfig{Host: host, Port: portNum}
+}
+
+func testSessionStore(t *testing.T, store utils.SessionStore) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	sessions := []utils.Session{
+		{Id: "first", UserId: "user", CreatedAt: now, ExpiresAt: now.Add(time.Hour), RefreshHash: "hash"},
+		{Id: "second", UserId: "user", CreatedAt: now.Add(time.Minute), ExpiresAt: now.Add(time.Hour)},
+		{Id: "other", UserId: "other user", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+	}
+	for _, s := range sessions {
+		if err := store.Create(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s, err := store.Get(ctx, "first"); err != nil || s.UserId != "user" {
+		t.Errorf("Got %+v (%v), want the first session", s, err)
+	}
+	list, err := store.List(ctx, "user")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(list) != 2 || list[0].Id != "first" || list[1].Id != "second" {
+		t.Errorf("Got %+v, want sessions of the user oldest first", list)
+	}
+
+	rotated := sessions[0]
+	rotated.RefreshHash, rotated.PreviousRefreshHash = "new hash", "hash"
+	if err := store.Rotate(ctx, rotated, "hash"); err != nil {
+		t.Error(err)
+	}
+	if s, _ := store.Get(ctx, "first"); s.RefreshHash != "new hash" {
+		t.Errorf("Got %+v, want the session rotated", s)
+	}
+	if err := store.Rotate(ctx, rotated, "hash"); err == nil {
+		t.Error("Got no error, want rotation from a stale hash rejected")
+	}
+
+	if err := store.Delete(ctx, "first"); err != nil {
+		t.Error(err)
+	}
+	if _, err := store.Get(ctx, "first"); err != utils.ErrSessionNotFound {
+		t.Errorf("Got %v, want deleted session not to be found", err)
+	}
+
+	if err := store.DeleteForUser(ctx, "other user", ""); err != nil {
+		t.Error(err)
+	}
+	if list, _ := store.List(ctx, "other user"); len(list) != 0 {
+		t.Errorf("Got %+v, want every session of the user deleted", list)
+	}
+	if list, _ := store.List(ctx, "user"); len(list) != 1 {
+		t.Errorf("Got %+v, want sessions of other users kept", list)
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		store := utils.NewMemorySessionStore()
+		testSessionStore(t, store)
+
+		now := time.Now()
+		store.Create(context.Background(), utils.Session{Id: "expired", UserId: "user", CreatedAt: now.Add(-time.Hour), ExpiresAt: now})
+		if _, err := store.Get(context.Background(), "expired"); err != utils.ErrSessionNotFound {
+			t.Errorf("Got %v, want expired session not to be found", err)
+		}
+	})
+	t.Run("redis", func(t *testing.T) {
+		client := utils.NewRedisClient(fakeRedis(t))
+		defer client.Close()
+		testSessionStore(t, utils.NewRedisSessionStore(client))
+	})
+}
+
+func TestRefreshSession(t *testing.T) {
+	ctx := context.Background()
+	claims := utils.UserClaims{Id: "user", Role: utils.RoleStudent}
+
+	t.Run("refresh rotates the token and extends the session", func(t *testing.T) {
+		session, first, err := utils.CreateSession(ctx, claims, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		refreshed, second, err := utils.RefreshSession(ctx, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if second == "" || second == first || refreshed.Id != session.Id || refreshed.Claims.Id != "user" {
+			t.Errorf("Got %+v with %q, want the session with a new refresh token", refreshed, second)
+		}
+		if refreshed.ExpiresAt.Before(session.ExpiresAt) {
+			t.Errorf("Got %v, want expiration extended from %v", refreshed.ExpiresAt, session.ExpiresAt)
+		}
+
+		//a concurrent request which refreshed with the previous token gets access, but no refresh token
+		if _, token, err := utils.RefreshSession(ctx, first); err != nil || token != "" {
+			t.Errorf("Got %q (%v), want previous token accepted within the grace", token, err)
+		}
+		if _, _, err := utils.RefreshSession(ctx, second); err != nil {
+			t.Errorf("Got %v, want the new token accepted", err)
+		}
+	})
+
+	t.Run("reusing a rotated token revokes the session", func(t *testing.T) {
+		session, first, err := utils.CreateSession(ctx, claims, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, second, err := utils.RefreshSession(ctx, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := utils.RefreshSession(ctx, second); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := utils.RefreshSession(ctx, first); err != utils.ErrRefreshTokenReused {
+			t.Errorf("Got %v, want %v", err, utils.ErrRefreshTokenReused)
+		}
+		if _, _, err := utils.RefreshSession(ctx, second); err != utils.ErrSessionNotFound {
+			t.Errorf("Got %v, want every token of the session revoked", err)
+		}
+		if sessions, _ := utils.ListSessions(ctx, "user"); slices.ContainsFunc(sessions, func(s utils.Session) bool {
+			return s.Id == session.Id
+		}) {
+			t.Error("Got the session listed, want it revoked")
+		}
+	})
+
+	t.Run("invalid token is rejected", func(t *testing.T) {
+		if _, _, err := utils.RefreshSession(ctx, "invalid"); err != utils.ErrInvalidRefreshToken {
+			t.Errorf("Got %v, want %v", err, utils.ErrInvalidRefreshToken)
+		}
+	})
+}
+
+func TestWithAuth(t *testing.T) {
+	handler := utils.WithAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("garbage token is unauthenticated and cleared", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/session", nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: "garbage"})
+		req.Header.Set("HX-Request", "true")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != http.StatusUnauthorized || res.Header().Get("HX-Redirect") != "/login" {
+			t.Errorf("Got %d redirected to %q, want %d redirected to login", res.Code, res.Header().Get("HX-Redirect"), http.StatusUnauthorized)
+		}
+		cleared := slices.ContainsFunc(res.Result().Cookies(), func(c *http.Cookie) bool {
+			return c.Name == "token" && c.MaxAge < 0
+		})
+		if !cleared {
+			t.Errorf("Got %v, want the token cleared", res.Result().Cookies())
+		}
+	})
+
+	t.Run("refresh token recovers the session of a token signed with another secret", func(t *testing.T) {
+		session, refreshToken, err := utils.CreateSession(context.Background(), utils.UserClaims{Id: "user", Role: utils.RoleStudent}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := session.Claims
+		claims.ID = session.Id
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("previous secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := &http.Cookie{Name: "token", Value: signed}
+
+		req := httptest.NewRequest(http.MethodGet, "/session", nil)
+		req.AddCookie(token)
+		req.AddCookie(utils.RefreshTokenCookie(refreshToken, session.ExpiresAt))
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.Code, http.StatusOK)
+		}
+		reissued := slices.ContainsFunc(res.Result().Cookies(), func(c *http.Cookie) bool {
+			return c.Name == "token" && c.Value != token.Value && c.MaxAge >= 0
+		})
+		if !reissued {
+			t.Errorf("Got %v, want a new token", res.Result().Cookies())
+		}
+	})
+}
+
+func TestSessions(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	userId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	admin, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	send := func(t *testing.T, method, endpoint string, cookies ...http.Cookie) *http.Response {
+		req, err := http.NewRequest(method, endpoint, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(&cookie)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+	status := func(t *testing.T, method, endpoint string, cookie http.Cookie) int {
+		return send(t, method, endpoint, cookie).StatusCode
+	}
+	cookie := func(res *http.Response, name string) *http.Cookie {
+		for _, c := range res.Cookies() {
+			if c.Name == name {
+				return c
+			}
+		}
+		return nil
+	}
+
+	t.Run("user lists and revokes own sessions", func(t *testing.T) {
+		current, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+		other, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+
+		res, err := getWithCookie("http://localhost:8080/session", current)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+		var got struct {
+			Sessions []struct {
+				Id      string `json:"id"`
+				Current bool   `json:"current"`
+			} `json:"sessions"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Sessions) != 2 || !got.Sessions[0].Current || got.Sessions[1].Current {
+			t.Fatalf("Got %+v, want both sessions with the first current", got.Sessions)
+		}
+
+		if code := status(t, http.MethodDelete, "http://localhost:8080/session/"+got.Sessions[1].Id, current); code != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", code, http.StatusNoContent)
+		}
+		if code := status(t, http.MethodGet, "http://localhost:8080/session", other); code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want revoked token rejected", code)
+		}
+		if code := status(t, http.MethodGet, "http://localhost:8080/session", current); code != http.StatusOK {
+			t.Errorf("Got %d, want current session kept", code)
+		}
+	})
+
+	t.Run("user can't revoke session of another user", func(t *testing.T) {
+		user, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+		sessions, err := utils.ListSessions(context.Background(), adminId)
+		if err != nil || len(sessions) == 0 {
+			t.Fatal(err)
+		}
+		if code := status(t, http.MethodDelete, "http://localhost:8080/session/"+sessions[0].Id, user); code != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("logout revokes the session", func(t *testing.T) {
+		user, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+		if code := status(t, http.MethodPost, "http://localhost:8080/logout", user); code != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", code, http.StatusNoContent)
+		}
+		if code := status(t, http.MethodGet, "http://localhost:8080/session", user); code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want logged out token rejected", code)
+		}
+	})
+
+	t.Run("admin revokes every session of a user", func(t *testing.T) {
+		user, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Error(err)
+		}
+		if code := status(t, http.MethodDelete, "http://localhost:8080/user/"+userId+"/session", user); code != http.StatusForbidden {
+			t.Errorf("Got %d, want %d", code, http.StatusForbidden)
+		}
+		if code := status(t, http.MethodDelete, "http://localhost:8080/user/"+userId+"/session", admin); code != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", code, http.StatusNoContent)
+		}
+		if code := status(t, http.MethodGet, "http://localhost:8080/session", user); code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want revoked token rejected", code)
+		}
+	})
+
+	t.Run("expired access token is refreshed transparently", func(t *testing.T) {
+		_, refresh, err := createUserSession(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//the browser drops the access token when it expires, only the refresh token is left
+		res := send(t, http.MethodGet, "http://localhost:8080/session", refresh)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		token, rotated := cookie(res, "token"), cookie(res, "refresh_token")
+		if token == nil || rotated == nil || rotated.Value == refresh.Value {
+			t.Fatalf("Got %v, want new access and refresh token", res.Cookies())
+		}
+		if code := status(t, http.MethodGet, "http://localhost:8080/session", *token); code != http.StatusOK {
+			t.Errorf("Got %d, want the new access token accepted", code)
+		}
+
+		//a revoked session can't be refreshed and the client forgets its tokens
+		utils.RevokeUserSessions(context.Background(), userId, "")
+		res = send(t, http.MethodGet, "http://localhost:8080/session", refresh)
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusUnauthorized)
+		}
+		if c := cookie(res, "refresh_token"); c == nil || c.MaxAge >= 0 {
+			t.Errorf("Got %v, want the refresh token cleared", res.Cookies())
+		}
+	})
+
+	t.Run("htmx is redirected to login when the session is gone", func(t *testing.T) {
+		_, refresh, err := createUserSession(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		utils.RevokeUserSessions(context.Background(), userId, "")
+
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/session", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(&refresh)
+		req.Header.Set("HX-Request", "true")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized || res.Header.Get("HX-Redirect") != "/login" {
+			t.Errorf("Got %d redirected to %q, want %d redirected to login", res.StatusCode, res.Header.Get("HX-Redirect"), http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("logout clears cookies and revokes the refresh token", func(t *testing.T) {
+		token, refresh, err := createUserSession(userId, schoolId, utils.RoleStudent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := send(t, http.MethodPost, "http://localhost:8080/logout", token, refresh)
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+		for _, name := range []string{"token", "refresh_token"} {
+			if c := cookie(res, name); c == nil || c.MaxAge >= 0 {
+				t.Errorf("Got %v, want %s cleared", res.Cookies(), name)
+			}
+		}
+		if code := status(t, http.MethodGet, "http://localhost:8080/session", refresh); code != http.StatusUnauthorized {
+			t.Errorf("Got %d, want refresh token of the logged out session rejected", code)
+		}
+	})
+}
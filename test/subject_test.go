@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -52,13 +53,19 @@ func TestSubject(t *testing.T) {
 		t.Error(err)
 	}
 
-	schoolIdStr := fmt.Sprint(schoolId)
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
 
 	create_subject_url := "http://localhost:8080/subject"
 
 	t.Run("can't create subject without name", func(t *testing.T) {
-		res, err := http.PostForm(create_subject_url, url.Values{
-			"school_id": {schoolIdStr},
+		res, err := postFormWithCookie(create_subject_url, claims, url.Values{
 			"mandatory": {"false"},
 		})
 		if err != nil {
@@ -74,9 +81,8 @@ func TestSubject(t *testing.T) {
 	})
 
 	t.Run("can create subject without passing if it's mandatory", func(t *testing.T) {
-		res, err := http.PostForm(create_subject_url, url.Values{
-			"school_id": {schoolIdStr},
-			"name":      {"Maths"},
+		res, err := postFormWithCookie(create_subject_url, claims, url.Values{
+			"name": {"Maths"},
 		})
 		if err != nil {
 			t.Error(err)
@@ -90,5 +96,34 @@ func TestSubject(t *testing.T) {
 		}
 	})
 
+	t.Run("subject is created in the school of the admin", func(t *testing.T) {
+		otherSchoolId, err := createSchool(conn)
+		if err != nil {
+			t.Error(err)
+		}
+		res, err := postFormWithCookie(create_subject_url, claims, url.Values{
+			"name":      {"Physics"},
+			"school_id": {fmt.Sprint(otherSchoolId)},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var subject struct {
+			Id int `json:"id"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&subject); err != nil {
+			t.Error(err)
+		}
+		var gotSchoolId int
+		if err := conn.QueryRow(context.Background(), "select school_id from subject where id = $1", subject.Id).Scan(&gotSchoolId); err != nil {
+			t.Error(err)
+		}
+		if gotSchoolId != schoolId {
+			t.Errorf("Got subject in school %d, want %d", gotSchoolId, schoolId)
+		}
+	})
+
 	//TODO: add testing if the created subject is mandatory
 }
@@ -71,10 +71,19 @@ func TestSubstituteTimetable(t *testing.T) {
 
 	date := time.Now().Add(24 * time.Hour).Format(time.DateOnly)
 
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_url := "http://localhost:8080/substitute_timetable"
 
 	t.Run("incomplete body returns 400 bad request", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"date": {date},
 		})
 
@@ -91,10 +100,9 @@ func TestSubstituteTimetable(t *testing.T) {
 	})
 
 	t.Run("ids must be numbers", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"period_id":  {"1"},
 			"subject_id": {"1"},
-			"school_id":  {"1"},
 			"room_id":    {"random"},
 			"date":       {date},
 		})
@@ -112,11 +120,10 @@ func TestSubstituteTimetable(t *testing.T) {
 	})
 
 	t.Run("invalid date returns 400 bad request", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"period_id":  {"1"},
 			"subject_id": {"1"},
 			"room_id":    {"1"},
-			"school_id":  {"1"},
 			"date":       {"2024"},
 		})
 
@@ -133,11 +140,10 @@ func TestSubstituteTimetable(t *testing.T) {
 	})
 
 	t.Run("can create valid substitute timetable", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"period_id":  {periodId},
 			"subject_id": {subjectId},
 			"room_id":    {roomId},
-			"school_id":  {fmt.Sprint(schoolId)},
 			"date":       {date},
 		})
 
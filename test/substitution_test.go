@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestSubstitutions(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherIds := make([]string, 3)
+	for n := range teacherIds {
+		if teacherIds[n], err = createUser(conn, schoolId); err != nil {
+			t.Error(err)
+		}
+		if _, err := conn.Exec(ctx, "update users set role = 'teacher' where id = $1", teacherIds[n]); err != nil {
+			t.Error(err)
+		}
+	}
+	absentId, qualifiedId, otherId := teacherIds[0], teacherIds[1], teacherIds[2]
+
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, absentId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	groupId, err := createGroup(conn)
+	if err != nil {
+		t.Error(err)
+	}
+
+	//monday lesson of the absent teacher
+	lessonId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_group (timetable_id, group_id) values ($1, $2)", lessonId, groupId); err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_teacher (timetable_id, teacher_id) values ($1, $2)", lessonId, absentId); err != nil {
+		t.Error(err)
+	}
+	//tuesday lesson of the same subject makes the teacher qualified
+	qualifyingId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "update regular_timetable set weekday = 'Út' where id = $1", qualifyingId); err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "insert into timetable_teacher (timetable_id, teacher_id) values ($1, $2)", qualifyingId, qualifiedId); err != nil {
+		t.Error(err)
+	}
+
+	var absenceId int
+	err = conn.QueryRow(ctx,
+		"insert into absence (user_id, span) values ($1, '[2024-09-02 00:00:00, 2024-09-02 23:59:59]') returning id",
+		absentId,
+	).Scan(&absenceId)
+	if err != nil {
+		t.Error(err)
+	}
+
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	substitutions_url := fmt.Sprintf("http://localhost:8080/absence/%d/substitutions", absenceId)
+
+	type suggestions struct {
+		Suggestions []struct {
+			Date   string `json:"date"`
+			Lesson struct {
+				TimetableId int `json:"timetableId"`
+			} `json:"lesson"`
+			Candidates []struct {
+				Id        string `json:"id"`
+				Qualified bool   `json:"qualified"`
+			} `json:"candidates"`
+		} `json:"suggestions"`
+	}
+
+	t.Run("suggests free teachers, qualified first", func(t *testing.T) {
+		res, err := getWithCookie(substitutions_url, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		var got suggestions
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Suggestions) != 1 {
+			t.Fatalf("Got %d suggestions, want 1", len(got.Suggestions))
+		}
+		s := got.Suggestions[0]
+		if s.Date != "2024-09-02" || fmt.Sprint(s.Lesson.TimetableId) != lessonId {
+			t.Errorf("Got lesson %d on %s, want %s on 2024-09-02", s.Lesson.TimetableId, s.Date, lessonId)
+		}
+		if len(s.Candidates) != 2 || s.Candidates[0].Id != qualifiedId || !s.Candidates[0].Qualified || s.Candidates[1].Id != otherId {
+			t.Errorf("Got candidates %+v, want %s (qualified) and %s", s.Candidates, qualifiedId, otherId)
+		}
+	})
+
+	t.Run("lesson outside of the absence can't be substituted", func(t *testing.T) {
+		res, err := postFormWithCookie(substitutions_url, claims, url.Values{
+			"timetable_id": {lessonId},
+			"date":         {"2024-09-09"},
+			"teacher_id":   {otherId},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("accepted suggestion creates substitute lesson", func(t *testing.T) {
+		res, err := postFormWithCookie(substitutions_url, claims, url.Values{
+			"timetable_id": {lessonId},
+			"date":         {"2024-09-02"},
+			"teacher_id":   {qualifiedId},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		if got, want := res.Header.Get("Location"), "/substitute_timetable?from=2024-09-02&to=2024-09-02"; got != want {
+			t.Errorf("Got location %q, want %q", got, want)
+		}
+
+		res, err = getWithCookie(substitutions_url, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var got suggestions
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Suggestions) != 0 {
+			t.Errorf("Got %d suggestions, want none after the lesson was substituted", len(got.Suggestions))
+		}
+	})
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestTimetableDraft(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	teacherId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	classId, err := createClass(conn, teacherId)
+	if err != nil {
+		t.Error(err)
+	}
+	var groupId string
+	if err := conn.QueryRow(ctx, `insert into "group" (name, class_id, school_id) values ($1, $2, (select school_id from class where id = $2)) returning id::text`, "whole class", classId).Scan(&groupId); err != nil {
+		t.Error(err)
+	}
+	//only one period, so the week has 5 slots
+	if _, err := createPeriod(conn, schoolId); err != nil {
+		t.Error(err)
+	}
+	mathId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	physicsId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.Exec(ctx, "update subject set school_id = $1 where id in ($2, $3)", schoolId, mathId, physicsId); err != nil {
+		t.Error(err)
+	}
+	//subject without a school, so it isn't in the school of the admin
+	foreignSubjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, teacherId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	create_url := "http://localhost:8080/timetable/draft"
+
+	t.Run("requirements must be complete", func(t *testing.T) {
+		res, err := postFormWithCookie(create_url, claims, url.Values{
+			"group_id":   {groupId, groupId},
+			"subject_id": {mathId},
+			"teacher_id": {teacherId},
+			"hours":      {"3"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusBadRequest
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("subjects must be in the school", func(t *testing.T) {
+		for name, data := range map[string]url.Values{
+			"required subject": {
+				"group_id":   {groupId},
+				"subject_id": {foreignSubjectId},
+				"teacher_id": {teacherId},
+				"hours":      {"1"},
+			},
+			"room subject": {
+				"group_id":        {groupId},
+				"subject_id":      {mathId},
+				"teacher_id":      {teacherId},
+				"hours":           {"1"},
+				"room_id":         {roomId},
+				"room_subject_id": {foreignSubjectId},
+			},
+		} {
+			res, err := postFormWithCookie(create_url, claims, data)
+			if err != nil {
+				t.Error(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: got %d, want %d", name, res.StatusCode, http.StatusBadRequest)
+			}
+		}
+	})
+
+	var draft struct {
+		Id      int `json:"id"`
+		Lessons []struct {
+			Weekday int `json:"weekday"`
+		} `json:"lessons"`
+		Unplaced []struct {
+			SubjectId int `json:"subjectId"`
+			Hours     int `json:"hours"`
+		} `json:"unplaced"`
+	}
+
+	t.Run("generates draft and reports lessons which don't fit", func(t *testing.T) {
+		res, err := postFormWithCookie(create_url, claims, url.Values{
+			"group_id":   {groupId, groupId},
+			"subject_id": {mathId, physicsId},
+			"teacher_id": {teacherId, teacherId},
+			"hours":      {"3", "3"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusCreated {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusCreated)
+		}
+		if err := json.NewDecoder(res.Body).Decode(&draft); err != nil {
+			t.Error(err)
+		}
+		if len(draft.Lessons) != 5 {
+			t.Errorf("Got %d lessons, want 5", len(draft.Lessons))
+		}
+		if len(draft.Unplaced) != 1 || draft.Unplaced[0].Hours != 1 {
+			t.Errorf("Got %+v, want one unplaced hour", draft.Unplaced)
+		}
+
+		days := map[int]bool{}
+		for _, l := range draft.Lessons {
+			days[l.Weekday] = true
+		}
+		if len(days) != 5 {
+			t.Errorf("Lessons are on %d days, want all 5", len(days))
+		}
+	})
+
+	t.Run("committed draft becomes regular timetable", func(t *testing.T) {
+		res, err := postFormWithCookie(fmt.Sprintf("%s/%d/commit", create_url, draft.Id), claims, url.Values{})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+
+		var count int
+		if err := conn.QueryRow(ctx, "select count(*) from timetable where school_id = $1 and type = 'regular'", schoolId).Scan(&count); err != nil {
+			t.Error(err)
+		}
+		if count != 5 {
+			t.Errorf("Got %d regular lessons, want 5", count)
+		}
+
+		res, err = getWithCookie(fmt.Sprintf("%s/%d", create_url, draft.Id), claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("draft of an academic year ignores lessons of other years", func(t *testing.T) {
+		var lastYearId, yearId int
+		if err := conn.QueryRow(ctx,
+			"insert into academic_year (school_id, name, span) values ($1, 'last', daterange(current_date - 400, current_date - 30)) returning id", schoolId,
+		).Scan(&lastYearId); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.QueryRow(ctx,
+			"insert into academic_year (school_id, name, span) values ($1, 'this', daterange(current_date - 29, current_date + 300)) returning id", schoolId,
+		).Scan(&yearId); err != nil {
+			t.Fatal(err)
+		}
+		//the committed lessons were of last year, so the week is free again
+		if _, err := conn.Exec(ctx,
+			"update regular_timetable set academic_year_id = $1 where id in (select id from timetable where school_id = $2)", lastYearId, schoolId,
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := postFormWithCookie(create_url, claims, url.Values{
+			"group_id":   {groupId},
+			"subject_id": {mathId},
+			"teacher_id": {teacherId},
+			"hours":      {"5"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		var yearDraft struct {
+			Id             int  `json:"id"`
+			AcademicYearId *int `json:"academicYearId"`
+			Lessons        []struct {
+				Weekday int `json:"weekday"`
+			} `json:"lessons"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&yearDraft); err != nil {
+			t.Error(err)
+		}
+		if yearDraft.AcademicYearId == nil || *yearDraft.AcademicYearId != yearId {
+			t.Errorf("Got academic year %v, want the current one %d", yearDraft.AcademicYearId, yearId)
+		}
+		if len(yearDraft.Lessons) != 5 {
+			t.Errorf("Got %d lessons, want 5", len(yearDraft.Lessons))
+		}
+
+		res, err = postFormWithCookie(fmt.Sprintf("%s/%d/commit", create_url, yearDraft.Id), claims, url.Values{})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusNoContent)
+		}
+
+		var count int
+		if err := conn.QueryRow(ctx, "select count(*) from regular_timetable where academic_year_id = $1", yearId).Scan(&count); err != nil {
+			t.Error(err)
+		}
+		if count != 5 {
+			t.Errorf("Got %d lessons in the academic year, want 5", count)
+		}
+	})
+}
@@ -55,7 +55,11 @@ func TestTimetableGroup(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	groupId, err := createGroup(conn)
+	classId, err := createClass(conn, userId)
+	if err != nil {
+		t.Error(err)
+	}
+	groupId, err := createClassGroup(conn, classId)
 	if err != nil {
 		t.Error(err)
 	}
@@ -77,10 +81,19 @@ func TestTimetableGroup(t *testing.T) {
 		t.Error(err)
 	}
 
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_url := "http://localhost:8080/timetable_group"
 
 	t.Run("can't create timetable_group without  timetable id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"group_id": {groupId},
 		})
 		if err != nil {
@@ -96,7 +109,7 @@ func TestTimetableGroup(t *testing.T) {
 	})
 
 	t.Run("can't create timetable_group without group id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"timetable_id": {timetableId},
 		})
 		if err != nil {
@@ -112,7 +125,7 @@ func TestTimetableGroup(t *testing.T) {
 	})
 
 	t.Run("can create timetable_group", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"timetable_id": {timetableId},
 			"group_id":     {groupId},
 		})
@@ -72,10 +72,19 @@ func TestTimetableTeacher(t *testing.T) {
 		t.Error(err)
 	}
 
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
 	create_url := "http://localhost:8080/timetable_teacher"
 
 	t.Run("can't create regualar_timetable_teacher without  timetable id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"teacher_id": {teacherId},
 		})
 		if err != nil {
@@ -91,8 +100,29 @@ func TestTimetableTeacher(t *testing.T) {
 	})
 
 	t.Run("can't create timetable_teacher without teacher id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
+			"timetable_id": {timetableId},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusBadRequest
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("can't add teacher of other school", func(t *testing.T) {
+		foreignTeacherId, err := createUser(conn, -1)
+		if err != nil {
+			t.Error(err)
+		}
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"timetable_id": {timetableId},
+			"teacher_id":   {foreignTeacherId},
 		})
 		if err != nil {
 			t.Error(err)
@@ -107,7 +137,7 @@ func TestTimetableTeacher(t *testing.T) {
 	})
 
 	t.Run("can create regualar_timetable_teacher", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"timetable_id": {timetableId},
 			"teacher_id":   {teacherId},
 		})
@@ -132,6 +132,27 @@ func TestUser(t *testing.T) {
 		}
 	})
 
+	t.Run("user can't register as teacher", func(t *testing.T) {
+		res, err := http.PostForm(register_url, url.Values{
+			"user_name": {"test"},
+			"surname":   {"idk"},
+			"email":     {"teacher@email.com"},
+			"school_id": {schoolIdStr},
+			"password":  {"test123456"},
+			"role":      {"teacher"},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusForbidden
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
 	t.Run("valid user is created", func(t *testing.T) {
 		res, err := http.PostForm(register_url, url.Values{
 			"user_name": {"test"},
@@ -154,7 +175,7 @@ func TestUser(t *testing.T) {
 		gotCookies := res.Cookies()
 
 		gotCookiesLen := len(res.Cookies())
-		wantCookiesLen := 1
+		wantCookiesLen := 2
 		if gotCookiesLen != wantCookiesLen {
 			t.Errorf("Got %d cookies, wanted %d", gotCookiesLen, wantCookiesLen)
 		}
@@ -162,6 +183,9 @@ func TestUser(t *testing.T) {
 		if gotCookies[0].Name != "token" || !gotCookies[0].HttpOnly || gotCookies[0].SameSite != http.SameSiteStrictMode {
 			t.Errorf("Got %s invalid cookie", gotCookies[0])
 		}
+		if gotCookies[1].Name != "refresh_token" || !gotCookies[1].HttpOnly || gotCookies[1].SameSite != http.SameSiteStrictMode {
+			t.Errorf("Got %s invalid cookie", gotCookies[1])
+		}
 	})
 
 	t.Run("user can register and log in", func(t *testing.T) {
@@ -204,7 +228,7 @@ func TestUser(t *testing.T) {
 		gotCookies := res.Cookies()
 
 		gotCookiesLen := len(res.Cookies())
-		wantCookiesLen := 1
+		wantCookiesLen := 2
 		if gotCookiesLen != wantCookiesLen {
 			t.Errorf("Got %d cookies, wanted %d", gotCookiesLen, wantCookiesLen)
 		}
@@ -212,5 +236,8 @@ func TestUser(t *testing.T) {
 		if gotCookies[0].Name != "token" || !gotCookies[0].HttpOnly || gotCookies[0].SameSite != http.SameSiteStrictMode {
 			t.Errorf("Got %s invalid cookie", gotCookies[0])
 		}
+		if gotCookies[1].Name != "refresh_token" || !gotCookies[1].HttpOnly || gotCookies[1].SameSite != http.SameSiteStrictMode {
+			t.Errorf("Got %s invalid cookie", gotCookies[1])
+		}
 	})
 }
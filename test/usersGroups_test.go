@@ -47,11 +47,27 @@ func TestUsersGroup(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	userId, err := createUser(conn, -1)
+	schoolId, err := createSchool(conn)
 	if err != nil {
 		t.Error(err)
 	}
-	groupId, err := createGroup(conn)
+	userId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	classId, err := createClass(conn, userId)
+	if err != nil {
+		t.Error(err)
+	}
+	groupId, err := createClassGroup(conn, classId)
+	if err != nil {
+		t.Error(err)
+	}
+	adminId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	claims, err := createUserJWT(adminId, schoolId, utils.RoleAdmin)
 	if err != nil {
 		t.Error(err)
 	}
@@ -59,7 +75,7 @@ func TestUsersGroup(t *testing.T) {
 	create_url := "http://localhost:8080/users_group"
 
 	t.Run("can't create users_group without user id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"group_id": {groupId},
 		})
 		if err != nil {
@@ -75,7 +91,7 @@ func TestUsersGroup(t *testing.T) {
 	})
 
 	t.Run("can't create users_group without group id", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"user_id": {userId},
 		})
 		if err != nil {
@@ -91,7 +107,7 @@ func TestUsersGroup(t *testing.T) {
 	})
 
 	t.Run("can create users_group", func(t *testing.T) {
-		res, err := http.PostForm(create_url, url.Values{
+		res, err := postFormWithCookie(create_url, claims, url.Values{
 			"user_id":  {userId},
 			"group_id": {groupId},
 		})
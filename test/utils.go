@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/dr0th3r/learnscape/internal/utils"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
@@ -184,11 +185,40 @@ func createRegularTimetable(db *pgx.Conn, periodId, subjectId, roomId string, sc
 	return id, nil
 }
 
+func createSubstituteTimetable(db *pgx.Conn, periodId, subjectId, roomId, date string, schoolId int) (string, error) {
+	id := fmt.Sprint(rand.Intn(10000))
+
+	_, err := db.Exec(context.Background(),
+		`
+		WITH inserted_timetable AS (
+		    INSERT INTO timetable (id, school_id, type) 
+		    VALUES ($1, $2, $3)
+		    RETURNING id
+		),
+		inserted_academic_timetable AS (
+		    INSERT INTO academic_timetable (id, period_id, subject_id, room_id)
+		    SELECT id, $4, $5, $6
+		    FROM inserted_timetable
+		)
+		INSERT INTO substitute_timetable (id, date)
+		SELECT id, $7
+		FROM inserted_timetable
+		`,
+		id, schoolId, "substitute", periodId, subjectId, roomId, date,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
 func createClass(db *pgx.Conn, teacherId string) (string, error) {
 	id := fmt.Sprint(rand.Intn(10000))
 
 	_, err := db.Exec(context.Background(),
-		"insert into class (id, name, year, class_teacher_id) values ($1, $2, $3, $4)",
+		"insert into class (id, name, year, class_teacher_id, school_id) values ($1, $2, $3, $4, (select school_id from users where id = $4))",
 		id, "test", 1, teacherId,
 	)
 	if err != nil {
@@ -212,6 +242,21 @@ func createGroup(db *pgx.Conn) (string, error) {
 	return id, nil
 }
 
+// createClassGroup creates group of the class, so it belongs to the school of the class
+func createClassGroup(db *pgx.Conn, classId string) (string, error) {
+	id := fmt.Sprint(rand.Intn(10000))
+
+	_, err := db.Exec(context.Background(),
+		`insert into "group" (id, name, class_id, school_id) values ($1, $2, $3, (select school_id from class where id = $3))`,
+		id, "test_group", classId,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
 func createReport(db *pgx.Conn, reportedBy, timetableId string) (string, error) {
 	id := fmt.Sprint(rand.Intn(10000))
 
@@ -226,32 +271,65 @@ func createReport(db *pgx.Conn, reportedBy, timetableId string) (string, error)
 	return id, nil
 }
 
-func createUserJWT(id string, schoolId int) (http.Cookie, error) {
-	exp := time.Now().Add(time.Hour)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		utils.UserClaims{
-			Id: id,
-			//the "idk" values dont matter
-			Name:     "idk",
-			Surname:  "idk",
-			Email:    "idk@idk.com",
-			SchoolId: schoolId,
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(exp),
-			},
-		},
-	)
+func createUserJWT(id string, schoolId int, role utils.Role) (http.Cookie, error) {
+	token, _, err := createUserSession(id, schoolId, role)
+	return token, err
+}
+
+// createUserSession starts a session of the user, it returns cookies with its access and refresh token
+func createUserSession(id string, schoolId int, role utils.Role) (http.Cookie, http.Cookie, error) {
+	//the server runs in the test process, so the session is in its store
+	session, refreshToken, err := utils.CreateSession(context.Background(), utils.UserClaims{
+		Id: id,
+		//the "idk" values dont matter
+		Name:     "idk",
+		Surname:  "idk",
+		Email:    "idk@idk.com",
+		SchoolId: schoolId,
+		Role:     role,
+	}, "test")
+	if err != nil {
+		return http.Cookie{}, http.Cookie{}, err
+	}
+
+	token, err := utils.AccessTokenCookie(session)
+	if err != nil {
+		return http.Cookie{}, http.Cookie{}, err
+	}
+	return *token, *utils.RefreshTokenCookie(refreshToken, session.ExpiresAt), nil
+}
+
+func postFormWithCookie(endpoint string, cookie http.Cookie, data url.Values) (*http.Response, error) {
+	return sendFormWithCookie(http.MethodPost, endpoint, cookie, data)
+}
+
+func sendFormWithCookie(method, endpoint string, cookie http.Cookie, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(method, endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.AddCookie(&cookie)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return http.DefaultClient.Do(req)
+}
+
+func getWithCookie(endpoint string, cookie http.Cookie) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.AddCookie(&cookie)
+
+	return http.DefaultClient.Do(req)
+}
 
-	tokenStr, err := token.SignedString([]byte("my secret"))
+func deleteWithCookie(endpoint string, cookie http.Cookie) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
 	if err != nil {
-		return http.Cookie{}, err
+		return nil, err
 	}
+	req.AddCookie(&cookie)
 
-	return http.Cookie{
-		Name:     "token",
-		Value:    tokenStr,
-		Expires:  time.Now().Add(time.Hour),
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-	}, nil
+	return http.DefaultClient.Do(req)
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+
+	i "github.com/dr0th3r/learnscape/internal"
+	"github.com/dr0th3r/learnscape/internal/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestWeekTimetable(t *testing.T) {
+	config, err := utils.ParseConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	connectionUrl := config.DB.GetConnectionUrlWithoutName()
+	db_name := "test_" + fmt.Sprint(rand.Int())
+	config.DB.Name = db_name
+
+	if err := createNewDB(connectionUrl, db_name); err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(func() {
+		if err := dropDB(connectionUrl, db_name); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	go i.Run(ctx, config)
+
+	if err := waitForReady(ctx); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := pgx.Connect(context.Background(), config.DB.GetConnectionUrl())
+	if err != nil {
+		t.Error(err)
+	}
+	schoolId, err := createSchool(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	userId, err := createUser(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	groupId, err := createGroup(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	periodId, err := createPeriod(conn, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	subjectId, err := createSubject(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	roomId, err := createRoom(conn, userId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	//regular timetable is always on monday
+	regularId, err := createRegularTimetable(conn, periodId, subjectId, roomId, schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	substituteId, err := createSubstituteTimetable(conn, periodId, subjectId, roomId, "2024-09-02", schoolId)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, timetableId := range []string{regularId, substituteId} {
+		if _, err := conn.Exec(ctx, "insert into timetable_group (timetable_id, group_id) values ($1, $2)", timetableId, groupId); err != nil {
+			t.Error(err)
+		}
+	}
+
+	claims, err := createUserJWT(userId, schoolId, utils.RoleStudent)
+	if err != nil {
+		t.Error(err)
+	}
+
+	url := "http://localhost:8080/timetable"
+
+	getMondayLessons := func(t *testing.T, week string) []struct {
+		TimetableId int  `json:"timetableId"`
+		Substitute  bool `json:"substitute"`
+	} {
+		res, err := getWithCookie(url+"?group="+groupId+"&week="+week, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+
+		var timetable struct {
+			Days []struct {
+				Slots [][]struct {
+					TimetableId int  `json:"timetableId"`
+					Substitute  bool `json:"substitute"`
+				} `json:"slots"`
+			} `json:"days"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&timetable); err != nil {
+			t.Error(err)
+		}
+		if len(timetable.Days) != 5 || len(timetable.Days[0].Slots) != 1 {
+			t.Errorf("Got %d days, want 5 days with one period", len(timetable.Days))
+			return nil
+		}
+		return timetable.Days[0].Slots[0]
+	}
+
+	t.Run("can't get timetable without group, teacher or room", func(t *testing.T) {
+		res, err := getWithCookie(url, claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		got := res.StatusCode
+		want := http.StatusBadRequest
+		if got != want {
+			t.Errorf("Got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("regular lesson is in the week without substitution", func(t *testing.T) {
+		lessons := getMondayLessons(t, "2024-09-11")
+		if len(lessons) != 1 || lessons[0].Substitute || fmt.Sprint(lessons[0].TimetableId) != regularId {
+			t.Errorf("Got %+v, want regular lesson %s", lessons, regularId)
+		}
+	})
+
+	t.Run("substitution replaces regular lesson", func(t *testing.T) {
+		lessons := getMondayLessons(t, "2024-09-04")
+		if len(lessons) != 1 || !lessons[0].Substitute || fmt.Sprint(lessons[0].TimetableId) != substituteId {
+			t.Errorf("Got %+v, want substitute lesson %s", lessons, substituteId)
+		}
+	})
+
+	t.Run("homepage shows user's timetable with highlighted substitution", func(t *testing.T) {
+		if _, err := conn.Exec(ctx, "insert into users_group (user_id, group_id) values ($1, $2)", userId, groupId); err != nil {
+			t.Error(err)
+		}
+
+		res, err := getWithCookie("http://localhost:8080/?week=2024-09-02", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Got %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		for _, want := range []string{"idk idk", "/?week=2024-09-09", "bg-amber-700", "Math"} {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("Homepage doesn't contain %q", want)
+			}
+		}
+	})
+
+	t.Run("homepage shows date of each day", func(t *testing.T) {
+		res, err := getWithCookie("http://localhost:8080/?week=2024-09-02", claims)
+		if err != nil {
+			t.Error(err)
+		}
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		//monday is also in the week heading, so it is checked by the other days
+		for _, want := range []string{"3. 9.", "6. 9."} {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("Homepage doesn't contain %q", want)
+			}
+		}
+	})
+}